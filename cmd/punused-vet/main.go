@@ -0,0 +1,12 @@
+// The punused-vet command runs the punused analyzer, standalone on package patterns,
+// e.g. punused-vet ./..., or with go vet -vettool=$(which punused-vet) ./...
+package main
+
+import (
+	"github.com/bep/punused/punused/analyzer"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"go/ast"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// accessorKey identifies a receiver type in a package directory, relative to the workspace.
+type accessorKey struct {
+	dir, recv string
+}
+
+// collapseAccessors replaces the unused (EU1002) accessors of a type, i.e. its GetX and SetX methods, or X and SetX
+// for an unexported field x, compared case insensitively, with a single finding, if the whole accessor family
+// of at least two methods is unused. The finding is the one of the first accessor, listing the others in Accessors.
+func (r *runner) collapseAccessors() error {
+	families := make(map[accessorKey]map[string]bool)
+	groups := make(map[accessorKey][]int)
+	for i, f := range r.findings {
+		recv := receiverType(f.Name)
+		if canonicalCode(f.Code) != codeUnused || f.Kind != "method" || recv == "" {
+			continue
+		}
+		filename := strings.TrimPrefix(strings.TrimPrefix(f.Filename, r.prefix), "/")
+		dir := path.Dir(filename)
+		if _, found := families[accessorKey{dir, ""}]; !found {
+			if err := r.addAccessorFamilies(families, dir); err != nil {
+				return err
+			}
+		}
+		key := accessorKey{dir, recv}
+		if families[key][symbolBase(f.Name)] {
+			groups[key] = append(groups[key], i)
+		}
+	}
+
+	removed := make(map[int]bool)
+	for key, group := range groups {
+		if len(group) < 2 || len(group) != len(families[key]) {
+			continue
+		}
+		first := &r.findings[group[0]]
+		for _, i := range group[1:] {
+			first.Accessors = append(first.Accessors, r.findings[i].Name)
+			first.Lines += r.findings[i].Lines
+			removed[i] = true
+		}
+		r.cfg.Logger.Debug("collapsed unused accessors", "type", key.recv, "dir", key.dir, "accessors", len(group))
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	findings := r.findings[:0]
+	for i, f := range r.findings {
+		if !removed[i] {
+			findings = append(findings, f)
+		}
+	}
+	r.findings = findings
+	return nil
+}
+
+// addAccessorFamilies adds the accessor methods of the struct types declared in the package in dir to families,
+// keyed by the receiver type. The key with no receiver type marks dir as added.
+func (r *runner) addAccessorFamilies(families map[accessorKey]map[string]bool, dir string) error {
+	families[accessorKey{dir, ""}] = nil
+	entries, err := os.ReadDir(filepath.Join(r.cfg.WorkspaceDir, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+	fields := make(map[string][]string)
+	methods := make(map[string][]string)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+		src, err := r.files.source(path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		for _, decl := range src.file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				if id := recvTypeIdent(fd); id != nil {
+					methods[id.Name] = append(methods[id.Name], fd.Name.Name)
+				}
+				continue
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				ts, ok := n.(*ast.TypeSpec)
+				if !ok {
+					return true
+				}
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					for _, field := range st.Fields.List {
+						for _, name := range field.Names {
+							fields[ts.Name.Name] = append(fields[ts.Name.Name], name.Name)
+						}
+					}
+				}
+				return false
+			})
+		}
+	}
+	for recv, names := range fields {
+		// Case insensitive, e.g. GetID for a field Id.
+		accessors := make(map[string]bool)
+		for _, field := range names {
+			lower := strings.ToLower(field)
+			accessors["get"+lower] = true
+			accessors["set"+lower] = true
+			if !ast.IsExported(field) {
+				accessors[lower] = true
+			}
+		}
+		family := make(map[string]bool)
+		for _, method := range methods[recv] {
+			if accessors[strings.ToLower(method)] {
+				family[method] = true
+			}
+		}
+		if len(family) > 0 {
+			families[accessorKey{dir, recv}] = family
+		}
+	}
+	return nil
+}
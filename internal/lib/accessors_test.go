@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCollapseAccessors(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.Mkdir(filepath.Join(dir, "p"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p", "p.go"), []byte(`package p
+
+type Msg struct {
+	Name string
+	id   int
+}
+
+func (m *Msg) GetName() string  { return m.Name }
+func (m *Msg) SetName(s string) { m.Name = s }
+func (m *Msg) ID() int          { return m.id }
+func (m *Msg) Reset()           {}
+
+type One struct{ A int }
+
+func (o One) GetA() int { return o.A }
+`), 0o644), qt.IsNil)
+
+	unused := func(name string, line int) Finding {
+		return Finding{Filename: "p/p.go", Line: line, Kind: "method", Name: name, Code: codeUnused, Lines: 1}
+	}
+	r := &runner{
+		cfg:   RunConfig{WorkspaceDir: dir, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+		files: &fileCache{workspaceDir: dir},
+		findings: []Finding{
+			unused("(*Msg).GetName", 8),
+			unused("(*Msg).SetName", 9),
+			unused("(*Msg).ID", 10),
+			unused("(*Msg).Reset", 11),
+			unused("(One).GetA", 15),
+		},
+	}
+	c.Assert(r.collapseAccessors(), qt.IsNil)
+	c.Assert(r.findings, qt.HasLen, 3)
+	c.Assert(r.findings[0].Name, qt.Equals, "(*Msg).GetName")
+	c.Assert(r.findings[0].Accessors, qt.DeepEquals, []string{"(*Msg).SetName", "(*Msg).ID"})
+	c.Assert(r.findings[0].Lines, qt.Equals, 3)
+	c.Assert(r.findings[0].Message(), qt.Equals, "and 2 other accessors of Msg are unused")
+	c.Assert(r.findings[1].Name, qt.Equals, "(*Msg).Reset")
+	c.Assert(r.findings[2].Accessors, qt.IsNil)
+
+	// Not collapsed if any accessor is used.
+	r.findings = []Finding{unused("(*Msg).GetName", 8), unused("(*Msg).ID", 10)}
+	c.Assert(r.collapseAccessors(), qt.IsNil)
+	c.Assert(r.findings, qt.HasLen, 2)
+}
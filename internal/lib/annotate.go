@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"text/tabwriter"
+)
+
+// annotate records the symbol s declared in filename, with its check code and number of references, see AnnotateAll.
+func (r *runner) annotate(filename string, s *Symbol, code string, refs int) {
+	r.annotations = append(r.annotations, newInventorySymbol(newFinding(path.Join(r.prefix, filename), s, code), refs))
+}
+
+// annotatedSymbols returns the symbols recorded by annotate, once per declaration and ordered by position,
+// with the check codes of the findings, e.g. those only found to be used by unused code with Transitive,
+// as written in the output.
+func (r *runner) annotatedSymbols() []InventorySymbol {
+	type key struct {
+		filename, name string
+		line           int
+	}
+	codes := make(map[key]string)
+	for _, f := range r.findings {
+		codes[key{f.Filename, f.Name, f.Line}] = f.Code
+	}
+	seen := make(map[key]bool)
+	symbols := []InventorySymbol{}
+	for _, s := range r.annotations {
+		k := key{s.Filename, s.Name, s.Line}
+		if seen[k] {
+			// Analyzed in more than one build configuration.
+			continue
+		}
+		seen[k] = true
+		if code, found := codes[k]; found && s.Code == "" {
+			s = newInventorySymbol(Finding{Filename: s.Filename, Line: s.Line, Column: s.Column, Kind: s.Kind, Name: s.Name, Code: code, Signature: s.Signature}, s.Refs)
+		}
+		if s.Code != "" {
+			s.Code = prefixedCode(r.cfg.CodePrefix, s.Code)
+		}
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return inventorySymbolLess(symbols[i], symbols[j]) })
+	return symbols
+}
+
+func printSymbols(w io.Writer, symbols []InventorySymbol) {
+	fmt.Fprint(w, "\nSymbols:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tKIND\tNAME\tSTATUS\tREFS\tCODE\t")
+	for _, s := range symbols {
+		fmt.Fprintf(tw, "%s:%d:%d\t%s\t%s\t%s\t%d\t%s\t\n", s.Filename, s.Line, s.Column, s.Kind, s.Name, s.Status, s.Refs, s.Code)
+	}
+	tw.Flush()
+}
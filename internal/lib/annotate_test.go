@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestAnnotatedSymbols(t *testing.T) {
+	c := qt.New(t)
+
+	symbol := func(name string, line int) *Symbol {
+		return &Symbol{Name: name, Kind: lsp.SKFunction, Location: lsp.Location{Range: lsp.Range{Start: lsp.Position{Line: line - 1, Character: 5}}}}
+	}
+	r := &runner{cfg: RunConfig{CodePrefix: "ACME"}}
+	r.annotate("p/p.go", symbol("Used", 9), "", 12)
+	r.annotate("p/p.go", symbol("Dead", 3), codeUnused, 0)
+	r.annotate("p/p.go", symbol("Caller", 6), "", 1)
+	r.annotate("p/p.go", symbol("Used", 9), "", 12)
+	// Found with Transitive once all the symbols are analyzed.
+	r.findings = []Finding{{Filename: "p/p.go", Line: 6, Name: "Caller", Code: codeTransitive}}
+
+	symbols := r.annotatedSymbols()
+	c.Assert(symbols, qt.HasLen, 3)
+	c.Assert(symbols[0].Name, qt.Equals, "Dead")
+	c.Assert(symbols[0].Status, qt.Equals, "unused")
+	c.Assert(symbols[0].Code, qt.Equals, "ACME1002")
+	c.Assert(symbols[1].Name, qt.Equals, "Caller")
+	c.Assert(symbols[1].Code, qt.Equals, "ACME1005")
+	c.Assert(symbols[2].Name, qt.Equals, "Used")
+	c.Assert(symbols[2].Status, qt.Equals, "widely_used")
+	c.Assert(symbols[2].Refs, qt.Equals, 12)
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"path/filepath"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+const (
+	// BackendGopls analyzes the workspace with gopls, the default, see RunConfig.Backend.
+	BackendGopls = "gopls"
+
+	// BackendPackages analyzes the workspace with golang.org/x/tools/go/packages, without gopls, see packagesClient.
+	BackendPackages = "packages"
+)
+
+// backend answers the queries of the analysis about the workspace: its symbols, the references to them and
+// their implementations, GoplsClient by default, or packagesClient, see RunConfig.Backend.
+// Both return the locations as in the Language Server Protocol, so the analysis is the same.
+type backend interface {
+	DocumentSymbol(ctx context.Context, filename string) ([]*Symbol, error)
+	DocumentReferences(ctx context.Context, loc lsp.Location) ([]*lsp.Location, error)
+	Implementation(ctx context.Context, loc lsp.Location) ([]*lsp.Location, error)
+	Rename(ctx context.Context, loc lsp.Location, newName string) (*WorkspaceEdit, error)
+
+	// Errors returns the errors in the packages of the workspace, see collectLoadErrors.
+	Errors() (map[lsp.DocumentURI][]lsp.Diagnostic, []string)
+
+	Close() error
+
+	// open, change and closeDocument set the content of documents to analyze instead of the files on disk.
+	open(ctx context.Context, params lsp.DidOpenTextDocumentParams) error
+	change(ctx context.Context, uri lsp.DocumentURI, version int, text string) error
+	closeDocument(ctx context.Context, uri lsp.DocumentURI) error
+
+	// documentURI returns the URI of filename, relative to the workspace or absolute.
+	documentURI(filename string) string
+}
+
+// workspaceURI returns the URI of filename, relative to the workspace dir or absolute, percent-encoded as by gopls,
+// see uriFilename.
+func workspaceURI(dir, filename string) string {
+	filename = filepath.ToSlash(filename)
+	if !path.IsAbs(filename) {
+		filename = path.Join(dir, filename)
+	}
+	return "file://" + (&url.URL{Path: filename}).EscapedPath()
+}
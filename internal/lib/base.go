@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The origins of a finding, see Finding.Origin.
+const (
+	originIntroduced  = "introduced"
+	originPreExisting = "pre-existing"
+)
+
+// markOrigins sets the Origin of the findings: pre-existing if the same finding, see Finding.Fingerprint,
+// is found at the merge base of Base and HEAD, analyzed in a temporary git worktree, introduced otherwise.
+func (r *runner) markOrigins(ctx context.Context) error {
+	cfg := r.cfg
+	mergeBase, err := git(cfg.WorkspaceDir, "merge-base", cfg.Base, "HEAD")
+	if err != nil {
+		return err
+	}
+	top, err := git(cfg.WorkspaceDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(top, cfg.WorkspaceDir)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp("", "punused-base")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	tree := filepath.Join(tmp, "tree")
+	if _, err := git(cfg.WorkspaceDir, "worktree", "add", "--detach", tree, mergeBase); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := git(cfg.WorkspaceDir, "worktree", "remove", "--force", tree); err != nil {
+			cfg.Logger.Warn("failed to remove the worktree of the base", "dir", tree, "error", err)
+		}
+	}()
+
+	cfg.Logger.Info("analyzing the base", "base", cfg.Base, "commit", mergeBase)
+	baseCfg := cfg.baseConfig(filepath.Join(tree, rel))
+	base, err := Findings(ctx, baseCfg)
+	if err != nil {
+		return fmt.Errorf("failed to analyze the base %s: %w", cfg.Base, err)
+	}
+	existing := make(map[string]bool, len(base))
+	for _, f := range base {
+		existing[f.Fingerprint()] = true
+	}
+	var introduced int
+	for i, f := range r.findings {
+		if existing[f.Fingerprint()] {
+			r.findings[i].Origin = originPreExisting
+		} else {
+			r.findings[i].Origin = originIntroduced
+			introduced++
+		}
+	}
+	cfg.Logger.Info("compared the findings with the base", "base", cfg.Base, "introduced", introduced, "pre-existing", len(r.findings)-introduced)
+	return nil
+}
+
+// baseConfig returns the configuration to analyze the base checked out in dir with: the same analysis options,
+// without the options writing files, changing them, stopping early or restricting the findings to lines of the current tree.
+func (cfg RunConfig) baseConfig(dir string) RunConfig {
+	base := cfg
+	base.WorkspaceDir = dir
+	base.WorkspaceDirs = nil
+	for _, wd := range cfg.WorkspaceDirs {
+		if filepath.IsAbs(wd) {
+			if rel, err := filepath.Rel(cfg.WorkspaceDir, wd); err == nil {
+				wd = rel
+			}
+		}
+		base.WorkspaceDirs = append(base.WorkspaceDirs, wd)
+	}
+	base.Out = io.Discard
+	base.Base, base.FailIntroducedOnly, base.FailFast = "", false, false
+	base.Overlay = nil
+	base.ChangedLines = nil
+	base.Resume = ""
+	base.ManifestOut, base.VerifyManifest = "", ""
+	base.HistoryFile, base.SummaryOut, base.OwnersDir, base.ModulesDir, base.OutputDir = "", "", "", "", ""
+	return base
+}
+
+// git runs git with args in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
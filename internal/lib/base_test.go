@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestBaseConfig(t *testing.T) {
+	c := qt.New(t)
+
+	cfg := RunConfig{
+		WorkspaceDir:       "/src/repo",
+		WorkspaceDirs:      []string{"/src/repo/a", "b"},
+		Base:               "origin/main",
+		FailIntroducedOnly: true,
+		FailFast:           true,
+		Transitive:         true,
+		ChangedLines:       ChangedLines{"p.go": {{Start: 1, End: 2}}},
+		SummaryOut:         "summary.json",
+	}
+	base := cfg.baseConfig("/tmp/tree")
+	c.Assert(base.WorkspaceDir, qt.Equals, "/tmp/tree")
+	c.Assert(base.WorkspaceDirs, qt.DeepEquals, []string{"a", "b"})
+	c.Assert(base.workspaceDirs(), qt.DeepEquals, []string{"/tmp/tree/a", "/tmp/tree/b"})
+	c.Assert(base.Base, qt.Equals, "")
+	c.Assert(base.FailIntroducedOnly, qt.IsFalse)
+	c.Assert(base.FailFast, qt.IsFalse)
+	c.Assert(base.ChangedLines, qt.IsNil)
+	c.Assert(base.SummaryOut, qt.Equals, "")
+	c.Assert(base.Transitive, qt.IsTrue)
+}
+
+func TestGateIntroducedOnly(t *testing.T) {
+	c := qt.New(t)
+
+	r := &runner{
+		cfg: RunConfig{FailOn: []string{codeUnused}, FailIntroducedOnly: true},
+		findings: []Finding{
+			{Name: "Old", Code: codeUnused, Origin: originPreExisting},
+		},
+	}
+	c.Assert(r.gate(), qt.IsNil)
+	r.findings = append(r.findings, Finding{Name: "New", Code: codeUnused, Origin: originIntroduced})
+	c.Assert(r.gate(), qt.ErrorMatches, "check failed: 1 findings with code EU1002")
+}
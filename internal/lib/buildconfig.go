@@ -0,0 +1,204 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// BuildConfig is a build configuration to analyze the workspace with,
+// for code behind build constraints, e.g. windows only files or integration tests.
+type BuildConfig struct {
+	// Name identifies the configuration in the findings.
+	Name string
+
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// ParseBuildConfig parses a build configuration on the form "goos=windows goarch=arm64 tags=integration,e2e".
+// All the keys are optional, and the string itself is used as the name.
+func ParseBuildConfig(s string) (BuildConfig, error) {
+	bc := BuildConfig{Name: strings.TrimSpace(s)}
+	for _, field := range strings.Fields(s) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return bc, fmt.Errorf("invalid build config %q: %q is not on the form key=value", s, field)
+		}
+		switch strings.ToLower(key) {
+		case "goos":
+			bc.GOOS = value
+		case "goarch":
+			bc.GOARCH = value
+		case "tags":
+			bc.Tags = strings.Split(value, ",")
+		default:
+			return bc, fmt.Errorf("invalid build config %q: unknown key %q, must be one of goos, goarch or tags", s, key)
+		}
+	}
+	if bc.Name == "" {
+		return bc, fmt.Errorf("empty build config")
+	}
+	return bc, nil
+}
+
+// goplsSettings returns the gopls settings for bc, passed as initialization options.
+func (bc *BuildConfig) goplsSettings() map[string]any {
+	if bc == nil {
+		return nil
+	}
+	settings := make(map[string]any)
+	if len(bc.Tags) > 0 {
+		settings["buildFlags"] = []string{"-tags=" + strings.Join(bc.Tags, ",")}
+	}
+	env := make(map[string]string)
+	if bc.GOOS != "" {
+		env["GOOS"] = bc.GOOS
+	}
+	if bc.GOARCH != "" {
+		env["GOARCH"] = bc.GOARCH
+	}
+	if len(env) > 0 {
+		settings["env"] = env
+	}
+	return settings
+}
+
+// withBuildFlag returns the gopls settings with flag added to the build flags.
+func withBuildFlag(settings map[string]any, flag string) map[string]any {
+	if settings == nil {
+		settings = make(map[string]any)
+	}
+	flags, _ := settings["buildFlags"].([]string)
+	settings["buildFlags"] = append(flags, flag)
+	return settings
+}
+
+// errNotInBuild is returned when analyzing a file not part of the current build configuration.
+var errNotInBuild = errors.New("file not in build")
+
+// isNotInBuild reports whether err is the error gopls, or the packages backend, returns for a file excluded
+// by build constraints.
+func isNotInBuild(err error) bool {
+	if errors.Is(err, errNotInBuild) {
+		return true
+	}
+	var respErr *responseError
+	return errors.As(err, &respErr) && strings.Contains(respErr.Message, "no package metadata")
+}
+
+// findingKey identifies the finding of a symbol across build configurations.
+func findingKey(f Finding) string {
+	return fmt.Sprintf("%s:%d:%d:%s:%s", f.Filename, f.Line, f.Column, f.Name, f.Code)
+}
+
+// mergeBuild adds the findings collected with another build configuration of the same workspace to r.
+// Duplicate findings are merged, with BuildConfigs listing all the configurations they appeared in.
+func (r *runner) mergeBuild(o *runner) {
+	seen := make(map[string]int)
+	for i, f := range r.findings {
+		seen[findingKey(f)] = i
+	}
+	for _, f := range o.findings {
+		if i, found := seen[findingKey(f)]; found {
+			r.findings[i].BuildConfigs = append(r.findings[i].BuildConfigs, f.BuildConfigs...)
+			continue
+		}
+		seen[findingKey(f)] = len(r.findings)
+		r.findings = append(r.findings, f)
+	}
+
+	// The configurations analyze mostly the same symbols.
+	if o.exported > r.exported {
+		r.exported = o.exported
+		r.exportedByOwner = o.exportedByOwner
+		r.exportedByPackage = o.exportedByPackage
+	}
+	r.skipped = append(r.skipped, o.skipped...)
+	r.walked = append(r.walked, o.walked...)
+	r.loadErrors = append(r.loadErrors, o.loadErrors...)
+}
+
+// symbolKey identifies the symbol of f in its package, e.g. (T).M in p, for the symbols declared in files
+// for different build constraints, e.g. foo_linux.go and foo_windows.go, or "" for fields and interface methods,
+// whose names are not qualified by their type. The test files are kept apart, as they may be another package.
+func symbolKey(f Finding) string {
+	if f.Kind == "field" || f.Kind == "method" && !strings.HasPrefix(f.Name, "(") {
+		return ""
+	}
+	return fmt.Sprintf("%s:%t:%s", path.Dir(f.Filename), strings.HasSuffix(f.Filename, "_test.go"), f.Name)
+}
+
+// consolidateSites merges the findings of the same symbol declared in multiple files, found with different build
+// configurations, into one, whatever the order the files were visited in: The finding in the first file, in
+// lexical order, with Sites listing all the declarations, the BuildConfigs of all and the lines of all removed.
+// If their checks contradict each other, e.g. unused with one configuration and used in test only with another,
+// the symbol is used, and the finding that is not EU1002 wins.
+func (r *runner) consolidateSites() {
+	findings := sortedByPosition(r.findings)
+	bySymbol := make(map[string]int)
+	consolidated := findings[:0]
+	for _, f := range findings {
+		key := symbolKey(f)
+		i, found := bySymbol[key]
+		if key == "" || !found {
+			if key != "" {
+				bySymbol[key] = len(consolidated)
+			}
+			consolidated = append(consolidated, f)
+			continue
+		}
+		first := &consolidated[i]
+		if len(first.Sites) == 0 {
+			first.Sites = []string{first.location()}
+		}
+		first.Sites = append(first.Sites, f.location())
+		first.Lines += f.Lines
+		for _, name := range f.BuildConfigs {
+			if !slices.Contains(first.BuildConfigs, name) {
+				first.BuildConfigs = append(first.BuildConfigs, name)
+			}
+		}
+		if canonicalCode(first.Code) == codeUnused && canonicalCode(f.Code) != codeUnused {
+			r.cfg.Logger.Debug("contradictory findings across build configurations", "symbol", f.Name, "unused_in", first.Filename, "code", f.Code, "in", f.Filename)
+			first.Code, first.Severity, first.Hint, first.Rename, first.UsedBy = f.Code, f.Severity, f.Hint, f.Rename, f.UsedBy
+		}
+	}
+	r.findings = consolidated
+}
+
+// analyzedFiles returns the files, as in the findings, analyzed by r, i.e. walked and not skipped,
+// e.g. excluded by the build constraints.
+func (r *runner) analyzedFiles() map[string]bool {
+	files := make(map[string]bool, len(r.walked))
+	for _, filename := range r.walked {
+		files[filename] = true
+	}
+	for _, sf := range r.skipped {
+		delete(files, sf.Filename)
+	}
+	return files
+}
+
+// keepInAllBuilds removes the findings not appearing with every build configuration analyzing the file declaring
+// the symbol, given the files analyzed with each, see RunConfig.AllBuildConfigs.
+func (r *runner) keepInAllBuilds(analyzed []map[string]bool) {
+	findings := r.findings[:0]
+	for _, f := range r.findings {
+		var builds int
+		for _, files := range analyzed {
+			if files[f.Filename] {
+				builds++
+			}
+		}
+		if len(f.BuildConfigs) < builds {
+			r.cfg.Logger.Debug("finding not in all build configurations", "filename", f.Filename, "name", f.Name, "build_configs", f.BuildConfigs)
+			continue
+		}
+		findings = append(findings, f)
+	}
+	r.findings = findings
+}
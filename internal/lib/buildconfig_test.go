@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestKeepInAllBuilds(t *testing.T) {
+	c := qt.New(t)
+
+	r := &runner{cfg: RunConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}}
+	r.findings = []Finding{
+		{Filename: "p/p.go", Name: "Dead", BuildConfigs: []string{"goos=linux", "goos=windows"}},
+		{Filename: "p/p.go", Name: "OnlyUsedOnLinux", BuildConfigs: []string{"goos=windows"}},
+		{Filename: "p/p_windows.go", Name: "WinDead", BuildConfigs: []string{"goos=windows"}},
+	}
+	linux := map[string]bool{"p/p.go": true}
+	windows := map[string]bool{"p/p.go": true, "p/p_windows.go": true}
+	r.keepInAllBuilds([]map[string]bool{linux, windows})
+
+	var names []string
+	for _, f := range r.findings {
+		names = append(names, f.Name)
+	}
+	c.Assert(names, qt.DeepEquals, []string{"Dead", "WinDead"})
+}
+
+func TestConsolidateSites(t *testing.T) {
+	c := qt.New(t)
+
+	r := &runner{cfg: RunConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}}
+	r.findings = []Finding{
+		{Filename: "p/foo_windows.go", Line: 3, Column: 6, Kind: "function", Name: "Open", Code: codeTestOnly, Lines: 2, BuildConfigs: []string{"goos=windows"}},
+		{Filename: "p/foo_linux.go", Line: 5, Column: 6, Kind: "function", Name: "Open", Code: codeUnused, Lines: 3, BuildConfigs: []string{"goos=linux"}},
+		{Filename: "p/foo_linux.go", Line: 9, Column: 2, Kind: "field", Name: "Name", Code: codeUnused, BuildConfigs: []string{"goos=linux"}},
+		{Filename: "p/foo_windows.go", Line: 9, Column: 2, Kind: "field", Name: "Name", Code: codeUnused, BuildConfigs: []string{"goos=windows"}},
+		{Filename: "p/foo_linux_test.go", Line: 3, Column: 6, Kind: "function", Name: "Open", Code: codeUnused, BuildConfigs: []string{"goos=linux"}},
+		{Filename: "q/foo_windows.go", Line: 3, Column: 6, Kind: "function", Name: "Open", Code: codeUnused, BuildConfigs: []string{"goos=windows"}},
+	}
+	r.consolidateSites()
+
+	c.Assert(r.findings, qt.HasLen, 5)
+	f := r.findings[0]
+	c.Assert(f.Filename, qt.Equals, "p/foo_linux.go")
+	c.Assert(f.Name, qt.Equals, "Open")
+	c.Assert(f.Code, qt.Equals, codeTestOnly)
+	c.Assert(f.Lines, qt.Equals, 5)
+	c.Assert(f.Sites, qt.DeepEquals, []string{"p/foo_linux.go:5:6", "p/foo_windows.go:3:6"})
+	c.Assert(f.BuildConfigs, qt.DeepEquals, []string{"goos=linux", "goos=windows"})
+
+	// The fields of different types may share a name, and a test file may be another package.
+	c.Assert(r.findings[1].Sites, qt.IsNil)
+	c.Assert(r.findings[2].Filename, qt.Equals, "p/foo_linux_test.go")
+	c.Assert(r.findings[3].Filename, qt.Equals, "p/foo_windows.go")
+	c.Assert(r.findings[4].Filename, qt.Equals, "q/foo_windows.go")
+
+	var b strings.Builder
+	f.Print(&b, false)
+	c.Assert(b.String(), qt.Contains, "\talso declared at p/foo_windows.go:3:6\n")
+}
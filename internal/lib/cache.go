@@ -0,0 +1,182 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheVersion is part of the cache key, bumped when the results cached, or how they're computed, change.
+const cacheVersion = 2
+
+// cacheMaxAge is how long the results cached are kept when not used, see pruneCache.
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// cacheKeyFiles are the files, relative to the workspace, besides the Go files, whose content is part of the cache key.
+var cacheKeyFiles = append([]string{"go.mod", "go.sum", "go.work", "go.work.sum"}, codeOwnersLocations...)
+
+// cachedResult holds the results of the analysis of a workspace with a build configuration, see RunConfig.Cache.
+type cachedResult struct {
+	Findings          []Finding         `json:"findings"`
+	Skipped           []SkippedFile     `json:"skipped,omitempty"`
+	Walked            []string          `json:"walked"`
+	Annotations       []InventorySymbol `json:"annotations,omitempty"`
+	LoadErrors        []LoadError       `json:"load_errors,omitempty"`
+	Exported          int               `json:"exported"`
+	ExportedByOwner   map[string]int    `json:"exported_by_owner"`
+	ExportedByPackage map[string]int    `json:"exported_by_package"`
+}
+
+// cacheable reports whether the results of r can be cached, i.e. they only depend on what's in the cache key,
+// and nothing but the findings is needed from gopls after the walk. The code of the downstream consumers,
+// e.g. a module at @latest, is not in the key.
+func (r *runner) cacheable() bool {
+	cfg := r.cfg
+	return cfg.Cache != "" && !cfg.Fix && !cfg.Rename && cfg.Resume == "" && !cfg.Blame && cfg.MinAge == 0 && !cfg.FailFast && cfg.DotOut == nil &&
+		len(cfg.Downstream) == 0
+}
+
+// cacheKey returns a hash of everything the results of r depend on: the configuration, the build configuration,
+// the content of the Go files, the module files, CODEOWNERS, the findings ignored and the names in the embedded templates.
+func (r *runner) cacheKey() (string, error) {
+	treeHash, err := r.treeHash()
+	if err != nil {
+		return "", err
+	}
+	build, err := json.Marshal(r.build)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%s\x00", cacheVersion, r.cfg.hash(), build, r.prefix, treeHash)
+	for _, filename := range cacheKeyFiles {
+		b, err := r.overlay.readFile(filepath.Join(r.cfg.WorkspaceDir, filepath.FromSlash(filename)))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%s", filename, len(b), b)
+	}
+	for _, names := range []map[string]bool{r.ignored, r.templateNames} {
+		keys := make([]string, 0, len(names))
+		for name := range names {
+			keys = append(keys, name)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(h, "\x00%s", strings.Join(keys, ","))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// loadCache restores the results cached for r, if any, reporting the findings, and reports whether they were found.
+// If not, the results are cached when the analysis completes, see saveCache.
+func (r *runner) loadCache() (bool, error) {
+	cache, err := openStore(r.cfg.Cache)
+	if err != nil {
+		return false, err
+	}
+	key, err := r.cacheKey()
+	if err != nil {
+		return false, err
+	}
+	r.cache, r.cacheObject = cache, key+".json"
+
+	b, err := cache.Get(r.ctx, r.cacheObject)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			r.cfg.Logger.Warn("failed to read the cached results", "cache", r.cfg.Cache, "key", r.cacheObject, "error", err)
+		}
+		return false, nil
+	}
+	var cached cachedResult
+	if err := json.Unmarshal(b, &cached); err != nil {
+		r.cfg.Logger.Warn("failed to read the cached results, analyzing the workspace", "cache", r.cfg.Cache, "key", r.cacheObject, "error", err)
+		return false, nil
+	}
+
+	r.cfg.Logger.Info("using the cached results", "cache", r.cfg.Cache, "key", r.cacheObject, "files", len(cached.Walked), "findings", len(cached.Findings))
+	if dir, ok := cache.(dirStore); ok {
+		// Kept by pruneCache for as long as it's used.
+		now := time.Now()
+		if err := os.Chtimes(filepath.Join(dir.dir, r.cacheObject), now, now); err != nil {
+			r.cfg.Logger.Warn("failed to touch the cached results", "cache", r.cfg.Cache, "key", r.cacheObject, "error", err)
+		}
+	}
+
+	r.skipped, r.walked = cached.Skipped, cached.Walked
+	r.annotations, r.loadErrors = cached.Annotations, cached.LoadErrors
+	r.exported = cached.Exported
+	for owner, n := range cached.ExportedByOwner {
+		r.exportedByOwner[owner] += n
+	}
+	for pkg, n := range cached.ExportedByPackage {
+		r.exportedByPackage[pkg] += n
+	}
+	for _, f := range cached.Findings {
+		// Already decorated, with the prefix.
+		r.findings = append(r.findings, f)
+		if err := r.emit(f); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// saveCache writes the results of r to its cache and, in a directory, removes the results not used in cacheMaxAge.
+// The buckets are expected to expire them with a lifecycle rule.
+func (r *runner) saveCache() error {
+	b, err := json.Marshal(cachedResult{
+		Findings:          r.findings,
+		Skipped:           r.skipped,
+		Walked:            r.walked,
+		Annotations:       r.annotations,
+		LoadErrors:        r.loadErrors,
+		Exported:          r.exported,
+		ExportedByOwner:   r.exportedByOwner,
+		ExportedByPackage: r.exportedByPackage,
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.cache.Put(r.ctx, r.cacheObject, b); err != nil {
+		return err
+	}
+	if dir, ok := r.cache.(dirStore); ok {
+		return pruneCache(dir.dir, cacheMaxAge)
+	}
+	return nil
+}
+
+// pruneCache removes the results in the cache dir not used within maxAge.
+func pruneCache(dir string, maxAge time.Duration) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > maxAge {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCache(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/a\n"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nfunc A() {}\n"), 0o644), qt.IsNil)
+	cacheDir := filepath.Join(t.TempDir(), "punused")
+	newTestRunner := func(cfg RunConfig) *runner {
+		cfg.WorkspaceDir, cfg.Cache = dir, cacheDir
+		cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		return &runner{ctx: context.Background(), cfg: cfg, exportedByOwner: make(map[string]int), exportedByPackage: make(map[string]int)}
+	}
+
+	r := newTestRunner(RunConfig{})
+	c.Assert(r.cacheable(), qt.IsTrue)
+	found, err := r.loadCache()
+	c.Assert(err, qt.IsNil)
+	c.Assert(found, qt.IsFalse)
+	r.findings = []Finding{{Filename: "a.go", Line: 3, Name: "A", Code: codeUnused}}
+	r.walked, r.exported = []string{"a.go"}, 1
+	r.exportedByPackage["."] = 1
+	c.Assert(r.saveCache(), qt.IsNil)
+
+	r = newTestRunner(RunConfig{})
+	found, err = r.loadCache()
+	c.Assert(err, qt.IsNil)
+	c.Assert(found, qt.IsTrue)
+	c.Assert(r.findings, qt.HasLen, 1)
+	c.Assert(r.findings[0].Name, qt.Equals, "A")
+	c.Assert(r.walked, qt.DeepEquals, []string{"a.go"})
+	c.Assert(r.exported, qt.Equals, 1)
+	c.Assert(r.exportedByPackage, qt.DeepEquals, map[string]int{".": 1})
+
+	// Streamed too.
+	var streamed []Finding
+	r = newTestRunner(RunConfig{OnFinding: func(f Finding) { streamed = append(streamed, f) }})
+	found, err = r.loadCache()
+	c.Assert(err, qt.IsNil)
+	c.Assert(found, qt.IsTrue)
+	c.Assert(streamed, qt.HasLen, 1)
+	c.Assert(streamed[0].Name, qt.Equals, "A")
+
+	// Another configuration.
+	r = newTestRunner(RunConfig{SameFile: true})
+	found, err = r.loadCache()
+	c.Assert(err, qt.IsNil)
+	c.Assert(found, qt.IsFalse)
+
+	// Changed since.
+	c.Assert(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nfunc A() {}\n\nfunc B() {}\n"), 0o644), qt.IsNil)
+	r = newTestRunner(RunConfig{})
+	found, err = r.loadCache()
+	c.Assert(err, qt.IsNil)
+	c.Assert(found, qt.IsFalse)
+
+	c.Assert(newTestRunner(RunConfig{Fix: true}).cacheable(), qt.IsFalse)
+	c.Assert(newTestRunner(RunConfig{Blame: true}).cacheable(), qt.IsFalse)
+	c.Assert(newTestRunner(RunConfig{Downstream: []string{"../app"}}).cacheable(), qt.IsFalse)
+
+	// Not used in a while.
+	entries, err := os.ReadDir(cacheDir)
+	c.Assert(err, qt.IsNil)
+	c.Assert(entries, qt.HasLen, 1)
+	old := time.Now().Add(-2 * cacheMaxAge)
+	c.Assert(os.Chtimes(filepath.Join(cacheDir, entries[0].Name()), old, old), qt.IsNil)
+	c.Assert(pruneCache(cacheDir, cacheMaxAge), qt.IsNil)
+	entries, err = os.ReadDir(cacheDir)
+	c.Assert(err, qt.IsNil)
+	c.Assert(entries, qt.HasLen, 0)
+}
@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// onlyInCases reports whether refs, the references to the exported symbol base, are all switch case expressions,
+// e.g. case Red:, or blank assignments, e.g. _ = Red, as with the cases listed for exhaustiveness.
+func (r *runner) onlyInCases(base string, refs []*lsp.Location) (bool, error) {
+	for _, ref := range refs {
+		uses, err := r.caseUses(ref.URI)
+		if err != nil {
+			return false, err
+		}
+		if !uses[declKey{line: ref.Range.Start.Line + 1, name: base}] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// caseUses returns, for the exported identifiers used in the file at uri, whether all their uses on a line
+// are switch case expressions or blank assignments, keyed by the 1-based line and the name.
+func (r *runner) caseUses(uri lsp.DocumentURI) (map[declKey]bool, error) {
+	if uses, found := r.caseOnly[uri]; found {
+		return uses, nil
+	}
+	filename, err := uriFilename(uri)
+	if err != nil {
+		return nil, err
+	}
+	content, err := r.files.overlay.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, 0)
+	if err != nil {
+		return nil, err
+	}
+	uses := collectCaseUses(fset, file)
+	r.caseOnly[uri] = uses
+	return uses, nil
+}
+
+func collectCaseUses(fset *token.FileSet, file *ast.File) map[declKey]bool {
+	inCase := make(map[*ast.Ident]bool)
+	mark := func(exprs []ast.Expr) {
+		for _, expr := range exprs {
+			expr = ast.Unparen(expr)
+			if sel, ok := expr.(*ast.SelectorExpr); ok {
+				expr = sel.Sel
+			}
+			if ident, ok := expr.(*ast.Ident); ok {
+				inCase[ident] = true
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CaseClause:
+			mark(n.List)
+		case *ast.AssignStmt:
+			if allBlank(n.Lhs) {
+				mark(n.Rhs)
+			}
+		case *ast.ValueSpec:
+			if allBlank(identExprs(n.Names)) {
+				mark(n.Values)
+			}
+		}
+		return true
+	})
+
+	uses := make(map[declKey]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.IsExported() {
+			key := declKey{line: fset.Position(ident.Pos()).Line, name: ident.Name}
+			only, seen := uses[key]
+			uses[key] = (only || !seen) && inCase[ident]
+		}
+		return true
+	})
+	return uses
+}
+
+// allBlank reports whether exprs are all the blank identifier.
+func allBlank(exprs []ast.Expr) bool {
+	for _, expr := range exprs {
+		if ident, ok := expr.(*ast.Ident); !ok || ident.Name != "_" {
+			return false
+		}
+	}
+	return len(exprs) > 0
+}
+
+func identExprs(idents []*ast.Ident) []ast.Expr {
+	exprs := make([]ast.Expr, len(idents))
+	for i, ident := range idents {
+		exprs[i] = ident
+	}
+	return exprs
+}
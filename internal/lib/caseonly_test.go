@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCollectCaseUses(t *testing.T) {
+	c := qt.New(t)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", `package p
+
+var _ = Blue
+var x, _ = Yellow, Orange
+
+func name(c color.Color) string {
+	_ = Green
+	switch c {
+	case Red, color.Green, (Blue):
+		return "red"
+	case Green + Red:
+		return "green"
+	}
+	return Red.String()
+}
+`, 0)
+	c.Assert(err, qt.IsNil)
+	uses := collectCaseUses(fset, file)
+	c.Assert(uses[declKey{line: 3, name: "Blue"}], qt.IsTrue)
+	c.Assert(uses[declKey{line: 4, name: "Orange"}], qt.IsFalse)
+	c.Assert(uses[declKey{line: 7, name: "Green"}], qt.IsTrue)
+	c.Assert(uses[declKey{line: 9, name: "Red"}], qt.IsTrue)
+	c.Assert(uses[declKey{line: 9, name: "Green"}], qt.IsTrue)
+	c.Assert(uses[declKey{line: 9, name: "Blue"}], qt.IsTrue)
+	c.Assert(uses[declKey{line: 11, name: "Green"}], qt.IsFalse)
+	c.Assert(uses[declKey{line: 14, name: "Red"}], qt.IsFalse)
+	c.Assert(uses[declKey{line: 6, name: "Color"}], qt.IsFalse)
+}
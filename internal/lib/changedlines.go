@@ -0,0 +1,196 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of lines, starting at 1.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// ChangedLines maps filenames, relative to the workspace with forward slashes, to the lines changed in them,
+// see RunConfig.ChangedLines.
+type ChangedLines map[string][]LineRange
+
+// Add adds the lines start to end in filename.
+func (c ChangedLines) Add(filename string, start, end int) {
+	filename = path.Clean(strings.TrimPrefix(filename, "./"))
+	c[filename] = append(c[filename], LineRange{Start: start, End: end})
+}
+
+// intersects reports whether any of the lines start to end in filename has changed.
+func (c ChangedLines) intersects(filename string, start, end int) bool {
+	for _, lr := range c[filename] {
+		if lr.Start <= end && start <= lr.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLineRange parses a filename with a line range, e.g. "file.go:10-80" or "file.go:10", and adds it to c.
+func (c ChangedLines) ParseLineRange(s string) error {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return fmt.Errorf("invalid line range %q, expected file.go:start-end", s)
+	}
+	filename, lines := s[:i], s[i+1:]
+	startStr, endStr, found := strings.Cut(lines, "-")
+	if !found {
+		endStr = startStr
+	}
+	start, err1 := strconv.Atoi(startStr)
+	end, err2 := strconv.Atoi(endStr)
+	if err1 != nil || err2 != nil || start < 1 || end < start {
+		return fmt.Errorf("invalid line range %q, expected file.go:start-end", s)
+	}
+	c.Add(filename, start, end)
+	return nil
+}
+
+// ParseDiff returns the lines added in the unified diff read from r, e.g. the output of git diff,
+// by the filenames in the new version with any b/ prefix removed. Deleted files are left out.
+func ParseDiff(r io.Reader) (ChangedLines, error) {
+	c := make(ChangedLines)
+	var (
+		filename string
+		line     int // The line in the new version of the next line in the hunk.
+		lines    []int
+	)
+	flush := func() {
+		// Merges the consecutive lines into ranges.
+		for i := 0; i < len(lines); {
+			j := i
+			for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+				j++
+			}
+			c.Add(filename, lines[i], lines[j])
+			i = j + 1
+		}
+		lines = nil
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for sc.Scan() {
+		s := sc.Text()
+		switch {
+		case strings.HasPrefix(s, "+++ "):
+			flush()
+			filename, _, _ = strings.Cut(strings.TrimPrefix(s, "+++ "), "\t")
+			if filename == "/dev/null" {
+				filename = ""
+			}
+			filename = strings.TrimPrefix(filename, "b/")
+			line = 0
+		case strings.HasPrefix(s, "@@ "):
+			// E.g. @@ -10,7 +10,8 @@ func main() {
+			fields := strings.Fields(s)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("invalid hunk header %q", s)
+			}
+			startStr, _, _ := strings.Cut(fields[2][1:], ",")
+			start, err := strconv.Atoi(startStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hunk header %q", s)
+			}
+			line = start
+		case line == 0 || filename == "":
+		case strings.HasPrefix(s, "+"):
+			lines = append(lines, line)
+			line++
+		case strings.HasPrefix(s, " "), s == "":
+			line++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	for filename := range c {
+		sort.Slice(c[filename], func(i, j int) bool { return c[filename][i].Start < c[filename][j].Start })
+	}
+	return c, nil
+}
+
+// GitDiff returns the lines added since base, a git revision, e.g. origin/main, in the repository in dir:
+// the diff of the working tree against the merge base of base and HEAD, i.e. the changes of the branch,
+// committed or not, with the filenames relative to dir. Untracked files are left out.
+func GitDiff(dir, base string) (ChangedLines, error) {
+	cmd := exec.Command("git", "diff", "--relative", "--no-color", "--no-ext-diff", "-U0", "--merge-base", base, "--")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %w: %s", base, err, strings.TrimSpace(stderr.String()))
+	}
+	return ParseDiff(bytes.NewReader(out))
+}
+
+// GitStaged returns the Go files staged in the repository in dir, added or modified, with their staged content,
+// and the lines added by the staged changes, with the filenames relative to dir, e.g. to analyze what's about to be
+// committed in a pre-commit hook. Files outside dir are left out.
+func GitStaged(dir string) (map[string]string, ChangedLines, error) {
+	git := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+	out, err := git("diff", "--cached", "--relative", "--name-only", "--diff-filter=AM", "-z", "--", "*.go")
+	if err != nil {
+		return nil, nil, err
+	}
+	staged := make(map[string]string)
+	for _, filename := range strings.Split(string(out), "\x00") {
+		if filename == "" {
+			continue
+		}
+		content, err := git("show", ":./"+filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		staged[filename] = string(content)
+	}
+	if len(staged) == 0 {
+		return staged, make(ChangedLines), nil
+	}
+	out, err = git("diff", "--cached", "--relative", "--no-color", "--no-ext-diff", "-U0", "--", "*.go")
+	if err != nil {
+		return nil, nil, err
+	}
+	changed, err := ParseDiff(bytes.NewReader(out))
+	if err != nil {
+		return nil, nil, err
+	}
+	return staged, changed, nil
+}
+
+// changed reports whether the declaration of f, including its doc comment, intersects ChangedLines.
+func (r *runner) changed(f Finding, base string) (bool, error) {
+	src, err := r.files.source(f.Filename)
+	if err != nil {
+		return false, err
+	}
+	start, end := src.declRange(f.Line, base)
+	if start == 0 {
+		start, end = f.Line, f.Line
+	}
+	return r.cfg.ChangedLines.intersects(f.Filename, start, end), nil
+}
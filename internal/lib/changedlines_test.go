@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseDiff(t *testing.T) {
+	c := qt.New(t)
+
+	diff := `diff --git a/p/p.go b/p/p.go
+index 1111111..2222222 100644
+--- a/p/p.go
++++ b/p/p.go
+@@ -3,6 +3,10 @@ package p
+ func Used() {}
+ 
+-func Old() {}
++func New() {}
++
++// Dead is dead.
++func Dead() {}
+ 
+ func Other() {}
+@@ -20,2 +24,3 @@ func Other() {}
+ var A = 1
++var B = 2
+diff --git a/p/gone.go b/p/gone.go
+deleted file mode 100644
+--- a/p/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package p
+-func Gone() {}
+`
+	changed, err := ParseDiff(strings.NewReader(diff))
+	c.Assert(err, qt.IsNil)
+	c.Assert(changed, qt.DeepEquals, ChangedLines{"p/p.go": {{Start: 5, End: 8}, {Start: 25, End: 25}}})
+
+	c.Assert(changed.intersects("p/p.go", 7, 8), qt.IsTrue)
+	c.Assert(changed.intersects("p/p.go", 9, 10), qt.IsFalse)
+	c.Assert(changed.intersects("p/other.go", 5, 8), qt.IsFalse)
+}
+
+func TestParseLineRange(t *testing.T) {
+	c := qt.New(t)
+
+	changed := make(ChangedLines)
+	c.Assert(changed.ParseLineRange("./p/p.go:10-80"), qt.IsNil)
+	c.Assert(changed.ParseLineRange("p/p.go:90"), qt.IsNil)
+	c.Assert(changed, qt.DeepEquals, ChangedLines{"p/p.go": {{Start: 10, End: 80}, {Start: 90, End: 90}}})
+
+	for _, s := range []string{"p/p.go", "p/p.go:10-5", "p/p.go:a-b", ":10"} {
+		c.Assert(changed.ParseLineRange(s), qt.ErrorMatches, "invalid line range.*")
+	}
+}
+
+func TestGitStaged(t *testing.T) {
+	c := qt.New(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		c.Skip("git not installed")
+	}
+
+	dir := c.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		c.Assert(err, qt.IsNil, qt.Commentf("%s", out))
+	}
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filename)
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o644), qt.IsNil)
+	}
+
+	git("init", "-q")
+	write("p/p.go", "package p\n\nfunc A() {}\n")
+	git("add", ".")
+	git("commit", "-qm", "initial")
+
+	files, changed, err := GitStaged(dir)
+	c.Assert(err, qt.IsNil)
+	c.Assert(files, qt.HasLen, 0)
+	c.Assert(changed, qt.HasLen, 0)
+
+	write("p/p.go", "package p\n\nfunc A() {}\n\nfunc B() {}\n")
+	write("p/new.go", "package p\n\nfunc C() {}\n")
+	write("README.md", "readme\n")
+	git("add", ".")
+	// Not staged.
+	write("p/p.go", "package p\n\nfunc A() {}\n\nfunc B() {}\n\nfunc D() {}\n")
+	write("p/untracked.go", "package p\n")
+
+	files, changed, err = GitStaged(dir)
+	c.Assert(err, qt.IsNil)
+	c.Assert(files, qt.DeepEquals, map[string]string{
+		"p/p.go":   "package p\n\nfunc A() {}\n\nfunc B() {}\n",
+		"p/new.go": "package p\n\nfunc C() {}\n",
+	})
+	c.Assert(changed, qt.DeepEquals, ChangedLines{
+		"p/p.go":   {{Start: 4, End: 5}},
+		"p/new.go": {{Start: 1, End: 3}},
+	})
+
+	// Relative to the workspace root.
+	files, changed, err = GitStaged(filepath.Join(dir, "p"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(files, qt.HasLen, 2)
+	c.Assert(files["new.go"], qt.Equals, "package p\n\nfunc C() {}\n")
+	c.Assert(changed["p.go"], qt.DeepEquals, []LineRange{{Start: 4, End: 5}})
+}
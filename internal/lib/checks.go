@@ -0,0 +1,320 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	codeTestOnly   = "EU1001"
+	codeUnused     = "EU1002"
+	codeSamePkg    = "EU1003"
+	codeRarelyUsed = "EU1004"
+	codeTransitive = "EU1005"
+	codeGenerated  = "EU1006"
+	codeSameFile   = "EU1007"
+	codeDynamic    = "EU1008"
+	codeTagged     = "EU1009"
+	codeDuplicate  = "EU1010"
+	codeReexport   = "EU1011"
+	codeCaseOnly   = "EU1012"
+	codeStdlib     = "EU1013"
+	codeWeak       = "EU1014"
+)
+
+// check describes one of the checks punused performs.
+type check struct {
+	Code string
+	Name string
+
+	// Short is a one line description.
+	Short string
+
+	Description string
+
+	// FalsePositives lists typical reasons for a finding that isn't real.
+	FalsePositives []string
+
+	// Suppression describes how to silence a finding.
+	Suppression string
+
+	// Hint is the action usually taken on a finding, printed with it with -v unless configured otherwise, see RunConfig.Hints.
+	Hint string
+
+	// DefaultSeverity is the severity used when not configured, defaults to warning.
+	DefaultSeverity Severity
+}
+
+// URL returns the address of the documentation of c.
+func (c check) URL() string {
+	return "https://github.com/bep/punused#" + strings.ToLower(c.Code)
+}
+
+var checks = []check{
+	{
+		Code:        codeTestOnly,
+		Name:        "TestOnly",
+		Short:       "Exported symbol is used in test only",
+		Description: "The exported symbol is only referenced from _test.go files. It's either dead code kept alive by its tests, or test infrastructure that should live in a _test.go file (e.g. export_test.go) or be unexported.",
+		FalsePositives: []string{
+			"The symbol is part of a public API used by other modules.",
+			"The symbol is used via reflection, templates or plugins.",
+		},
+		Suppression: "Add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Move it to a _test.go file in the same package, e.g. with punused -fix, or unexport it.",
+	},
+	{
+		Code:        codeUnused,
+		Name:        "Unused",
+		Short:       "Exported symbol is unused",
+		Description: "The exported symbol has no references in the workspace, not even from tests, and can usually be deleted.",
+		FalsePositives: []string{
+			"The symbol is part of a public API used by other modules.",
+			"The symbol is used via reflection, templates or plugins.",
+			"The symbol is only used in files excluded by build tags for the current GOOS/GOARCH.",
+		},
+		Suppression: "Add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Delete it, unless it's part of a public API used by other modules.",
+	},
+	{
+		Code:        codeSamePkg,
+		Name:        "OnlyUsedInDeclaringPackage",
+		Short:       "Exported symbol is only used in the package declaring it",
+		Description: "Every reference to the exported symbol is in the package declaring it, including its in-package tests, so it can be unexported. The suggested unexported name is applied with -rename or -fix. Only reported with -same-package.",
+		FalsePositives: []string{
+			"The symbol is part of a public API used by other modules.",
+			"The field is set by encoding/json or similar.",
+		},
+		Suppression: "Run without -same-package, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Unexport it, e.g. with punused -same-package -rename.",
+	},
+	{
+		Code:        codeRarelyUsed,
+		Name:        "RarelyUsed",
+		Short:       "Exported symbol has few references",
+		Description: "The exported symbol is used, but has fewer references than the -min-refs limit, e.g. one or two with -min-refs 3, counted in the finding, so it may be inlined into its callers or unexported. Reported with severity info unless configured otherwise, and only with -min-refs.",
+		FalsePositives: []string{
+			"The symbol is part of a public API used by other modules.",
+			"The symbol is a deliberate abstraction, e.g. an extension point or a name for a non-obvious computation.",
+		},
+		Suppression: "Run without -min-refs, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Inline it into its callers, or unexport it if they're in its package.",
+
+		DefaultSeverity: SeverityInfo,
+	},
+	{
+		Code:        codeTransitive,
+		Name:        "OnlyUsedByUnused",
+		Short:       "Exported symbol is only used by unused code",
+		Description: "The exported symbol is only referenced from within the declarations of other unused symbols, so it will become unused when they are removed. Only reported with -transitive.",
+		FalsePositives: []string{
+			"Any of the false positives of the symbols using it (see EU1002).",
+		},
+		Suppression: "Run without -transitive, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Delete it along with the unused code using it, listed in the finding.",
+	},
+	{
+		Code:        codeGenerated,
+		Name:        "OnlyUsedInGenerated",
+		Short:       "Exported symbol is only used in generated code",
+		Description: "The exported symbol is only referenced from generated files (files with a \"// Code generated ... DO NOT EDIT.\" header), e.g. an old mock. Only reported with -ignore-generated-refs.",
+		FalsePositives: []string{
+			"The generated code is used, e.g. protobuf messages or a mock used in tests.",
+		},
+		Suppression: "Run without -ignore-generated-refs, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Delete it along with the generated code using it, e.g. an old mock, and regenerate the code.",
+	},
+	{
+		Code:        codeSameFile,
+		Name:        "OnlyUsedInDeclaringFile",
+		Short:       "Exported symbol is only used in the file declaring it",
+		Description: "Every reference to the exported symbol is in the file it is declared in, which makes it a prime candidate for unexporting. Only reported with -same-file.",
+		FalsePositives: []string{
+			"The symbol is part of a public API used by other modules.",
+			"The field is set by encoding/json or similar.",
+		},
+		Suppression: "Run without -same-file, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Unexport it, or move it next to its only users if they belong elsewhere.",
+	},
+	{
+		Code:        codeDynamic,
+		Name:        "DynamicUsageSuspected",
+		Short:       "Exported symbol is unused, but dynamic usage is suspected",
+		Description: "The exported symbol has no references, but is likely used by name or via reflection: Its name matches one of the dynamic-usage names in the config, or it is a method on a type passed to one of the dynamic-usage calls, by default the net/rpc and encoding/gob registrations and template executions. Reported with severity info unless configured otherwise.",
+		FalsePositives: []string{
+			"The heuristics are approximate; the symbol may well be unused.",
+		},
+		Suppression: "Set the severity of EU1008 in the config, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Check whether it's used by name or via reflection before deleting it; if so, add a //punused:ignore comment.",
+
+		DefaultSeverity: SeverityInfo,
+	},
+	{
+		Code:        codeTagged,
+		Name:        "UnusedTaggedField",
+		Short:       "Exported field with serialization tags is unused in Go code",
+		Description: "The exported struct field has no references in the workspace, but carries json, yaml, xml, toml or similar struct tags, so it may still be part of a wire or file format read or written by other programs. Reported with severity info unless configured otherwise, and only with -tagged-fields; without it, these fields are reported as unused (EU1002).",
+		FalsePositives: []string{
+			"The field is part of a wire format, e.g. an API response, and must be kept for compatibility.",
+		},
+		Suppression: "Run without -tagged-fields, add a //punused:ignore comment to the field, or exclude the file with the filename pattern argument.",
+		Hint:        "Check that no other program reads or writes the field before deleting it.",
+
+		DefaultSeverity: SeverityInfo,
+	},
+	{
+		Code:        codeDuplicate,
+		Name:        "DuplicateFunction",
+		Short:       "Exported function duplicates one in another package",
+		Description: "The exported top level function has the same signature as, and a body at least as similar as the -duplicates threshold to, an exported function in another package, which the finding lists. Cleaning up dead code often goes along with removing duplicates. The bodies are compared by their tokens, and bodies of fewer than 20 tokens are ignored. Reported with severity info unless configured otherwise, and only with -duplicates.",
+		FalsePositives: []string{
+			"The duplication is intended, e.g. to avoid a dependency between the packages.",
+			"The functions are alike by nature, e.g. generated or table driven code.",
+		},
+		Suppression: "Raise the -duplicates threshold, add a //punused:ignore comment to the function, or exclude the file with the filename pattern argument.",
+		Hint:        "Call the function it duplicates instead, listed in the finding, and delete it.",
+
+		DefaultSeverity: SeverityInfo,
+	},
+	{
+		Code:        codeReexport,
+		Name:        "OnlyReexported",
+		Short:       "Exported symbol is only re-exported by unused or deprecated shims",
+		Description: "The exported symbol is only referenced by re-export shims in other packages, which the finding lists, that are themselves unused or deprecated: functions whose body only calls the symbol, variables or constants set to it, or type aliases of it. Both layers of the chain can be removed together. Only reported with -reexports.",
+		FalsePositives: []string{
+			"The deprecated shims are still used by other modules.",
+			"Any of the false positives of the shims (see EU1002).",
+		},
+		Suppression: "Run without -reexports, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Delete it along with its shims, listed in the finding, once their callers in other modules have migrated.",
+	},
+	{
+		Code:        codeCaseOnly,
+		Name:        "OnlyUsedInSwitchCases",
+		Short:       "Exported constant is only used in switch cases or blank assignments",
+		Description: "Every reference to the exported constant is a switch case expression, e.g. case Red:, or a blank assignment, e.g. _ = Red, as in the cases listed to make a switch exhaustive, so no code ever produces the value. Reported with severity info unless configured otherwise, and only with -case-only; without it, these constants count as used.",
+		FalsePositives: []string{
+			"The constant is a member of an enum whose type is part of a public API, and other modules produce the value.",
+			"The value is produced without naming the constant, e.g. decoded or converted from an integer.",
+		},
+		Suppression: "Run without -case-only, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Keep it while its type is part of the public API; otherwise delete it along with its cases.",
+
+		DefaultSeverity: SeverityInfo,
+	},
+	{
+		Code:        codeStdlib,
+		Name:        "DuplicatesStdlib",
+		Short:       "Exported function duplicates a standard library function",
+		Description: "The exported top level function has few callers, at most the -stdlib-clones limit, and its name and signature match a function added to the standard library in a recent Go version, e.g. a ContainsString(s []string, v string) bool helper written before slices.Contains, available with the Go version of the workspace. The finding names the function to use instead. Reported with severity info unless configured otherwise, and only with -stdlib-clones.",
+		FalsePositives: []string{
+			"The behavior differs from the standard library function, e.g. compares case-insensitively.",
+			"The function is part of a public API used by other modules.",
+		},
+		Suppression: "Run without -stdlib-clones, add a //punused:ignore comment to the function, or exclude the file with the filename pattern argument.",
+		Hint:        "Replace its calls with the standard library function, listed in the finding, and delete it.",
+
+		DefaultSeverity: SeverityInfo,
+	},
+	{
+		Code:        codeWeak,
+		Name:        "OnlyWeaklyUsed",
+		Short:       "Exported symbol is only used by weak references",
+		Description: "The exported symbol is only referenced from the files matching the weak-refs globs of the config, or -ignore-refs, e.g. examples/** or tools/**, whose usage alone does not keep an API alive. Set the severity of EU1014 in the config to decide whether it does: info to keep such symbols, error to fail the run on them.",
+		FalsePositives: []string{
+			"The examples document a public API used by other modules.",
+		},
+		Suppression: "Remove the paths from weak-refs and -ignore-refs, set the severity of EU1014 in the config, add a //punused:ignore comment to the declaration, or exclude the file with the filename pattern argument.",
+		Hint:        "Delete it along with the examples or tools using it, or move it next to them.",
+	},
+}
+
+func isCheckCode(code string) bool {
+	code = canonicalCode(code)
+	for _, c := range checks {
+		if c.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// checkByCode looks up a check by its code, with any prefix, ignoring case.
+func checkByCode(code string) (check, bool) {
+	code = canonicalCode(strings.ToUpper(code))
+	for _, c := range checks {
+		if strings.EqualFold(c.Code, code) {
+			return c, true
+		}
+	}
+	return check{}, false
+}
+
+// canonicalCode returns code with its prefix, e.g. the configured code prefix ACME in ACME1002, replaced with EU.
+func canonicalCode(code string) string {
+	i := strings.IndexFunc(code, func(r rune) bool { return r < 'A' || r > 'Z' })
+	if i <= 0 || strings.HasPrefix(code, "EU") {
+		return code
+	}
+	return "EU" + code[i:]
+}
+
+// prefixedCode returns code, e.g. EU1002, with its EU prefix replaced with prefix, if set, e.g. ACME1002.
+func prefixedCode(prefix, code string) string {
+	if prefix == "" {
+		return code
+	}
+	return prefix + strings.TrimPrefix(code, "EU")
+}
+
+// validateCodePrefix checks that prefix, if set, is made of upper case letters, e.g. ACME.
+func validateCodePrefix(prefix string) error {
+	for _, r := range prefix {
+		if r < 'A' || r > 'Z' {
+			return fmt.Errorf("code-prefix: %q must only contain the letters A to Z", prefix)
+		}
+	}
+	return nil
+}
+
+// Explain writes the documentation of the check with the given code to w.
+func Explain(w io.Writer, code string) error {
+	c, found := checkByCode(code)
+	if !found {
+		var codes []string
+		for _, c := range checks {
+			codes = append(codes, c.Code)
+		}
+		return fmt.Errorf("unknown check %q, must be one of %s", code, strings.Join(codes, ", "))
+	}
+
+	fmt.Fprintf(w, "%s %s: %s\n\n%s\n\nTypical false positives:\n", c.Code, c.Name, c.Short, c.Description)
+	for _, fp := range c.FalsePositives {
+		fmt.Fprintf(w, "  - %s\n", fp)
+	}
+	fmt.Fprintf(w, "\nSuppression:\n  %s\n\nHint:\n  %s\n\nMore: %s\n", c.Suppression, c.Hint, c.URL())
+
+	return nil
+}
+
+// Severity is the severity of a finding, one of error, warning or info.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// sarifLevel returns the SARIF result level for s.
+func (s Severity) sarifLevel() string {
+	switch s {
+	case SeverityInfo:
+		return "note"
+	case "":
+		return string(SeverityWarning)
+	default:
+		return string(s)
+	}
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const formatCheckstyle = "checkstyle"
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// writeCheckstyle writes the findings to w as a Checkstyle XML report, as ingested by e.g. the Jenkins Warnings
+// plugin and SonarQube, with a file element per file and an error element per finding, sorted by position, e.g.
+//
+//	<error line="125" column="2" severity="warning" message="field Detail is unused" source="punused.EU1002"></error>
+//
+// The severity is that of the finding, i.e. of its check, see RunConfig.Severity.
+func writeCheckstyle(w io.Writer, findings []Finding) error {
+	report := checkstyleReport{Version: "4.3"}
+	for _, f := range sortedByPosition(findings) {
+		if n := len(report.Files); n == 0 || report.Files[n-1].Name != f.Filename {
+			report.Files = append(report.Files, checkstyleFile{Name: f.Filename})
+		}
+		sev := f.Severity
+		if sev == "" {
+			sev = SeverityWarning
+		}
+		msg := fmt.Sprintf("%s %s %s", f.Kind, f.Name, f.Message())
+		file := &report.Files[len(report.Files)-1]
+		file.Errors = append(file.Errors, checkstyleError{
+			Line:     f.Line,
+			Column:   f.Column,
+			Severity: string(sev),
+			Message:  strings.Join(strings.Fields(msg), " "),
+			Source:   "punused." + f.Code,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
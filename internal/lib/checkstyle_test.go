@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWriteCheckstyle(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	c.Assert(writeCheckstyle(&buf, []Finding{
+		{Filename: "b.go", Line: 3, Column: 6, Kind: "function", Name: "B", Code: codeUnused, Severity: SeverityError},
+		{Filename: "a.go", Line: 12, Column: 2, Kind: "field", Name: "F", Code: codeTestOnly},
+		{Filename: "a.go", Line: 7, Column: 6, Kind: "method", Name: "(*T).M", Code: codeDynamic, Severity: SeverityInfo},
+	}), qt.IsNil)
+
+	c.Assert(buf.String(), qt.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+  <file name="a.go">
+    <error line="7" column="6" severity="info" message="method (*T).M is unused, but dynamic usage is suspected" source="punused.EU1008"></error>
+    <error line="12" column="2" severity="warning" message="field F is used in test only" source="punused.EU1001"></error>
+  </file>
+  <file name="b.go">
+    <error line="3" column="6" severity="error" message="function B is unused" source="punused.EU1002"></error>
+  </file>
+</checkstyle>
+`)
+}
@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strings"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// classify returns the check code for a symbol declared in uri with the given references,
+// or an empty string if the symbol is considered used.
+// It also returns the references that count as usage.
+func (r *runner) classify(isTestFile bool, uri lsp.DocumentURI, refs []*lsp.Location) (string, []*lsp.Location, error) {
+	if !r.cfg.IncludeTestdata {
+		refs = withoutTestdata(r.client.documentURI(""), refs)
+	}
+
+	if r.cfg.IgnoreGeneratedRefs && len(refs) > 0 {
+		var live []*lsp.Location
+		for _, ref := range refs {
+			generated, err := r.isGenerated(ref.URI)
+			if err != nil {
+				return "", nil, err
+			}
+			if !generated {
+				live = append(live, ref)
+			}
+		}
+		if len(live) == 0 {
+			return codeGenerated, nil, nil
+		}
+		refs = live
+	}
+
+	refs, weak := r.withoutWeakRefs(uri, refs)
+	if len(refs) == 0 && len(weak) > 0 {
+		return codeWeak, weak, nil
+	}
+
+	if len(refs) == 0 {
+		return codeUnused, refs, nil
+	}
+
+	if !isTestFile {
+		testOnly := true
+		for _, ref := range refs {
+			if !strings.HasSuffix(string(ref.URI), "_test.go") {
+				testOnly = false
+				break
+			}
+		}
+		if testOnly {
+			if r.cfg.IgnoreTestOnly {
+				return "", refs, nil
+			}
+			return codeTestOnly, refs, nil
+		}
+	}
+
+	if r.cfg.SameFile {
+		sameFile := true
+		for _, ref := range refs {
+			if ref.URI != uri {
+				sameFile = false
+				break
+			}
+		}
+		if sameFile {
+			return codeSameFile, refs, nil
+		}
+	}
+
+	if r.cfg.SamePackage && !isTestFile {
+		samePackage, err := r.inDeclaringPackage(uri, refs)
+		if err != nil {
+			return "", nil, err
+		}
+		if samePackage {
+			return codeSamePkg, refs, nil
+		}
+	}
+
+	return "", refs, nil
+}
+
+// inDeclaringPackage reports whether the refs are all in the package of the file at uri,
+// i.e. in its directory and not in an external test package, e.g. p_test.
+func (r *runner) inDeclaringPackage(uri lsp.DocumentURI, refs []*lsp.Location) (bool, error) {
+	pkg, err := r.packageName(uri)
+	if err != nil {
+		return false, err
+	}
+	dir := path.Dir(string(uri))
+	for _, ref := range refs {
+		if path.Dir(string(ref.URI)) != dir {
+			return false, nil
+		}
+		if ref.URI == uri {
+			continue
+		}
+		refPkg, err := r.packageName(ref.URI)
+		if err != nil {
+			return false, err
+		}
+		if refPkg != pkg {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// packageName returns the package name of the file at uri.
+func (r *runner) packageName(uri lsp.DocumentURI) (string, error) {
+	if name, found := r.packageNames[uri]; found {
+		return name, nil
+	}
+	filename, err := uriFilename(uri)
+	if err != nil {
+		return "", err
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+	r.packageNames[uri] = file.Name.Name
+	return file.Name.Name, nil
+}
+
+// withoutTestdata returns the references not in testdata directories within the workspace at the root URI,
+// which may itself be in one, e.g. a test fixture.
+func withoutTestdata(root string, refs []*lsp.Location) []*lsp.Location {
+	var filtered []*lsp.Location
+	for _, ref := range refs {
+		if !strings.Contains(strings.TrimPrefix(string(ref.URI), root), "/testdata/") {
+			filtered = append(filtered, ref)
+		}
+	}
+	return filtered
+}
+
+// isGenerated reports whether the file at uri is generated, see isGeneratedFile.
+func (r *runner) isGenerated(uri lsp.DocumentURI) (bool, error) {
+	if generated, found := r.generated[uri]; found {
+		return generated, nil
+	}
+	filename, err := uriFilename(uri)
+	if err != nil {
+		return false, err
+	}
+	generated, err := isGeneratedFile(filename)
+	if err != nil {
+		return false, err
+	}
+	r.generated[uri] = generated
+	return generated, nil
+}
+
+// isGeneratedFile reports whether filename has the standard
+// "// Code generated ... DO NOT EDIT." comment before the package clause.
+func isGeneratedFile(filename string) (bool, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false, err
+	}
+	return ast.IsGenerated(file), nil
+}
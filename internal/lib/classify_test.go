@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestInDeclaringPackage(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	uri := func(name, content string) lsp.DocumentURI {
+		filename := filepath.Join(dir, name)
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o644), qt.IsNil)
+		return lsp.DocumentURI("file://" + filename)
+	}
+	decl := uri("p/p.go", "package p\n")
+	other := uri("p/other.go", "package p\n")
+	internalTest := uri("p/p_test.go", "package p\n")
+	externalTest := uri("p/external_test.go", "package p_test\n")
+	otherPkg := uri("q/q.go", "package q\n")
+
+	refs := func(uris ...lsp.DocumentURI) []*lsp.Location {
+		var locs []*lsp.Location
+		for _, u := range uris {
+			locs = append(locs, &lsp.Location{URI: u})
+		}
+		return locs
+	}
+	r := &runner{packageNames: make(map[lsp.DocumentURI]string)}
+	for _, test := range []struct {
+		refs []*lsp.Location
+		want bool
+	}{
+		{refs(decl), true},
+		{refs(decl, other, internalTest), true},
+		{refs(other, externalTest), false},
+		{refs(other, otherPkg), false},
+	} {
+		got, err := r.inDeclaringPackage(decl, test.refs)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.Equals, test.want)
+	}
+}
+
+func TestClassifyTestOnly(t *testing.T) {
+	c := qt.New(t)
+
+	refs := []*lsp.Location{{URI: "file:///m/p/p_test.go"}}
+	for _, test := range []struct {
+		cfg  RunConfig
+		want string
+	}{
+		{RunConfig{IncludeTestdata: true}, codeTestOnly},
+		{RunConfig{IncludeTestdata: true, IgnoreTestOnly: true}, ""},
+		{RunConfig{IncludeTestdata: true, IgnoreTestOnly: true, SameFile: true}, ""},
+	} {
+		r := &runner{cfg: test.cfg}
+		code, _, err := r.classify(false, "file:///m/p/p.go", refs)
+		c.Assert(err, qt.IsNil)
+		c.Assert(code, qt.Equals, test.want)
+	}
+}
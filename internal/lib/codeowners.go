@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// codeOwnersLocations are the locations GitHub looks for a CODEOWNERS file in, in order.
+var codeOwnersLocations = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+// codeOwners holds the rules of a CODEOWNERS file.
+type codeOwners []codeOwnersRule
+
+type codeOwnersRule struct {
+	matchers []glob.Glob
+	owners   []string
+}
+
+// loadCodeOwners loads the first CODEOWNERS file found in dir.
+// It returns nil if none is found.
+func loadCodeOwners(dir string) (codeOwners, error) {
+	for _, location := range codeOwnersLocations {
+		filename := filepath.Join(dir, filepath.FromSlash(location))
+		f, err := os.Open(filename)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		defer f.Close()
+		co, err := parseCodeOwners(bufio.NewScanner(f))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", location, err)
+		}
+		return co, nil
+	}
+	return nil, nil
+}
+
+func parseCodeOwners(scanner *bufio.Scanner) (codeOwners, error) {
+	var co codeOwners
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		matchers, err := compileCodeOwnersPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		co = append(co, codeOwnersRule{matchers: matchers, owners: fields[1:]})
+	}
+	return co, scanner.Err()
+}
+
+// compileCodeOwnersPattern compiles a gitignore style CODEOWNERS pattern.
+// A pattern matching a directory also matches everything below it, and
+// a pattern without a leading or inner slash matches at any depth.
+func compileCodeOwnersPattern(pattern string) ([]glob.Glob, error) {
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	patterns := []string{pattern}
+	if !anchored {
+		patterns = append(patterns, "**/"+pattern)
+	}
+	if !strings.HasSuffix(pattern, "/*") {
+		// As in GitHub, "docs/*" does not match the files in subdirectories of docs.
+		for _, p := range patterns {
+			patterns = append(patterns, p+"/**")
+		}
+	}
+
+	var matchers []glob.Glob
+	for _, p := range patterns {
+		m, err := glob.Compile(p, '/')
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// owners returns the owners of filename, relative to the repository root.
+// The last matching rule wins, as in GitHub.
+func (co codeOwners) owners(filename string) []string {
+	for i := len(co) - 1; i >= 0; i-- {
+		for _, m := range co[i].matchers {
+			if m.Match(filename) {
+				return co[i].owners
+			}
+		}
+	}
+	return nil
+}
+
+// ownerOf returns the owners of f as a single group name, e.g. "@org/team".
+func ownerOf(f Finding) string {
+	return ownerGroup(f.Owners)
+}
+
+func ownerGroup(owners []string) string {
+	return strings.Join(owners, " ")
+}
+
+var nonFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ownerFilename returns a filename, without extension, for the report of the given owner group.
+func ownerFilename(owner string) string {
+	if owner == "" {
+		return "unowned"
+	}
+	return strings.Trim(nonFilenameChars.ReplaceAllString(owner, "-"), "-")
+}
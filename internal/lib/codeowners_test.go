@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCodeOwners(t *testing.T) {
+	c := qt.New(t)
+
+	co, err := parseCodeOwners(bufio.NewScanner(strings.NewReader(`
+# Default owners.
+*       @org/core
+
+/internal/  @org/internal # Inline comment.
+docs/*      @org/docs
+*.pb.go     @org/proto @jane
+/cmd/tool   @org/tools
+`)))
+	c.Assert(err, qt.IsNil)
+
+	for _, test := range []struct {
+		filename string
+		expect   []string
+	}{
+		{"main.go", []string{"@org/core"}},
+		{"internal/lib/run.go", []string{"@org/internal"}},
+		{"internal/lib/api.pb.go", []string{"@org/proto", "@jane"}},
+		{"docs/gen.go", []string{"@org/docs"}},
+		{"docs/sub/gen.go", []string{"@org/core"}},
+		{"cmd/tool/main.go", []string{"@org/tools"}},
+		{"pkg/cmd/tool/main.go", []string{"@org/core"}},
+	} {
+		c.Assert(co.owners(test.filename), qt.DeepEquals, test.expect, qt.Commentf(test.filename))
+	}
+
+	c.Assert(ownerFilename("@org/core @jane"), qt.Equals, "org-core-jane")
+	c.Assert(ownerFilename(""), qt.Equals, "unowned")
+}
+
+func TestGateFailOwners(t *testing.T) {
+	c := qt.New(t)
+
+	max := 40.0
+	r := &runner{
+		cfg: RunConfig{FailOn: []string{codeUnused}, FailOwners: []string{"payments"}, MaxUnusedPercent: &max},
+		findings: []Finding{
+			{Name: "Billing", Code: codeUnused, Owners: []string{"@org/billing"}},
+			{Name: "Unowned", Code: codeUnused},
+		},
+		exportedByOwner: map[string]int{"@org/billing": 2, "@org/payments @org/platform": 4, "": 1},
+	}
+	c.Assert(r.gate(), qt.IsNil)
+
+	r.findings = append(r.findings, Finding{Name: "Pay", Code: codeUnused, Owners: []string{"@org/payments", "@org/platform"}})
+	c.Assert(r.gate(), qt.ErrorMatches, "check failed: 1 findings with code EU1002")
+
+	// Out of the 4 exported symbols owned.
+	r.findings = append(r.findings, Finding{Name: "Pay2", Code: codeTestOnly, Owners: []string{"@org/payments"}})
+	c.Assert(r.gate(), qt.ErrorMatches, `check failed: 1 findings with code EU1002; 50.00% of the exported symbols have findings, exceeds the maximum of 40.00%`)
+
+	c.Assert(ownedBy(nil, []string{"unowned"}), qt.IsTrue)
+	c.Assert(ownedBy([]string{"@org/payments"}, []string{"@org/payments"}), qt.IsTrue)
+	c.Assert(ownedBy([]string{"dev@example.com"}, []string{"dev@example.com"}), qt.IsTrue)
+	c.Assert(ownedBy([]string{"@org/payments-eu"}, []string{"payments"}), qt.IsFalse)
+}
+
+func TestRunOwners(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o777), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o666), qt.IsNil)
+	}
+	write("go.mod", "module example.com/m\n\ngo 1.21\n")
+	write("a/a.go", "package a\n\nfunc Alpha() {}\n")
+	write("b/b.go", "package b\n\nfunc Beta() {}\n")
+
+	run := func(format string, owners ...string) (string, error) {
+		var buff bytes.Buffer
+		err := Run(context.Background(), RunConfig{
+			WorkspaceDir:     dir,
+			FilenamePatterns: []string{"**.go"},
+			Out:              &buff,
+			Format:           format,
+			Backend:          BackendPackages,
+			Owners:           owners,
+		})
+		return buff.String(), err
+	}
+
+	_, err := run(formatText, "alpha")
+	c.Assert(err, qt.ErrorMatches, `Owners requires a CODEOWNERS file, none found in .*`)
+
+	write(".github/CODEOWNERS", "/a/ @org/alpha\n")
+	out, err := run(formatText, "alpha")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "function Alpha is unused")
+	c.Assert(out, qt.Not(qt.Contains), "Beta")
+
+	out, err = run(formatText, "unowned")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Not(qt.Contains), "Alpha")
+	c.Assert(out, qt.Contains, "function Beta is unused")
+
+	out, err = run(formatHTML)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "<th>Owners</th>")
+	c.Assert(out, qt.Contains, "<td><code>@org/alpha</code> </td>")
+	c.Assert(out, qt.Contains, "<tr><td>unowned</td><td>1</td>")
+}
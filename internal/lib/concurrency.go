@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+// prefetched holds the symbols and references of a file fetched from gopls ahead of handling it,
+// see RunConfig.Concurrency.
+type prefetched struct {
+	filename string
+	symbols  []*Symbol
+	err      error
+	refs     map[lsp.Location]prefetchedRefs
+}
+
+type prefetchedRefs struct {
+	locations []*lsp.Location
+	err       error
+}
+
+// handleFiles handles the files, relative to the workspace, in order, while up to Concurrency
+// workers fetch the symbols and references of the next files from gopls. The findings are
+// reported in the same order as without.
+func (r *runner) handleFiles(filenames []string) error {
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	results := make([]chan *prefetched, len(filenames))
+	for i := range results {
+		results[i] = make(chan *prefetched, 1)
+	}
+	// Limits the files fetched, but not yet handled.
+	slots := make(chan struct{}, r.cfg.Concurrency)
+	go func() {
+		for i, filename := range filenames {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, filename string) {
+				results[i] <- r.prefetch(ctx, filename)
+			}(i, filename)
+		}
+	}()
+
+	defer func() { r.prefetched = nil }()
+	for i, filename := range filenames {
+		select {
+		case r.prefetched = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-slots
+		if err := r.analyzeFile(filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefetch fetches the symbols of filename and the references of those handleFile looks up.
+func (r *runner) prefetch(ctx context.Context, filename string) *prefetched {
+	p := &prefetched{filename: filename, refs: make(map[lsp.Location]prefetchedRefs)}
+	p.symbols, p.err = r.client.DocumentSymbol(ctx, filename)
+	if p.err != nil {
+		return p
+	}
+	if max := r.cfg.MaxSymbolsPerFile; max > 0 && countSymbols(p.symbols) > max {
+		// Skipped.
+		return p
+	}
+	p.refs = r.fetchReferences(ctx, filename, p.symbols)
+	return p
+}
+
+// fetchReferences fetches the references of the symbols in filename handleFile looks up, with up to Pipeline
+// requests sent to gopls without waiting for the responses, amortizing the round trips.
+func (r *runner) fetchReferences(ctx context.Context, filename string, symbols []*Symbol) map[lsp.Location]prefetchedRefs {
+	isTestFile := strings.HasSuffix(filename, "_test.go")
+	var (
+		locations []lsp.Location
+		collect   func(symbols []*Symbol)
+	)
+	collect = func(symbols []*Symbol) {
+		for _, s := range symbols {
+			base := symbolBaseName(s)
+			if !r.analyzes(s, base) || isTestFile && s.Kind == lsp.SKFunction && isTestFunc(base) {
+				continue
+			}
+			locations = append(locations, s.Location)
+			collect(s.Children)
+		}
+	}
+	collect(symbols)
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		refs = make(map[lsp.Location]prefetchedRefs, len(locations))
+	)
+	slots := make(chan struct{}, max(r.cfg.Pipeline, 1))
+	for _, loc := range locations {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			// The references not fetched are looked up as needed.
+			wg.Wait()
+			return refs
+		}
+		wg.Add(1)
+		go func(loc lsp.Location) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			locations, err := r.client.DocumentReferences(ctx, loc)
+			mu.Lock()
+			refs[loc] = prefetchedRefs{locations: locations, err: err}
+			mu.Unlock()
+		}(loc)
+	}
+	wg.Wait()
+	return refs
+}
+
+// documentSymbols returns the symbols in filename, relative to the workspace, prefetched if available.
+func (r *runner) documentSymbols(filename string) ([]*Symbol, error) {
+	if p := r.prefetched; p != nil && p.filename == filename {
+		return p.symbols, p.err
+	}
+	return r.client.DocumentSymbol(r.ctx, filename)
+}
+
+// references returns the references to the symbol at loc, prefetched if available.
+func (r *runner) references(loc lsp.Location) ([]*lsp.Location, error) {
+	if p := r.prefetched; p != nil {
+		if refs, found := p.refs[loc]; found {
+			return refs.locations, refs.err
+		}
+	}
+	return r.client.DocumentReferences(r.ctx, loc)
+}
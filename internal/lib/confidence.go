@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"strings"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// Confidence is how likely a finding is to be correct, given the heuristics it triggered.
+type Confidence string
+
+const (
+	ConfidenceHigh   Confidence = "high"
+	ConfidenceMedium Confidence = "medium"
+	ConfidenceLow    Confidence = "low"
+)
+
+func (c Confidence) rank() int {
+	switch c {
+	case ConfidenceLow:
+		return 1
+	case ConfidenceMedium:
+		return 2
+	case ConfidenceHigh:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// ParseConfidence parses s as one of high, medium or low.
+func ParseConfidence(s string) (Confidence, error) {
+	c := Confidence(strings.ToLower(s))
+	if c.rank() == 0 {
+		return "", fmt.Errorf("invalid confidence %q, must be one of high, medium or low", s)
+	}
+	return c, nil
+}
+
+// confidence returns the confidence of f, declared in a file relative to the workspace:
+//
+//   - low if dynamic usage is suspected (EU1008),
+//   - medium if it's only used in generated code (EU1006), is a field tagged for serialization (EU1009),
+//     a constant only used in switch cases (EU1012), a function matching a standard library one by name
+//     and signature (EU1013), declared in a generated file,
+//     or declared in a file with build constraints, as it may be used in other builds,
+//   - high otherwise.
+func (r *runner) confidence(f Finding) (Confidence, error) {
+	switch f.Code {
+	case codeDynamic:
+		return ConfidenceLow, nil
+	case codeGenerated, codeTagged, codeCaseOnly, codeStdlib:
+		return ConfidenceMedium, nil
+	}
+	generated, err := r.isGenerated(lsp.DocumentURI(r.client.documentURI(f.Filename)))
+	if err != nil {
+		return "", err
+	}
+	if generated {
+		return ConfidenceMedium, nil
+	}
+	src, err := r.files.source(f.Filename)
+	if err != nil {
+		return "", err
+	}
+	if src.hasBuildConstraint() {
+		return ConfidenceMedium, nil
+	}
+	return ConfidenceHigh, nil
+}
+
+// hasBuildConstraint reports whether the file has a //go:build or // +build line.
+func (f *sourceFile) hasBuildConstraint() bool {
+	for _, cg := range f.file.Comments {
+		if cg.Pos() > f.file.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if constraint.IsGoBuild(c.Text) || constraint.IsPlusBuild(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
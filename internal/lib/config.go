@@ -0,0 +1,263 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFilename is the name of the config file looked for in the workspace root.
+const ConfigFilename = ".punused.yaml"
+
+// Config is the content of the config file.
+type Config struct {
+	// Severity maps check codes (e.g. EU1001) to a severity.
+	// Checks not listed default to warning.
+	Severity map[string]Severity `yaml:"severity"`
+
+	// Thresholds maps filename globs to the number of findings allowed in the matching files, e.g.
+	//
+	//	thresholds:
+	//	  "pkg/legacy/**":
+	//	    max-findings: 50
+	Thresholds map[string]Threshold `yaml:"thresholds"`
+
+	// Hints maps check codes to the hints printed with their findings, e.g. "Ask #api-owners before deleting it.",
+	// replacing the default ones, or, if empty, leaving them out.
+	Hints map[string]string `yaml:"hints"`
+
+	// FailOn lists check codes, e.g. EU1002, whose findings fail the run whatever their severity.
+	FailOn []string `yaml:"fail-on"`
+
+	// EntryPoints lists globs matching package directories whose exported symbols
+	// are entry points and never reported, e.g. "cmd/**" or "magefiles".
+	EntryPoints []string `yaml:"entrypoints"`
+
+	// TestPackages lists globs matching package directories of test infrastructure, e.g. "internal/fakes",
+	// whose exported symbols used in test only are not reported, see RunConfig.TestPackages.
+	TestPackages []string `yaml:"test-packages"`
+
+	// Files lists the filename patterns to check, as given as arguments, e.g. "!**/*_gen.go".
+	// Arguments override it.
+	Files []string `yaml:"files"`
+
+	// Exclude lists globs matching directories not analyzed, e.g. "third_party/**".
+	Exclude []string `yaml:"exclude"`
+
+	// VendoredForks lists globs matching the paths of vendored modules patched locally, e.g. "github.com/acme/*",
+	// analyzed with -include-vendored.
+	VendoredForks []string `yaml:"vendored-forks"`
+
+	// SkipHeaders lists regular expressions matching the header comments of files not analyzed,
+	// e.g. the license headers of copied third-party code.
+	SkipHeaders []string `yaml:"skip-headers"`
+
+	// Keep lists regular expressions matching the names of symbols never reported, e.g. "^Must",
+	// optionally scoped to package directories with a glob prefix, e.g. "pkg/api/...=^New".
+	Keep []string `yaml:"keep"`
+
+	// Kinds lists the kinds of symbols to check, e.g. function, field or type, or, prefixed with !, not to check,
+	// e.g. !const. All are checked if not set.
+	Kinds []string `yaml:"kinds"`
+
+	// Fix, if set, applies the fixes as with -fix, unless the flag is set.
+	Fix *bool `yaml:"fix"`
+
+	// DeadTargets lists globs matching package directories of deprecated or excluded
+	// build targets, e.g. "cmd/legacy-*", whose references do not count as usage.
+	DeadTargets []string `yaml:"dead-targets"`
+
+	// WeakRefs lists globs matching the files whose references are weak usage, e.g. "examples/**",
+	// reported as EU1014 when a symbol has no others.
+	WeakRefs []string `yaml:"weak-refs"`
+
+	// Receivers lists globs matching receiver types, as package name and type name,
+	// whose methods are never reported, e.g. "ent.*" or "*_gen.*".
+	Receivers []string `yaml:"receivers"`
+
+	// DynamicUsage configures the heuristics for symbols used by name or via reflection, see EU1008.
+	DynamicUsage DynamicUsage `yaml:"dynamic-usage"`
+
+	// EmbeddedTemplates lists globs matching the files embedded with //go:embed that are code templates,
+	// e.g. "**/*.go.tmpl", whose identifiers count as usage.
+	EmbeddedTemplates []string `yaml:"embedded-templates"`
+
+	// Downstream lists the consumers of the API, directories or module paths, e.g. "../app" or
+	// "github.com/acme/app@latest", whose references count as usage, see RunConfig.Downstream.
+	Downstream []string `yaml:"downstream"`
+
+	// IssueLink is a Go template rendered into a link for each finding to the
+	// team's issue tracker, e.g. "https://jira/browse?text={{ .Symbol.Name | urlquery }}".
+	IssueLink string `yaml:"issue-link"`
+
+	// SourceLink is a Go template rendered into a link for each finding to its declaration in the
+	// repository browser, e.g. "https://github.com/acme/app/blob/main/{{ .Symbol.Filename }}#L{{ .Symbol.Line }}".
+	SourceLink string `yaml:"source-link"`
+
+	// CodePrefix replaces the EU prefix of the check codes in the output, e.g. ACME for ACME1002 instead of EU1002.
+	// The codes are accepted with either prefix in the config and the suppression directives.
+	CodePrefix string `yaml:"code-prefix"`
+
+	// GoVersion is the Go language version to analyze the workspace with, e.g. 1.18, as with -go-version.
+	GoVersion string `yaml:"go-version"`
+
+	// MainPackages is the policy for the exported symbols in package main, one of check, skip or unexported-rule,
+	// as with -main-packages.
+	MainPackages string `yaml:"main-packages"`
+
+	// EnumPolicy is the policy for the constants of an enum, one of each or any-used, as with -enum-policy.
+	EnumPolicy string `yaml:"enum-policy"`
+}
+
+// DynamicUsage configures the heuristics for symbols used by name or via reflection.
+type DynamicUsage struct {
+	// Names are globs matching the names of symbols used by name, e.g. "Handle*" or "(*Server).*".
+	Names []string `yaml:"names"`
+
+	// Calls are globs matching calls, as written, whose arguments' methods are used via reflection,
+	// e.g. "rpc.Register" or "*.Execute". If not set, the net/rpc and encoding/gob registrations
+	// and template executions are used.
+	Calls []string `yaml:"calls"`
+}
+
+// LoadConfig reads the config from filename.
+// A missing file is not an error, it returns an empty config.
+func LoadConfig(filename string) (Config, error) {
+	var conf Config
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := yaml.Unmarshal(b, &conf); err != nil {
+		return conf, fmt.Errorf("failed to parse config %s: %w", filename, err)
+	}
+	if err := conf.validate(); err != nil {
+		return conf, fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+	return conf, nil
+}
+
+func (conf Config) validate() error {
+	if err := validateSeverities(conf.Severity); err != nil {
+		return err
+	}
+	for code := range conf.Hints {
+		if !isCheckCode(code) {
+			return fmt.Errorf("hints: unknown check %q", code)
+		}
+	}
+	if err := validateFailOn(conf.FailOn); err != nil {
+		return err
+	}
+	if _, err := compileThresholds(conf.Thresholds); err != nil {
+		return err
+	}
+	if _, err := compileGlobs(conf.EntryPoints); err != nil {
+		return fmt.Errorf("entrypoints: %w", err)
+	}
+	if _, err := compileGlobs(conf.TestPackages); err != nil {
+		return fmt.Errorf("test-packages: %w", err)
+	}
+	if _, err := compileFilenamePatterns(conf.Files); err != nil {
+		return fmt.Errorf("files: %w", err)
+	}
+	if _, err := compileGlobs(conf.Exclude); err != nil {
+		return fmt.Errorf("exclude: %w", err)
+	}
+	if _, err := compileGlobs(conf.VendoredForks); err != nil {
+		return fmt.Errorf("vendored-forks: %w", err)
+	}
+	if _, err := compileHeaderMatchers(conf.SkipHeaders); err != nil {
+		return err
+	}
+	if _, err := newSymbolFilter(conf.Keep, conf.Kinds); err != nil {
+		return err
+	}
+	if _, err := compileGlobs(conf.DeadTargets); err != nil {
+		return fmt.Errorf("dead-targets: %w", err)
+	}
+	if _, err := compileGlobs(conf.WeakRefs); err != nil {
+		return fmt.Errorf("weak-refs: %w", err)
+	}
+	if _, err := compileGlobs(conf.Receivers); err != nil {
+		return fmt.Errorf("receivers: %w", err)
+	}
+	if _, err := newDynamicUsage(conf.DynamicUsage.Names, conf.DynamicUsage.Calls); err != nil {
+		return err
+	}
+	if _, err := compileGlobs(conf.EmbeddedTemplates); err != nil {
+		return fmt.Errorf("embedded-templates: %w", err)
+	}
+	if _, err := compileSourceLink(conf.SourceLink); err != nil {
+		return err
+	}
+	if _, err := compileIssueLink(conf.IssueLink); err != nil {
+		return err
+	}
+	if err := validateCodePrefix(conf.CodePrefix); err != nil {
+		return err
+	}
+	if err := validateGoVersion(conf.GoVersion); err != nil {
+		return fmt.Errorf("go-version: %w", err)
+	}
+	if err := validateMainPackages(conf.MainPackages); err != nil {
+		return fmt.Errorf("main-packages: %w", err)
+	}
+	if err := validateEnumPolicy(conf.EnumPolicy); err != nil {
+		return fmt.Errorf("enum-policy: %w", err)
+	}
+	return nil
+}
+
+// canonicalCodes returns codes with the EU prefix, see canonicalCode.
+func canonicalCodes(codes []string) []string {
+	if codes == nil {
+		return nil
+	}
+	canonical := make([]string, len(codes))
+	for i, code := range codes {
+		canonical[i] = canonicalCode(code)
+	}
+	return canonical
+}
+
+// canonicalKeys returns m keyed by the codes with the EU prefix, see canonicalCode.
+func canonicalKeys[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	canonical := make(map[string]V, len(m))
+	for code, v := range m {
+		canonical[canonicalCode(code)] = v
+	}
+	return canonical
+}
+
+func validateFailOn(codes []string) error {
+	for _, code := range codes {
+		if !isCheckCode(code) {
+			return fmt.Errorf("fail-on: unknown check %q", code)
+		}
+	}
+	return nil
+}
+
+func validateSeverities(m map[string]Severity) error {
+	for code, sev := range m {
+		if !isCheckCode(code) {
+			return fmt.Errorf("severity: unknown check %q", code)
+		}
+		switch sev {
+		case SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return fmt.Errorf("severity: invalid severity %q for %s, must be one of error, warning or info", sev, code)
+		}
+	}
+	return nil
+}
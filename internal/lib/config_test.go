@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLoadConfig(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(content string) string {
+		filename := filepath.Join(dir, ConfigFilename)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o644), qt.IsNil)
+		return filename
+	}
+
+	conf, err := LoadConfig(filepath.Join(dir, "missing.yaml"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.Severity, qt.IsNil)
+
+	conf, err = LoadConfig(write("severity:\n  EU1001: info\n  EU1002: error\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.Severity, qt.DeepEquals, map[string]Severity{codeTestOnly: SeverityInfo, codeUnused: SeverityError})
+
+	_, err = LoadConfig(write("severity:\n  EU9999: info\n"))
+	c.Assert(err, qt.ErrorMatches, `.*unknown check "EU9999"`)
+
+	_, err = LoadConfig(write("severity:\n  EU1001: fatal\n"))
+	c.Assert(err, qt.ErrorMatches, `.*invalid severity "fatal".*`)
+
+	conf, err = LoadConfig(write("code-prefix: ACME\nfail-on: [ACME1002]\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.CodePrefix, qt.Equals, "ACME")
+
+	_, err = LoadConfig(write("code-prefix: acme-\n"))
+	c.Assert(err, qt.ErrorMatches, `.*code-prefix: "acme-" must only contain the letters A to Z`)
+
+	_, err = LoadConfig(write("hints:\n  EU9999: Delete it.\n"))
+	c.Assert(err, qt.ErrorMatches, `.*hints: unknown check "EU9999"`)
+
+	_, err = LoadConfig(write("source-link: \"https://example.com/{{ .Symbol.Filename\"\n"))
+	c.Assert(err, qt.ErrorMatches, `.*invalid source link template.*`)
+
+	conf, err = LoadConfig(write("main-packages: unexported-rule\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.MainPackages, qt.Equals, "unexported-rule")
+
+	_, err = LoadConfig(write("main-packages: ignore\n"))
+	c.Assert(err, qt.ErrorMatches, `.*main-packages: invalid main packages policy "ignore".*`)
+}
+
+func TestHints(t *testing.T) {
+	c := qt.New(t)
+
+	hints := canonicalKeys(map[string]string{"ACME1002": "Ask #api first.", "EU1008": ""})
+	r := &runner{cfg: RunConfig{Hints: hints}}
+	for _, f := range []Finding{{Name: "A", Code: codeUnused}, {Name: "B", Code: codeTestOnly}, {Name: "C", Code: codeDynamic}} {
+		c.Assert(r.report(f), qt.IsNil)
+	}
+	c.Assert(r.findings[0].Hint, qt.Equals, "Ask #api first.")
+	c.Assert(r.findings[1].Hint, qt.Matches, "Move it to a _test.go file.*")
+	c.Assert(r.findings[2].Hint, qt.Equals, "")
+
+	for _, check := range checks {
+		c.Assert(check.Hint, qt.Not(qt.Equals), "", qt.Commentf(check.Code))
+	}
+}
+
+func TestCodePrefix(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(prefixedCode("", codeUnused), qt.Equals, "EU1002")
+	c.Assert(prefixedCode("ACME", codeUnused), qt.Equals, "ACME1002")
+	c.Assert(canonicalCode("ACME1002"), qt.Equals, codeUnused)
+	c.Assert(canonicalCode(codeUnused), qt.Equals, codeUnused)
+	c.Assert(isCheckCode("ACME1001"), qt.IsTrue)
+	c.Assert(isCheckCode("ACME9999"), qt.IsFalse)
+	c.Assert(suppresses("//punused:ignore ACME1002 kept for v2", codeUnused), qt.IsTrue)
+	c.Assert(suppresses("//punused:ignore ACME1001", codeUnused), qt.IsFalse)
+	c.Assert(Finding{Code: "ACME1001"}.Message(), qt.Equals, "is used in test only")
+	c.Assert(Finding{Code: "ACME1001", Name: "F"}.Fingerprint(), qt.Equals, Finding{Code: codeTestOnly, Name: "F"}.Fingerprint())
+}
+
+func TestSymbolFilter(t *testing.T) {
+	c := qt.New(t)
+
+	sf, err := newSymbolFilter([]string{"^Must", `^\(\*Server\)\.`}, []string{"function", "method"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(sf.kept("MustParse", "p/p.go"), qt.IsTrue)
+	c.Assert(sf.kept("(*Server).Handle", "p/p.go"), qt.IsTrue)
+	c.Assert(sf.kept("(Server).Handle", "p/p.go"), qt.IsFalse)
+	c.Assert(sf.checks("method"), qt.IsTrue)
+	c.Assert(sf.checks("field"), qt.IsFalse)
+
+	sf, err = newSymbolFilter([]string{"pkg/api/...=^New", "internal/*=^Must"}, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(sf.kept("NewClient", "pkg/api/api.go"), qt.IsTrue)
+	c.Assert(sf.kept("NewClient", "pkg/api/v2/api.go"), qt.IsTrue)
+	c.Assert(sf.kept("NewClient", "pkg/other/other.go"), qt.IsFalse)
+	c.Assert(sf.kept("MustParse", "internal/p/p.go"), qt.IsTrue)
+	c.Assert(sf.kept("MustParse", "p.go"), qt.IsFalse)
+
+	sf, err = newSymbolFilter(nil, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(sf.checks("field"), qt.IsTrue)
+
+	_, err = newSymbolFilter([]string{"("}, nil)
+	c.Assert(err, qt.ErrorMatches, `keep: invalid regular expression.*`)
+	sf, err = newSymbolFilter(nil, []string{"type", "func"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(sf.checks("interface"), qt.IsTrue)
+	c.Assert(sf.checks("function"), qt.IsTrue)
+	c.Assert(sf.checks("method"), qt.IsFalse)
+
+	sf, err = newSymbolFilter(nil, []string{"!const", "!field"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(sf.checks("constant"), qt.IsFalse)
+	c.Assert(sf.checks("field"), qt.IsFalse)
+	c.Assert(sf.checks("method"), qt.IsTrue)
+
+	_, err = newSymbolFilter(nil, []string{"fn"})
+	c.Assert(err, qt.ErrorMatches, `kinds: unknown kind "fn".*`)
+}
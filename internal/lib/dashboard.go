@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Chart dimensions of the dashboard, in pixels.
+const (
+	dashboardWidth, dashboardHeight = 640, 160
+	sparklineWidth, sparklineHeight = 160, 24
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>punused dashboard{{ with .Module }} {{ . }}{{ end }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+td.n { text-align: right; }
+svg { background: #f6f8fa; }
+.findings { stroke: #4a78c2; }
+.unused { stroke: #b00020; }
+.test-only { stroke: #a15c00; }
+polyline { fill: none; stroke-width: 2; }
+.legend span { display: inline-block; width: 1em; height: 0.3em; margin: 0 0.3em 0.2em 1em; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>punused dashboard{{ with .Module }} {{ . }}{{ end }}</h1>
+<p>{{ len .Runs }} runs, from {{ (index .Runs 0).Label }} to {{ .Last.Label }}.</p>
+<h2>Findings over time</h2>
+<svg width="{{ .Width }}" height="{{ .Height }}" role="img" aria-label="Findings over time">
+<polyline class="findings" points="{{ .Findings }}"/>
+<polyline class="unused" points="{{ .Unused }}"/>
+<polyline class="test-only" points="{{ .TestOnly }}"/>
+</svg>
+<p class="legend"><span style="background: #4a78c2"></span>findings<span style="background: #b00020"></span>unused<span style="background: #a15c00"></span>used in test only</p>
+<table>
+<tr><th>Run</th><th>Findings</th><th>Unused</th><th>Test only</th><th>Lines</th><th>Exported</th><th>Health score</th><th>New</th><th>Fixed</th></tr>
+{{ range .Runs }}<tr><td>{{ .Label }}</td><td class="n">{{ .Summary.Findings }}</td><td class="n">{{ .Summary.Unused }}</td><td class="n">{{ .Summary.TestOnly }}</td><td class="n">{{ .Summary.Lines }}</td><td class="n">{{ .Summary.Exported }}</td><td class="n">{{ printf "%.2f" .Summary.HealthScore }}</td><td class="n">{{ .Added }}</td><td class="n">{{ .Fixed }}</td></tr>
+{{ end }}</table>
+<h2>Packages</h2>
+<table>
+<tr><th>Package</th><th>Findings over time</th><th>First</th><th>Last</th><th>Change</th></tr>
+{{ range .Packages }}<tr><td>{{ .Package }}</td><td><svg width="{{ $.SparklineWidth }}" height="{{ $.SparklineHeight }}"><polyline class="findings" points="{{ .Points }}"/></svg></td><td class="n">{{ .First }}</td><td class="n">{{ .Last }}</td><td class="n">{{ printf "%+d" .Change }}</td></tr>
+{{ end }}</table>
+</body>
+</html>
+`))
+
+// dashboardRun is a run of the dashboard, with the number of findings new and fixed since the previous one.
+type dashboardRun struct {
+	Label        string
+	Summary      Summary
+	Added, Fixed int
+}
+
+// dashboardPackage is the number of findings of a package directory in each run of the dashboard.
+type dashboardPackage struct {
+	Package     string
+	Counts      []int
+	First, Last int
+	Change      int
+	Points      string
+}
+
+// Dashboard writes a standalone HTML page to w comparing the JSON reports in filenames, e.g. the artifacts
+// of the CI runs written with -format=json or punused analyze, ordered by the time they were analyzed:
+// charts of the findings over time, overall and per package, and a table of the runs with the number of
+// findings new and fixed since the previous one.
+func Dashboard(w io.Writer, filenames ...string) error {
+	if len(filenames) == 0 {
+		return fmt.Errorf("no reports")
+	}
+	type run struct {
+		filename string
+		report   Report
+	}
+	runs := make([]run, len(filenames))
+	for i, filename := range filenames {
+		report, err := readJSONReport(filename)
+		if err != nil {
+			return err
+		}
+		runs[i] = run{filename, report}
+	}
+	timed := true
+	for _, r := range runs {
+		timed = timed && r.report.Run != nil
+	}
+	if timed {
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].report.Run.Started.Before(runs[j].report.Run.Started) })
+	}
+	// Else, e.g. with reports written by punused merge, in the order given.
+
+	var (
+		module   string
+		rows     []dashboardRun
+		packages = make(map[string]*dashboardPackage)
+		prev     map[string]bool
+	)
+	for i, r := range runs {
+		row := dashboardRun{Label: filepath.Base(r.filename), Summary: r.report.Summary}
+		if info := r.report.Run; info != nil {
+			row.Label = info.Started.Format("2006-01-02 15:04 MST")
+			module = info.Module
+		}
+		current := make(map[string]bool, len(r.report.Findings))
+		for _, f := range r.report.Findings {
+			current[f.Fingerprint()] = true
+			pkg := packageOf(f)
+			p := packages[pkg]
+			if p == nil {
+				p = &dashboardPackage{Package: pkg, Counts: make([]int, len(runs))}
+				packages[pkg] = p
+			}
+			p.Counts[i]++
+		}
+		if i > 0 {
+			for fp := range current {
+				if !prev[fp] {
+					row.Added++
+				}
+			}
+			for fp := range prev {
+				if !current[fp] {
+					row.Fixed++
+				}
+			}
+		}
+		rows = append(rows, row)
+		prev = current
+	}
+
+	var (
+		findings, unused, testOnly = make([]int, len(rows)), make([]int, len(rows)), make([]int, len(rows))
+		max                        int
+	)
+	for i, row := range rows {
+		findings[i], unused[i], testOnly[i] = row.Summary.Findings, row.Summary.Unused, row.Summary.TestOnly
+		if row.Summary.Findings > max {
+			max = row.Summary.Findings
+		}
+	}
+
+	sorted := make([]dashboardPackage, 0, len(packages))
+	for _, p := range packages {
+		p.First, p.Last = p.Counts[0], p.Counts[len(p.Counts)-1]
+		p.Change = p.Last - p.First
+		p.Points = polylinePoints(p.Counts, slices.Max(p.Counts), sparklineWidth, sparklineHeight)
+		sorted = append(sorted, *p)
+	}
+	// The packages with the most findings left first.
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Last != b.Last {
+			return a.Last > b.Last
+		}
+		return a.Package < b.Package
+	})
+
+	return dashboardTemplate.Execute(w, struct {
+		Module                          string
+		Runs                            []dashboardRun
+		Last                            dashboardRun
+		Width, Height                   int
+		Findings, Unused, TestOnly      string
+		SparklineWidth, SparklineHeight int
+		Packages                        []dashboardPackage
+	}{
+		module, rows, rows[len(rows)-1],
+		dashboardWidth, dashboardHeight,
+		polylinePoints(findings, max, dashboardWidth, dashboardHeight),
+		polylinePoints(unused, max, dashboardWidth, dashboardHeight),
+		polylinePoints(testOnly, max, dashboardWidth, dashboardHeight),
+		sparklineWidth, sparklineHeight,
+		sorted,
+	})
+}
+
+// polylinePoints returns the points of an SVG polyline charting values, scaled to max, in a width by height chart
+// with a margin of 2 pixels, e.g. "2,158 638,2".
+func polylinePoints(values []int, max, width, height int) string {
+	const margin = 2
+	points := make([]string, len(values))
+	for i, v := range values {
+		x := width / 2
+		if len(values) > 1 {
+			x = margin + i*(width-2*margin)/(len(values)-1)
+		}
+		y := height - margin
+		if max > 0 {
+			y -= v * (height - 2*margin) / max
+		}
+		points[i] = fmt.Sprintf("%d,%d", x, y)
+	}
+	return strings.Join(points, " ")
+}
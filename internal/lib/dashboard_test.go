@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDashboard(t *testing.T) {
+	c := qt.New(t)
+
+	dir := c.TempDir()
+	write := func(name string, started time.Time, findings ...Finding) string {
+		var b bytes.Buffer
+		c.Assert(writeJSON(&b, Report{
+			Run:      &RunInfo{Module: "example.com/p", Started: started},
+			Summary:  summarize(findings, 10),
+			Findings: findings,
+		}), qt.IsNil)
+		filename := filepath.Join(dir, name)
+		c.Assert(os.WriteFile(filename, b.Bytes(), 0o644), qt.IsNil)
+		return filename
+	}
+	a := Finding{Filename: "p/a.go", Line: 3, Column: 6, Kind: "function", Name: "A", Code: codeUnused}
+	first := write("first.json", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		a,
+		Finding{Filename: "p/c.go", Line: 3, Column: 6, Kind: "function", Name: "C", Code: codeUnused},
+		Finding{Filename: "p/d.go", Line: 3, Column: 6, Kind: "function", Name: "D", Code: codeTestOnly},
+	)
+	second := write("second.json", time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC),
+		a,
+		Finding{Filename: "q/b.go", Line: 3, Column: 6, Kind: "function", Name: "B", Code: codeTestOnly},
+	)
+
+	var out bytes.Buffer
+	// Ordered by the time they were analyzed.
+	c.Assert(Dashboard(&out, second, first), qt.IsNil)
+	s := out.String()
+	c.Assert(s, qt.Contains, "<title>punused dashboard example.com/p</title>")
+	c.Assert(s, qt.Contains, "2 runs, from 2024-01-01 12:00 UTC to 2024-01-02 12:00 UTC.")
+	c.Assert(s, qt.Contains, `<polyline class="findings" points="2,2 638,54"/>`)
+	c.Assert(s, qt.Contains, `<polyline class="unused" points="2,54 638,106"/>`)
+	// 1 new finding and 2 fixed since the first run.
+	c.Assert(s, qt.Contains, `<td>2024-01-02 12:00 UTC</td><td class="n">2</td><td class="n">1</td><td class="n">1</td><td class="n">0</td><td class="n">10</td><td class="n">80.00</td><td class="n">1</td><td class="n">2</td>`)
+	c.Assert(s, qt.Contains, `<td>p</td><td><svg width="160" height="24"><polyline class="findings" points="2,2 158,16"/></svg></td><td class="n">3</td><td class="n">1</td><td class="n">-2</td>`)
+	c.Assert(s, qt.Contains, `<td class="n">0</td><td class="n">1</td><td class="n">&#43;1</td>`)
+	c.Assert(strings.Index(s, "<td>p</td>") < strings.Index(s, "<td>q</td>"), qt.IsTrue)
+
+	c.Assert(Dashboard(&out), qt.ErrorMatches, "no reports")
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"path"
+	"sort"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// deadTarget returns the package directory, relative to the workspace, of the file at uri if it
+// matches one of the configured dead targets, else "".
+func (r *runner) deadTarget(uri lsp.DocumentURI) string {
+	if len(r.deadTargets) == 0 {
+		return ""
+	}
+	rel, ok := r.workspacePath(uri)
+	if !ok {
+		return ""
+	}
+	if dir := path.Dir(rel); r.deadTargets.Match(dir) {
+		return dir
+	}
+	return ""
+}
+
+// withoutDeadTargets returns the references not in dead targets and the sorted package directories
+// of the dead targets referencing the symbol declared in uri.
+// References from within a dead target to its own symbols count as usage.
+func (r *runner) withoutDeadTargets(uri lsp.DocumentURI, refs []*lsp.Location) ([]*lsp.Location, []string) {
+	if len(r.deadTargets) == 0 || r.deadTarget(uri) != "" {
+		return refs, nil
+	}
+	var (
+		live    []*lsp.Location
+		targets []string
+		seen    = make(map[string]bool)
+	)
+	for _, ref := range refs {
+		target := r.deadTarget(ref.URI)
+		if target == "" {
+			live = append(live, ref)
+			continue
+		}
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return live, targets
+}
@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// minGoplsVersion is the oldest gopls known to support the features we need,
+// e.g. hierarchical document symbols.
+const minGoplsVersion = "v0.7.0"
+
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkOK:
+		return "ok"
+	case checkWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+type checkResult struct {
+	name    string
+	status  checkStatus
+	message string
+
+	// remedy describes how to fix a failed check.
+	remedy string
+}
+
+// Doctor checks the environment punused runs in and prints the results with remediation
+// steps to w. It returns an error if any required check failed.
+func Doctor(w io.Writer, workspaceDir string) error {
+	results := []checkResult{
+		checkGo(),
+		checkGopls(),
+		checkWorkspace(workspaceDir),
+		checkModuleLoads(workspaceDir),
+	}
+
+	var failed int
+	for _, r := range results {
+		fmt.Fprintf(w, "%-4s  %s: %s\n", r.status, r.name, r.message)
+		if r.status != checkOK && r.remedy != "" {
+			fmt.Fprintf(w, "      %s\n", r.remedy)
+		}
+		if r.status == checkFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+func checkGo() checkResult {
+	r := checkResult{name: "go"}
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		r.status = checkFail
+		r.message = "go not found in PATH"
+		r.remedy = "Install Go from https://go.dev/dl/"
+		return r
+	}
+	r.message = strings.TrimSpace(string(out))
+	return r
+}
+
+func checkGopls() checkResult {
+	r := checkResult{name: "gopls"}
+	binary := goplsBinary("")
+	filename, err := exec.LookPath(binary)
+	if err != nil {
+		r.status = checkFail
+		r.message = fmt.Sprintf("%s not found", binary)
+		r.remedy = "Install it with: go install golang.org/x/tools/gopls@latest, or set " + GoplsEnv + " to its path"
+		return r
+	}
+
+	version := goplsVersion(filename)
+	r.message = fmt.Sprintf("%s (%s)", version, filename)
+	switch {
+	case version == "":
+		r.status = checkFail
+		r.message = fmt.Sprintf("failed to get version of %s", filename)
+		r.remedy = "Reinstall it with: go install golang.org/x/tools/gopls@latest"
+	case !strings.HasPrefix(version, "v"):
+		// E.g. (devel).
+		r.status = checkWarn
+		r.remedy = fmt.Sprintf("Unable to verify the version; punused requires gopls %s or later", minGoplsVersion)
+	case validateGoplsVersion(version) != nil:
+		r.status = checkFail
+		r.remedy = fmt.Sprintf("punused requires gopls %s or later, before %s, install one with: go install golang.org/x/tools/gopls@%s", minGoplsVersion, maxGoplsVersion, pinnedGoplsVersion)
+	}
+	return r
+}
+
+func checkWorkspace(dir string) checkResult {
+	r := checkResult{name: "workspace"}
+	var found []string
+	for _, name := range []string{"go.mod", "go.work"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		}
+	}
+	if len(found) == 0 {
+		r.status = checkFail
+		r.message = fmt.Sprintf("no go.mod or go.work in %s", dir)
+		r.remedy = "Run punused from the root of a Go module"
+		return r
+	}
+	r.message = fmt.Sprintf("%s found in %s", strings.Join(found, " and "), dir)
+	return r
+}
+
+func checkModuleLoads(dir string) checkResult {
+	r := checkResult{name: "packages"}
+	cmd := exec.Command("go", "list", "./...")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		r.status = checkFail
+		r.message = "go list ./... failed: " + firstLine(stderr.String())
+		r.remedy = "Fix the build errors (run go list ./... for details); findings in packages that don't load are unreliable"
+		return r
+	}
+	r.message = fmt.Sprintf("%d packages load cleanly", bytes.Count(out, []byte("\n")))
+	return r
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// compareVersions compares two semantic versions on the form vMAJOR.MINOR.PATCH,
+// ignoring any pre-release suffix, and returns -1, 0 or 1.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) [3]int {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	for i, s := range strings.SplitN(v, ".", 3) {
+		parts[i], _ = strconv.Atoi(s)
+	}
+	return parts
+}
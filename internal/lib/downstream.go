@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// downstreamRefs maps the import paths of the packages imported by the downstream consumers, see RunConfig.Downstream,
+// to the identifiers selected, e.g. Name in pkg.Name or v.Name, in the files importing them.
+// Identifiers are matched by name, so a symbol may be kept by a same-named one of another type, never the reverse.
+type downstreamRefs map[string]map[string]bool
+
+// used reports whether the identifier name of the package importPath is referenced downstream.
+func (d downstreamRefs) used(importPath, name string) bool {
+	return d[importPath][name]
+}
+
+// loadDownstreamRefs collects the references of the consumers, directories, relative to the workspace dir,
+// or module paths, optionally with a version, e.g. github.com/acme/app@v1.2.0, fetched with go get.
+func loadDownstreamRefs(ctx context.Context, dir string, consumers []string) (downstreamRefs, error) {
+	refs := make(downstreamRefs)
+	for _, consumer := range consumers {
+		consumerDir, cleanup, err := downstreamDir(ctx, dir, consumer)
+		if err != nil {
+			return nil, fmt.Errorf("downstream %s: %w", consumer, err)
+		}
+		err = refs.collect(consumerDir)
+		cleanup()
+		if err != nil {
+			return nil, fmt.Errorf("downstream %s: %w", consumer, err)
+		}
+	}
+	return refs, nil
+}
+
+// downstreamDir returns the directory of consumer: A directory, relative to dir, or else a module fetched with go get
+// into a temporary module, removed by cleanup, and read from the module cache.
+func downstreamDir(ctx context.Context, dir, consumer string) (string, func(), error) {
+	consumerDir := consumer
+	if !filepath.IsAbs(consumerDir) {
+		consumerDir = filepath.Join(dir, consumerDir)
+	}
+	if fi, err := os.Stat(consumerDir); err == nil && fi.IsDir() {
+		return consumerDir, func() {}, nil
+	}
+
+	mod, version, found := strings.Cut(consumer, "@")
+	if !found {
+		version = "latest"
+	}
+	tmp, err := os.MkdirTemp("", "punused-downstream")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	goCmd := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "go", args...)
+		cmd.Dir = tmp
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("go %s: %s", strings.Join(args, " "), firstLine(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	if _, err := goCmd("mod", "init", "punused.local/downstream"); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := goCmd("get", mod+"@"+version); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	moduleDir, err := goCmd("list", "-m", "-f", "{{.Dir}}", mod)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return moduleDir, cleanup, nil
+}
+
+// collect adds the references of the Go files in dir, test files included.
+// Hidden directories, testdata and vendor are left out, as by the go tool.
+func (d downstreamRefs) collect(dir string) error {
+	return filepath.WalkDir(dir, func(filename string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.IsDir() {
+			if name := de.Name(); filename != dir && (strings.HasPrefix(name, ".") || name == "testdata" || name == vendorDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(filename, ".go") {
+			return nil
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			// Not our code to fix.
+			return nil
+		}
+		d.collectFile(file)
+		return nil
+	})
+}
+
+func (d downstreamRefs) collectFile(file *ast.File) {
+	var (
+		imported []string
+		dotted   bool
+	)
+	for _, spec := range file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		imported = append(imported, p)
+		dotted = dotted || spec.Name != nil && spec.Name.Name == "."
+	}
+	if len(imported) == 0 {
+		return
+	}
+	names := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			names[n.Sel.Name] = true
+		case *ast.KeyValueExpr:
+			// A field in a composite literal, e.g. pkg.Options{Name: "a"}.
+			if id, ok := n.Key.(*ast.Ident); ok {
+				names[id.Name] = true
+			}
+		case *ast.Ident:
+			if dotted {
+				names[n.Name] = true
+			}
+		}
+		return true
+	})
+	for _, p := range imported {
+		if d[p] == nil {
+			d[p] = make(map[string]bool)
+		}
+		for name := range names {
+			d[p][name] = true
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDownstreamRefs(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o777), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o666), qt.IsNil)
+	}
+	write("lib/lib.go", "package lib\n")
+	write("app/main.go", `package main
+
+import (
+	"example.com/lib/api"
+	. "example.com/lib/dot"
+)
+
+func main() {
+	s := api.NewServer(api.Options{Name: "a"})
+	s.Serve()
+	Helper()
+}
+`)
+	write("app/main_test.go", "package main\n\nimport \"example.com/lib/api\"\n\nvar _ = api.TestOnly\n")
+	write("app/testdata/skipped.go", "package p\n\nimport \"example.com/lib/api\"\n\nvar _ = api.Skipped\n")
+
+	refs, err := loadDownstreamRefs(context.Background(), filepath.Join(dir, "lib"), []string{"../app"})
+	c.Assert(err, qt.IsNil)
+	for _, name := range []string{"NewServer", "Options", "Name", "Serve", "TestOnly"} {
+		c.Assert(refs.used("example.com/lib/api", name), qt.IsTrue, qt.Commentf(name))
+	}
+	c.Assert(refs.used("example.com/lib/api", "Skipped"), qt.IsFalse)
+	c.Assert(refs.used("example.com/lib/api", "Unused"), qt.IsFalse)
+	c.Assert(refs.used("example.com/lib/dot", "Helper"), qt.IsTrue)
+	c.Assert(refs.used("example.com/lib/other", "NewServer"), qt.IsFalse)
+
+	var none downstreamRefs
+	c.Assert(none.used("example.com/lib/api", "NewServer"), qt.IsFalse)
+}
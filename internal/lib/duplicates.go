@@ -0,0 +1,253 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+)
+
+// minDuplicateTokens is the minimum number of tokens in a function body to compare it,
+// as short bodies, e.g. a single return statement, are often alike for good reasons.
+const minDuplicateTokens = 20
+
+// exportedFunc is an exported top level function compared by reportDuplicates.
+type exportedFunc struct {
+	filename     string
+	name         string
+	line, column int
+	signature    string
+
+	// trigrams counts the sequences of three tokens in the body.
+	trigrams map[string]int
+	n        int
+}
+
+func (fn *exportedFunc) location() string {
+	return fmt.Sprintf("%s:%d:%d", fn.filename, fn.line, fn.column)
+}
+
+// reportDuplicates reports the exported functions with the same signature as, and a body at least
+// DuplicateThreshold similar to, an exported function in another package (EU1010).
+func (r *runner) reportDuplicates() error {
+	bySignature := make(map[string][]*exportedFunc)
+	for _, filename := range r.walked {
+		filename = strings.TrimPrefix(strings.TrimPrefix(filename, r.prefix), "/")
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		src, err := r.files.source(filename)
+		if err != nil {
+			return err
+		}
+		for _, decl := range src.file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil || fd.Body == nil || !fd.Name.IsExported() {
+				continue
+			}
+			fn := &exportedFunc{
+				filename:  filename,
+				name:      fd.Name.Name,
+				line:      src.fset.Position(fd.Name.Pos()).Line,
+				column:    src.fset.Position(fd.Name.Pos()).Column,
+				signature: funcSignature(fd.Type),
+			}
+			fn.trigrams, fn.n = tokenTrigrams(src.content[src.offset(fd.Body.Lbrace):src.offset(fd.Body.End())], localNames(fd))
+			if fn.n < minDuplicateTokens {
+				continue
+			}
+			bySignature[fn.signature] = append(bySignature[fn.signature], fn)
+		}
+	}
+
+	duplicates := make(map[*exportedFunc][]*exportedFunc)
+	for _, funcs := range bySignature {
+		for i, a := range funcs {
+			for _, b := range funcs[i+1:] {
+				if path.Dir(a.filename) == path.Dir(b.filename) {
+					continue
+				}
+				if similarity(a, b) >= r.cfg.DuplicateThreshold {
+					duplicates[a] = append(duplicates[a], b)
+					duplicates[b] = append(duplicates[b], a)
+				}
+			}
+		}
+	}
+
+	funcs := make([]*exportedFunc, 0, len(duplicates))
+	for fn := range duplicates {
+		funcs = append(funcs, fn)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].filename != funcs[j].filename {
+			return funcs[i].filename < funcs[j].filename
+		}
+		return funcs[i].line < funcs[j].line
+	})
+	for _, fn := range funcs {
+		suppressed, err := r.isSuppressed(fn.filename, fn.line, fn.name, codeDuplicate)
+		if err != nil {
+			return err
+		}
+		if suppressed || r.symbols.kept(fn.name, fn.filename) || !r.symbols.checks("function") {
+			continue
+		}
+		f := Finding{
+			Filename:  fn.filename,
+			Line:      fn.line,
+			Column:    fn.column,
+			Kind:      "function",
+			Name:      fn.name,
+			Code:      codeDuplicate,
+			Signature: fn.signature,
+		}
+		for _, dup := range duplicates[fn] {
+			f.Duplicates = append(f.Duplicates, dup.location())
+		}
+		sort.Strings(f.Duplicates)
+		if err := r.reportFinding(f, fn.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// funcSignature returns the signature of a function without the parameter names, e.g. func(string, int) error.
+// The type parameters keep their names.
+func funcSignature(ft *ast.FuncType) string {
+	fields := func(fl *ast.FieldList) []string {
+		if fl == nil {
+			return nil
+		}
+		var s []string
+		for _, field := range fl.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				s = append(s, types.ExprString(field.Type))
+			}
+		}
+		return s
+	}
+	var b strings.Builder
+	b.WriteString("func")
+	if ft.TypeParams != nil {
+		// The parameters refer to the type parameters by name.
+		var tparams []string
+		for _, field := range ft.TypeParams.List {
+			for _, id := range field.Names {
+				tparams = append(tparams, id.Name+" "+types.ExprString(field.Type))
+			}
+		}
+		b.WriteString("[" + strings.Join(tparams, ", ") + "]")
+	}
+	b.WriteString("(" + strings.Join(fields(ft.Params), ", ") + ")")
+	switch results := fields(ft.Results); len(results) {
+	case 0:
+	case 1:
+		b.WriteString(" " + results[0])
+	default:
+		b.WriteString(" (" + strings.Join(results, ", ") + ")")
+	}
+	return b.String()
+}
+
+// localNames maps the names of the parameters and the variables declared in the body of fd
+// to placeholders by order of declaration, so renaming them does not make bodies differ.
+func localNames(fd *ast.FuncDecl) map[string]string {
+	names := make(map[string]string)
+	add := func(id *ast.Ident) {
+		if id != nil && id.Name != "_" && names[id.Name] == "" {
+			names[id.Name] = fmt.Sprintf("$%d", len(names))
+		}
+	}
+	for _, fl := range []*ast.FieldList{fd.Type.Params, fd.Type.Results} {
+		if fl == nil {
+			continue
+		}
+		for _, field := range fl.List {
+			for _, id := range field.Names {
+				add(id)
+			}
+		}
+	}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, lhs := range n.Lhs {
+					id, _ := lhs.(*ast.Ident)
+					add(id)
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				id, _ := n.Key.(*ast.Ident)
+				add(id)
+				id, _ = n.Value.(*ast.Ident)
+				add(id)
+			}
+		case *ast.ValueSpec:
+			for _, id := range n.Names {
+				add(id)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// tokenTrigrams returns the sequences of three tokens in src, ignoring comments, and the number of tokens.
+// The identifiers in rename are replaced, see localNames.
+func tokenTrigrams(src []byte, rename map[string]string) (map[string]int, int) {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+	var toks []string
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			// Inserted at the line ends.
+			continue
+		}
+		switch {
+		case lit == "":
+			lit = tok.String()
+		case tok == token.IDENT && rename[lit] != "":
+			lit = rename[lit]
+		}
+		toks = append(toks, lit)
+	}
+	trigrams := make(map[string]int)
+	for i := 0; i+2 < len(toks); i++ {
+		trigrams[toks[i]+" "+toks[i+1]+" "+toks[i+2]]++
+	}
+	return trigrams, len(toks)
+}
+
+// similarity returns how alike the bodies of a and b are, from 0 to 1 (the same tokens),
+// as the Dice coefficient of their token trigrams.
+func similarity(a, b *exportedFunc) float64 {
+	var common, total int
+	for t, n := range a.trigrams {
+		common += min(n, b.trigrams[t])
+		total += n
+	}
+	for _, n := range b.trigrams {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
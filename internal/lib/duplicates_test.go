@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDuplicateSimilarity(t *testing.T) {
+	c := qt.New(t)
+
+	parse := func(src string) (*ast.FuncDecl, *exportedFunc) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "", "package p\n\n"+src, 0)
+		c.Assert(err, qt.IsNil)
+		fd := file.Decls[0].(*ast.FuncDecl)
+		start, end := fset.Position(fd.Body.Lbrace).Offset, fset.Position(fd.Body.End()).Offset
+		fn := &exportedFunc{signature: funcSignature(fd.Type)}
+		fn.trigrams, fn.n = tokenTrigrams([]byte("package p\n\n" + src)[start:end], localNames(fd))
+		return fd, fn
+	}
+
+	_, a := parse(`func Sum(values []int, start int) (sum int) {
+	sum = start
+	for _, v := range values {
+		sum += v // Add.
+	}
+	return sum
+}`)
+	_, renamed := parse(`func Total(xs []int, initial int) int {
+	total := initial
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}`)
+	_, other := parse(`func Max(values []int, start int) int {
+	if len(values) == 0 {
+		return start
+	}
+	return values[0]
+}`)
+
+	c.Assert(a.signature, qt.Equals, "func([]int, int) int")
+	c.Assert(similarity(a, a), qt.Equals, 1.0)
+	c.Assert(similarity(a, renamed) > 0.8, qt.IsTrue, qt.Commentf("%v", similarity(a, renamed)))
+	c.Assert(similarity(a, other) < 0.5, qt.IsTrue, qt.Commentf("%v", similarity(a, other)))
+
+	fd, _ := parse(`func F[K comparable, V any](m map[K]V, keys ...K) (V, bool, error) { return *new(V), false, nil }`)
+	c.Assert(funcSignature(fd.Type), qt.Equals, "func[K comparable, V any](map[K]V, ...K) (V, bool, error)")
+}
@@ -0,0 +1,184 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// defaultDynamicCalls are the calls whose arguments' methods are typically invoked via reflection:
+// RPC services, gob registrations and template data.
+var defaultDynamicCalls = []string{
+	"rpc.Register",
+	"rpc.RegisterName",
+	"*.Register",
+	"gob.Register",
+	"gob.RegisterName",
+	"*.Execute",
+	"*.ExecuteTemplate",
+}
+
+// dynamicUsage detects symbols used by name or via reflection.
+type dynamicUsage struct {
+	names globs
+	calls globs
+
+	// receivers caches whether a receiver type, by its position, is passed to one of the calls.
+	receivers map[string]bool
+
+	// files caches the parsed files referencing the receiver types.
+	files map[lsp.DocumentURI]*parsedFile
+}
+
+type parsedFile struct {
+	fset *token.FileSet
+	file *ast.File
+}
+
+func newDynamicUsage(names, calls []string) (*dynamicUsage, error) {
+	if calls == nil {
+		calls = defaultDynamicCalls
+	}
+	d := &dynamicUsage{receivers: make(map[string]bool), files: make(map[lsp.DocumentURI]*parsedFile)}
+	var err error
+	if d.names, err = compileGlobs(names); err != nil {
+		return nil, fmt.Errorf("dynamic usage names: %w", err)
+	}
+	if d.calls, err = compileGlobs(calls); err != nil {
+		return nil, fmt.Errorf("dynamic usage calls: %w", err)
+	}
+	return d, nil
+}
+
+// isDynamicallyUsed reports whether the unused symbol s declared in filename is suspected to be used
+// by name or via reflection, i.e. its name matches one of the configured names, or it is a method
+// on a type passed to one of the configured calls, e.g. rpc.Register(new(Arith)).
+func (r *runner) isDynamicallyUsed(filename string, s *Symbol, base string) (bool, error) {
+	d := r.dynamic
+	if d.names.Match(s.Name) || d.names.Match(base) {
+		return true, nil
+	}
+	if s.Kind != lsp.SKMethod || len(d.calls) == 0 {
+		return false, nil
+	}
+
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	recv := receiverTypeIdent(src, s.Location.Range.Start.Line+1, base)
+	if recv == nil {
+		return false, nil
+	}
+	pos := src.fset.Position(recv.Pos())
+	key := fmt.Sprintf("%s:%d:%d", filename, pos.Line, pos.Column)
+	if used, found := d.receivers[key]; found {
+		return used, nil
+	}
+
+	loc := lsp.Location{URI: s.Location.URI, Range: lsp.Range{Start: lsp.Position{Line: pos.Line - 1, Character: pos.Column - 1}}}
+	refs, err := r.client.DocumentReferences(r.ctx, loc)
+	if err != nil {
+		return false, fmt.Errorf("failed to get references: %w", err)
+	}
+
+	used := false
+	for _, ref := range refs {
+		f, err := d.parse(ref.URI)
+		if err != nil {
+			return false, err
+		}
+		if f.inCallArg(ref.Range.Start, d.calls) {
+			used = true
+			break
+		}
+	}
+	d.receivers[key] = used
+
+	return used, nil
+}
+
+func (d *dynamicUsage) parse(uri lsp.DocumentURI) (*parsedFile, error) {
+	if f, found := d.files[uri]; found {
+		return f, nil
+	}
+	filename, err := uriFilename(uri)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	f := &parsedFile{fset: fset, file: file}
+	d.files[uri] = f
+	return f, nil
+}
+
+// inCallArg reports whether pos is within an argument to a call whose function, as written, matches calls.
+func (f *parsedFile) inCallArg(pos lsp.Position, calls globs) bool {
+	tf := f.fset.File(f.file.Pos())
+	if pos.Line+1 > tf.LineCount() {
+		return false
+	}
+	p := tf.LineStart(pos.Line+1) + token.Pos(pos.Character)
+
+	found := false
+	ast.Inspect(f.file, func(n ast.Node) bool {
+		if found || n == nil || p < n.Pos() || p > n.End() {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !calls.Match(types.ExprString(call.Fun)) {
+			return true
+		}
+		for _, arg := range call.Args {
+			if p >= arg.Pos() && p <= arg.End() {
+				found = true
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// receiverTypeIdent returns the identifier of the receiver type of the method name declared on the given 1-based line.
+func receiverTypeIdent(src *sourceFile, line int, name string) *ast.Ident {
+	for _, decl := range src.file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Name.Name != name || src.fset.Position(fd.Name.Pos()).Line != line {
+			continue
+		}
+		if id := recvTypeIdent(fd); id != nil {
+			return id
+		}
+	}
+	return nil
+}
+
+// recvTypeIdent returns the identifier of the receiver type of the method fd, nil if fd is not a method.
+func recvTypeIdent(fd *ast.FuncDecl) *ast.Ident {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return nil
+	}
+	typ := fd.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t
+		default:
+			return nil
+		}
+	}
+}
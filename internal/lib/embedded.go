@@ -0,0 +1,30 @@
+package lib
+
+import "go/ast"
+
+// isEmbeddedField reports whether the field s, named base, is an embedded field.
+func (r *runner) isEmbeddedField(filename string, s *Symbol, base string) (bool, error) {
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	d := src.decls[declKey{line: s.Location.Range.Start.Line + 1, name: base}]
+	return d != nil && d.embedded, nil
+}
+
+// embeddedIdent returns the identifier naming the embedded field of type typ, e.g. T in *pkg.T[int].
+func embeddedIdent(typ ast.Expr) *ast.Ident {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return embeddedIdent(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	case *ast.IndexExpr:
+		return embeddedIdent(t.X)
+	case *ast.IndexListExpr:
+		return embeddedIdent(t.X)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// identRe matches the Go identifiers in a template.
+var identRe = regexp.MustCompile(`[\pL_][\pL\pN_]*`)
+
+// embeddedTemplateNames returns the identifiers in the files embedded with //go:embed by the Go files in dir
+// whose filenames, relative to dir, match templates, e.g. "**/*.go.tmpl", see RunConfig.EmbeddedTemplates.
+// Hidden directories, testdata and vendor are left out, as by the go tool.
+func embeddedTemplateNames(dir string, templates globs) (map[string]bool, error) {
+	names := make(map[string]bool)
+	if len(templates) == 0 {
+		return names, nil
+	}
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); filename != dir && (strings.HasPrefix(name, ".") || name == "testdata" || name == vendorDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(filename, ".go") {
+			return nil
+		}
+		embedded, err := embeddedFiles(filename)
+		if err != nil {
+			return err
+		}
+		for _, e := range embedded {
+			rel, err := filepath.Rel(dir, e)
+			if err != nil {
+				return err
+			}
+			if seen[e] || !templates.Match(filepath.ToSlash(rel)) {
+				continue
+			}
+			seen[e] = true
+			b, err := os.ReadFile(e)
+			if err != nil {
+				return err
+			}
+			for _, name := range identRe.FindAll(b, -1) {
+				names[string(name)] = true
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+// embeddedFiles returns the files embedded by the //go:embed directives in the Go file filename,
+// with the directories embedded walked, e.g. templates/*.
+func embeddedFiles(filename string) ([]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(b, []byte("//go:embed")) {
+		return nil, nil
+	}
+	dir := filepath.Dir(filename)
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		rest, found := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "//go:embed ")
+		if !found {
+			continue
+		}
+		for _, pattern := range embedPatterns(rest) {
+			matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(pattern, "all:"))))
+			if err != nil {
+				// An invalid pattern fails the build, not the analysis.
+				continue
+			}
+			for _, match := range matches {
+				err := filepath.WalkDir(match, func(filename string, d fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if !d.IsDir() {
+						files = append(files, filename)
+					}
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// embedPatterns returns the patterns of a //go:embed directive, separated by spaces and optionally quoted,
+// e.g. templates/*.tmpl "my file.txt".
+func embedPatterns(s string) []string {
+	var patterns []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' || s[0] == '`' {
+			prefix, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return patterns
+			}
+			p, _ := strconv.Unquote(prefix)
+			patterns = append(patterns, p)
+			s = s[len(prefix):]
+			continue
+		}
+		p, rest, _ := strings.Cut(s, " ")
+		patterns = append(patterns, p)
+		s = rest
+	}
+	return patterns
+}
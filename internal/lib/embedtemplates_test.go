@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestEmbeddedTemplateNames(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o777), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o666), qt.IsNil)
+	}
+	write("gen/gen.go", "package gen\n\nimport \"embed\"\n\n//go:embed templates \"extra file.go.tmpl\"\nvar templates embed.FS\n")
+	write("gen/templates/main.go.tmpl", "func init() { rt.Register({{ .Name }}) }\n")
+	write("gen/templates/README.md", "Not a Template.\n")
+	write("gen/extra file.go.tmpl", "var _ = rt.Extra\n")
+	// Not embedded.
+	write("gen/other.go.tmpl", "var _ = rt.Other\n")
+
+	templates, err := compileGlobs([]string{"**/*.go.tmpl"})
+	c.Assert(err, qt.IsNil)
+	names, err := embeddedTemplateNames(dir, templates)
+	c.Assert(err, qt.IsNil)
+	c.Assert(names["Register"], qt.IsTrue)
+	c.Assert(names["Extra"], qt.IsTrue)
+	c.Assert(names["Name"], qt.IsTrue)
+	c.Assert(names["Template"], qt.IsFalse)
+	c.Assert(names["Other"], qt.IsFalse)
+}
+
+func TestEmbedPatterns(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(embedPatterns(`templates/*.tmpl  "my file.txt" all:static`), qt.DeepEquals, []string{"templates/*.tmpl", "my file.txt", "all:static"})
+	c.Assert(embedPatterns("`raw name`"), qt.DeepEquals, []string{"raw name"})
+}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// The policies for the constants of an enum, see RunConfig.EnumPolicy.
+const (
+	// enumPolicyEach checks each constant on its own, the default.
+	enumPolicyEach = "each"
+
+	// enumPolicyAnyUsed leaves out the constants of an enum if any of them is used.
+	enumPolicyAnyUsed = "any-used"
+)
+
+func validateEnumPolicy(policy string) error {
+	switch policy {
+	case "", enumPolicyEach, enumPolicyAnyUsed:
+		return nil
+	}
+	return fmt.Errorf("invalid enum policy %q, one of each or any-used", policy)
+}
+
+// isEnum reports whether g is an enum, i.e. a parenthesized const declaration with implicit values
+// or using iota, whose constants depend on their position in the group.
+func isEnum(g *ast.GenDecl) bool {
+	if g == nil || g.Tok != token.CONST || !g.Lparen.IsValid() {
+		return false
+	}
+	for _, spec := range g.Specs {
+		s := spec.(*ast.ValueSpec)
+		if len(s.Values) == 0 {
+			return true
+		}
+		for _, v := range s.Values {
+			var iota bool
+			ast.Inspect(v, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && ident.Name == "iota" {
+					iota = true
+				}
+				return !iota
+			})
+			if iota {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enumUsed reports whether s, a constant in filename, relative to the workspace, is a member of an enum
+// any other constant of which, among symbols, the symbols of the file, has references, see enumPolicyAnyUsed.
+// The results are cached in used by the line of the enum. It reports false unless EnumPolicy is any-used.
+func (r *runner) enumUsed(filename string, s *Symbol, symbols []*Symbol, used map[int]bool) (bool, error) {
+	if r.cfg.EnumPolicy != enumPolicyAnyUsed || s.Kind != lsp.SKConstant {
+		return false, nil
+	}
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	d := src.decls[declKey{line: s.Location.Range.Start.Line + 1, name: symbolBaseName(s)}]
+	if d == nil || !isEnum(d.group) {
+		return false, nil
+	}
+	line := src.fset.Position(d.group.Pos()).Line
+	if u, found := used[line]; found {
+		return u, nil
+	}
+	start, end := line, src.fset.Position(d.group.End()).Line
+
+	used[line] = false
+	for _, sibling := range symbols {
+		l := sibling.Location.Range.Start.Line + 1
+		if sibling == s || sibling.Kind != lsp.SKConstant || l < start || l > end {
+			continue
+		}
+		refs, err := r.references(sibling.Location)
+		if err != nil {
+			return false, err
+		}
+		if len(refs) > 0 {
+			used[line] = true
+			break
+		}
+	}
+	return used[line], nil
+}
+
+// removeValue returns the content of s without the constant or variable d, as removed by FixInteractive,
+// if it shares its spec with other names or is a member of an enum: Its name is replaced with _ unless it
+// is the last spec of the enum, keeping the values of the other names, e.g. the iota sequence, unchanged.
+// It reports false if d is not a constant or variable.
+func (s *sourceFile) removeValue(d *decl) ([]byte, bool) {
+	if d.spec == nil {
+		return nil, false
+	}
+	if len(d.spec.Names) == 1 {
+		if g := d.group; g != nil && g.Specs[len(g.Specs)-1] == d.spec {
+			return removeSpans(s.content, []span{{s.lineStart(d.start), s.offset(d.end)}}), true
+		}
+	}
+
+	var spans []span
+	doc := d.spec.Doc
+	if d.group == nil && d.doc != nil && d.doc.Pos() == d.start {
+		// The doc comment of an ungrouped declaration, e.g. var X = f().
+		doc = d.doc
+	}
+	if doc != nil && len(d.spec.Names) == 1 {
+		// It documents the constant removed.
+		spans = append(spans, span{s.lineStart(doc.Pos()), s.offset(doc.End())})
+	}
+	start, end := s.offset(d.ident.Pos()), s.offset(d.ident.End())
+	content := append(append(append([]byte(nil), s.content[:start]...), '_'), s.content[end:]...)
+	return removeSpans(content, spans), true
+}
+
+// lineStart returns the offset of the start of the line of pos.
+func (s *sourceFile) lineStart(pos token.Pos) int {
+	return bytes.LastIndexByte(s.content[:s.offset(pos)], '\n') + 1
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRemoveValue(t *testing.T) {
+	c := qt.New(t)
+
+	const code = `package p
+
+const (
+	// Red is red.
+	Red = iota
+	Green // The color of grass.
+	Blue
+)
+
+var A, B = 1, 2
+`
+	src, err := parseSource("p.go", []byte(code))
+	c.Assert(err, qt.IsNil)
+
+	remove := func(line int, name string) string {
+		content, ok := src.removeValue(src.decls[declKey{line: line, name: name}])
+		c.Assert(ok, qt.IsTrue)
+		return string(content)
+	}
+
+	c.Assert(remove(5, "Red"), qt.Equals, `package p
+
+const (
+	_ = iota
+	Green // The color of grass.
+	Blue
+)
+
+var A, B = 1, 2
+`)
+	c.Assert(remove(6, "Green"), qt.Equals, `package p
+
+const (
+	// Red is red.
+	Red = iota
+	_ // The color of grass.
+	Blue
+)
+
+var A, B = 1, 2
+`)
+	c.Assert(remove(7, "Blue"), qt.Equals, `package p
+
+const (
+	// Red is red.
+	Red = iota
+	Green // The color of grass.
+)
+
+var A, B = 1, 2
+`)
+	c.Assert(remove(10, "B"), qt.Equals, `package p
+
+const (
+	// Red is red.
+	Red = iota
+	Green // The color of grass.
+	Blue
+)
+
+var A, _ = 1, 2
+`)
+}
+
+func TestEnumPolicy(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/enums\n\ngo 1.21\n"), 0o666), qt.IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(dir, "color"), 0o777), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "color", "color.go"), []byte(`package color
+
+const (
+	Red = iota
+	Green
+	Blue
+)
+
+const (
+	Small = iota
+	Large
+)
+`), 0o666), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import "example.com/enums/color"
+
+func main() {
+	println(color.Red)
+}
+`), 0o666), qt.IsNil)
+
+	run := func(policy string) string {
+		var buff bytes.Buffer
+		c.Assert(Run(context.Background(), RunConfig{WorkspaceDir: dir, FilenamePatterns: []string{"**.go"}, Out: &buff, Backend: BackendPackages, EnumPolicy: policy}), qt.IsNil)
+		return buff.String()
+	}
+
+	out := run(enumPolicyEach)
+	c.Assert(out, qt.Contains, "Green")
+	c.Assert(out, qt.Contains, "Small")
+
+	out = run(enumPolicyAnyUsed)
+	c.Assert(out, qt.Not(qt.Contains), "Green")
+	c.Assert(out, qt.Not(qt.Contains), "Blue")
+	c.Assert(out, qt.Contains, "Small")
+	c.Assert(out, qt.Contains, "Large")
+
+	c.Assert(validateEnumPolicy("all"), qt.ErrorMatches, `invalid enum policy "all", one of each or any-used`)
+}
@@ -0,0 +1,71 @@
+package lib
+
+import "errors"
+
+// The exit codes of punused, see ExitCode.
+const (
+	// ExitClean is used when there are no findings, or only findings with severity info.
+	ExitClean = 0
+
+	// ExitWarnings is used when there are findings, but the run passed the gates.
+	ExitWarnings = 1
+
+	// ExitErrors is used when the run failed the gates, e.g. with findings with severity error
+	// or a threshold exceeded, or was stopped at the first finding.
+	ExitErrors = 2
+
+	// ExitFailure is used when the run failed to complete, e.g. on an invalid configuration or if gopls failed.
+	ExitFailure = 3
+)
+
+// Result is the outcome of a completed run.
+type Result struct {
+	// Findings is the number of findings.
+	Findings int
+
+	// Severity is the highest severity among the findings, empty if none.
+	Severity Severity
+}
+
+func (r *runner) result() Result {
+	return resultOf(r.findings)
+}
+
+func resultOf(findings []Finding) Result {
+	res := Result{Findings: len(findings)}
+	for _, f := range findings {
+		if severityRank(f.Severity) > severityRank(res.Severity) {
+			res.Severity = f.Severity
+		}
+	}
+	return res
+}
+
+func severityRank(s Severity) int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityError:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// ExitCode returns the exit code for the outcome of Check, the same with and without Fix,
+// where the outcome is given by the findings before they were fixed.
+func ExitCode(res Result, err error) int {
+	var fe *FailedError
+	switch {
+	case errors.As(err, &fe):
+		return ExitErrors
+	case err != nil:
+		return ExitFailure
+	case severityRank(res.Severity) > severityRank(SeverityInfo):
+		return ExitWarnings
+	default:
+		return ExitClean
+	}
+}
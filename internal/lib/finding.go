@@ -0,0 +1,347 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Finding describes an exported symbol that is either unused or only used in tests.
+type Finding struct {
+	// ID is the short identifier of the finding, the prefix of its Fingerprint, to suppress it in the ignore file,
+	// see IgnoreFilename.
+	ID string `json:"id,omitempty"`
+
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+
+	Severity Severity `json:"severity"`
+
+	// Confidence is how likely the finding is to be correct, given the heuristics it triggered.
+	Confidence Confidence `json:"confidence,omitempty"`
+
+	// Signature is the symbol's signature as reported by gopls, e.g. "func(s string) error".
+	Signature string `json:"signature,omitempty"`
+
+	// Doc is the first sentence of the symbol's doc comment, if any.
+	Doc string `json:"doc,omitempty"`
+
+	// Lines is the number of lines, including the doc comment, removing the declaration would delete.
+	Lines int `json:"lines,omitempty"`
+
+	// Snippet is the first lines of the declaration, including the doc comment, if enabled.
+	Snippet string `json:"snippet,omitempty"`
+
+	// UsedBy lists the unused symbols, or the package directories of the dead build targets,
+	// referencing this symbol (EU1005 only).
+	UsedBy []string `json:"used_by,omitempty"`
+
+	// Refs lists the locations, as file:line:column, referencing the symbol, if enabled.
+	Refs []string `json:"refs,omitempty"`
+
+	// Duplicates lists the locations, as file:line:column, of the functions this one duplicates (EU1010 only).
+	Duplicates []string `json:"duplicates,omitempty"`
+
+	// Stdlib is the standard library function the function duplicates, e.g. slices.Contains (EU1013 only).
+	Stdlib string `json:"stdlib,omitempty"`
+
+	// References is the number of references to the symbol (EU1004 only).
+	References int `json:"references,omitempty"`
+
+	// Blame is the git blame information for the declaration line, if enabled.
+	Blame *Blame `json:"blame,omitempty"`
+
+	// Owners are the owners of the file from CODEOWNERS, if any.
+	Owners []string `json:"owners,omitempty"`
+
+	// BuildConfigs lists the names of the build configurations the finding appeared in, if analyzed with multiple.
+	BuildConfigs []string `json:"build_configs,omitempty"`
+
+	// Sites lists the locations, as file:line:column, of all the declarations of the symbol, if declared in
+	// multiple files for different build configurations, e.g. foo_linux.go and foo_windows.go.
+	Sites []string `json:"sites,omitempty"`
+
+	// Rename is the suggested new name of a symbol used in test only (EU1001), its unexported name
+	// or, if that's taken, suffixed with ForTest.
+	Rename string `json:"rename,omitempty"`
+
+	// Hint is the action to take on the finding, e.g. "Unexport it.", see RunConfig.Hints.
+	Hint string `json:"hint,omitempty"`
+
+	// IssueURL is the link to the issue tracker rendered from the configured template, if any.
+	IssueURL string `json:"issue_url,omitempty"`
+
+	// SourceURL is the link to the declaration rendered from the configured template, if any.
+	SourceURL string `json:"source_url,omitempty"`
+
+	// Origin is introduced, if the finding is new on the branch, or pre-existing, if it's also found
+	// at the merge base of RunConfig.Base, if set.
+	Origin string `json:"origin,omitempty"`
+
+	// Accessors are the other unused accessors of the receiver type collapsed into the finding,
+	// e.g. (*T).SetName, with RunConfig.CollapseAccessors.
+	Accessors []string `json:"accessors,omitempty"`
+}
+
+func newFinding(filename string, s *Symbol, code string) Finding {
+	loc := s.Location
+	f := Finding{
+		Filename:  filename,
+		Line:      loc.Range.Start.Line + 1,
+		Column:    loc.Range.Start.Character + 1,
+		Kind:      strings.ToLower(s.Kind.String()),
+		Name:      s.Name,
+		Code:      code,
+		Signature: s.Detail,
+	}
+	return f
+}
+
+// location returns the position of the declaration of f as file:line:column.
+func (f Finding) location() string {
+	return fmt.Sprintf("%s:%d:%d", f.Filename, f.Line, f.Column)
+}
+
+// Fingerprint returns an identifier of f that is stable across runs as long as the
+// symbol keeps its name and file, i.e. it does not change when lines are added above it.
+func (f Finding) Fingerprint() string {
+	h := sha256.Sum256([]byte(canonicalCode(f.Code) + "\x00" + f.Filename + "\x00" + f.Name))
+	return hex.EncodeToString(h[:8])
+}
+
+// Message returns the human readable description of the finding.
+func (f Finding) Message() string {
+	switch canonicalCode(f.Code) {
+	case codeTestOnly:
+		return "is used in test only"
+	case codeSamePkg:
+		return "is only used in its declaring package"
+	case codeRarelyUsed:
+		if f.References == 1 {
+			return "has only 1 reference"
+		}
+		return fmt.Sprintf("has only %d references", f.References)
+	case codeTransitive:
+		return "is only used by unused code"
+	case codeGenerated:
+		return "is only used in generated code"
+	case codeSameFile:
+		return "is only used in its declaring file"
+	case codeDynamic:
+		return "is unused, but dynamic usage is suspected"
+	case codeTagged:
+		return "is unused in Go code, but tagged for serialization"
+	case codeDuplicate:
+		return "duplicates an exported function in another package"
+	case codeReexport:
+		return "is only re-exported by unused or deprecated shims"
+	case codeCaseOnly:
+		return "is only used in switch cases or blank assignments"
+	case codeStdlib:
+		return "duplicates a standard library function"
+	case codeWeak:
+		return "is only used by weak references"
+	default:
+		if len(f.Accessors) > 0 {
+			return fmt.Sprintf("and %d other accessors of %s are unused", len(f.Accessors), receiverType(f.Name))
+		}
+		return "is unused"
+	}
+}
+
+// Print writes f to w in the default text format, e.g.
+//
+//	internal/lib/gopls.go:125:2 field Detail is unused (EU1002)
+//
+// If verbose is set, the hint, signature and doc summary are printed on separate, indented lines.
+// Blame information and the references, if set, are always printed.
+func (f Finding) Print(w io.Writer, verbose bool) {
+	fmt.Fprintf(w, "%s:%d:%d %s %s %s (%s)", f.Filename, f.Line, f.Column, f.Kind, f.Name, f.Message(), f.Code)
+	if len(f.BuildConfigs) > 0 {
+		fmt.Fprintf(w, " [%s]", strings.Join(f.BuildConfigs, "; "))
+	}
+	if f.Origin != "" {
+		fmt.Fprintf(w, " [%s]", f.Origin)
+	}
+	fmt.Fprintln(w)
+	if f.Hint != "" && verbose {
+		fmt.Fprintf(w, "\thint: %s\n", f.Hint)
+	}
+	if len(f.Owners) > 0 && verbose {
+		fmt.Fprintf(w, "\towned by %s\n", strings.Join(f.Owners, " "))
+	}
+	if f.Blame != nil {
+		fmt.Fprintf(w, "\tlast modified %s by %s (%d days ago)\n", f.Blame.Time.Format("2006-01-02"), f.Blame.Author, int(f.Blame.Age().Hours()/24))
+	}
+	for _, site := range f.Sites {
+		if site != f.location() {
+			fmt.Fprintf(w, "\talso declared at %s\n", site)
+		}
+	}
+	for _, ref := range f.Refs {
+		fmt.Fprintf(w, "\treferenced at %s\n", ref)
+	}
+	for _, dup := range f.Duplicates {
+		fmt.Fprintf(w, "\tduplicate at %s\n", dup)
+	}
+	if f.Stdlib != "" {
+		fmt.Fprintf(w, "\tuse %s instead\n", f.Stdlib)
+	}
+	for _, accessor := range f.Accessors {
+		fmt.Fprintf(w, "\taccessor %s\n", accessor)
+	}
+	if !verbose {
+		return
+	}
+	if f.ID != "" {
+		fmt.Fprintf(w, "\tid %s\n", f.ID)
+	}
+	if f.Signature != "" {
+		fmt.Fprintf(w, "\t%s\n", f.Signature)
+	}
+	if f.Confidence != "" {
+		fmt.Fprintf(w, "\t%s confidence\n", f.Confidence)
+	}
+	if f.Doc != "" {
+		fmt.Fprintf(w, "\t%s\n", f.Doc)
+	}
+	if len(f.UsedBy) > 0 {
+		fmt.Fprintf(w, "\tused by %s\n", strings.Join(f.UsedBy, ", "))
+	}
+	if f.Lines > 0 {
+		fmt.Fprintf(w, "\t%s\n", plural(f.Lines, "line"))
+	}
+	if f.Rename != "" {
+		fmt.Fprintf(w, "\trename to %s\n", f.Rename)
+	}
+	if f.IssueURL != "" {
+		fmt.Fprintf(w, "\t%s\n", f.IssueURL)
+	}
+}
+
+// Summary holds the totals for a set of findings.
+type Summary struct {
+	Findings int `json:"findings"`
+	Unused   int `json:"unused"`
+	TestOnly int `json:"test_only"`
+
+	// ByCode holds the number of findings per check code.
+	ByCode map[string]int `json:"by_code"`
+
+	// Lines is the total number of lines removing all the flagged declarations would delete.
+	Lines int `json:"lines"`
+
+	// Exported is the number of exported symbols analyzed.
+	Exported int `json:"exported"`
+
+	// UnusedPercent is the percentage of the exported symbols with findings.
+	UnusedPercent float64 `json:"unused_percent"`
+
+	// HealthScore is 100 - UnusedPercent.
+	HealthScore float64 `json:"health_score"`
+
+	// Sample is the fraction of the files analyzed, if sampled.
+	Sample float64 `json:"sample,omitempty"`
+
+	// EstimatedFindings is the number of findings extrapolated to all the files, if sampled.
+	EstimatedFindings int `json:"estimated_findings,omitempty"`
+
+	// Skipped lists the files not analyzed.
+	Skipped []SkippedFile `json:"skipped,omitempty"`
+
+	// LoadErrors lists the errors in the packages in the workspace.
+	LoadErrors []LoadError `json:"load_errors,omitempty"`
+}
+
+// SkippedFile is a file not analyzed.
+type SkippedFile struct {
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+// summarize returns the summary for the given findings out of exported analyzed symbols.
+func summarize(findings []Finding, exported int) Summary {
+	s := Summary{Exported: exported, ByCode: make(map[string]int)}
+	for _, f := range findings {
+		code := canonicalCode(f.Code)
+		s.Findings++
+		s.ByCode[f.Code]++
+		switch code {
+		case codeTestOnly:
+			s.TestOnly++
+		case codeUnused:
+			s.Unused++
+		}
+		if code != codeSameFile && code != codeSamePkg {
+			// Those are unexported, not removed.
+			s.Lines += f.Lines
+		}
+	}
+	s.UnusedPercent = percent(s.Findings, s.Exported)
+	s.HealthScore = 100 - s.UnusedPercent
+	return s
+}
+
+// Print writes s to w as a single line.
+func (s Summary) Print(w io.Writer) {
+	// The codes may have a configured prefix.
+	byCode := make(map[string]int, len(s.ByCode))
+	for code, n := range s.ByCode {
+		byCode[canonicalCode(code)] += n
+	}
+	var others string
+	for _, c := range checks {
+		if c.Code == codeUnused || c.Code == codeTestOnly || byCode[c.Code] == 0 {
+			continue
+		}
+		others += fmt.Sprintf(", %d %s", byCode[c.Code], strings.TrimPrefix(Finding{Code: c.Code}.Message(), "is "))
+	}
+	fmt.Fprintf(w, "%d findings (%d unused, %d used in test only%s) in %d exported symbols (%.2f%%, health score %.2f), removing them would delete %s\n", s.Findings, s.Unused, s.TestOnly, others, s.Exported, s.UnusedPercent, s.HealthScore, plural(s.Lines, "line"))
+	if s.Sample > 0 {
+		fmt.Fprintf(w, "sampled %.0f%% of the files, an estimated %d findings in total\n", s.Sample*100, s.EstimatedFindings)
+	}
+	for _, sf := range s.Skipped {
+		fmt.Fprintf(w, "skipped %s: %s\n", sf.Filename, sf.Reason)
+	}
+	printLoadErrors(w, s.LoadErrors)
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// Report is the outcome of a run, as written with -format=json.
+type Report struct {
+	Run         *RunInfo   `json:"run,omitempty"`
+	Summary     Summary    `json:"summary"`
+	TopPackages []Offender `json:"top_packages,omitempty"`
+	TopAuthors  []Offender `json:"top_authors,omitempty"`
+	TopOwners   []Offender `json:"top_owners,omitempty"`
+
+	// Packages is the proportion of the exported API used only by tests per package.
+	Packages []PackageUsage `json:"packages,omitempty"`
+
+	Findings []Finding `json:"findings"`
+
+	// Symbols lists every exported symbol analyzed, with or without findings, with AnnotateAll.
+	Symbols []InventorySymbol `json:"symbols,omitempty"`
+}
+
+func writeJSON(w io.Writer, report Report) error {
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,560 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fix applies the available fixes for the findings to the files in dir:
+// The declarations of the symbols used in test only (EU1001) are moved to a _test.go file in the same package,
+// the unused types are removed with their methods and constructors, and then the unused functions, variables
+// and constants with their doc comments, see removeUnusedDecls. The symbols only used in their
+// declaring package (EU1003) are renamed while analyzing, with gopls, and added to the fix report here.
+// With FixMinAge, only the findings whose declaration has not been modified within it are fixed,
+// and with FixTestOnly, only the symbols used in test only are moved.
+// The edits are recorded in the fix report as made in the given round, see fixRounds.
+func (r *runner) fix(dir string, round int) error {
+	findings, err := r.fixable(dir)
+	if err != nil {
+		return err
+	}
+	start := len(r.fixReport.Edits)
+	r.fixReport.Edits = append(r.fixReport.Edits, r.renamed...)
+	snap, err := r.snapshotFix(dir, findings)
+	if err != nil {
+		return err
+	}
+	fixStart := len(r.fixReport.Edits)
+
+	byFile := make(map[string][]Finding)
+	for _, f := range findings {
+		if f.Code == codeTestOnly && !strings.HasSuffix(f.Filename, "_test.go") {
+			byFile[f.Filename] = append(byFile[f.Filename], f)
+		}
+	}
+
+	filenames := make([]string, 0, len(byFile))
+	for filename := range byFile {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		target, edits, err := moveToTestFile(dir, filename, byFile[filename], r.importedNamesIn(dir), r.cfg.patched)
+		if err != nil {
+			r.fixFailed(filename, fmt.Errorf("failed to move test only symbols: %w", err))
+			continue
+		}
+		for _, e := range edits {
+			r.cfg.Logger.Info("moved test only symbol", "symbol", e.Symbol, "from", filename, "to", target)
+		}
+		r.fixReport.Edits = append(r.fixReport.Edits, edits...)
+	}
+
+	if !r.cfg.FixTestOnly {
+		if _, err := r.removeUnusedTypes(dir, findings); err != nil {
+			return err
+		}
+		if _, err := r.removeUnusedDecls(dir, findings); err != nil {
+			return err
+		}
+	}
+	if snap != nil {
+		if err := r.verifyFix(dir, snap, fixStart); err != nil {
+			return err
+		}
+	}
+	for i := start; i < len(r.fixReport.Edits); i++ {
+		r.fixReport.Edits[i].Round = round
+	}
+	r.cfg.Logger.Info("fix round finished", "round", round, "edits", len(r.fixReport.Edits)-start)
+
+	if r.cfg.FixReport != "" {
+		if err := writeFixReport(r.cfg.FixReport, r.fixReport); err != nil {
+			return fmt.Errorf("failed to write fix report: %w", err)
+		}
+	}
+	if r.cfg.FixMessage != "" {
+		if err := writeFixMessage(r.cfg.FixMessage, r.fixReport, toolVersion(), r.cfg.hash()); err != nil {
+			return fmt.Errorf("failed to write fix commit message: %w", err)
+		}
+	}
+
+	if n := len(r.fixReport.Failures); n > 0 {
+		return fmt.Errorf("failed to fix %s, see the log", plural(n, "file or package"))
+	}
+
+	return nil
+}
+
+// fixRounds re-analyzes and fixes the workspace root dirs after the first round of fixes in first,
+// until a round changes nothing or cfg.Iterations rounds are done. The findings of the later rounds
+// are not reported, but their edits are added to the fix report of first.
+func fixRounds(ctx context.Context, cfg RunConfig, dirs []string, first *runner) error {
+	cfg.Out, cfg.OnFinding = io.Discard, nil
+	rep, err := newReporter(io.Discard, formatText, textOptions{})
+	if err != nil {
+		return err
+	}
+	edits := len(first.fixReport.Edits)
+	for round := 2; round <= cfg.Iterations && edits > 0; round++ {
+		r, err := analyzeWorkspaces(ctx, cfg, rep, dirs)
+		if err != nil {
+			return fmt.Errorf("fix round %d: %w", round, err)
+		}
+		r.fixReport, r.fixVerified, r.reportOnly = first.fixReport, first.fixVerified, first.reportOnly
+		err = r.fix(cfg.WorkspaceDir, round)
+		edits = len(r.fixReport.Edits) - len(first.fixReport.Edits)
+		first.fixReport, first.fixVerified, first.reportOnly = r.fixReport, r.fixVerified, r.reportOnly
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixable returns the findings to fix in dir, leaving out those whose declaration
+// has been modified within FixMinAge, if set, and those whose fixes were rolled back, see verifyFix.
+func (r *runner) fixable(dir string) ([]Finding, error) {
+	if r.cfg.FixMinAge <= 0 && len(r.reportOnly) == 0 {
+		return r.findings, nil
+	}
+	files := &fileCache{workspaceDir: dir}
+	var findings []Finding
+	for _, f := range r.findings {
+		if r.reportOnly[f.Filename] {
+			continue
+		}
+		if r.cfg.FixMinAge <= 0 {
+			findings = append(findings, f)
+			continue
+		}
+		recent, err := r.modifiedWithin(files, f, symbolBase(f.Name), r.cfg.FixMinAge)
+		if err != nil {
+			return nil, err
+		}
+		if recent {
+			r.cfg.Logger.Info("recently modified symbol not fixed", "symbol", f.Name, "filename", f.Filename)
+			continue
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// errNotMovable is returned for declarations that cannot be moved on their own.
+var errNotMovable = errors.New("declaration cannot be moved on its own")
+
+// moveToTestFile moves the declarations of findings in filename, relative to dir, to a _test.go file
+// in the same package, and returns the target filename and the edits made.
+// Symbols that cannot be moved on their own, e.g. struct fields, are left alone.
+// The imports copied are matched by the package names resolved in names, see importNames.
+// The files are read from and written to patched, if set, see RunConfig.Patch.
+func moveToTestFile(dir, filename string, findings []Finding, names *importedNames, patched overlay) (string, []FixEdit, error) {
+	src, err := patched.parseSourceFile(filepath.Join(dir, filename))
+	if err != nil {
+		return "", nil, err
+	}
+	names.resolve(src.file)
+	content := src.content
+
+	target, targetContent, err := testFileFor(dir, filename, src.file.Name.Name, patched)
+	if err != nil {
+		return "", nil, err
+	}
+
+	type cut struct {
+		start, end int
+		text       string
+	}
+	var (
+		cuts  []cut
+		edits []FixEdit
+		used  = make(map[string]bool)
+	)
+	for _, f := range findings {
+		d, err := src.movableDecl(f)
+		if err != nil {
+			continue
+		}
+		start, end := src.offset(d.start), src.offset(d.end)
+		end = lineCommentEnd(content, end)
+		text := string(content[start:end])
+		if d.grouped {
+			// Insert the keyword between the doc comment and the spec.
+			at := start
+			if d.doc != nil && d.doc.Pos() == d.start {
+				at = src.offset(d.doc.End()) + 1
+			}
+			text = string(content[start:at]) + d.tok.String() + " " + strings.TrimLeft(string(content[at:end]), " \t")
+		}
+		cuts = append(cuts, cut{start: start, end: end, text: text})
+		edits = append(edits, FixEdit{
+			Filename:     filename,
+			StartLine:    src.fset.Position(d.start).Line,
+			EndLine:      src.fset.Position(d.end).Line,
+			Symbol:       f.Name,
+			Action:       fixActionMoved,
+			Target:       target,
+			BytesRemoved: end - start,
+		})
+		for name := range src.importsUsedIn(d.start, d.end) {
+			used[name] = true
+		}
+	}
+	if len(cuts) == 0 {
+		return target, nil, nil
+	}
+
+	// Remove the declarations bottom up to keep the offsets valid,
+	// but keep them in source order in the target.
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].start < cuts[j].start })
+	var texts []string
+	for _, c := range cuts {
+		texts = append(texts, c.text)
+	}
+	for i := len(cuts) - 1; i >= 0; i-- {
+		c := cuts[i]
+		content = append(content[:c.start:c.start], content[c.end:]...)
+	}
+
+	var imports []string
+	for _, spec := range src.file.Imports {
+		if importUsed(spec, used, names) {
+			imports = append(imports, importSpecText(spec))
+		}
+	}
+
+	targetContent = addImports(targetContent, imports)
+	targetContent = append(targetContent, []byte("\n"+strings.Join(texts, "\n\n")+"\n")...)
+
+	if content, err = removeUnusedImports(src.content, content, names); err != nil {
+		return "", nil, err
+	}
+	if content, err = format.Source(content); err != nil {
+		return "", nil, err
+	}
+	if targetContent, err = format.Source(targetContent); err != nil {
+		return "", nil, err
+	}
+
+	if err := writeFiles(dir, []fileWrite{{target, targetContent}, {filename, content}}, patched); err != nil {
+		return "", nil, err
+	}
+
+	return target, edits, nil
+}
+
+// testFileFor returns the filename, relative to dir, and the current content of the _test.go file to move
+// declarations from filename in package pkg to: foo_test.go for foo.go, falling back to export_test.go
+// if foo_test.go is an external test package. A new file gets a package clause only.
+func testFileFor(dir, filename, pkg string, patched overlay) (string, []byte, error) {
+	candidates := []string{
+		strings.TrimSuffix(filename, ".go") + "_test.go",
+		path.Join(path.Dir(filename), "export_test.go"),
+	}
+	for _, candidate := range candidates {
+		b, err := patched.readFile(filepath.Join(dir, candidate))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return candidate, []byte("package " + pkg + "\n"), nil
+			}
+			return "", nil, err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), candidate, b, parser.PackageClauseOnly)
+		if err != nil {
+			return "", nil, err
+		}
+		if file.Name.Name == pkg {
+			return candidate, b, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no _test.go file in package %s available, tried %s", pkg, strings.Join(candidates, ", "))
+}
+
+// movableDecl returns the top level declaration of the symbol of f, if it can be moved on its own.
+func (s *sourceFile) movableDecl(f Finding) (*decl, error) {
+	d := s.decls[declKey{line: f.Line, name: symbolBase(f.Name)}]
+	if d == nil || d.tok == token.ILLEGAL || d.shared {
+		return nil, errNotMovable
+	}
+	return d, nil
+}
+
+// symbolBase returns the name of the symbol without any receiver, e.g. MyMethod for (*MyType).MyMethod.
+func symbolBase(name string) string {
+	if strings.HasPrefix(name, "(") {
+		if i := strings.Index(name, ")."); i >= 0 {
+			return name[i+2:]
+		}
+	}
+	return name
+}
+
+func (s *sourceFile) offset(pos token.Pos) int {
+	return s.fset.Position(pos).Offset
+}
+
+// lineCommentEnd returns the offset after the line ending at or after end if the rest of the line is
+// whitespace or a trailing comment, else end.
+func lineCommentEnd(content []byte, end int) int {
+	i := bytes.IndexByte(content[end:], '\n')
+	if i < 0 {
+		return end
+	}
+	rest := bytes.TrimSpace(content[end : end+i])
+	if len(rest) == 0 || bytes.HasPrefix(rest, []byte("//")) {
+		return end + i + 1
+	}
+	return end
+}
+
+// importsUsedIn returns the names of the imports referenced in the given extent of s.
+func (s *sourceFile) importsUsedIn(start, end token.Pos) map[string]bool {
+	return usedImportNames(s.file, start, end)
+}
+
+func usedImportNames(file *ast.File, start, end token.Pos) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || n.End() < start || n.Pos() > end {
+			return false
+		}
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && sel.Pos() >= start && sel.End() <= end {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// importedNames holds the names of the packages imported in a workspace, by import path, as they may not
+// match the path, e.g. lru for github.com/hashicorp/golang-lru. A nil importedNames resolves nothing.
+type importedNames struct {
+	dir   string
+	names map[string]string
+}
+
+// importedNamesIn returns the names of the packages imported in dir, resolved on use.
+func (r *runner) importedNamesIn(dir string) *importedNames {
+	if r.imported == nil || r.imported.dir != dir {
+		r.imported = &importedNames{dir: dir, names: make(map[string]string)}
+	}
+	return r.imported
+}
+
+// resolve loads the names of the packages imported by files not resolved yet with go/packages.
+// The names not found, e.g. if the workspace does not build, are left to be guessed, see importNames.
+func (p *importedNames) resolve(files ...*ast.File) {
+	if p == nil {
+		return
+	}
+	var paths []string
+	for _, file := range files {
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			if _, ok := p.names[importPath]; ok || importPath == "C" {
+				continue
+			}
+			p.names[importPath] = ""
+			paths = append(paths, importPath)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName, Dir: p.dir}, paths...)
+	if err != nil {
+		return
+	}
+	for _, pkg := range pkgs {
+		if pkg.Name != "" {
+			p.names[pkg.PkgPath] = pkg.Name
+		}
+	}
+}
+
+// name returns the resolved name of the package with the given import path, or "" if not resolved.
+func (p *importedNames) name(path string) string {
+	if p == nil {
+		return ""
+	}
+	return p.names[path]
+}
+
+// importNames returns the names an import may be referenced by: its name if renamed, else the package name
+// if resolved, else those guessed from the path, e.g. yaml for gopkg.in/yaml.v3, bar or gobar for
+// github.com/foo/go-bar/v2 and v1 or core for k8s.io/api/core/v1.
+func importNames(spec *ast.ImportSpec, resolved *importedNames) []string {
+	if spec.Name != nil {
+		return []string{spec.Name.Name}
+	}
+	p, _ := strconv.Unquote(spec.Path.Value)
+	if name := resolved.name(p); name != "" {
+		return []string{name}
+	}
+	elems := []string{path.Base(p)}
+	if isMajorVersion(elems[0]) {
+		elems = append(elems, path.Base(path.Dir(p)))
+	}
+	var names []string
+	for _, name := range elems {
+		if i := strings.Index(name, ".v"); i > 0 {
+			name = name[:i]
+		}
+		names = append(names, strings.ReplaceAll(strings.TrimPrefix(name, "go-"), "-", ""))
+		if strings.HasPrefix(name, "go-") {
+			names = append(names, strings.ReplaceAll(name, "-", ""))
+		}
+	}
+	return names
+}
+
+// importUsed reports whether any of the names of spec is in used, see importNames.
+func importUsed(spec *ast.ImportSpec, used map[string]bool, resolved *importedNames) bool {
+	for _, name := range importNames(spec, resolved) {
+		if used[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
+
+func importSpecText(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name + " " + spec.Path.Value
+	}
+	return spec.Path.Value
+}
+
+// addImports adds the import specs missing in content.
+func addImports(content []byte, specs []string) []byte {
+	if len(specs) == 0 {
+		return content
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ImportsOnly)
+	if err != nil {
+		return content
+	}
+	existing := make(map[string]bool)
+	for _, spec := range file.Imports {
+		existing[importSpecText(spec)] = true
+	}
+	var missing []string
+	for _, spec := range specs {
+		if !existing[spec] {
+			missing = append(missing, "\t"+spec+"\n")
+		}
+	}
+	if len(missing) == 0 {
+		return content
+	}
+
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if gd.Lparen.IsValid() {
+			at := fset.Position(gd.Lparen).Offset + 1
+			return splice(content, at, "\n"+strings.Join(missing, ""))
+		}
+		// Turn e.g. import "testing" into a parenthesized declaration.
+		start, end := fset.Position(gd.Pos()).Offset, fset.Position(gd.End()).Offset
+		spec := importSpecText(gd.Specs[0].(*ast.ImportSpec))
+		return splice(append(content[:start:start], content[end:]...), start, "import (\n\t"+spec+"\n"+strings.Join(missing, "")+")")
+	}
+	at := fset.Position(file.Name.End()).Offset
+	return splice(content, at, "\n\nimport (\n"+strings.Join(missing, "")+")\n")
+}
+
+func splice(content []byte, at int, s string) []byte {
+	var b bytes.Buffer
+	b.Write(content[:at])
+	b.WriteString(s)
+	b.Write(content[at:])
+	return b.Bytes()
+}
+
+// removeUnusedImports removes the imports no longer referenced in content, edited from orig: those referenced
+// in orig only. The package names are resolved in resolved, else guessed, see importNames, so an import whose
+// name is guessed wrong, and so looks unused in both, is kept.
+func removeUnusedImports(orig, content []byte, resolved *importedNames) ([]byte, error) {
+	origFile, err := parser.ParseFile(token.NewFileSet(), "", orig, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	resolved.resolve(file)
+	usedBefore := usedImportNames(origFile, origFile.Pos(), origFile.End())
+	used := usedImportNames(file, file.Pos(), file.End())
+
+	var spans []span
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		var unused []ast.Spec
+		for _, spec := range gd.Specs {
+			is := spec.(*ast.ImportSpec)
+			if name := importNames(is, resolved)[0]; name != "_" && name != "." &&
+				importUsed(is, usedBefore, resolved) && !importUsed(is, used, resolved) {
+				unused = append(unused, spec)
+			}
+		}
+		if len(unused) == len(gd.Specs) {
+			spans = append(spans, span{fset.Position(gd.Pos()).Offset, fset.Position(gd.End()).Offset})
+			continue
+		}
+		for _, spec := range unused {
+			spans = append(spans, span{fset.Position(spec.Pos()).Offset, fset.Position(spec.End()).Offset})
+		}
+	}
+
+	return removeSpans(content, spans), nil
+}
+
+// span is an extent of a file's content, as offsets.
+type span struct{ start, end int }
+
+// removeSpans removes the non-overlapping spans, including any trailing line comment, from content.
+func removeSpans(content []byte, spans []span) []byte {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	// Bottom up to keep the offsets valid.
+	for i := len(spans) - 1; i >= 0; i-- {
+		s := spans[i]
+		content = append(content[:s.start:s.start], content[lineCommentEnd(content, s.end):]...)
+	}
+	return content
+}
@@ -0,0 +1,340 @@
+package lib
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMoveToTestFile(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		c.Assert(os.WriteFile(filepath.Join(dir, filename), []byte(content), 0o644), qt.IsNil)
+	}
+	read := func(filename string) string {
+		b, err := os.ReadFile(filepath.Join(dir, filename))
+		c.Assert(err, qt.IsNil)
+		return string(b)
+	}
+
+	write("p.go", `package p
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Helper is used in test only.
+func Helper(s string) string { return strings.ToUpper(s) }
+
+func Used() { fmt.Println() }
+
+const (
+	// TestConst is used in test only.
+	TestConst = 1
+	UsedConst = 2
+)
+
+type T struct {
+	TestField int
+}
+`)
+	write("p_test.go", `package p
+
+import "testing"
+
+func TestP(t *testing.T) {}
+`)
+
+	target, edits, err := moveToTestFile(dir, "p.go", []Finding{
+		{Name: "Helper", Line: 9},
+		{Name: "TestConst", Line: 15},
+		{Name: "TestField", Line: 20},
+	}, nil, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(target, qt.Equals, "p_test.go")
+	c.Assert(edits, qt.HasLen, 2)
+	c.Assert(edits[0], qt.DeepEquals, FixEdit{
+		Filename: "p.go", StartLine: 8, EndLine: 9, Symbol: "Helper", Action: "moved", Target: "p_test.go", BytesRemoved: 91,
+	})
+	c.Assert(edits[1].Symbol, qt.Equals, "TestConst")
+
+	c.Assert(read("p.go"), qt.Equals, `package p
+
+import (
+	"fmt"
+)
+
+func Used() { fmt.Println() }
+
+const (
+	UsedConst = 2
+)
+
+type T struct {
+	TestField int
+}
+`)
+	c.Assert(read("p_test.go"), qt.Equals, `package p
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestP(t *testing.T) {}
+
+// Helper is used in test only.
+func Helper(s string) string { return strings.ToUpper(s) }
+
+// TestConst is used in test only.
+const TestConst = 1
+`)
+}
+
+func TestMoveToTestFileResolvedImportNames(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filename)
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o644), qt.IsNil)
+	}
+	write("go.mod", "module example.com/m\n\ngo 1.23\n")
+	write("golang-lru/lru.go", "package lru\n\nfunc New() int { return 0 }\n")
+	write("p/p.go", `package p
+
+import "example.com/m/golang-lru"
+
+// Helper is used in test only.
+func Helper() int { return lru.New() }
+`)
+
+	target, _, err := moveToTestFile(filepath.Join(dir, "p"), "p.go", []Finding{{Name: "Helper", Line: 6}},
+		&importedNames{dir: dir, names: make(map[string]string)}, nil)
+	c.Assert(err, qt.IsNil)
+	b, err := os.ReadFile(filepath.Join(dir, "p", target))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Contains, `"example.com/m/golang-lru"`)
+}
+
+func TestTestFileFor(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "p_test.go"), []byte("package p_test\n"), 0o644), qt.IsNil)
+
+	target, content, err := testFileFor(dir, "p.go", "p", nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(target, qt.Equals, "export_test.go")
+	c.Assert(string(content), qt.Equals, "package p\n")
+}
+
+func TestRemoveUnusedImports(t *testing.T) {
+	c := qt.New(t)
+
+	const kept = `
+var _ = v1.Pod{}
+
+var _ = gobar.X
+
+var _ = lru.New
+`
+	const header = `package p
+
+import (
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+	"k8s.io/api/core/v1"
+	"github.com/foo/go-bar/v2"
+	"example.com/m/golang-lru"
+)
+`
+	orig := header + `
+func dead() { _, _ = strings.ToUpper(""), yaml.Marshal }
+` + kept
+	content, err := removeUnusedImports([]byte(orig), []byte(header+kept), nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(content), qt.Not(qt.Contains), "strings")
+	c.Assert(string(content), qt.Not(qt.Contains), "yaml")
+	// Referenced by the package name, not the last element of the path.
+	c.Assert(string(content), qt.Contains, `"k8s.io/api/core/v1"`)
+	c.Assert(string(content), qt.Contains, `"github.com/foo/go-bar/v2"`)
+	// Package lru, whose name is not guessed, but was not referenced in the code removed.
+	c.Assert(string(content), qt.Contains, `"example.com/m/golang-lru"`)
+}
+
+func TestRemoveTypesInPackage(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.Mkdir(filepath.Join(dir, "p"), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p", "p.go"), []byte(`package p
+
+import "fmt"
+
+// Dead is unused.
+type Dead struct{ n int }
+
+// NewDead returns a new Dead.
+func NewDead() *Dead { return &Dead{} }
+
+func (d *Dead) String() string { return fmt.Sprint(d.n) } // String implements fmt.Stringer.
+
+// Kept is referenced by Hold.
+type Kept int
+
+func Hold(k Kept) {}
+
+type (
+	Alive int
+	Gone  int
+)
+`), 0o644), qt.IsNil)
+
+	r := &runner{cfg: RunConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}}
+	n, err := r.removeTypesInPackage(dir, "p", map[string]bool{"Dead": true, "Kept": true, "Gone": true}, map[string]bool{"NewDead": true})
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 2)
+	var symbols []string
+	for _, e := range r.fixReport.Edits {
+		symbols = append(symbols, e.Symbol)
+	}
+	c.Assert(symbols, qt.DeepEquals, []string{"Dead", "NewDead", "(*Dead).String", "Gone"})
+
+	b, err := os.ReadFile(filepath.Join(dir, "p", "p.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, `package p
+
+// Kept is referenced by Hold.
+type Kept int
+
+func Hold(k Kept) {}
+
+type (
+	Alive int
+)
+`)
+}
+
+func TestRemoveUnusedDecls(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(dir, "p"), 0o777), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p", "p.go"), []byte(`package p
+
+import (
+	"fmt"
+	"os"
+)
+
+// Dead is unused.
+// It prints.
+func Dead() { fmt.Println(limit) }
+
+func Used() {}
+
+var (
+	// Stale is unused.
+	Stale = 1
+)
+
+var Env = os.Getenv("P")
+
+const limit = 10
+
+const (
+	Red = iota
+	Green // Unused.
+	Blue
+)
+
+// Helper is only used by Kept, reported as unused.
+func Helper() {}
+
+type T struct{}
+
+func (T) Kept() { Helper() }
+`), 0o644), qt.IsNil)
+
+	r := &runner{cfg: RunConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}}
+	n, err := r.removeUnusedDecls(dir, []Finding{
+		{Filename: "p/p.go", Name: "Dead", Code: codeUnused},
+		{Filename: "p/p.go", Name: "Stale", Code: codeUnused},
+		{Filename: "p/p.go", Name: "Env", Code: codeUnused},
+		{Filename: "p/p.go", Name: "limit", Code: codeTransitive, UsedBy: []string{"Dead"}},
+		{Filename: "p/p.go", Name: "Green", Code: codeUnused},
+		{Filename: "p/p.go", Name: "Helper", Code: codeTransitive, UsedBy: []string{"(T).Kept"}},
+		{Filename: "p/p.go", Name: "(T).Kept", Code: codeUnused},
+		{Filename: "p/p.go", Name: "T", Code: codeUnused},
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 5)
+	c.Assert(r.fixReport.Edits[0], qt.DeepEquals, FixEdit{
+		Filename: "p/p.go", StartLine: 8, EndLine: 10, Symbol: "Dead", Action: fixActionRemoved, BytesRemoved: 68,
+	})
+	var symbols []string
+	for _, e := range r.fixReport.Edits {
+		symbols = append(symbols, e.Symbol)
+	}
+	c.Assert(symbols, qt.DeepEquals, []string{"Dead", "Stale", "Env", "limit", "Green"})
+
+	b, err := os.ReadFile(filepath.Join(dir, "p", "p.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, `package p
+
+import (
+	"os"
+)
+
+func Used() {}
+
+var _ = os.Getenv("P")
+
+const (
+	Red = iota
+	_   // Unused.
+	Blue
+)
+
+// Helper is only used by Kept, reported as unused.
+func Helper() {}
+
+type T struct{}
+
+func (T) Kept() { Helper() }
+`)
+}
+
+func TestWriteFiles(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0o644), qt.IsNil)
+
+	c.Assert(writeFiles(dir, []fileWrite{{"a.go", []byte("package b\n")}, {"c.go", []byte("package c\n")}}, nil), qt.IsNil)
+	b, err := os.ReadFile(filepath.Join(dir, "c.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "package c\n")
+
+	// The missing directory fails the last write, the others are rolled back.
+	err = writeFiles(dir, []fileWrite{{"a.go", []byte("package x\n")}, {"d.go", []byte("package d\n")}, {"missing/e.go", nil}}, nil)
+	var we *writeError
+	c.Assert(errors.As(err, &we), qt.IsTrue)
+	c.Assert(we.rolledBack, qt.DeepEquals, []string{"d.go", "a.go"})
+	b, err = os.ReadFile(filepath.Join(dir, "a.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "package b\n")
+	_, err = os.Stat(filepath.Join(dir, "d.go"))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+}
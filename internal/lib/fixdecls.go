@@ -0,0 +1,246 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// removeUnusedDecls removes the declarations of the unused functions, variables and constants in the findings
+// (EU1002), with their doc comments, from the files in dir, including those declared in other files for other
+// build configurations, see Finding.Sites. The symbols only used by unused code (EU1005) are removed too if all
+// the code using them is. Methods, fields and types are left alone, the unused types are removed by
+// removeUnusedTypes, which runs first, so the declarations are looked up by name, not line.
+// The members of an enum and the names sharing a spec with others are replaced with _, see removeValue,
+// as are the variables initialized with a call, which may have side effects.
+// It returns the number of declarations removed. The files failing are recorded in the fix report.
+func (r *runner) removeUnusedDecls(dir string, findings []Finding) (int, error) {
+	remove := make(map[string]map[string]bool)
+	add := func(pkg, name string) {
+		if remove[pkg] == nil {
+			remove[pkg] = make(map[string]bool)
+		}
+		remove[pkg][name] = true
+	}
+	var candidates []Finding
+	for _, f := range findings {
+		if f.Code != codeUnused && f.Code != codeTransitive || strings.HasPrefix(f.Name, "(") {
+			continue
+		}
+		src, err := r.cfg.patched.parseSourceFile(filepath.Join(dir, f.Filename))
+		if err != nil {
+			return 0, err
+		}
+		if d := src.topLevelDecl(f.Name); d == nil || d.tok == token.TYPE {
+			continue
+		}
+		if f.Code == codeUnused {
+			add(path.Dir(f.Filename), f.Name)
+		}
+		candidates = append(candidates, f)
+	}
+	// The symbols only used by unused code, until none is added.
+	for changed := true; changed; {
+		changed = false
+		for _, f := range candidates {
+			pkg := path.Dir(f.Filename)
+			if remove[pkg][f.Name] || !usedOnlyBy(f, remove[pkg]) {
+				continue
+			}
+			add(pkg, f.Name)
+			changed = true
+		}
+	}
+
+	byFile := make(map[string][]Finding)
+	for _, f := range candidates {
+		if !remove[path.Dir(f.Filename)][f.Name] {
+			continue
+		}
+		for _, filename := range siteFilenames(f) {
+			byFile[filename] = append(byFile[filename], f)
+		}
+	}
+	filenames := make([]string, 0, len(byFile))
+	for filename := range byFile {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var removed int
+	for _, filename := range filenames {
+		edits, err := r.removeDeclsInFile(dir, filename, byFile[filename])
+		if err != nil {
+			r.fixFailed(filename, fmt.Errorf("failed to remove unused declarations: %w", err))
+			continue
+		}
+		for _, e := range edits {
+			r.cfg.Logger.Info("removed unused declaration", "symbol", e.Symbol, "filename", filename)
+		}
+		r.fixReport.Edits = append(r.fixReport.Edits, edits...)
+		removed += len(edits)
+	}
+	return removed, nil
+}
+
+// usedOnlyBy reports whether all the symbols using f, a symbol only used by unused code, are in names.
+func usedOnlyBy(f Finding, names map[string]bool) bool {
+	if f.Code != codeTransitive || len(f.UsedBy) == 0 {
+		return false
+	}
+	for _, name := range f.UsedBy {
+		if !names[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// siteFilenames returns the files declaring the symbol of f, see Finding.Sites.
+func siteFilenames(f Finding) []string {
+	if len(f.Sites) == 0 {
+		return []string{f.Filename}
+	}
+	var filenames []string
+	for _, site := range f.Sites {
+		// file:line:column
+		filename := site
+		for i := 0; i < 2; i++ {
+			if j := strings.LastIndexByte(filename, ':'); j >= 0 {
+				filename = filename[:j]
+			}
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames
+}
+
+// removeDeclsInFile removes the declarations of findings from filename, relative to dir, bottom up, so the lines
+// above stay valid, and returns the edits made, in source order. The var and const groups left empty are removed.
+func (r *runner) removeDeclsInFile(dir, filename string, findings []Finding) ([]FixEdit, error) {
+	content, err := r.cfg.patched.readFile(filepath.Join(dir, filepath.FromSlash(filename)))
+	if err != nil {
+		return nil, err
+	}
+	orig := content
+	src, err := parseSource(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range findings {
+		// E.g. a constructor removed with its type is gone.
+		if d := src.topLevelDecl(f.Name); d != nil {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return src.topLevelDecl(names[i]).start > src.topLevelDecl(names[j]).start })
+
+	var edits []FixEdit
+	for _, name := range names {
+		if src, err = parseSource(filename, content); err != nil {
+			return nil, err
+		}
+		d := src.topLevelDecl(name)
+		var next []byte
+		if d.shared || d.tok == token.VAR && hasCall(d.spec) {
+			next, _ = src.removeValue(d)
+		} else {
+			next = removeSpans(content, []span{{src.lineStart(d.start), src.offset(d.end)}})
+		}
+		edits = append([]FixEdit{{
+			Filename:     filename,
+			StartLine:    src.fset.Position(d.start).Line,
+			EndLine:      src.fset.Position(d.end).Line,
+			Symbol:       name,
+			Action:       fixActionRemoved,
+			BytesRemoved: len(content) - len(next),
+		}}, edits...)
+		content = next
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+
+	if src, err = parseSource(filename, content); err != nil {
+		return nil, err
+	}
+	var empty []span
+	for _, decl := range src.file.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok != token.IMPORT && len(gd.Specs) == 0 {
+			empty = append(empty, span{src.lineStart(gd.Pos()), src.offset(gd.End())})
+		}
+	}
+	content = removeSpans(content, empty)
+	if content, err = removeUnusedImports(orig, content, r.importedNamesIn(dir)); err != nil {
+		return nil, err
+	}
+	if content, err = format.Source(content); err != nil {
+		return nil, err
+	}
+	if err := writeFiles(dir, []fileWrite{{filename, content}}, r.cfg.patched); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// topLevelDecl returns the declaration of the package level function, variable, constant or type name in s,
+// or nil if not found, e.g. for a method or a field.
+func (s *sourceFile) topLevelDecl(name string) *decl {
+	lookup := func(ident *ast.Ident) *decl {
+		return s.decls[declKey{line: s.fset.Position(ident.Pos()).Line, name: name}]
+	}
+	for _, d := range s.file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name == name {
+				return lookup(d.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, ident := range spec.Names {
+						if ident.Name == name {
+							return lookup(ident)
+						}
+					}
+				case *ast.TypeSpec:
+					if spec.Name.Name == name {
+						return lookup(spec.Name)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasCall reports whether any of the values of spec, if set, is or contains a function call, or a conversion.
+func hasCall(spec *ast.ValueSpec) bool {
+	if spec == nil {
+		return false
+	}
+	for _, v := range spec.Values {
+		var call bool
+		ast.Inspect(v, func(n ast.Node) bool {
+			switch n.(type) {
+			case *ast.CallExpr:
+				call = true
+			case *ast.FuncLit:
+				// Not called when declared.
+				return false
+			}
+			return !call
+		})
+		if call {
+			return true
+		}
+	}
+	return false
+}
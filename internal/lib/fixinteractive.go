@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// fixContextLines is the number of lines of code shown before and after a declaration in FixInteractive.
+const fixContextLines = 3
+
+// FixInteractive analyzes the workspace like Run, then walks through the unused symbols (EU1002) and the symbols
+// used in test only (EU1001), showing each declaration with the code around it and reading an action from in:
+//
+//   - r removes the declaration, or, for a symbol used in test only, moves it to a _test.go file as -fix does;
+//     an unused type is removed with its methods, and the name of a constant in an enum is replaced with _
+//     unless it is the last one, keeping the iota sequence,
+//   - k, or an empty line, keeps the symbol for now,
+//   - a keeps it always, adding its name to the keep list of the config file in the workspace root,
+//   - q quits.
+//
+// The decisions are written to the files right away.
+func FixInteractive(ctx context.Context, cfg RunConfig, in io.Reader) error {
+	t, err := newTriage(ctx, cfg, in)
+	if err != nil {
+		return err
+	}
+	return t.runFix()
+}
+
+func (t *triage) runFix() error {
+	var findings []Finding
+	for _, f := range t.r.findings {
+		if f.Code == codeUnused || f.Code == codeTestOnly {
+			findings = append(findings, f)
+		}
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(t.out, "nothing to remove")
+		return nil
+	}
+	var skipped int
+findings:
+	for i, f := range findings {
+		for {
+			line, err := t.locate(f)
+			if err != nil {
+				return err
+			}
+			if line == 0 {
+				// E.g. a method removed with its type.
+				fmt.Fprintf(t.out, "[%d/%d] %s is no longer declared in %s, skipped\n", i+1, len(findings), f.Name, f.Filename)
+				continue findings
+			}
+			f.Line = line
+
+			fmt.Fprintf(t.out, "\n[%d/%d] ", i+1, len(findings))
+			t.r.outputFinding(f).Print(t.out, false)
+			if err := t.previewWithContext(f); err != nil {
+				return err
+			}
+			fmt.Fprint(t.out, "[r]emove [k]eep keep-[a]lways [q]uit: ")
+			if !t.in.Scan() {
+				fmt.Fprintln(t.out)
+				break findings
+			}
+			switch action := strings.TrimSpace(t.in.Text()); action {
+			case "", "k":
+				skipped++
+				continue findings
+			case "a":
+				if err := t.keep(f); err != nil {
+					return err
+				}
+				continue findings
+			case "q":
+				break findings
+			case "r":
+				removed, err := t.remove(f)
+				if err != nil {
+					fmt.Fprintf(t.out, "remove failed: %s\n", err)
+				}
+				if removed {
+					continue findings
+				}
+			default:
+				fmt.Fprintf(t.out, "unknown action %q\n", action)
+			}
+		}
+	}
+	fmt.Fprintf(t.out, "%d removed, %d kept, %d kept always\n", t.fixed, skipped, t.kept)
+	return nil
+}
+
+// previewWithContext prints the declaration of f, as preview does, marked, with fixContextLines lines before and after it.
+func (t *triage) previewWithContext(f Finding) error {
+	src, err := parseSourceFile(filepath.Join(t.dir, f.Filename))
+	if err != nil {
+		return err
+	}
+	start, end := src.declRange(f.Line, symbolBase(f.Name))
+	if start == 0 {
+		return nil
+	}
+	lines := strings.Split(string(src.content), "\n")
+	from, to := max(1, start-fixContextLines), min(len(lines), end+fixContextLines)
+	if end-start+1 > triagePreviewLines {
+		// The context after a truncated declaration would be misleading.
+		end = start + triagePreviewLines - 1
+		to = end
+	}
+	for i := from; i <= to; i++ {
+		marker := " "
+		if i >= start && i <= end {
+			marker = ">"
+		}
+		fmt.Fprintf(t.out, "%s%5d | %s\n", marker, i, lines[i-1])
+	}
+	return nil
+}
+
+// remove removes the declaration of f, see FixInteractive, and reports whether the code was changed.
+func (t *triage) remove(f Finding) (bool, error) {
+	filename := filepath.Join(t.dir, f.Filename)
+	src, err := parseSourceFile(filename)
+	if err != nil {
+		return false, err
+	}
+	var content []byte
+	d, err := src.movableDecl(f)
+	if err != nil {
+		// E.g. a member of an enum, whose name is blanked to keep the values of the others.
+		d := src.decls[declKey{line: f.Line, name: symbolBase(f.Name)}]
+		if d == nil || !d.shared || f.Code == codeTestOnly {
+			return false, err
+		}
+		content, _ = src.removeValue(d)
+	} else if f.Code == codeTestOnly || d.tok == token.TYPE {
+		return t.fix(f)
+	} else {
+		start, end := src.offset(d.start), src.offset(d.end)
+		content = removeSpans(src.content, []span{{start, end}})
+	}
+	if content, err = removeUnusedImports(src.content, content, t.r.importedNamesIn(t.dir)); err != nil {
+		return false, err
+	}
+	if content, err = format.Source(content); err != nil {
+		return false, err
+	}
+	if err := writeFiles(t.dir, []fileWrite{{f.Filename, content}}, nil); err != nil {
+		return false, err
+	}
+	t.fixed++
+	fmt.Fprintf(t.out, "removed %s from %s\n", f.Name, f.Filename)
+	return true, nil
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestFixInteractive(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	const code = `package p
+
+import "strings"
+
+// Dead is unused.
+func Dead() string {
+	return strings.ToUpper("dead")
+}
+
+// Kept is unused.
+var Kept = 1
+
+// Always is unused.
+const Always = 2
+
+type T struct {
+	Field int
+}
+`
+	c.Assert(os.MkdirAll(filepath.Join(dir, "p"), 0o777), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p", "p.go"), []byte(code), 0o666), qt.IsNil)
+
+	r := &runner{cfg: RunConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}}
+	r.findings = []Finding{
+		{Filename: "p/p.go", Line: 6, Column: 6, Kind: "function", Name: "Dead", Code: codeUnused},
+		{Filename: "p/p.go", Line: 11, Column: 5, Kind: "variable", Name: "Kept", Code: codeUnused},
+		{Filename: "p/p.go", Line: 14, Column: 7, Kind: "constant", Name: "Always", Code: codeUnused},
+		{Filename: "p/p.go", Line: 17, Column: 2, Kind: "field", Name: "Field", Code: codeUnused},
+		{Filename: "p/p.go", Line: 16, Column: 6, Kind: "struct", Name: "T", Code: codeSamePkg},
+	}
+	var out bytes.Buffer
+	tr := &triage{r: r, dir: dir, in: newLineScanner("r\n\na\nr\nq\n"), out: &out}
+	c.Assert(tr.runFix(), qt.IsNil)
+
+	b, err := os.ReadFile(filepath.Join(dir, "p", "p.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, `package p
+
+// Kept is unused.
+var Kept = 1
+
+// Always is unused.
+const Always = 2
+
+type T struct {
+	Field int
+}
+`)
+	// Kept was moved up by the removal of Dead, with the context before it.
+	c.Assert(out.String(), qt.Contains, "[2/4] p/p.go:4:5 variable Kept is unused (EU1002)\n     1 | package p\n     2 | \n>    3 | // Kept is unused.\n>    4 | var Kept = 1\n     5 | \n")
+	c.Assert(out.String(), qt.Contains, "remove failed: "+errNotMovable.Error())
+	c.Assert(out.String(), qt.Contains, "1 removed, 1 kept, 1 kept always\n")
+
+	conf, err := LoadConfig(filepath.Join(dir, ConfigFilename))
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.Keep, qt.DeepEquals, []string{"^Always$"})
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Fingerprint returns an identifier of the edit, from the file, the symbol, the action and its target,
+// so the edits listed in a commit message can be checked against the fix report.
+func (e FixEdit) Fingerprint() string {
+	h := sha256.Sum256([]byte(e.Filename + "\x00" + e.Symbol + "\x00" + e.Action + "\x00" + e.Target))
+	return hex.EncodeToString(h[:8])
+}
+
+// fixDigest returns the digest of the edits in report, independent of their order.
+func fixDigest(report FixReport) string {
+	fingerprints := make([]string, len(report.Edits))
+	for i, e := range report.Edits {
+		fingerprints[i] = e.Fingerprint()
+	}
+	sort.Strings(fingerprints)
+	h := sha256.Sum256([]byte(strings.Join(fingerprints, "\n")))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// fixMessage returns a commit message for the edits in report: A summary, the edits with their
+// fingerprints, and git trailers with the punused version, the configuration hash and the digest of the edits.
+func fixMessage(report FixReport, version, configHash string) string {
+	counts := make(map[string]int)
+	for _, e := range report.Edits {
+		counts[e.Action]++
+	}
+	var parts []string
+	if n := counts[fixActionMoved]; n > 0 {
+		parts = append(parts, fmt.Sprintf("moved %s", plural(n, "declaration")))
+	}
+	if n := counts[fixActionRemoved]; n > 0 {
+		parts = append(parts, fmt.Sprintf("removed %s", plural(n, "declaration")))
+	}
+	if n := counts[fixActionRenamed]; n > 0 {
+		parts = append(parts, fmt.Sprintf("renamed %s", plural(n, "symbol")))
+	}
+	summary := "Nothing changed."
+	if len(parts) > 0 {
+		summary = strings.ToUpper(parts[0][:1]) + parts[0][1:]
+		if len(parts) > 1 {
+			summary += ", " + strings.Join(parts[1:], ", ")
+		}
+		summary += "."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Remove unused code found by punused\n\n%s\n", summary)
+	if len(report.Edits) > 0 {
+		b.WriteString("\n")
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, e := range report.Edits {
+			symbol := e.Symbol
+			if e.Target != "" {
+				symbol += " -> " + e.Target
+			}
+			fmt.Fprintf(w, "%s\t%s:%d-%d\t%s\t%s\n", e.Action, e.Filename, e.StartLine, e.EndLine, symbol, e.Fingerprint())
+		}
+		w.Flush()
+	}
+
+	fmt.Fprintf(&b, "\nPunused-Version: %s\nPunused-Config: %s\nPunused-Edits: %d\n", version, configHash, len(report.Edits))
+	if n := len(report.Failures); n > 0 {
+		fmt.Fprintf(&b, "Punused-Failures: %d\n", n)
+	}
+	fmt.Fprintf(&b, "Punused-Digest: %s\n", fixDigest(report))
+	return b.String()
+}
+
+func writeFixMessage(filename string, report FixReport, version, configHash string) error {
+	return os.WriteFile(filename, []byte(fixMessage(report, version, configHash)), 0o644)
+}
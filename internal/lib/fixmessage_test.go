@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestFixMessage(t *testing.T) {
+	c := qt.New(t)
+
+	report := FixReport{Edits: []FixEdit{
+		{Filename: "p/p.go", StartLine: 8, EndLine: 9, Symbol: "Helper", Action: fixActionMoved, Target: "p/p_test.go"},
+		{Filename: "p/p.go", StartLine: 12, EndLine: 14, Symbol: "T", Action: fixActionRemoved},
+		{Filename: "p/p.go", StartLine: 16, EndLine: 18, Symbol: "(T).M", Action: fixActionRemoved},
+	}}
+	msg := fixMessage(report, "v1.0.0", "abc")
+	c.Assert(msg, qt.Contains, "Moved 1 declaration, removed 2 declarations.\n")
+	c.Assert(msg, qt.Contains, "moved    p/p.go:8-9    Helper -> p/p_test.go  "+report.Edits[0].Fingerprint()+"\n")
+	c.Assert(msg, qt.Contains, "\nPunused-Version: v1.0.0\nPunused-Config: abc\nPunused-Edits: 3\nPunused-Digest: sha256:")
+	c.Assert(strings.HasSuffix(msg, fixDigest(report)+"\n"), qt.IsTrue)
+
+	// The digest does not depend on the order of the edits, nor on the lines.
+	reordered := FixReport{Edits: []FixEdit{report.Edits[2], report.Edits[0], report.Edits[1]}}
+	reordered.Edits[1].StartLine, reordered.Edits[1].EndLine = 20, 21
+	c.Assert(fixDigest(reordered), qt.Equals, fixDigest(report))
+	c.Assert(fixDigest(FixReport{Edits: report.Edits[1:]}), qt.Not(qt.Equals), fixDigest(report))
+
+	c.Assert(fixMessage(FixReport{Failures: []FixFailure{{Filename: "q/q.go"}}}, "v1.0.0", "abc"), qt.Contains, "Nothing changed.\n\nPunused-Version: v1.0.0\nPunused-Config: abc\nPunused-Edits: 0\nPunused-Failures: 1\n")
+}
@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FixReport describes the changes made by Fix, written to RunConfig.FixReport.
+type FixReport struct {
+	Edits    []FixEdit    `json:"edits"`
+	Failures []FixFailure `json:"failures,omitempty"`
+
+	// Reverted lists the package directories whose fixes were rolled back as the workspace
+	// no longer built with them, see RunConfig.FixVerify. Their findings are only reported.
+	Reverted []FixFailure `json:"reverted,omitempty"`
+}
+
+// FixEdit is a declaration removed from, or moved out of, a file, or a symbol renamed.
+type FixEdit struct {
+	// Filename is the file edited, relative to the workspace.
+	Filename string `json:"filename"`
+
+	// StartLine and EndLine are the 1-based lines of the declaration, including its doc comment, before the edit.
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+
+	// Symbol is the name of the symbol, e.g. (*MyType).MyMethod, or the names in a type declaration group.
+	Symbol string `json:"symbol"`
+
+	// Action is either moved (to Target), removed or renamed (to Target).
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+
+	// BytesRemoved is the size of the declaration removed from Filename.
+	BytesRemoved int `json:"bytes_removed"`
+
+	// Round is the fix round the edit was made in, starting at 1, see RunConfig.Iterations.
+	Round int `json:"round"`
+}
+
+// FixFailure is a file, or a package directory, that could not be fixed.
+type FixFailure struct {
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+
+	// RolledBack lists the files already written that were restored to their original content.
+	RolledBack []string `json:"rolled_back,omitempty"`
+}
+
+const (
+	fixActionMoved   = "moved"
+	fixActionRemoved = "removed"
+	fixActionRenamed = "renamed"
+)
+
+// fixFailed records that filename, or a package directory, relative to the workspace, could not be fixed.
+func (r *runner) fixFailed(filename string, err error) {
+	r.cfg.Logger.Error("fix failed", "filename", filename, "error", err)
+	failure := FixFailure{Filename: filename, Error: err.Error()}
+	var we *writeError
+	if errors.As(err, &we) {
+		failure.RolledBack = we.rolledBack
+	}
+	r.fixReport.Failures = append(r.fixReport.Failures, failure)
+}
+
+func writeFixReport(filename string, report FixReport) error {
+	if report.Edits == nil {
+		report.Edits = []FixEdit{}
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0o644)
+}
+
+// fileWrite is the new content of a file, relative to dir in writeFiles.
+type fileWrite struct {
+	filename string
+	content  []byte
+}
+
+// writeError is returned from writeFiles when a write failed, after restoring the files already written.
+type writeError struct {
+	err        error
+	rolledBack []string
+}
+
+func (e *writeError) Error() string { return e.err.Error() }
+func (e *writeError) Unwrap() error { return e.err }
+
+// writeFiles writes the files in dir, all or none: If a write fails, the files already written
+// are restored to their original content, or removed if they did not exist.
+// With patched set, see RunConfig.Patch, the content is set there instead.
+func writeFiles(dir string, writes []fileWrite, patched overlay) error {
+	if patched != nil {
+		for _, w := range writes {
+			patched[filepath.Join(dir, filepath.FromSlash(w.filename))] = string(w.content)
+		}
+		return nil
+	}
+	type original struct {
+		filename string
+		content  []byte
+		existed  bool
+	}
+	var written []original
+	rollback := func(err error) error {
+		we := &writeError{err: err}
+		for i := len(written) - 1; i >= 0; i-- {
+			o := written[i]
+			filename := filepath.Join(dir, filepath.FromSlash(o.filename))
+			var rerr error
+			if o.existed {
+				rerr = os.WriteFile(filename, o.content, 0o644)
+			} else {
+				rerr = os.Remove(filename)
+			}
+			if rerr != nil {
+				we.err = errors.Join(we.err, fmt.Errorf("failed to restore %s: %w", o.filename, rerr))
+				continue
+			}
+			we.rolledBack = append(we.rolledBack, o.filename)
+		}
+		return we
+	}
+	for _, w := range writes {
+		filename := filepath.Join(dir, filepath.FromSlash(w.filename))
+		content, err := os.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return rollback(err)
+		}
+		o := original{filename: w.filename, content: content, existed: err == nil}
+		if err := os.WriteFile(filename, w.content, 0o644); err != nil {
+			return rollback(err)
+		}
+		written = append(written, o)
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// removeUnusedTypes removes the unused types in the findings along with all their methods and their
+// unused constructors, e.g. NewMyType, from the files in dir, so no orphaned methods are left behind.
+// Types still referenced by other code, e.g. by an unused function taking it as an argument, are left alone.
+// It returns the number of types removed. The packages failing are recorded in the fix report.
+func (r *runner) removeUnusedTypes(dir string, findings []Finding) (int, error) {
+	// Types and unused functions by package directory.
+	types := make(map[string]map[string]bool)
+	funcs := make(map[string]map[string]bool)
+	add := func(m map[string]map[string]bool, pkg, name string) {
+		if m[pkg] == nil {
+			m[pkg] = make(map[string]bool)
+		}
+		m[pkg][name] = true
+	}
+	var typeFindings []Finding
+	for _, f := range findings {
+		if f.Code != codeUnused && f.Code != codeTransitive || strings.HasPrefix(f.Name, "(") {
+			continue
+		}
+		src, err := r.cfg.patched.parseSourceFile(filepath.Join(dir, f.Filename))
+		if err != nil {
+			return 0, err
+		}
+		d := src.decls[declKey{line: f.Line, name: f.Name}]
+		switch {
+		case d == nil:
+		case d.tok == token.TYPE:
+			typeFindings = append(typeFindings, f)
+		case d.tok == token.FUNC:
+			add(funcs, path.Dir(f.Filename), f.Name)
+		}
+	}
+	for _, f := range typeFindings {
+		pkg := path.Dir(f.Filename)
+		if onlyUsedByOwnCode(f, funcs[pkg]) {
+			add(types, pkg, f.Name)
+		}
+	}
+
+	pkgs := make([]string, 0, len(types))
+	for pkg := range types {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	var removed int
+	for _, pkg := range pkgs {
+		n, err := r.removeTypesInPackage(dir, pkg, types[pkg], funcs[pkg])
+		if err != nil {
+			r.fixFailed(pkg, fmt.Errorf("failed to remove unused types: %w", err))
+			continue
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// onlyUsedByOwnCode reports whether the type of f is only used by its own methods and
+// the given unused functions, if any.
+func onlyUsedByOwnCode(f Finding, funcs map[string]bool) bool {
+	for _, name := range f.UsedBy {
+		if receiverType(name) != f.Name && !funcs[name] {
+			return false
+		}
+	}
+	return true
+}
+
+type typesFile struct {
+	filename string
+	content  []byte
+	fset     *token.FileSet
+	file     *ast.File
+}
+
+// removeTypesInPackage removes the types in the package directory pkg, relative to dir, with their methods
+// and the constructors among the unused funcs, i.e. those returning one of the types.
+func (r *runner) removeTypesInPackage(dir, pkg string, types, funcs map[string]bool) (int, error) {
+	filenames, err := r.cfg.patched.glob(filepath.Join(dir, filepath.FromSlash(pkg), "*.go"))
+	if err != nil {
+		return 0, err
+	}
+	var files []typesFile
+	for _, filename := range filenames {
+		content, err := r.cfg.patched.readFile(filename)
+		if err != nil {
+			return 0, err
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, typesFile{filename: filename, content: content, fset: fset, file: file})
+	}
+
+	// Leave the types referenced from the code kept, until there are none.
+	var removals map[*typesFile][]ast.Node
+	for {
+		removals = make(map[*typesFile][]ast.Node)
+		for i := range files {
+			if nodes := typeRemovals(files[i].file, types, funcs); len(nodes) > 0 {
+				removals[&files[i]] = nodes
+			}
+		}
+		referenced := make(map[string]bool)
+		for i := range files {
+			referencedTypes(files[i].file, removals[&files[i]], types, referenced)
+		}
+		if len(referenced) == 0 {
+			break
+		}
+		for name := range referenced {
+			r.cfg.Logger.Info("unused type still referenced, not removed", "type", name, "package", pkg)
+			delete(types, name)
+		}
+	}
+
+	var (
+		writes []fileWrite
+		edits  []FixEdit
+	)
+	for i := range files {
+		tf := &files[i]
+		nodes := removals[tf]
+		if len(nodes) == 0 {
+			continue
+		}
+		filename, err := filepath.Rel(dir, tf.filename)
+		if err != nil {
+			return 0, err
+		}
+		filename = filepath.ToSlash(filename)
+		var spans []span
+		for _, node := range nodes {
+			start, end := tf.fset.Position(node.Pos()), tf.fset.Position(node.End())
+			spans = append(spans, span{start.Offset, end.Offset})
+			edits = append(edits, FixEdit{
+				Filename:     filename,
+				StartLine:    start.Line,
+				EndLine:      end.Line,
+				Symbol:       nodeSymbol(node),
+				Action:       fixActionRemoved,
+				BytesRemoved: lineCommentEnd(tf.content, end.Offset) - start.Offset,
+			})
+		}
+		content := removeSpans(tf.content, spans)
+		if content, err = removeUnusedImports(tf.content, content, r.importedNamesIn(dir)); err != nil {
+			return 0, err
+		}
+		if content, err = format.Source(content); err != nil {
+			return 0, err
+		}
+		writes = append(writes, fileWrite{filename, content})
+	}
+	if err := writeFiles(dir, writes, r.cfg.patched); err != nil {
+		return 0, err
+	}
+	r.fixReport.Edits = append(r.fixReport.Edits, edits...)
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.cfg.Logger.Info("removed unused type with its methods", "type", name, "package", pkg)
+	}
+	return len(names), nil
+}
+
+// typeRemovals returns the declarations in file to remove to remove the types: the type declarations
+// (or specs in a group) and the methods on them, and the funcs returning one of them.
+// The nodes include their doc comments.
+func typeRemovals(file *ast.File, types, funcs map[string]bool) []ast.Node {
+	var nodes []ast.Node
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if recv := recvTypeIdent(d); recv != nil && types[recv.Name] || d.Recv == nil && funcs[d.Name.Name] && returnsOneOf(d, types) {
+				nodes = append(nodes, withDoc{d.Doc, d})
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			var specs []ast.Node
+			for _, spec := range d.Specs {
+				if ts := spec.(*ast.TypeSpec); types[ts.Name.Name] {
+					specs = append(specs, withDoc{ts.Doc, ts})
+				}
+			}
+			if len(specs) > 0 && len(specs) == len(d.Specs) {
+				nodes = append(nodes, withDoc{d.Doc, d})
+			} else {
+				nodes = append(nodes, specs...)
+			}
+		}
+	}
+	return nodes
+}
+
+// referencedTypes adds the types referenced in file outside of the removed nodes to referenced.
+func referencedTypes(file *ast.File, removed []ast.Node, types, referenced map[string]bool) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		for _, rn := range removed {
+			if n.Pos() >= rn.Pos() && n.End() <= rn.End() {
+				return false
+			}
+		}
+		if id, ok := n.(*ast.Ident); ok && types[id.Name] {
+			referenced[id.Name] = true
+		}
+		return true
+	})
+}
+
+// nodeSymbol returns the name of the symbol declared by n, one of the nodes returned by typeRemovals,
+// e.g. (*MyType).MyMethod, or the names in a type declaration group.
+func nodeSymbol(n ast.Node) string {
+	if wd, ok := n.(withDoc); ok {
+		n = wd.node
+	}
+	switch d := n.(type) {
+	case *ast.FuncDecl:
+		recv := recvTypeIdent(d)
+		if recv == nil {
+			return d.Name.Name
+		}
+		if _, ok := d.Recv.List[0].Type.(*ast.StarExpr); ok {
+			return "(*" + recv.Name + ")." + d.Name.Name
+		}
+		return "(" + recv.Name + ")." + d.Name.Name
+	case *ast.TypeSpec:
+		return d.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			names = append(names, spec.(*ast.TypeSpec).Name.Name)
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+// withDoc is a node extended to include its doc comment.
+type withDoc struct {
+	doc  *ast.CommentGroup
+	node ast.Node
+}
+
+func (n withDoc) Pos() token.Pos {
+	if n.doc != nil {
+		return n.doc.Pos()
+	}
+	return n.node.Pos()
+}
+
+func (n withDoc) End() token.Pos { return n.node.End() }
+
+// returnsOneOf reports whether the first result of fd is one of types or a pointer to it.
+func returnsOneOf(fd *ast.FuncDecl, types map[string]bool) bool {
+	if fd.Type.Results == nil || len(fd.Type.Results.List) == 0 {
+		return false
+	}
+	typ := fd.Type.Results.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	id, ok := typ.(*ast.Ident)
+	return ok && types[id.Name]
+}
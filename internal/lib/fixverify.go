@@ -0,0 +1,257 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The verifications of the code changed by Fix, see RunConfig.FixVerify.
+const (
+	// fixVerifyBuild runs go build ./..., the default.
+	fixVerifyBuild = "build"
+
+	// fixVerifyVet also runs go vet ./..., which type checks the tests too.
+	fixVerifyVet = "vet"
+
+	// fixVerifyTest also compiles the tests, with go test -run=^$ ./..., running none.
+	fixVerifyTest = "test"
+
+	// fixVerifyNone leaves the code changed unverified.
+	fixVerifyNone = "none"
+)
+
+func validateFixVerify(verify string) error {
+	switch verify {
+	case "", fixVerifyBuild, fixVerifyVet, fixVerifyTest, fixVerifyNone:
+		return nil
+	}
+	return fmt.Errorf("invalid fix verification %q, one of build, vet, test or none", verify)
+}
+
+// fixVerifyCommands returns the go commands verifying the code changed by Fix with verify.
+func fixVerifyCommands(verify string) [][]string {
+	commands := [][]string{{"build", "./..."}}
+	switch verify {
+	case fixVerifyVet:
+		commands = append(commands, []string{"vet", "./..."})
+	case fixVerifyTest:
+		commands = append(commands, []string{"test", "-count=1", "-run=^$", "./..."})
+	}
+	return commands
+}
+
+// verifyBuild runs the commands of FixVerify in dir and returns the output of the first failing, if any.
+func (r *runner) verifyBuild(ctx context.Context, dir string) (string, []byte, error) {
+	for _, args := range fixVerifyCommands(r.cfg.FixVerify) {
+		cmd := exec.CommandContext(ctx, "go", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return "go " + strings.Join(args, " "), out, err
+		}
+	}
+	return "", nil, nil
+}
+
+// snapshotFix returns a snapshot of the package directories of findings, relative to dir, before a fix round,
+// or nil if the code changed is not verified: With FixVerify none, with Patch or Script, which leave the files
+// alone, or if the workspace does not build before the fixes, which is verified once.
+func (r *runner) snapshotFix(dir string, findings []Finding) (*fixSnapshot, error) {
+	if r.cfg.FixVerify == fixVerifyNone || r.cfg.patched != nil {
+		return nil, nil
+	}
+	if !r.fixVerified {
+		if command, out, err := r.verifyBuild(r.ctx, dir); err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			r.cfg.Logger.Warn("the fixes are not verified, the workspace does not build before them", "command", command, "error", buildError(out))
+			return nil, nil
+		}
+		r.fixVerified = true
+	}
+	return newFixSnapshot(dir, findings)
+}
+
+// fixSnapshot holds the content of the Go files in the package directories fixed, relative to dir,
+// before a fix round, to roll back the packages that no longer build after it, see verifyFix.
+type fixSnapshot struct {
+	dir   string
+	files map[string][]string
+	saved map[string][]byte
+}
+
+// newFixSnapshot saves the Go files of the package directories of findings, relative to dir.
+func newFixSnapshot(dir string, findings []Finding) (*fixSnapshot, error) {
+	s := &fixSnapshot{dir: dir, files: make(map[string][]string), saved: make(map[string][]byte)}
+	for _, f := range findings {
+		pkg := path.Dir(f.Filename)
+		if _, found := s.files[pkg]; found {
+			continue
+		}
+		filenames, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pkg), "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		s.files[pkg] = []string{}
+		for _, filename := range filenames {
+			b, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			rel := path.Join(pkg, filepath.Base(filename))
+			s.files[pkg] = append(s.files[pkg], rel)
+			s.saved[rel] = b
+		}
+	}
+	return s, nil
+}
+
+// changed returns the files changed or added in pkg since the snapshot, relative to dir.
+func (s *fixSnapshot) changed(pkg string) ([]string, error) {
+	filenames, err := filepath.Glob(filepath.Join(s.dir, filepath.FromSlash(pkg), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, filename := range filenames {
+		rel := path.Join(pkg, filepath.Base(filename))
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if saved, found := s.saved[rel]; !found || !bytes.Equal(saved, b) {
+			changed = append(changed, rel)
+		}
+	}
+	for _, rel := range s.files[pkg] {
+		if _, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(rel))); os.IsNotExist(err) {
+			changed = append(changed, rel)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// restore restores the files, relative to dir, to their content in the snapshot, removing those added.
+func (s *fixSnapshot) restore(filenames []string) error {
+	for _, rel := range filenames {
+		filename := filepath.Join(s.dir, filepath.FromSlash(rel))
+		if saved, found := s.saved[rel]; found {
+			if err := os.WriteFile(filename, saved, 0o644); err != nil {
+				return err
+			}
+		} else if err := os.Remove(filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errorPosition matches the file of a compiler or vet error, e.g. p/p.go:10:2: undefined: Foo.
+var errorPosition = regexp.MustCompile(`(?m)^(?:vet: )?(\S+\.go):\d+`)
+
+// buildError returns the first error in out, the output of a failing go command.
+func buildError(out []byte) string {
+	if loc := errorPosition.FindIndex(out); loc != nil {
+		return firstLine(string(out[loc[0]:]))
+	}
+	return firstLine(string(out))
+}
+
+// errorPackages returns the package directories, relative to dir, of the files in the errors in out.
+func errorPackages(dir string, out []byte) map[string]bool {
+	pkgs := make(map[string]bool)
+	for _, m := range errorPosition.FindAllSubmatch(out, -1) {
+		filename := filepath.FromSlash(string(m[1]))
+		if filepath.IsAbs(filename) {
+			rel, err := filepath.Rel(dir, filename)
+			if err != nil {
+				continue
+			}
+			filename = rel
+		}
+		pkgs[path.Dir(path.Clean(filepath.ToSlash(filename)))] = true
+	}
+	return pkgs
+}
+
+// verifyFix verifies that the workspace root dir still builds after a fix round, see FixVerify, rolling back the
+// packages in snap failing, or all of them changed if the errors are elsewhere, e.g. in a package importing one
+// fixed, until it does. The edits made in the packages rolled back, from start on, are dropped from the fix report,
+// with the package recorded as reverted, and their findings are left for report only, not fixed in later rounds.
+func (r *runner) verifyFix(dir string, snap *fixSnapshot, start int) error {
+	if r.reportOnly == nil {
+		r.reportOnly = make(map[string]bool)
+	}
+	for {
+		command, out, err := r.verifyBuild(r.ctx, dir)
+		if err == nil {
+			return nil
+		}
+		if ctxErr := r.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		changed := make(map[string][]string)
+		for pkg := range snap.files {
+			filenames, err := snap.changed(pkg)
+			if err != nil {
+				return err
+			}
+			if len(filenames) > 0 {
+				changed[pkg] = filenames
+			}
+		}
+		if len(changed) == 0 {
+			return fmt.Errorf("%s failed after rolling back the fixes: %s", command, buildError(out))
+		}
+
+		failing := errorPackages(dir, out)
+		rollback := make([]string, 0, len(changed))
+		for pkg := range changed {
+			if failing[pkg] {
+				rollback = append(rollback, pkg)
+			}
+		}
+		if len(rollback) == 0 {
+			for pkg := range changed {
+				rollback = append(rollback, pkg)
+			}
+		}
+		sort.Strings(rollback)
+
+		for _, pkg := range rollback {
+			if err := snap.restore(changed[pkg]); err != nil {
+				return fmt.Errorf("failed to roll back %s: %w", pkg, err)
+			}
+			r.cfg.Logger.Warn("fix rolled back", "package", pkg, "command", command, "error", buildError(out))
+			for _, rel := range changed[pkg] {
+				r.reportOnly[rel] = true
+			}
+			r.fixReport.Reverted = append(r.fixReport.Reverted, FixFailure{
+				Filename:   pkg,
+				Error:      command + " failed: " + buildError(out),
+				RolledBack: changed[pkg],
+			})
+
+			edits := r.fixReport.Edits[:start]
+			for _, e := range r.fixReport.Edits[start:] {
+				if path.Dir(e.Filename) == pkg {
+					r.reportOnly[e.Filename] = true
+					continue
+				}
+				edits = append(edits, e)
+			}
+			r.fixReport.Edits = edits
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestVerifyFix(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o777), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o666), qt.IsNil)
+	}
+	write("go.mod", "module example.com/m\n\ngo 1.21\n")
+	write("a/a.go", "package a\n\n// Dead is unused.\nfunc Dead() {}\n\nfunc Used() {}\n")
+	write("b/b.go", "package b\n\ntype T struct{}\n\nfunc (T) String() string { return \"\" }\n")
+	write("c/c.go", "package c\n\nimport (\n\t\"example.com/m/a\"\n\t\"example.com/m/b\"\n)\n\nvar _ interface{ String() string } = b.T{}\n\nfunc F() { a.Used() }\n")
+
+	r := &runner{ctx: context.Background(), cfg: RunConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}}
+	findings := []Finding{
+		{Filename: "a/a.go", Name: "Dead", Code: codeUnused},
+		{Filename: "b/b.go", Name: "(T).String", Code: codeUnused},
+	}
+	snap, err := r.snapshotFix(dir, findings)
+	c.Assert(err, qt.IsNil)
+	c.Assert(snap, qt.Not(qt.IsNil))
+
+	// Removing the method breaks the build of c, which imports b.
+	write("a/a.go", "package a\n\nfunc Used() {}\n")
+	write("b/b.go", "package b\n\ntype T struct{}\n")
+	r.fixReport.Edits = []FixEdit{
+		{Filename: "a/a.go", Symbol: "Dead", Action: fixActionRemoved},
+		{Filename: "b/b.go", Symbol: "(T).String", Action: fixActionRemoved},
+	}
+	c.Assert(r.verifyFix(dir, snap, 0), qt.IsNil)
+
+	b, err := os.ReadFile(filepath.Join(dir, "b", "b.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Contains, "String()")
+	b, err = os.ReadFile(filepath.Join(dir, "a", "a.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Contains, "Dead")
+
+	// The errors are in c, not fixed, so a and b are both rolled back.
+	c.Assert(r.fixReport.Edits, qt.HasLen, 0)
+	c.Assert(r.fixReport.Reverted, qt.HasLen, 2)
+	c.Assert(r.fixReport.Reverted[1].Filename, qt.Equals, "b")
+	c.Assert(r.fixReport.Reverted[1].RolledBack, qt.DeepEquals, []string{"b/b.go"})
+	c.Assert(r.reportOnly["b/b.go"], qt.IsTrue)
+
+	fixable, err := r.fixable(dir)
+	c.Assert(err, qt.IsNil)
+	c.Assert(fixable, qt.HasLen, 0)
+
+	// With the errors in a package fixed, only that is rolled back.
+	write("c/c.go", "package c\n\nimport \"example.com/m/a\"\n\nfunc F() { a.Used() }\n")
+	write("b/b2.go", "package b\n\nvar _ = T{}.String()\n")
+	r.fixReport, r.reportOnly = FixReport{}, nil
+	snap, err = r.snapshotFix(dir, findings)
+	c.Assert(err, qt.IsNil)
+	write("a/a.go", "package a\n\nfunc Used() {}\n")
+	write("b/b.go", "package b\n\ntype T struct{}\n")
+	r.fixReport.Edits = []FixEdit{
+		{Filename: "a/a.go", Symbol: "Dead", Action: fixActionRemoved},
+		{Filename: "b/b.go", Symbol: "(T).String", Action: fixActionRemoved},
+	}
+	c.Assert(r.verifyFix(dir, snap, 0), qt.IsNil)
+	c.Assert(r.fixReport.Edits, qt.DeepEquals, []FixEdit{{Filename: "a/a.go", Symbol: "Dead", Action: fixActionRemoved}})
+	c.Assert(r.fixReport.Reverted, qt.HasLen, 1)
+	c.Assert(r.fixReport.Reverted[0].Filename, qt.Equals, "b")
+}
+
+func TestErrorPackages(t *testing.T) {
+	c := qt.New(t)
+
+	out := []byte("# example.com/m/c\nc/c.go:8:38: cannot use b.T{} (value of struct type b.T) as interface{String() string} value\nvet: ./d/d.go:3:1: expected declaration\n")
+	c.Assert(errorPackages("/ws", out), qt.DeepEquals, map[string]bool{"c": true, "d": true})
+	c.Assert(buildError(out), qt.Equals, "c/c.go:8:38: cannot use b.T{} (value of struct type b.T) as interface{String() string} value")
+	c.Assert(validateFixVerify("lint"), qt.ErrorMatches, `invalid fix verification "lint", one of build, vet, test or none`)
+}
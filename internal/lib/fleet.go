@@ -0,0 +1,212 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FleetRepo is a repository analyzed by Fleet.
+type FleetRepo struct {
+	// Name identifies the repository in the combined report, prefixing its filenames, e.g. acme/billing.
+	Name string
+
+	// Source is the git URL, cloned or updated, or a local directory, analyzed as is.
+	Source string
+}
+
+// ReadFleetRepos reads the repositories listed in filename, one per line as the source, a git URL or a local
+// directory relative to the file, optionally followed by the name, which defaults to the path of the URL,
+// e.g. acme/billing for https://github.com/acme/billing.git. Blank lines and lines starting with # are ignored.
+func ReadFleetRepos(filename string) ([]FleetRepo, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		repos []FleetRepo
+		names = make(map[string]int)
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for i := 1; scanner.Scan(); i++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: want a source and an optional name, got %q", filename, i, scanner.Text())
+		}
+		repo := FleetRepo{Source: fields[0]}
+		if !isGitURL(repo.Source) && !filepath.IsAbs(repo.Source) {
+			repo.Source = filepath.Join(filepath.Dir(filename), repo.Source)
+		}
+		if len(fields) == 2 {
+			repo.Name = fields[1]
+		} else {
+			repo.Name = repoName(fields[0])
+		}
+		if line, found := names[repo.Name]; found {
+			return nil, fmt.Errorf("%s:%d: duplicate name %s, also on line %d, set the name after the source", filename, i, repo.Name, line)
+		}
+		names[repo.Name] = i
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// isGitURL reports whether source is a git URL, e.g. https://github.com/acme/billing or git@github.com:acme/billing.git,
+// rather than a local directory.
+func isGitURL(source string) bool {
+	if strings.Contains(source, "://") {
+		return true
+	}
+	// The scp-like syntax, e.g. git@github.com:acme/billing.git.
+	at, colon := strings.Index(source, "@"), strings.Index(source, ":")
+	return at > 0 && colon > at && !strings.Contains(source[:colon], "/")
+}
+
+// repoName returns the name of the repository for source, e.g. acme/billing for https://github.com/acme/billing.git,
+// or the base name of a local directory.
+func repoName(source string) string {
+	source = strings.TrimSuffix(strings.TrimSuffix(source, "/"), ".git")
+	if !isGitURL(source) {
+		return filepath.Base(source)
+	}
+	if _, rest, found := strings.Cut(source, "://"); found {
+		// Drop the host.
+		if _, p, found := strings.Cut(rest, "/"); found {
+			return p
+		}
+		return rest
+	}
+	_, p, _ := strings.Cut(source, ":")
+	return p
+}
+
+// FleetConfig configures Fleet.
+type FleetConfig struct {
+	Repos []FleetRepo
+
+	// Dir is the directory the repositories are cloned into, one directory per repository named after it.
+	Dir string
+
+	// Offline analyzes the repositories already cloned without updating them.
+	Offline bool
+
+	// Run configures the analysis of each repository, with its WorkspaceDir, IgnoreFile, PathPrefix, Out and Format
+	// set by Fleet.
+	// The fix and output file options do not apply.
+	Run RunConfig
+
+	// Format is the format of the combined report written to Out, one of text (default), json or sarif.
+	Format string
+	Out    io.Writer
+}
+
+// FleetResult is the outcome of a Fleet run.
+type FleetResult struct {
+	// Failed lists the repositories that could not be cloned, updated or analyzed, with the errors, by name.
+	Failed map[string]error
+}
+
+// Fleet clones, or updates, the repositories in cfg.Dir, analyzes them one after the other and writes the
+// combined report, with the filenames prefixed by the repository names, to cfg.Out.
+// The repositories failing are logged and left out of the report, but do not stop the run.
+func Fleet(ctx context.Context, cfg FleetConfig) (FleetResult, error) {
+	res := FleetResult{Failed: make(map[string]error)}
+	if len(cfg.Repos) == 0 {
+		return res, fmt.Errorf("no repositories")
+	}
+	logger := cfg.Run.Logger
+	if logger == nil {
+		return res, fmt.Errorf("Run.Logger is required")
+	}
+
+	var reports []Report
+	for _, repo := range cfg.Repos {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		report, err := analyzeFleetRepo(ctx, cfg, repo)
+		if err != nil {
+			logger.Error("failed to analyze repository", "repo", repo.Name, "error", err)
+			res.Failed[repo.Name] = err
+			continue
+		}
+		logger.Info("analyzed repository", "repo", repo.Name, "findings", len(report.Findings))
+		reports = append(reports, report)
+	}
+
+	format := cfg.Format
+	if format == "" {
+		format = formatText
+	}
+	if err := writeCombinedReport(cfg.Out, format, reports); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// analyzeFleetRepo syncs repo and returns its report.
+func analyzeFleetRepo(ctx context.Context, cfg FleetConfig, repo FleetRepo) (Report, error) {
+	dir := repo.Source
+	if isGitURL(repo.Source) {
+		dir = filepath.Join(cfg.Dir, filepath.FromSlash(repo.Name))
+		if err := syncRepo(dir, repo.Source, cfg.Offline); err != nil {
+			return Report{}, err
+		}
+	}
+
+	var buf bytes.Buffer
+	rc := cfg.Run
+	rc.WorkspaceDir = dir
+	rc.IgnoreFile = filepath.Join(dir, IgnoreFilename)
+	rc.PathPrefix = path.Clean(repo.Name)
+	rc.Out = &buf
+	rc.Format = formatJSON
+	rc.Logger = cfg.Run.Logger.With("repo", repo.Name)
+	rc.Fix, rc.Rename, rc.Patch, rc.Script = false, false, nil, nil
+	rc.FixReport, rc.FixMessage, rc.HistoryFile, rc.SummaryOut, rc.ManifestOut, rc.VerifyManifest = "", "", "", "", "", ""
+	rc.OwnersDir, rc.ModulesDir, rc.OutputDir, rc.Resume, rc.DotOut = "", "", "", "", nil
+	// The gates do not apply to the digest.
+	var fe *FailedError
+	if err := Run(ctx, rc); err != nil && !errors.As(err, &fe) {
+		return Report{}, err
+	}
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		return Report{}, fmt.Errorf("failed to read the report: %w", err)
+	}
+	return report, nil
+}
+
+// syncRepo clones url into dir, or updates the clone in dir to the latest commit of its default branch,
+// unless offline. The clones are shallow, and owned by Fleet, i.e. any local changes are discarded.
+func syncRepo(dir, url string, offline bool) error {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		if offline {
+			return nil
+		}
+		if _, err := git(dir, "fetch", "--depth", "1", "origin", "HEAD"); err != nil {
+			return err
+		}
+		_, err := git(dir, "reset", "--hard", "FETCH_HEAD")
+		return err
+	}
+	if offline {
+		return fmt.Errorf("%s is not cloned", url)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	_, err := git(filepath.Dir(dir), "clone", "--depth", "1", url, dir)
+	return err
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReadFleetRepos(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "repos.txt")
+	c.Assert(os.WriteFile(filename, []byte(`# Services.
+https://github.com/acme/billing.git
+
+git@github.com:acme/ledger.git ledger
+../tools
+/abs/tools abs-tools
+`), 0o644), qt.IsNil)
+
+	repos, err := ReadFleetRepos(filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(repos, qt.DeepEquals, []FleetRepo{
+		{Name: "acme/billing", Source: "https://github.com/acme/billing.git"},
+		{Name: "ledger", Source: "git@github.com:acme/ledger.git"},
+		{Name: "tools", Source: filepath.Join(filepath.Dir(dir), "tools")},
+		{Name: "abs-tools", Source: "/abs/tools"},
+	})
+
+	c.Assert(os.WriteFile(filename, []byte("/src/tools\n../tools\n"), 0o644), qt.IsNil)
+	_, err = ReadFleetRepos(filename)
+	c.Assert(err, qt.ErrorMatches, `.*:2: duplicate name tools, also on line 1.*`)
+
+	c.Assert(os.WriteFile(filename, []byte("../tools a b\n"), 0o644), qt.IsNil)
+	_, err = ReadFleetRepos(filename)
+	c.Assert(err, qt.ErrorMatches, `.*:1: want a source and an optional name.*`)
+}
+
+func TestRepoName(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(repoName("https://github.com/acme/billing.git"), qt.Equals, "acme/billing")
+	c.Assert(repoName("ssh://git@example.com:2222/acme/billing/"), qt.Equals, "acme/billing")
+	c.Assert(repoName("git@github.com:acme/billing.git"), qt.Equals, "acme/billing")
+	c.Assert(repoName("../src/billing"), qt.Equals, "billing")
+	c.Assert(isGitURL("file:///src/billing"), qt.IsTrue)
+	c.Assert(isGitURL("../src/user@host:billing"), qt.IsFalse)
+}
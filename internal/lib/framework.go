@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// isFrameworkAPI reports whether the method s is invoked by generated code or a framework
+// in ways invisible to gopls, and should never be reported.
+func (r *runner) isFrameworkAPI(filename string, s *Symbol, base string) (bool, error) {
+	if s.Kind != lsp.SKMethod {
+		return false, nil
+	}
+	if ok, err := r.isKubernetesAPI(filename, base); ok || err != nil {
+		return ok, err
+	}
+	if ok, err := r.isRegisteredInInit(filename, s); ok || err != nil {
+		return ok, err
+	}
+	return r.isProtobufAPI(filename, s, base)
+}
+
+// kubernetesMethods are the methods on Kubernetes API types invoked by apimachinery and controller-runtime.
+// The value is whether the file declaring the method needs to import a Kubernetes package,
+// for the names common enough to otherwise give false negatives.
+var kubernetesMethods = map[string]bool{
+	"GetObjectKind":  false,
+	"Hub":            true,
+	"ConvertTo":      true,
+	"ConvertFrom":    true,
+	"Default":        true,
+	"ValidateCreate": true,
+	"ValidateUpdate": true,
+	"ValidateDelete": true,
+}
+
+// isKubernetesAPI reports whether the method base declared in filename is required by the Kubernetes machinery,
+// i.e. the DeepCopy methods generated by deepcopy-gen and controller-gen, GetObjectKind and the conversion and
+// webhook methods.
+func (r *runner) isKubernetesAPI(filename string, base string) (bool, error) {
+	if strings.HasPrefix(base, "DeepCopy") {
+		return true, nil
+	}
+	needsImport, found := kubernetesMethods[base]
+	if !found {
+		return false, nil
+	}
+	if !needsImport {
+		return true, nil
+	}
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	for _, imp := range src.file.Imports {
+		path := strings.Trim(imp.Path.Value, `"`)
+		if strings.HasPrefix(path, "k8s.io/") || strings.HasPrefix(path, "sigs.k8s.io/") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isProtobufAPI reports whether the method s is part of the API of protobuf or gRPC generated code
+// that is used via registration or reflection invisible to gopls, i.e. either
+//
+//   - a getter (e.g. GetName) declared in a generated .pb.go file,
+//   - a method declared in a generated _grpc.pb.go file, e.g. of the GreeterServer interface, or
+//   - a method implementing a generated gRPC server interface.
+func (r *runner) isProtobufAPI(filename string, s *Symbol, base string) (bool, error) {
+	if strings.HasSuffix(filename, "_grpc.pb.go") || strings.HasPrefix(base, "Get") && strings.HasSuffix(filename, ".pb.go") {
+		generated, err := r.isGenerated(s.Location.URI)
+		if err != nil || generated {
+			return generated, err
+		}
+	}
+
+	if !r.hasGRPCFiles() {
+		// Avoid the relatively expensive implementation lookups.
+		return false, nil
+	}
+
+	impls, err := r.client.Implementation(r.ctx, s.Location)
+	if err != nil {
+		return false, fmt.Errorf("failed to get implementations: %w", err)
+	}
+	for _, impl := range impls {
+		if strings.HasSuffix(string(impl.URI), "_grpc.pb.go") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasGRPCFiles reports whether the workspace contains any _grpc.pb.go files.
+func (r *runner) hasGRPCFiles() bool {
+	if r.grpc == nil {
+		found := false
+		filepath.WalkDir(r.cfg.WorkspaceDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != r.cfg.WorkspaceDir {
+				return filepath.SkipDir
+			}
+			if strings.HasSuffix(path, "_grpc.pb.go") {
+				found = true
+				return fs.SkipAll
+			}
+			return nil
+		})
+		r.grpc = &found
+	}
+	return *r.grpc
+}
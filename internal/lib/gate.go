@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// FailedError is returned from Run when the findings fail the configured gates,
+// e.g. there are findings with severity error.
+type FailedError struct {
+	Reasons []string
+}
+
+func (e *FailedError) Error() string {
+	return "check failed: " + strings.Join(e.Reasons, "; ")
+}
+
+// Threshold is the finding budget for an area of the codebase.
+type Threshold struct {
+	// MaxFindings is the number of findings allowed before the run fails.
+	MaxFindings int `yaml:"max-findings" json:"max-findings"`
+}
+
+type threshold struct {
+	pattern string
+	matcher glob.Glob
+	Threshold
+}
+
+func compileThresholds(m map[string]Threshold) ([]threshold, error) {
+	var thresholds []threshold
+	for pattern, t := range m {
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("thresholds: invalid glob pattern %q: %w", pattern, err)
+		}
+		thresholds = append(thresholds, threshold{pattern: pattern, matcher: g, Threshold: t})
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i].pattern < thresholds[j].pattern })
+	return thresholds, nil
+}
+
+// gate checks the findings against the configured gates.
+// Findings in files covered by a threshold only fail the run when the threshold is exceeded,
+// so they're not counted as severity errors. Findings with the FailOn codes always fail the run.
+// With FailIntroducedOnly, the pre-existing findings are not counted at all,
+// nor, with FailOwners, the findings owned by others, with MaxUnusedPercent applying to the symbols owned.
+// With MaxFindings, the findings are only counted against it, like those covered by a threshold.
+// With FailOnExcluded, the files or directories gopls excluded from its view fail the run.
+func (r *runner) gate() error {
+	s := r.summary()
+	if len(r.cfg.FailOwners) > 0 {
+		var (
+			owned    []Finding
+			exported int
+		)
+		for _, f := range r.findings {
+			if ownedBy(f.Owners, r.cfg.FailOwners) {
+				owned = append(owned, f)
+			}
+		}
+		for group, n := range r.exportedByOwner {
+			if ownedBy(strings.Fields(group), r.cfg.FailOwners) {
+				exported += n
+			}
+		}
+		s = summarize(owned, exported)
+		s.LoadErrors = sortLoadErrors(r.loadErrors)
+	}
+	return gate(r.cfg, r.thresholds, r.findings, s)
+}
+
+// ownedBy reports whether any of owners, from CODEOWNERS, is one of names, see RunConfig.FailOwners.
+func ownedBy(owners, names []string) bool {
+	for _, name := range names {
+		if name == "unowned" && len(owners) == 0 {
+			return true
+		}
+		for _, owner := range owners {
+			if owner == name || strings.TrimPrefix(owner, "@") == name || strings.HasSuffix(owner, "/"+name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gate checks findings, summarized by s, against the gates in cfg, with thresholds compiled from cfg.Thresholds.
+func gate(cfg RunConfig, thresholds []threshold, findings []Finding, s Summary) error {
+	var reasons []string
+
+	counts := make([]int, len(thresholds))
+	failOn := make(map[string]bool)
+	for _, code := range cfg.FailOn {
+		failOn[code] = true
+	}
+	failing := make(map[string]int)
+	var errs, total int
+	for _, f := range findings {
+		if cfg.FailIntroducedOnly && f.Origin != originIntroduced {
+			continue
+		}
+		if len(cfg.FailOwners) > 0 && !ownedBy(f.Owners, cfg.FailOwners) {
+			continue
+		}
+		total++
+		covered := cfg.MaxFindings != nil
+		for i, t := range thresholds {
+			if t.matcher.Match(f.Filename) {
+				counts[i]++
+				covered = true
+			}
+		}
+		if !covered && f.Severity == SeverityError {
+			errs++
+		}
+		if failOn[f.Code] {
+			failing[f.Code]++
+		}
+	}
+
+	if errs > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d findings with severity error", errs))
+	}
+	for _, c := range checks {
+		if n := failing[c.Code]; n > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d findings with code %s", n, prefixedCode(cfg.CodePrefix, c.Code)))
+		}
+	}
+	if max := cfg.MaxFindings; max != nil && total > *max {
+		reasons = append(reasons, fmt.Sprintf("%d findings exceeds the maximum of %d", total, *max))
+	}
+	if max := cfg.MaxUnusedPercent; max != nil {
+		if s.UnusedPercent > *max {
+			reasons = append(reasons, fmt.Sprintf("%.2f%% of the exported symbols have findings, exceeds the maximum of %.2f%%", s.UnusedPercent, *max))
+		}
+	}
+	for i, t := range thresholds {
+		if counts[i] > t.MaxFindings {
+			reasons = append(reasons, fmt.Sprintf("%d findings in %s exceeds the maximum of %d", counts[i], t.pattern, t.MaxFindings))
+		} else {
+			cfg.Logger.Debug("threshold passed", "pattern", t.pattern, "findings", counts[i], "max", t.MaxFindings)
+		}
+	}
+	if cfg.FailOnExcluded {
+		var excluded int
+		for _, e := range s.LoadErrors {
+			if e.Excluded {
+				excluded++
+			}
+		}
+		if excluded > 0 {
+			reasons = append(reasons, fmt.Sprintf("gopls excluded %s from its view", plural(excluded, "file or directory")))
+		}
+	}
+
+	if len(reasons) > 0 {
+		return &FailedError{Reasons: reasons}
+	}
+	return nil
+}
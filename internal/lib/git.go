@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Blame holds the git blame information for the declaration line of a finding.
+type Blame struct {
+	Author string    `json:"author"`
+	Email  string    `json:"email,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Age returns the time passed since the line was last modified.
+func (b *Blame) Age() time.Duration {
+	return time.Since(b.Time)
+}
+
+// ParseAge parses a duration that, in addition to the units accepted by time.ParseDuration,
+// may be given in days, e.g. "90d".
+func ParseAge(s string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// gitBlame runs git blame on filename (relative to dir) and returns the blame info keyed by 1-based line number.
+func gitBlame(dir, filename string) (map[int]*Blame, error) {
+	cmd := exec.Command("git", "blame", "--line-porcelain", "--", filename)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame %s: %w: %s", filename, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseBlamePorcelain(out)
+}
+
+// parseBlamePorcelain parses the output of git blame --line-porcelain.
+func parseBlamePorcelain(b []byte) (map[int]*Blame, error) {
+	lines := make(map[int]*Blame)
+
+	var (
+		current *Blame
+		line    int
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "\t") {
+			// The source line ends the entry.
+			if current != nil {
+				lines[line] = current
+			}
+			current = nil
+			continue
+		}
+
+		var key, value string
+		if i := strings.IndexByte(text, ' '); i >= 0 {
+			key, value = text[:i], text[i+1:]
+		} else {
+			key = text
+		}
+
+		if current == nil {
+			// Header: <sha> <orig line> <final line> [<num lines>]
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("invalid blame header %q", text)
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid blame header %q: %w", text, err)
+			}
+			line = n
+			current = &Blame{}
+			continue
+		}
+
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-mail":
+			current.Email = strings.Trim(value, "<>")
+		case "author-time":
+			sec, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid author-time %q: %w", value, err)
+			}
+			current.Time = time.Unix(sec, 0).UTC()
+		}
+	}
+
+	return lines, scanner.Err()
+}
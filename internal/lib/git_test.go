@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseBlamePorcelain(t *testing.T) {
+	c := qt.New(t)
+
+	out := `4f30a5c0130f6b1de09a7d2c2b6f0e1b2d3c4e5f 1 1 2
+author Bjørn Erik Pedersen
+author-mail <bjorn.erik.pedersen@gmail.com>
+author-time 1634307130
+author-tz +0200
+summary Initial commit
+filename code.go
+	package p
+4f30a5c0130f6b1de09a7d2c2b6f0e1b2d3c4e5f 2 2
+author Bjørn Erik Pedersen
+author-mail <bjorn.erik.pedersen@gmail.com>
+author-time 1634307130
+author-tz +0200
+summary Initial commit
+filename code.go
+	
+`
+
+	lines, err := parseBlamePorcelain([]byte(out))
+	c.Assert(err, qt.IsNil)
+	c.Assert(lines, qt.HasLen, 2)
+	c.Assert(lines[1].Author, qt.Equals, "Bjørn Erik Pedersen")
+	c.Assert(lines[1].Email, qt.Equals, "bjorn.erik.pedersen@gmail.com")
+	c.Assert(lines[2].Time, qt.Equals, time.Unix(1634307130, 0).UTC())
+}
+
+func TestMinAgeOutsideGit(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.21\n"), 0o666), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p.go"), []byte("package m\n\nfunc Unused() {}\n"), 0o666), qt.IsNil)
+
+	// Without git blame, the findings are neither all dropped nor all reported.
+	err := Run(context.Background(), RunConfig{WorkspaceDir: dir, FilenamePatterns: []string{"**.go"}, Out: io.Discard, Backend: BackendPackages, MinAge: 24 * time.Hour})
+	c.Assert(err, qt.ErrorMatches, `(?s).*git blame p\.go.*`)
+}
+
+func TestFileCacheBlameError(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\n\nfunc F() {}\n"), 0o666), qt.IsNil)
+
+	// E.g. -blame decorating a finding before -fix-min-age checks it.
+	files := &fileCache{workspaceDir: dir}
+	_, err := files.blame("p.go")
+	c.Assert(err, qt.ErrorMatches, `(?s)git blame p\.go.*`)
+	r := &runner{}
+	_, err = r.modifiedWithin(files, Finding{Filename: "p.go", Line: 3, Name: "F"}, "F", time.Hour)
+	c.Assert(err, qt.ErrorMatches, `(?s)git blame p\.go.*`)
+}
@@ -3,40 +3,32 @@ package lib
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	lsp "github.com/sourcegraph/go-lsp"
-	"golang.org/x/sync/errgroup"
 )
 
 var requestID uint64 = 5000
 
-func newClient(ctx context.Context, workspaceDir string) (*GoplsClient, error) {
+// newClient starts the gopls binary for workspaceDir, forwarding to the gopls daemon remote, if set, see RunConfig.GoplsRemote.
+// settings, if set, are passed to gopls as initialization options, and env, if set, added to its environment.
+func newClient(ctx context.Context, binary, workspaceDir, remote string, logger *slog.Logger, settings map[string]any, env []string) (*GoplsClient, error) {
 	workspaceDir = path.Clean(filepath.ToSlash(workspaceDir))
 
-	args := []string{"serve"} //, "-rpc.trace", "-logfile=/Users/bep/dev/gopls.log"}
-	cmd := exec.Command("gopls", args...)
-	cmd.Stderr = os.Stderr
-	conn, err := newConn(cmd)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := conn.Start(); err != nil {
-		return nil, err
-	}
-
-	client := &GoplsClient{conn: conn, workspaceDir: workspaceDir}
-
-	initParams := &lsp.InitializeParams{
+	client := &GoplsClient{binary: binary, workspaceDir: workspaceDir, remote: remote, env: env, logger: logger, pending: make(map[uint64]chan response), stop: make(chan struct{})}
+	client.initParams = &lsp.InitializeParams{
 		RootURI: lsp.DocumentURI(client.documentURI("")),
 		Capabilities: lsp.ClientCapabilities{
 			TextDocument: lsp.TextDocumentClientCapabilities{
@@ -53,14 +45,62 @@ func newClient(ctx context.Context, workspaceDir string) (*GoplsClient, error) {
 		},
 	}
 
-	_, err = client.Initialize(ctx, initParams)
+	if settings != nil {
+		client.initParams.InitializationOptions = settings
+	}
+
+	proc, err := client.start(ctx)
 	if err != nil {
 		return nil, err
 	}
+	client.proc = proc
+
+	return client, nil
+}
 
-	err = client.Initialized(ctx)
+// stderrLines is the number of lines of the gopls stderr kept for the diagnostic when it fails.
+const stderrLines = 20
 
-	return client, err
+// start starts and initializes a gopls process.
+func (c *GoplsClient) start(ctx context.Context) (*goplsProcess, error) {
+	args := []string{"serve"} //, "-rpc.trace", "-logfile=/Users/bep/dev/gopls.log"}
+	if c.remote != "" {
+		// A global flag.
+		args = append([]string{"-remote=" + c.remote}, args...)
+	}
+	cmd := exec.Command(goplsBinary(c.binary), args...)
+	if c.env != nil {
+		cmd.Env = append(os.Environ(), c.env...)
+	}
+	stderr := &logWriter{logger: c.logger.With("source", "gopls"), level: slog.LevelWarn, keep: stderrLines}
+	if c.remote != "" {
+		// The forwarder reports the daemon connection closing on shutdown, the daemon logs elsewhere.
+		stderr.level = slog.LevelDebug
+	}
+	cmd.Stderr = stderr
+	// Do not wait for any daemon started, see RunConfig.GoplsRemote, holding on to stderr.
+	cmd.WaitDelay = time.Second
+	conn, err := newConn(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &goplsProcess{conn: conn, stderr: stderr, done: make(chan struct{})}
+	go c.readLoop(proc)
+
+	if err := c.call(ctx, proc, "initialize", c.initParams, &lsp.InitializeResult{}); err != nil {
+		proc.kill()
+		return nil, err
+	}
+	if err := c.call(ctx, proc, "initialized", &InitializedParams{}, nil); err != nil {
+		proc.kill()
+		return nil, err
+	}
+	return proc, nil
 }
 
 func newConn(cmd *exec.Cmd) (_ Conn, err error) {
@@ -102,28 +142,149 @@ func (c Conn) Close() error {
 	return nil
 }
 
-// Start starts conn's Cmd.
+// Start starts conn's Cmd, closing conn if that fails.
 func (c Conn) Start() error {
-	err := c.cmd.Start()
-	if err != nil {
-		return c.Close()
+	if err := c.cmd.Start(); err != nil {
+		c.Close()
+		return fmt.Errorf("failed to start %s: %w", c.cmd.Path, err)
 	}
-	return err
+	return nil
 }
 
 type GoplsClient struct {
+	// binary is the gopls binary run, see goplsBinary.
+	binary       string
 	workspaceDir string
+	remote       string
+	logger       *slog.Logger
+
+	// env is added to the environment of the gopls processes, e.g. GOPLSCACHE, see RunConfig.GoplsCacheDir.
+	env []string
+
+	// initParams initializes every gopls process started.
+	initParams *lsp.InitializeParams
+
+	// retries is the number of times a failed request is retried, waiting backoff,
+	// doubled for every attempt, in between.
+	retries int
+	backoff time.Duration
+
+	// timeout, if set, is the time a request may take before gopls is considered hung.
+	// restarts is the number of times gopls is restarted when it exits or hangs, see RunConfig.GoplsRestarts.
+	timeout  time.Duration
+	restarts int
+
+	// proc is the gopls process running, replaced on restart, restarted the number of restarts so far,
+	// opened the documents opened, reopened on restart, and closed set by Close, closing stop.
+	procMu    sync.Mutex
+	proc      *goplsProcess
+	restarted int
+	opened    []lsp.DidOpenTextDocumentParams
+	closed    bool
+	stop      chan struct{}
+
+	writeMu sync.Mutex
+
+	// pending holds the channels of the calls waiting for a response by request ID.
+	pendingMu sync.Mutex
+	pending   map[uint64]chan response
+
+	// errors holds the error diagnostics published by gopls by document, and
+	// messages the error messages shown, e.g. packages failing to load,
+	// both along with the warnings of the files or directories excluded from the view, see isExclusion.
+	errorsMu sync.Mutex
+	errors   map[lsp.DocumentURI][]lsp.Diagnostic
+	messages []string
+}
 
-	callMu sync.Mutex
+// goplsProcess is a gopls process started by GoplsClient.
+type goplsProcess struct {
 	conn   Conn
+	stderr *logWriter
+
+	// done is closed, with err set, when reading from gopls fails, e.g. when it exits or on Close.
+	done chan struct{}
+	err  error
+}
+
+// kill stops p, hung or not.
+func (p *goplsProcess) kill() {
+	if p.conn.cmd.Process != nil {
+		p.conn.cmd.Process.Kill()
+	}
+	p.conn.Close()
+}
+
+// goplsError is the error of a request failing because gopls exited or hung.
+type goplsError struct {
+	proc *goplsProcess
+	err  error
+}
+
+func (e *goplsError) Error() string {
+	msg := e.err.Error()
+	if lines := e.proc.stderr.lines(); len(lines) > 0 {
+		msg += "\ngopls stderr:\n\t" + strings.Join(lines, "\n\t")
+	}
+	return msg
+}
+
+func (e *goplsError) Unwrap() error {
+	return e.err
+}
+
+// notify records the errors in the notification resp.
+func (c *GoplsClient) notify(resp response) {
+	c.errorsMu.Lock()
+	defer c.errorsMu.Unlock()
+	switch resp.Method {
+	case "textDocument/publishDiagnostics":
+		var params lsp.PublishDiagnosticsParams
+		if err := json.Unmarshal(resp.Params, &params); err != nil {
+			return
+		}
+		var errs []lsp.Diagnostic
+		for _, d := range params.Diagnostics {
+			if d.Severity == lsp.Error || isExclusion(d.Message) {
+				errs = append(errs, d)
+			}
+		}
+		if c.errors == nil {
+			c.errors = make(map[lsp.DocumentURI][]lsp.Diagnostic)
+		}
+		// Every notification replaces the previous diagnostics for the document.
+		c.errors[params.URI] = errs
+	case "window/showMessage":
+		var params lsp.ShowMessageParams
+		if err := json.Unmarshal(resp.Params, &params); err == nil && (params.Type == lsp.MTError || isExclusion(params.Message)) {
+			c.messages = append(c.messages, params.Message)
+		}
+	}
+}
+
+// Errors returns the error diagnostics published so far by document, and the error messages shown,
+// including those of the exclusions from the view.
+func (c *GoplsClient) Errors() (map[lsp.DocumentURI][]lsp.Diagnostic, []string) {
+	c.errorsMu.Lock()
+	defer c.errorsMu.Unlock()
+	return c.errors, c.messages
 }
 
 // Call calls the gopls method with the params given. If result is non-nil, the response body is unmarshalled into it.
+// It's safe for concurrent use.
 func (c *GoplsClient) Call(ctx context.Context, method string, params, result interface{}) error {
-	// Only allow one call at a time for now.
-	c.callMu.Lock()
-	defer c.callMu.Unlock()
+	return c.call(ctx, c.process(), method, params, result)
+}
 
+// process returns the gopls process running.
+func (c *GoplsClient) process() *goplsProcess {
+	c.procMu.Lock()
+	defer c.procMu.Unlock()
+	return c.proc
+}
+
+// call calls method on proc, failing with a *goplsError if proc exits, or does not respond within c.timeout.
+func (c *GoplsClient) call(ctx context.Context, proc *goplsProcess, method string, params, result interface{}) error {
 	id := atomic.AddUint64(&requestID, 1)
 	req := request{
 		RPCVersion: "2.0",
@@ -134,35 +295,195 @@ func (c *GoplsClient) Call(ctx context.Context, method string, params, result in
 		Params: params,
 	}
 
-	if err := c.Write(req); err != nil {
-		return err
-	}
+	respChan := make(chan response, 1)
+	c.pendingMu.Lock()
+	c.pending[id] = respChan
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
 
-	respChan := make(chan response)
-	wg, ctx := errgroup.WithContext(ctx)
-	wg.Go(func() error {
-		return c.Read(ctx, id, respChan)
-	})
+	if err := c.write(proc.conn, req); err != nil {
+		// Report why gopls exited, if it did, rather than the broken pipe.
+		select {
+		case <-proc.done:
+			err = proc.err
+		case <-time.After(time.Second):
+		}
+		return &goplsError{proc: proc, err: err}
+	}
 
-	var unmarshalErr error
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 
 	select {
 	case resp := <-respChan:
+		if resp.Error != nil {
+			return resp.Error
+		}
 		if result != nil && resp.Result != nil {
-			unmarshalErr = json.Unmarshal(resp.Result, result)
+			return json.Unmarshal(resp.Result, result)
 		}
+		return nil
+	case <-proc.done:
+		return &goplsError{proc: proc, err: proc.err}
+	case <-timeout:
+		return &goplsError{proc: proc, err: fmt.Errorf("gopls did not respond to %s in %s", method, c.timeout)}
 	case <-ctx.Done():
 		return ctx.Err()
 	}
+}
 
-	if err := wg.Wait(); err != nil {
+// callWithRetry is Call retrying requests failed by gopls, which is common right after the workspace is loaded,
+// and restarting gopls when it exits or hangs, resuming with the request failing.
+func (c *GoplsClient) callWithRetry(ctx context.Context, method string, params, result interface{}) error {
+	backoff := c.backoff
+	for attempt := 0; ; {
+		err := c.Call(ctx, method, params, result)
+		var goplsErr *goplsError
+		if errors.As(err, &goplsErr) {
+			if err := c.restart(ctx, goplsErr); err != nil {
+				return err
+			}
+			// Not counted as an attempt, the restarts are limited on their own.
+			continue
+		}
+		var respErr *responseError
+		if err == nil || attempt >= c.retries || !errors.As(err, &respErr) {
+			return err
+		}
+		attempt++
+		c.logger.Debug("retrying gopls request", "method", method, "attempt", attempt, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// restart replaces the gopls process of the request failing with failed, unless already replaced for another request,
+// with a new one, with the documents opened reopened.
+// It returns failed if gopls is closed, or has been restarted c.restarts times already.
+func (c *GoplsClient) restart(ctx context.Context, failed *goplsError) error {
+	c.procMu.Lock()
+	defer c.procMu.Unlock()
+	if c.proc != failed.proc {
+		return nil
+	}
+	if c.closed || c.restarts == 0 {
+		return failed
+	}
+	if c.restarted >= c.restarts {
+		return fmt.Errorf("gave up after %d gopls restarts: %w", c.restarted, failed)
+	}
+	c.restarted++
+	c.logger.Warn("restarting gopls", "error", failed.err, "restart", c.restarted)
+	failed.proc.kill()
+
+	proc, err := c.start(ctx)
+	if err != nil {
+		return fmt.Errorf("%s\nfailed to restart gopls: %w", failed, err)
+	}
+	for _, params := range c.opened {
+		if err := c.call(ctx, proc, "textDocument/didOpen", params, nil); err != nil {
+			proc.kill()
+			return fmt.Errorf("%s\nfailed to reopen %s in gopls restarted: %w", failed, params.TextDocument.URI, err)
+		}
+	}
+	c.proc = proc
+	return nil
+}
+
+// open opens the document in params, sending its content to gopls to analyze instead of the file on disk.
+func (c *GoplsClient) open(ctx context.Context, params lsp.DidOpenTextDocumentParams) error {
+	if err := c.Call(ctx, "textDocument/didOpen", params, nil); err != nil {
+		return err
+	}
+	c.procMu.Lock()
+	c.opened = append(c.opened, params)
+	c.procMu.Unlock()
+	return nil
+}
+
+// change replaces the content of the document uri, opened with open, with text, as of version.
+func (c *GoplsClient) change(ctx context.Context, uri lsp.DocumentURI, version int, text string) error {
+	params := lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: version},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+	}
+	if err := c.Call(ctx, "textDocument/didChange", params, nil); err != nil {
+		return err
+	}
+	c.procMu.Lock()
+	defer c.procMu.Unlock()
+	for i := range c.opened {
+		if c.opened[i].TextDocument.URI == uri {
+			c.opened[i].TextDocument.Text, c.opened[i].TextDocument.Version = text, version
+		}
+	}
+	return nil
+}
+
+// closeDocument closes the document uri, opened with open, so gopls analyzes the file on disk again.
+func (c *GoplsClient) closeDocument(ctx context.Context, uri lsp.DocumentURI) error {
+	params := lsp.DidCloseTextDocumentParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}
+	if err := c.Call(ctx, "textDocument/didClose", params, nil); err != nil {
 		return err
 	}
-	return unmarshalErr
+	c.procMu.Lock()
+	defer c.procMu.Unlock()
+	opened := c.opened[:0]
+	for _, params := range c.opened {
+		if params.TextDocument.URI != uri {
+			opened = append(opened, params)
+		}
+	}
+	c.opened = opened
+	return nil
 }
 
+// shutdownTimeout is the time gopls is given to shut down on Close before it's killed.
+const shutdownTimeout = 5 * time.Second
+
+// Close shuts gopls down and waits for it to exit, killing it if it does not within shutdownTimeout,
+// e.g. when hung, so no gopls process is left behind.
 func (c *GoplsClient) Close() error {
-	return c.conn.Close()
+	c.procMu.Lock()
+	defer c.procMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.stop)
+
+	proc := c.proc
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	// The gopls daemon, see RunConfig.GoplsRemote, outlives the forwarder, exiting when its input is closed.
+	if c.remote == "" {
+		if err := c.call(ctx, proc, "shutdown", nil, nil); err == nil {
+			c.write(proc.conn, request{RPCVersion: "2.0", Method: "exit"})
+		}
+	}
+	proc.conn.WriteCloser.Close()
+	select {
+	case <-proc.done:
+	case <-ctx.Done():
+		c.logger.Warn("gopls did not shut down, killing it")
+		proc.kill()
+	}
+	if err := proc.conn.ReadCloser.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
 }
 
 func (s *GoplsClient) DocumentReferences(ctx context.Context, loc lsp.Location) ([]*lsp.Location, error) {
@@ -182,7 +503,25 @@ func (s *GoplsClient) DocumentReferences(ctx context.Context, loc lsp.Location)
 
 	var result []*lsp.Location
 
-	if err := s.Call(ctx, "textDocument/references", params, &result); err != nil {
+	if err := s.callWithRetry(ctx, "textDocument/references", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Implementation returns the locations of the implementations of the symbol at loc.
+// For a concrete method, these are the methods of the interfaces it implements.
+func (s *GoplsClient) Implementation(ctx context.Context, loc lsp.Location) ([]*lsp.Location, error) {
+	params := &lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: loc.URI,
+		},
+		Position: loc.Range.Start,
+	}
+
+	var result []*lsp.Location
+
+	if err := s.callWithRetry(ctx, "textDocument/implementation", params, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
@@ -198,7 +537,7 @@ func (s *GoplsClient) DocumentSymbol(ctx context.Context, filename string) ([]*S
 
 	var result []DocumentSymbol
 
-	if err := s.Call(ctx, "textDocument/documentSymbol", params, &result); err != nil {
+	if err := s.callWithRetry(ctx, "textDocument/documentSymbol", params, &result); err != nil {
 		return nil, err
 	}
 
@@ -210,120 +549,117 @@ func (s *GoplsClient) DocumentSymbol(ctx context.Context, filename string) ([]*S
 	return symbols, nil
 }
 
-// Read reads from gopls until candidate is found and sent on respChan.
-func (c *GoplsClient) Read(ctx context.Context, candidate uint64, respChan chan<- response) error {
-	done := make(chan bool)
-	var wg errgroup.Group
-
-	wg.Go(func() error {
-		for {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-				buff := make([]byte, 16)
-				_, err := io.ReadFull(c.conn, buff)
-				if err != nil {
-					return err
+// readLoop reads the messages from proc until it fails, passing the responses to the calls waiting for them.
+func (c *GoplsClient) readLoop(proc *goplsProcess) {
+	for {
+		resp, err := c.read(proc.conn)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				// gopls closed its stdout, i.e. exited.
+				err = errors.New("gopls exited")
+				if werr := proc.conn.cmd.Wait(); werr != nil {
+					err = fmt.Errorf("gopls exited: %w", werr)
 				}
+			}
+			proc.err = err
+			close(proc.done)
+			return
+		}
 
-				cl := make([]byte, 0, 2)
-				buff = buff[:1]
-				for {
-					_, err := io.ReadFull(c.conn, buff)
-					if err != nil {
-						return err
-					}
-					if buff[0] == '\r' {
-						break
-					}
-					cl = append(cl, buff[0])
-				}
+		// gopls sends a lot of chatter with ID=0 (notifications meant for the editor).
+		// We need to ignore those, except the errors.
+		if resp.ID == 0 {
+			c.notify(resp)
+			continue
+		}
+		if resp.Method != "" {
+			// A request from gopls, e.g. window/workDoneProgress/create.
+			continue
+		}
+		c.pendingMu.Lock()
+		respChan := c.pending[resp.ID]
+		c.pendingMu.Unlock()
+		if respChan != nil {
+			respChan <- resp
+		}
+	}
+}
 
-				// Consume the \n\r\n
-				buff = buff[:3]
-				_, err = io.ReadFull(c.conn, buff)
-				if err != nil {
-					return err
-				}
+// read reads the next message from gopls on conn.
+func (c *GoplsClient) read(conn Conn) (response, error) {
+	var resp response
+	buff := make([]byte, 16)
+	_, err := io.ReadFull(conn, buff)
+	if err != nil {
+		return resp, err
+	}
 
-				contentLength, err := strconv.Atoi(string(cl))
-				if err != nil {
-					return err
-				}
+	cl := make([]byte, 0, 2)
+	buff = buff[:1]
+	for {
+		_, err := io.ReadFull(conn, buff)
+		if err != nil {
+			return resp, err
+		}
+		if buff[0] == '\r' {
+			break
+		}
+		cl = append(cl, buff[0])
+	}
 
-				buff = make([]byte, contentLength)
-				_, err = io.ReadFull(c.conn, buff)
-				if err != nil {
-					return err
-				}
+	// Consume the \n\r\n
+	buff = buff[:3]
+	_, err = io.ReadFull(conn, buff)
+	if err != nil {
+		return resp, err
+	}
 
-				var resp response
-				if err := json.Unmarshal(buff, &resp); err != nil {
-					return err
-				}
+	contentLength, err := strconv.Atoi(string(cl))
+	if err != nil {
+		return resp, err
+	}
 
-				// gopls sends a lot of chatter with ID=0 (notifications meant for the editor).
-				// We need to ignore those.
-				if resp.ID == candidate {
-					close(done)
-					respChan <- resp
-					return nil
-				}
-			}
-		}
-	})
-
-	wg.Go(func() error {
-		for {
-			select {
-			case <-done:
-				return nil
-			case <-ctx.Done():
-				return c.conn.Close()
-			}
-		}
-	})
+	buff = make([]byte, contentLength)
+	_, err = io.ReadFull(conn, buff)
+	if err != nil {
+		return resp, err
+	}
 
-	return wg.Wait()
+	err = json.Unmarshal(buff, &resp)
+	return resp, err
 }
 
 // Write writes a request to gopls using the format specified by:
 // https://github.com/Microsoft/language-server-protocol/blob/gh-pages/_specifications/specification-3-14.md#text-documents
 func (c *GoplsClient) Write(r request) error {
+	return c.write(c.process().conn, r)
+}
+
+func (c *GoplsClient) write(conn Conn, r request) error {
 	b, err := json.Marshal(r)
 	if err != nil {
 		return err
 	}
-	_, err = c.conn.Write([]byte(fmt.Sprintf("Content-Length: %d\r\n\r\n", len(b))))
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = conn.Write([]byte(fmt.Sprintf("Content-Length: %d\r\n\r\n", len(b))))
 	if err != nil {
 		return err
 	}
-	_, err = c.conn.Write(b)
+	_, err = conn.Write(b)
 	return err
 }
 
-func (s *GoplsClient) Initialize(ctx context.Context, params *lsp.InitializeParams) (*lsp.InitializeResult, error) {
-	var result lsp.InitializeResult
-
-	if err := s.Call(ctx, "initialize", params, &result); err != nil {
-		return nil, err
-	}
-	return &result, nil
-}
-
-func (s *GoplsClient) Initialized(ctx context.Context) error {
-	return s.Call(ctx, "initialized", &InitializedParams{}, nil)
-}
-
 func (c *GoplsClient) documentSymbolToSymbol(uri lsp.DocumentURI, ds DocumentSymbol) *Symbol {
 	s := &Symbol{
-		Name: ds.Name,
-		Kind: ds.Kind,
+		Name:   ds.Name,
+		Kind:   ds.Kind,
+		Detail: ds.Detail,
 		Location: lsp.Location{
 			URI:   uri,
 			Range: ds.SelectionRange,
 		},
+		Range: ds.Range,
 	}
 
 	for _, d := range ds.Children {
@@ -334,17 +670,17 @@ func (c *GoplsClient) documentSymbolToSymbol(uri lsp.DocumentURI, ds DocumentSym
 }
 
 func (s *GoplsClient) documentURI(filename string) string {
-	filename = filepath.ToSlash(filename)
-	if path.IsAbs(filename) {
-		return "file://" + filename
-	}
-	return "file://" + path.Join(s.workspaceDir, filename)
+	return workspaceURI(s.workspaceDir, filename)
 }
 
 type Symbol struct {
 	Name     string
 	Kind     lsp.SymbolKind
+	Detail   string
 	Location lsp.Location
+
+	// Range is the range enclosing the entire declaration.
+	Range    lsp.Range
 	Children []*Symbol
 }
 
@@ -359,4 +695,19 @@ type response struct {
 	RPCVersion string          `json:"jsonrpc"`
 	ID         uint64          `json:"id"`
 	Result     json.RawMessage `json:"result"`
+	Error      *responseError  `json:"error"`
+
+	// Method and Params are set for notifications.
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// responseError is an error returned by gopls.
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("gopls: %s (code %d)", e.Message, e.Code)
 }
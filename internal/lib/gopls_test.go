@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestGoplsClientRestart(t *testing.T) {
+	c := qt.New(t)
+
+	dir := c.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n\ngo 1.22\n"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\n\nfunc A() {}\n"), 0o644), qt.IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client, err := newClient(ctx, "gopls", dir, "", slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil)
+	c.Assert(err, qt.IsNil)
+	defer client.Close()
+	client.restarts = 1
+	// The overlay is reopened on restart.
+	c.Assert(overlay{filepath.Join(dir, "p.go"): "package p\n\nfunc B() {}\n"}.open(ctx, client), qt.IsNil)
+
+	symbolNames := func() ([]string, error) {
+		symbols, err := client.DocumentSymbol(ctx, "p.go")
+		var names []string
+		for _, s := range symbols {
+			names = append(names, s.Name)
+		}
+		return names, err
+	}
+
+	// Crash gopls.
+	client.process().conn.cmd.Process.Kill()
+	names, err := symbolNames()
+	c.Assert(err, qt.IsNil)
+	c.Assert(names, qt.DeepEquals, []string{"B"})
+	c.Assert(client.restarted, qt.Equals, 1)
+
+	client.process().conn.cmd.Process.Kill()
+	_, err = symbolNames()
+	c.Assert(err, qt.ErrorMatches, `(?s)gave up after 1 gopls restarts: gopls exited: signal: killed.*`)
+}
+
+func TestConnStartFails(t *testing.T) {
+	c := qt.New(t)
+
+	binary := filepath.Join(c.TempDir(), "gopls")
+	conn, err := newConn(exec.Command(binary))
+	c.Assert(err, qt.IsNil)
+	c.Assert(conn.Start(), qt.ErrorMatches, `failed to start .*gopls: .*no such file or directory`)
+	_, err = conn.Write([]byte("{}"))
+	c.Assert(err, qt.ErrorIs, os.ErrClosed)
+}
+
+func TestGoplsClientTimeout(t *testing.T) {
+	c := qt.New(t)
+
+	// A gopls not responding.
+	cmd := exec.Command("sleep", "60")
+	stderr := &logWriter{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), keep: 2}
+	stderr.Write([]byte("one\ntwo\nthree\n"))
+	cmd.Stderr = stderr
+	conn, err := newConn(cmd)
+	c.Assert(err, qt.IsNil)
+	c.Assert(conn.Start(), qt.IsNil)
+	proc := &goplsProcess{conn: conn, stderr: stderr, done: make(chan struct{})}
+	defer proc.kill()
+	client := &GoplsClient{logger: stderr.logger, pending: make(map[uint64]chan response), proc: proc, timeout: 50 * time.Millisecond}
+	go client.readLoop(proc)
+
+	err = client.callWithRetry(context.Background(), "textDocument/documentSymbol", &lsp.DocumentSymbolParams{}, nil)
+	c.Assert(err, qt.ErrorMatches, "gopls did not respond to textDocument/documentSymbol in 50ms\ngopls stderr:\n\ttwo\n\tthree")
+}
@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// GoplsEnv is the environment variable naming the gopls binary used when RunConfig.Gopls is not set,
+// a path or a name looked up in PATH.
+const GoplsEnv = "PUNUSED_GOPLS"
+
+// maxGoplsVersion is the first gopls version not supported, i.e. the next major version,
+// which may change the protocol extensions we rely on.
+const maxGoplsVersion = "v1.0.0"
+
+// pinnedGoplsVersion is the gopls version installed with RunConfig.GoplsInstall.
+const pinnedGoplsVersion = "v0.16.2"
+
+// goplsInstallHint is how to get a supported gopls, appended to the errors about it.
+const goplsInstallHint = "install one with: go install golang.org/x/tools/gopls@latest, point -gopls or " + GoplsEnv + " to one, or use -gopls-install"
+
+// goplsResolved caches the binaries resolved by resolveGopls, keyed by its arguments.
+var goplsResolved sync.Map
+
+// goplsBinary returns the gopls binary to run, binary if set, else the one named by GoplsEnv, else gopls.
+func goplsBinary(binary string) string {
+	if binary != "" {
+		return binary
+	}
+	if binary = os.Getenv(GoplsEnv); binary != "" {
+		return binary
+	}
+	return "gopls"
+}
+
+// resolveGopls returns the path of the gopls binary, see goplsBinary, verifying that its version is supported,
+// so a missing or incompatible gopls fails the run up front instead of in its first request.
+// With install set, a missing or unsupported gopls is replaced by pinnedGoplsVersion, installed with go install
+// into cacheDir, or the user cache directory if not set, and reused by the following runs.
+func resolveGopls(ctx context.Context, binary string, install bool, cacheDir string) (string, error) {
+	binary = goplsBinary(binary)
+	key := fmt.Sprintf("%s\x00%t\x00%s", binary, install, cacheDir)
+	if filename, found := goplsResolved.Load(key); found {
+		return filename.(string), nil
+	}
+
+	filename, err := exec.LookPath(binary)
+	if err == nil {
+		err = validateGoplsVersion(goplsVersion(filename))
+		if err != nil {
+			err = fmt.Errorf("%s: %w", filename, err)
+		}
+	} else {
+		err = fmt.Errorf("gopls not found: %w", err)
+	}
+	if err != nil {
+		if !install {
+			return "", fmt.Errorf("%w; %s", err, goplsInstallHint)
+		}
+		if filename, err = installGopls(ctx, cacheDir); err != nil {
+			return "", err
+		}
+	}
+	goplsResolved.Store(key, filename)
+	return filename, nil
+}
+
+// validateGoplsVersion returns an error if the gopls version, as returned by goplsVersion, is not supported,
+// i.e. before minGoplsVersion or from maxGoplsVersion. A development build, e.g. (devel), is accepted as is.
+func validateGoplsVersion(version string) error {
+	switch {
+	case version == "":
+		return fmt.Errorf("failed to get the gopls version")
+	case !strings.HasPrefix(version, "v"):
+		return nil
+	case compareVersions(version, minGoplsVersion) < 0 || compareVersions(version, maxGoplsVersion) >= 0:
+		return fmt.Errorf("gopls %s is not supported, punused requires gopls %s or later, before %s", version, minGoplsVersion, maxGoplsVersion)
+	}
+	return nil
+}
+
+// installGopls installs gopls pinnedGoplsVersion into a directory of its own in cacheDir, or the user cache
+// directory if not set, unless already there, and returns the path of the binary.
+func installGopls(ctx context.Context, cacheDir string) (string, error) {
+	if cacheDir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to install gopls: %w", err)
+		}
+		cacheDir = filepath.Join(dir, "punused")
+	}
+	bin := filepath.Join(cacheDir, "gopls-"+pinnedGoplsVersion)
+	filename := filepath.Join(bin, "gopls")
+	if runtime.GOOS == "windows" {
+		filename += ".exe"
+	}
+	if _, err := os.Stat(filename); err == nil && goplsVersion(filename) != "" {
+		return filename, nil
+	}
+	cmd := exec.CommandContext(ctx, "go", "install", "golang.org/x/tools/gopls@"+pinnedGoplsVersion)
+	cmd.Env = append(os.Environ(), "GOBIN="+bin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to install gopls %s: %w: %s", pinnedGoplsVersion, err, strings.TrimSpace(stderr.String()))
+	}
+	return filename, nil
+}
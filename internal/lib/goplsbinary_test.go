@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestValidateGoplsVersion(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(validateGoplsVersion("v0.16.2"), qt.IsNil)
+	c.Assert(validateGoplsVersion(minGoplsVersion), qt.IsNil)
+	c.Assert(validateGoplsVersion("(devel)"), qt.IsNil)
+	c.Assert(validateGoplsVersion("v0.6.9"), qt.ErrorMatches, `gopls v0.6.9 is not supported, punused requires gopls v0.7.0 or later, before v1.0.0`)
+	c.Assert(validateGoplsVersion("v1.0.0"), qt.ErrorMatches, `gopls v1.0.0 is not supported.*`)
+	c.Assert(validateGoplsVersion(""), qt.ErrorMatches, `failed to get the gopls version`)
+}
+
+func TestResolveGopls(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts")
+	}
+	c := qt.New(t)
+	ctx := context.Background()
+	dir := c.TempDir()
+	fakeGopls := func(name, version string) string {
+		filename := filepath.Join(dir, name)
+		script := "#!/bin/sh\necho 'golang.org/x/tools/gopls " + version + "'\n"
+		c.Assert(os.WriteFile(filename, []byte(script), 0o755), qt.IsNil)
+		return filename
+	}
+
+	supported := fakeGopls("gopls-supported", "v0.16.2")
+	filename, err := resolveGopls(ctx, supported, false, "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(filename, qt.Equals, supported)
+
+	old := fakeGopls("gopls-old", "v0.6.0")
+	_, err = resolveGopls(ctx, old, false, "")
+	c.Assert(err, qt.ErrorMatches, `.*gopls-old: gopls v0.6.0 is not supported, punused requires gopls v0.7.0 or later, before v1.0.0; install one with: go install golang.org/x/tools/gopls@latest, point -gopls or PUNUSED_GOPLS to one, or use -gopls-install`)
+
+	_, err = resolveGopls(ctx, filepath.Join(dir, "missing"), false, "")
+	c.Assert(err, qt.ErrorMatches, `gopls not found: .*; install one with.*`)
+
+	c.Setenv(GoplsEnv, supported)
+	filename, err = resolveGopls(ctx, "", false, "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(filename, qt.Equals, supported)
+
+	// Installed already.
+	cacheDir := c.TempDir()
+	installed := filepath.Join(cacheDir, "gopls-"+pinnedGoplsVersion)
+	c.Assert(os.Mkdir(installed, 0o755), qt.IsNil)
+	dir = installed
+	fakeGopls("gopls", pinnedGoplsVersion)
+	filename, err = resolveGopls(ctx, old, true, cacheDir)
+	c.Assert(err, qt.IsNil)
+	c.Assert(filename, qt.Equals, filepath.Join(installed, "gopls"))
+}
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// goVersionRe matches the Go language versions accepted by RunConfig.GoVersion, e.g. 1.18 or 1.21.0.
+var goVersionRe = regexp.MustCompile(`^1\.\d+(\.\d+)?$`)
+
+func validateGoVersion(version string) error {
+	if version != "" && !goVersionRe.MatchString(version) {
+		return fmt.Errorf("invalid Go version %q, must be on the form 1.N, e.g. 1.18", version)
+	}
+	return nil
+}
+
+// goDirective returns the Go version of the go directive of the go.mod file filename, e.g. 1.22.0,
+// or an empty string if not available.
+func goDirective(filename string) string {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "go" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// goVersionModfile writes the go.mod file in dir, with its go directive set to version, if set, and its go.sum file,
+// if any, to a temporary directory, and returns the filename, passed to the go command with -modfile,
+// see RunConfig.GoVersion and RunConfig.ReadOnly. The caller removes the directory.
+// A go.work workspace is not supported, as -modfile cannot be used in workspace mode.
+func goVersionModfile(dir, version string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil && os.Getenv("GOWORK") != "off" {
+		if version == "" {
+			return "", fmt.Errorf("a go.work workspace cannot be analyzed read-only")
+		}
+		return "", fmt.Errorf("the Go version cannot be set for a go.work workspace")
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp("", "punused-modfile")
+	if err != nil {
+		return "", err
+	}
+	if version != "" {
+		b = withGoDirective(b, version)
+	}
+	filename := filepath.Join(tmp, "go.mod")
+	if err := os.WriteFile(filename, b, 0o644); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	sum, err := os.ReadFile(filepath.Join(dir, "go.sum"))
+	if err == nil {
+		err = os.WriteFile(filepath.Join(tmp, "go.sum"), sum, 0o644)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return filename, nil
+}
+
+// withGoDirective returns the go.mod or go.work file content b with its go directive set to version,
+// added after the module directive, or first, if missing.
+func withGoDirective(b []byte, version string) []byte {
+	directive := "go " + version
+	lines := strings.SplitAfter(string(b), "\n")
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "go" {
+			lines[i] = directive + "\n"
+			return []byte(strings.Join(lines, ""))
+		}
+	}
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+			lines[i] = strings.TrimSuffix(line, "\n") + "\n\n" + directive + "\n"
+			return []byte(strings.Join(lines, ""))
+		}
+	}
+	return []byte(directive + "\n" + string(b))
+}
+
+// moduleFiles returns an overlay of the Go files of the module in dir with their content on disk, to open in gopls
+// along with the modfile written by goVersionModfile: gopls only considers the packages of the go.mod file
+// of the workspace, or with a file open, as part of the workspace, i.e. searches them for references.
+// Hidden directories, testdata, vendor and nested modules are left out.
+func moduleFiles(dir string) (overlay, error) {
+	o := make(overlay)
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if filename == dir {
+				return nil
+			}
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || name == "testdata" || name == vendorDir {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(filename, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(filename, ".go") {
+			return nil
+		}
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		o[filename] = string(b)
+		return nil
+	})
+	return o, err
+}
+
+// removeModfile removes the temporary directory of the modfile written by goVersionModfile, if any.
+func removeModfile(modfile string) {
+	if modfile != "" {
+		os.RemoveAll(filepath.Dir(modfile))
+	}
+}
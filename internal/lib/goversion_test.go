@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWithGoDirective(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(string(withGoDirective([]byte("module example.com/m\n\ngo 1.22.1\n\ntoolchain go1.23.0\n"), "1.18")), qt.Equals,
+		"module example.com/m\n\ngo 1.18\n\ntoolchain go1.23.0\n")
+	c.Assert(string(withGoDirective([]byte("module example.com/m\n\nrequire example.com/dep v1.0.0\n"), "1.18")), qt.Equals,
+		"module example.com/m\n\ngo 1.18\n\nrequire example.com/dep v1.0.0\n")
+	c.Assert(string(withGoDirective([]byte("// A comment.\n"), "1.18")), qt.Equals, "go 1.18\n// A comment.\n")
+}
+
+func TestGoVersionModfile(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.22\n"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.sum"), []byte("example.com/dep v1.0.0 h1:x=\n"), 0o644), qt.IsNil)
+
+	modfile, err := goVersionModfile(dir, "1.18")
+	c.Assert(err, qt.IsNil)
+	b, err := os.ReadFile(modfile)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "module example.com/m\n\ngo 1.18\n")
+	_, err = os.Stat(filepath.Join(filepath.Dir(modfile), "go.sum"))
+	c.Assert(err, qt.IsNil)
+	removeModfile(modfile)
+	_, err = os.Stat(modfile)
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+
+	// Copied as is with ReadOnly.
+	modfile, err = goVersionModfile(dir, "")
+	c.Assert(err, qt.IsNil)
+	b, err = os.ReadFile(modfile)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "module example.com/m\n\ngo 1.22\n")
+	removeModfile(modfile)
+
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.work"), []byte("go 1.22\n\nuse .\n"), 0o644), qt.IsNil)
+	c.Setenv("GOWORK", "")
+	_, err = goVersionModfile(dir, "1.18")
+	c.Assert(err, qt.ErrorMatches, ".*go.work workspace")
+	_, err = goVersionModfile(dir, "")
+	c.Assert(err, qt.ErrorMatches, "a go.work workspace cannot be analyzed read-only")
+
+	c.Assert(validateGoVersion("1.21.0"), qt.IsNil)
+	c.Assert(validateGoVersion("go1.21"), qt.Not(qt.IsNil))
+}
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// headerMatchers match the header comments of files not analyzed, see RunConfig.SkipHeaders.
+type headerMatchers []*regexp.Regexp
+
+func compileHeaderMatchers(exprs []string) (headerMatchers, error) {
+	var m headerMatchers
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("skip-headers: invalid regular expression %q: %w", expr, err)
+		}
+		m = append(m, re)
+	}
+	return m, nil
+}
+
+// match returns the expression matching the header of the Go source in content, empty if none.
+func (m headerMatchers) match(content []byte) string {
+	if len(m) == 0 {
+		return ""
+	}
+	header := fileHeader(content)
+	for _, re := range m {
+		if re.MatchString(header) {
+			return re.String()
+		}
+	}
+	return ""
+}
+
+// fileHeader returns the text of the comments before the package clause, e.g. the license header.
+func fileHeader(content []byte) string {
+	file, err := parser.ParseFile(token.NewFileSet(), "", content, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, cg := range file.Comments {
+		if cg.Pos() > file.Package {
+			break
+		}
+		b.WriteString(cg.Text())
+	}
+	return b.String()
+}
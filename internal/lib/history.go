@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// historyRecord is the summary of a run appended to the history file, one JSON object per line.
+type historyRecord struct {
+	Time    time.Time `json:"time"`
+	Summary Summary   `json:"summary"`
+
+	// Fingerprints of all the findings, used to tell new findings from fixed ones.
+	Fingerprints []string `json:"fingerprints"`
+}
+
+func newHistoryRecord(summary Summary, findings []Finding) historyRecord {
+	rec := historyRecord{
+		Time:         time.Now().UTC(),
+		Summary:      summary,
+		Fingerprints: make([]string, len(findings)),
+	}
+	for i, f := range findings {
+		rec.Fingerprints[i] = f.Fingerprint()
+	}
+	return rec
+}
+
+// appendHistory appends rec to the history file, or object, see RunConfig.HistoryFile, creating it if needed.
+func appendHistory(ctx context.Context, loc string, rec historyRecord) error {
+	s, key, err := openStoreFile(loc)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return appendObject(ctx, s, key, append(b, '\n'))
+}
+
+func readHistory(ctx context.Context, loc string) ([]historyRecord, error) {
+	s, key, err := openStoreFile(loc)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []historyRecord
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for i := 1; scanner.Scan(); i++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", loc, i, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// Trend prints the runs recorded in the history file, or object, see RunConfig.HistoryFile,
+// with the deltas against the previous run.
+func Trend(ctx context.Context, w io.Writer, historyFile string) error {
+	records, err := readHistory(ctx, historyFile)
+	if err != nil {
+		return err
+	}
+	printTrend(w, records)
+	return nil
+}
+
+func printTrend(w io.Writer, records []historyRecord) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tFINDINGS\tUNUSED\tTEST ONLY\tLINES\tNEW\tFIXED\t")
+
+	var prev map[string]bool
+	for _, rec := range records {
+		current := make(map[string]bool, len(rec.Fingerprints))
+		for _, fp := range rec.Fingerprints {
+			current[fp] = true
+		}
+
+		var added, fixed int
+		for fp := range current {
+			if !prev[fp] {
+				added++
+			}
+		}
+		for fp := range prev {
+			if !current[fp] {
+				fixed++
+			}
+		}
+
+		s := rec.Summary
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n", rec.Time.Local().Format("2006-01-02 15:04"), s.Findings, s.Unused, s.TestOnly, s.Lines, added, fixed)
+
+		prev = current
+	}
+	tw.Flush()
+}
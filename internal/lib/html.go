@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"sort"
+)
+
+const formatHTML = "html"
+
+// htmlSnippetLines is the number of lines of the declarations shown in the HTML report, unless RunConfig.SnippetLines is set.
+const htmlSnippetLines = 10
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"checkURL": func(code string) string { return check{Code: canonicalCode(code)}.URL() },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>punused{{ with .Run }} {{ .Module }}{{ end }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+code, pre { font-size: 0.9em; }
+pre { margin: 0; background: #f6f8fa; padding: 0.5em; }
+.error { color: #b00020; }
+.warning { color: #a15c00; }
+.chart td { border: none; }
+.bar { display: inline-block; height: 0.9em; background: #4a78c2; }
+</style>
+</head>
+<body>
+<h1>punused{{ with .Run }} {{ .Module }}{{ end }}</h1>
+{{ with .Run }}<p>Analyzed {{ .Started.Format "2006-01-02 15:04 MST" }} with punused {{ .Version }}.</p>
+{{ end }}<p>{{ .Summary }}</p>
+{{ if .Findings }}{{ template "chart" .Kinds }}{{ template "chart" .Codes }}{{ with .Owners }}{{ template "chart" . }}{{ end }}<h2>Packages</h2>
+<ul>
+{{ range .Packages }}<li><a href="#{{ .Package }}">{{ .Package }}</a> ({{ len .Findings }})</li>
+{{ end }}</ul>
+{{ range .Packages }}<h2 id="{{ .Package }}">{{ .Package }}</h2>
+<table>
+<tr><th>Location</th><th>Symbol</th><th>Finding</th><th>Code</th><th>Severity</th>{{ if $.Owners }}<th>Owners</th>{{ end }}<th>Hint</th></tr>
+{{ range .Findings }}<tr><td><code>{{ with .SourceURL }}<a href="{{ . }}">{{ end }}{{ .Filename }}:{{ .Line }}:{{ .Column }}{{ if .SourceURL }}</a>{{ end }}</code></td><td>{{ .Kind }} <code>{{ .Name }}</code></td><td>{{ .Message }}{{ with .IssueURL }} (<a href="{{ . }}">issue</a>){{ end }}</td><td><a href="{{ checkURL .Code }}">{{ .Code }}</a></td><td class="{{ .Severity }}">{{ .Severity }}</td>{{ if $.Owners }}<td>{{ range .Owners }}<code>{{ . }}</code> {{ end }}</td>{{ end }}<td>{{ .Hint }}</td></tr>
+{{ with .Snippet }}<tr><td colspan="{{ if $.Owners }}7{{ else }}6{{ end }}"><pre><code>{{ . }}</code></pre></td></tr>
+{{ end }}{{ end }}</table>
+{{ end }}{{ end }}</body>
+</html>
+{{ define "chart" }}<table class="chart">
+{{ range . }}<tr><td>{{ .Label }}</td><td>{{ .Count }}</td><td><span class="bar" style="width: {{ .Width }}em"></span></td></tr>
+{{ end }}</table>
+{{ end }}`))
+
+// htmlBar is a bar of a chart of the HTML report.
+type htmlBar struct {
+	Label string
+	Count int
+
+	// Width is the length of the bar, in em, proportional to the largest count of the chart.
+	Width int
+}
+
+// htmlPackage is the findings of a package directory, sorted by position.
+type htmlPackage struct {
+	Package  string
+	Findings []Finding
+}
+
+// writeHTML writes report to w as a standalone HTML page: the summary, charts of the findings by symbol kind and
+// by check, and a table per package of the findings sorted by position, with the first lines of their declarations
+// and links to the checks' documentation, and to the source and the issue tracker if configured.
+func writeHTML(w io.Writer, info *RunInfo, report Report) error {
+	var summary bytes.Buffer
+	report.Summary.Print(&summary)
+
+	findings := sortedByPosition(report.Findings)
+	byPackage := make(map[string][]Finding)
+	for _, f := range findings {
+		byPackage[packageOf(f)] = append(byPackage[packageOf(f)], f)
+	}
+	packages := make([]htmlPackage, 0, len(byPackage))
+	for pkg, findings := range byPackage {
+		packages = append(packages, htmlPackage{Package: pkg, Findings: findings})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Package < packages[j].Package })
+
+	// The findings by CODEOWNERS owner group, if any are owned.
+	var owners []htmlBar
+	for _, f := range findings {
+		if len(f.Owners) > 0 {
+			owners = htmlChart(findings, func(f Finding) string {
+				if len(f.Owners) == 0 {
+					return "unowned"
+				}
+				return ownerGroup(f.Owners)
+			})
+			break
+		}
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		Run      *RunInfo
+		Summary  string
+		Findings []Finding
+		Kinds    []htmlBar
+		Codes    []htmlBar
+		Owners   []htmlBar
+		Packages []htmlPackage
+	}{
+		info, summary.String(), findings,
+		htmlChart(findings, func(f Finding) string { return f.Kind }),
+		htmlChart(findings, func(f Finding) string {
+			if c, found := checkByCode(f.Code); found {
+				return f.Code + " " + c.Short
+			}
+			return f.Code
+		}),
+		owners,
+		packages,
+	})
+}
+
+// htmlChart counts the findings by label, the largest counts first.
+func htmlChart(findings []Finding, label func(Finding) string) []htmlBar {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[label(f)]++
+	}
+	var bars []htmlBar
+	var max int
+	for l, n := range counts {
+		bars = append(bars, htmlBar{Label: l, Count: n})
+		if n > max {
+			max = n
+		}
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		if bars[i].Count != bars[j].Count {
+			return bars[i].Count > bars[j].Count
+		}
+		return bars[i].Label < bars[j].Label
+	})
+	for i := range bars {
+		bars[i].Width = (bars[i].Count*20 + max - 1) / max
+	}
+	return bars
+}
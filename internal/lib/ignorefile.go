@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// IgnoreFilename is the name of the ignore file in the workspace root, listing the IDs of the findings
+// suppressed, see Finding.ID and Suppress.
+const IgnoreFilename = ".punused-ignore"
+
+// idLength is the length of Finding.ID, a prefix of the fingerprint.
+const idLength = 8
+
+// findingID returns the ID of the finding with the given check code, filename and symbol name, see Finding.ID.
+func findingID(code, filename, name string) string {
+	return Finding{Code: code, Filename: filename, Name: name}.Fingerprint()[:idLength]
+}
+
+// loadIgnoreFile reads the IDs in the ignore file filename, one per line, optionally followed by a description,
+// e.g. the symbol and why it's kept. Blank lines and lines starting with # are ignored.
+// A missing file is not an error.
+func loadIgnoreFile(filename string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	if filename == "" {
+		return ids, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ids, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for i := 1; scanner.Scan(); i++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if !isFindingID(fields[0]) {
+			return nil, fmt.Errorf("%s:%d: invalid finding ID %q", filename, i, fields[0])
+		}
+		ids[fields[0]] = true
+	}
+	return ids, scanner.Err()
+}
+
+func isFindingID(s string) bool {
+	if len(s) != idLength {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Suppress analyzes the workspace like Run and appends the findings with the given IDs, see Finding.ID,
+// to cfg.IgnoreFile, with their symbol and the reason, if set, so they're no longer reported.
+// The IDs already in the file are left alone.
+func Suppress(ctx context.Context, cfg RunConfig, reason string, ids ...string) error {
+	if cfg.IgnoreFile == "" {
+		return errors.New("IgnoreFile is required")
+	}
+	for _, id := range ids {
+		if !isFindingID(id) {
+			return fmt.Errorf("invalid finding ID %q, use the id printed with -v or in the JSON output", id)
+		}
+	}
+	filename := cfg.IgnoreFile
+	ignored, err := loadIgnoreFile(filename)
+	if err != nil {
+		return err
+	}
+	// The findings already suppressed are not reported.
+	findings, err := Findings(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]Finding, len(findings))
+	for _, f := range findings {
+		byID[f.ID] = f
+	}
+
+	var sb strings.Builder
+	for _, id := range ids {
+		if ignored[id] {
+			continue
+		}
+		f, found := byID[id]
+		if !found {
+			return fmt.Errorf("no finding with ID %s", id)
+		}
+		ignored[id] = true
+		fmt.Fprintf(&sb, "%s %s %s in %s (%s)", id, f.Kind, f.Name, f.Filename, f.Code)
+		if reason != "" {
+			fmt.Fprintf(&sb, ": %s", reason)
+		}
+		sb.WriteByte('\n')
+		cfg.Logger.Info("suppressed finding", "id", id, "symbol", f.Name, "filename", f.Filename)
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(b) == 0 {
+		b = []byte("# Findings suppressed with punused suppress, by ID.\n")
+	} else if b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	return os.WriteFile(filename, append(b, sb.String()...), 0o666)
+}
@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLoadIgnoreFile(t *testing.T) {
+	c := qt.New(t)
+
+	filename := filepath.Join(t.TempDir(), IgnoreFilename)
+	ids, err := loadIgnoreFile(filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ids, qt.HasLen, 0)
+
+	c.Assert(os.WriteFile(filename, []byte("# Suppressed.\n3f2a9c1d function Handler in p/p.go (EU1002): plugin API\n\n9c1d3f2a\n"), 0o666), qt.IsNil)
+	ids, err = loadIgnoreFile(filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ids, qt.DeepEquals, map[string]bool{"3f2a9c1d": true, "9c1d3f2a": true})
+
+	c.Assert(os.WriteFile(filename, []byte("Handler\n"), 0o666), qt.IsNil)
+	_, err = loadIgnoreFile(filename)
+	c.Assert(err, qt.ErrorMatches, `.*:1: invalid finding ID "Handler"`)
+}
+
+func TestFindingID(t *testing.T) {
+	c := qt.New(t)
+
+	f := Finding{Filename: "p/p.go", Line: 3, Name: "Dead", Code: codeUnused}
+	id := findingID(f.Code, f.Filename, f.Name)
+	c.Assert(isFindingID(id), qt.IsTrue)
+	c.Assert(f.Fingerprint()[:idLength], qt.Equals, id)
+	// The same with a prefixed code.
+	c.Assert(findingID("ACME1002", f.Filename, f.Name), qt.Equals, id)
+	c.Assert(findingID(codeTestOnly, f.Filename, f.Name), qt.Not(qt.Equals), id)
+}
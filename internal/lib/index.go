@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// indexVersion is the version of the index format, incremented on incompatible changes.
+const indexVersion = 1
+
+// Index is a compact index of the usage classification of every analyzed exported symbol,
+// keyed by filename, for editor plugins to decorate the code without running the analysis.
+type Index struct {
+	Version int                     `json:"version"`
+	Files   map[string][]IndexEntry `json:"files"`
+}
+
+// IndexEntry is the usage classification of a symbol.
+type IndexEntry struct {
+	Line   int    `json:"l"`
+	Column int    `json:"c"`
+	Name   string `json:"n"`
+
+	// Code is the check code of the finding, empty if the symbol is used.
+	Code string `json:"code,omitempty"`
+
+	// Refs is the number of references counting as usage.
+	Refs int `json:"r"`
+}
+
+// ExportIndex analyzes the exported symbols like Run, but writes an Index of all of them,
+// with or without findings, to cfg.Out as compact JSON.
+func ExportIndex(ctx context.Context, cfg RunConfig) error {
+	idx := Index{Version: indexVersion, Files: make(map[string][]IndexEntry)}
+	err := walkSymbols(ctx, cfg, func(filename string, s *Symbol, code string, refs int) {
+		f := newFinding(filename, s, code)
+		idx.Files[filename] = append(idx.Files[filename], IndexEntry{Line: f.Line, Column: f.Column, Name: f.Name, Code: code, Refs: refs})
+	})
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(cfg.Out).Encode(idx)
+}
@@ -0,0 +1,282 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// survey is what Init found out about a workspace.
+type survey struct {
+	module string
+
+	// published is set for a module whose path starts with a domain, e.g. github.com/acme/lib,
+	// with packages outside internal and package main, which others may import.
+	published bool
+
+	// hasMain, hasInternal and hasTemplates are set if any package main, any internal package, or any
+	// embedded Go code template, see Config.EmbeddedTemplates, is found.
+	hasMain, hasInternal, hasTemplates bool
+
+	// frameworks are the frameworks imported, by name, e.g. cobra.
+	frameworks map[string]bool
+
+	// generated is the number of generated files, skipped by default.
+	generated int
+
+	// entryPoints, testPackages, weakRefs and exclude are the globs recommended for the config.
+	entryPoints, testPackages, weakRefs, exclude []string
+}
+
+// frameworkImports maps the import path prefixes of the frameworks detected by Init to their names.
+var frameworkImports = map[string]string{
+	"github.com/spf13/cobra":         "cobra",
+	"github.com/urfave/cli":          "urfave/cli",
+	"github.com/google/wire":         "wire",
+	"entgo.io/ent":                   "ent",
+	"k8s.io/":                        "kubernetes",
+	"sigs.k8s.io/controller-runtime": "kubernetes",
+	"google.golang.org/grpc":         "grpc",
+	"google.golang.org/protobuf":     "protobuf",
+	"github.com/golang/protobuf":     "protobuf",
+}
+
+// Init inspects the workspace dir, i.e. the frameworks imported, the generated files, the layout of its packages
+// and whether its module is published, and writes a starter config file, ConfigFilename, recommended for it,
+// to out, or to the workspace if nil, unless it has one already and force is not set.
+// What was found is printed to w.
+func Init(w, out io.Writer, dir string, force bool) error {
+	filename := filepath.Join(dir, ConfigFilename)
+	if out == nil && !force {
+		if _, err := os.Stat(filename); err == nil {
+			return fmt.Errorf("%s already exists, use -force to overwrite it", ConfigFilename)
+		}
+	}
+	s, err := surveyWorkspace(dir)
+	if err != nil {
+		return err
+	}
+	s.print(w)
+
+	content := s.config()
+	// Never write a config we cannot read.
+	var conf Config
+	if err := yaml.Unmarshal([]byte(content), &conf); err != nil {
+		return err
+	}
+	if err := conf.validate(); err != nil {
+		return err
+	}
+	if out != nil {
+		_, err = io.WriteString(out, content)
+		return err
+	}
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Wrote %s, review it and run punused.\n", ConfigFilename)
+	return nil
+}
+
+// surveyWorkspace walks the Go files in dir, leaving out hidden directories, testdata and vendor, as the go tool.
+func surveyWorkspace(dir string) (*survey, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		return nil, fmt.Errorf("workspace %s is not a Go module (go.mod is missing): %w", dir, err)
+	}
+	s := &survey{module: modulePath(dir), frameworks: make(map[string]bool)}
+	var (
+		libraries bool
+		seen      = make(map[string]bool)
+	)
+	addGlob := func(globs *[]string, glob string) {
+		if !seen[glob] {
+			seen[glob] = true
+			*globs = append(*globs, glob)
+		}
+	}
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			name := d.Name()
+			if filename == dir {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || name == "testdata" || name == vendorDir {
+				return filepath.SkipDir
+			}
+			switch name {
+			case "third_party", "third-party", "external":
+				addGlob(&s.exclude, rel+"/**")
+				return filepath.SkipDir
+			case "examples", "example", "_examples", "tools":
+				addGlob(&s.weakRefs, rel+"/**")
+			case "mocks", "mock", "fakes", "fake", "testutil", "testing":
+				addGlob(&s.testPackages, rel+"/**")
+			case "magefiles":
+				addGlob(&s.entryPoints, rel)
+			case "internal":
+				s.hasInternal = true
+			}
+			return nil
+		}
+		if strings.HasSuffix(filename, ".go.tmpl") || strings.HasSuffix(filename, ".go.tpl") {
+			s.hasTemplates = true
+			return nil
+		}
+		if !strings.HasSuffix(filename, ".go") {
+			return nil
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			// Reported by gopls when analyzing.
+			return nil
+		}
+		if ast.IsGenerated(file) {
+			s.generated++
+		}
+		for _, imp := range file.Imports {
+			p, _ := strconv.Unquote(imp.Path.Value)
+			for prefix, name := range frameworkImports {
+				if p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/") {
+					s.frameworks[name] = true
+				}
+			}
+		}
+		if strings.HasSuffix(filename, "_test.go") {
+			return nil
+		}
+		if file.Name.Name == "main" {
+			s.hasMain = true
+		} else if !isInternalDir(path.Dir(rel)) {
+			libraries = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	first, _, _ := strings.Cut(s.module, "/")
+	s.published = libraries && strings.Contains(first, ".") && first != "example.com"
+	return s, nil
+}
+
+// isInternalDir reports whether the package directory dir, relative to the module, is internal, i.e. only importable
+// from the module.
+func isInternalDir(dir string) bool {
+	return dir == "internal" || strings.HasPrefix(dir, "internal/") || strings.Contains(dir, "/internal/") || strings.HasSuffix(dir, "/internal")
+}
+
+// frameworkNames returns the names of the frameworks detected, sorted.
+func (s *survey) frameworkNames() []string {
+	var names []string
+	for name := range s.frameworks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// print prints what was found to w.
+func (s *survey) print(w io.Writer) {
+	fmt.Fprintf(w, "Module: %s\n", s.module)
+	if s.published {
+		fmt.Fprintln(w, "Published: yes, its exported packages may be imported by other modules")
+	} else {
+		fmt.Fprintln(w, "Published: no, all its usage is in the workspace")
+	}
+	if names := s.frameworkNames(); len(names) > 0 {
+		fmt.Fprintf(w, "Frameworks: %s\n", strings.Join(names, ", "))
+	}
+	fmt.Fprintf(w, "Generated files: %d (skipped by default)\n", s.generated)
+	if s.hasInternal {
+		fmt.Fprintln(w, "Internal packages: yes")
+	}
+	if s.hasMain {
+		fmt.Fprintln(w, "Main packages: yes")
+	}
+}
+
+// config returns the recommended config file, with comments.
+func (s *survey) config() string {
+	var b strings.Builder
+	b.WriteString("# Generated by punused init. Review it, see the README for all the options.\n")
+	if names := s.frameworkNames(); len(names) > 0 {
+		fmt.Fprintf(&b, "# Frameworks detected: %s.\n", strings.Join(names, ", "))
+	}
+	if s.frameworks["kubernetes"] || s.frameworks["grpc"] || s.frameworks["protobuf"] {
+		b.WriteString("# The methods called by the Kubernetes, gRPC and protobuf machinery are recognized without config.\n")
+	}
+	if s.generated > 0 {
+		fmt.Fprintf(&b, "# The %d generated files found are skipped, see -include-generated.\n", s.generated)
+	}
+
+	b.WriteString("\n")
+	if s.published {
+		b.WriteString("# The module is published, so its exported API may be used by other modules:\n")
+		b.WriteString("# Unused symbols are warnings, and the consumers known can be listed in downstream.\n")
+		b.WriteString("severity:\n  EU1002: warning\n")
+		b.WriteString("# downstream: [\"github.com/acme/app@latest\"]\n")
+		if s.hasInternal {
+			b.WriteString("# Nothing outside the module can import its internal packages, whose unused\n")
+			b.WriteString("# symbols are dead code either way.\n")
+		}
+	} else {
+		b.WriteString("# The module is not published, so all its usage is in the workspace:\n")
+		b.WriteString("# Unused symbols fail the run.\n")
+		b.WriteString("fail-on: [EU1002]\n")
+	}
+
+	if s.hasMain {
+		b.WriteString("\n# Nothing can import package main, so its exported symbols, e.g. command\n")
+		b.WriteString("# variables, are only reported if without any references.\n")
+		b.WriteString("main-packages: " + mainPackagesUnexportedRule + "\n")
+	}
+	if s.frameworks["wire"] {
+		b.WriteString("\n# The wire providers are referenced from the wire.go files built with the\n")
+		b.WriteString("# wireinject tag only.\n")
+		b.WriteString("keep: [\"^Provide\"]\n")
+	}
+	if s.frameworks["ent"] {
+		b.WriteString("\n# The methods of the ent generated types are its API.\n")
+		b.WriteString("receivers: [\"ent.*\"]\n")
+	}
+	if s.hasTemplates {
+		b.WriteString("\n# The Go code templates embedded, whose identifiers are used by the generated code.\n")
+		b.WriteString("embedded-templates: [\"**/*.go.tmpl\", \"**/*.go.tpl\"]\n")
+	}
+	writeGlobs(&b, "entrypoints", "Package directories whose exported symbols are entry points.", s.entryPoints)
+	writeGlobs(&b, "test-packages", "Package directories of test infrastructure, used in test only by design.", s.testPackages)
+	writeGlobs(&b, "weak-refs", "Files whose references are weak usage, reported as EU1014.", s.weakRefs)
+	writeGlobs(&b, "exclude", "Directories of third-party code, not analyzed.", s.exclude)
+	return b.String()
+}
+
+// writeGlobs writes the config key with the globs, if any, preceded by the comment.
+func writeGlobs(b *strings.Builder, key, comment string, globs []string) {
+	if len(globs) == 0 {
+		return
+	}
+	quoted := make([]string, len(globs))
+	for i, glob := range globs {
+		quoted[i] = strconv.Quote(glob)
+	}
+	fmt.Fprintf(b, "\n# %s\n%s: [%s]\n", comment, key, strings.Join(quoted, ", "))
+}
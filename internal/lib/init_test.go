@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestInit(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o777), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o666), qt.IsNil)
+	}
+	write("go.mod", "module github.com/acme/lib\n")
+	write("api/api.go", "package api\n\nimport \"github.com/google/wire\"\n\nvar Set = wire.NewSet()\n")
+	write("api/api.pb.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n\nimport _ \"google.golang.org/protobuf/proto\"\n")
+	write("cmd/lib/main.go", "package main\n\nimport \"github.com/spf13/cobra\"\n\nvar RootCmd = &cobra.Command{}\n")
+	write("internal/mocks/api.go", "package mocks\n")
+	write("examples/basic/main.go", "package main\n")
+	write("third_party/x/x.go", "package x\n")
+
+	var log, out bytes.Buffer
+	c.Assert(Init(&log, &out, dir, false), qt.IsNil)
+	c.Assert(log.String(), qt.Contains, "Published: yes")
+	c.Assert(log.String(), qt.Contains, "Frameworks: cobra, protobuf, wire\n")
+	c.Assert(log.String(), qt.Contains, "Generated files: 1")
+
+	filename := filepath.Join(dir, ConfigFilename)
+	c.Assert(os.WriteFile(filename, out.Bytes(), 0o666), qt.IsNil)
+	conf, err := LoadConfig(filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.Severity, qt.DeepEquals, map[string]Severity{"EU1002": SeverityWarning})
+	c.Assert(conf.FailOn, qt.IsNil)
+	c.Assert(conf.MainPackages, qt.Equals, mainPackagesUnexportedRule)
+	c.Assert(conf.Keep, qt.DeepEquals, []string{"^Provide"})
+	c.Assert(conf.TestPackages, qt.DeepEquals, []string{"internal/mocks/**"})
+	c.Assert(conf.WeakRefs, qt.DeepEquals, []string{"examples/**"})
+	c.Assert(conf.Exclude, qt.DeepEquals, []string{"third_party/**"})
+
+	c.Assert(Init(&log, nil, dir, false), qt.ErrorMatches, `.*already exists.*`)
+	c.Assert(Init(&log, nil, dir, true), qt.IsNil)
+
+	// Not published.
+	write("go.mod", "module app\n")
+	out.Reset()
+	c.Assert(Init(&log, &out, dir, false), qt.IsNil)
+	c.Assert(out.String(), qt.Contains, "fail-on: [EU1002]\n")
+}
@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// implementsInterface reports whether the concrete method s, named base, implements a method of an interface,
+// e.g. Error, String or ServeHTTP, in the workspace or not. Removing it breaks compilation wherever its type is
+// used as the interface, typically in a package gopls does not report the references from, e.g. fmt or net/http.
+func (r *runner) implementsInterface(s *Symbol, base string) (bool, error) {
+	if s.Kind != lsp.SKMethod || receiverType(s.Name) == "" {
+		// Not a method, or an interface method.
+		return false, nil
+	}
+	impls, err := r.client.Implementation(r.ctx, s.Location)
+	if err != nil {
+		return false, fmt.Errorf("failed to get implementations: %w", err)
+	}
+	for _, impl := range impls {
+		// For a method not implementing any, gopls returns the interfaces implemented by the receiver type,
+		// and for Error, the builtin error type.
+		name, err := identAt(impl)
+		if err != nil {
+			return false, err
+		}
+		if name == base || base == "Error" && name == "error" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// identAt returns the text at the single-line range of loc, e.g. the name of a declaration.
+func identAt(loc *lsp.Location) (string, error) {
+	filename, err := uriFilename(loc.URI)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	lines := bytes.Split(content, []byte("\n"))
+	start, end := loc.Range.Start, loc.Range.End
+	if start.Line != end.Line || start.Line >= len(lines) || end.Character > len(lines[start.Line]) || start.Character > end.Character {
+		return "", nil
+	}
+	return string(lines[start.Line][start.Character:end.Character]), nil
+}
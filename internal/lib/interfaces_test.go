@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestIdentAt(t *testing.T) {
+	c := qt.New(t)
+
+	// The URIs are percent-encoded, as by gopls.
+	dir := filepath.Join(t.TempDir(), "my module")
+	c.Assert(os.Mkdir(dir, 0o755), qt.IsNil)
+	filename := filepath.Join(dir, "code.go")
+	c.Assert(os.WriteFile(filename, []byte("package p\n\ntype Stringer interface {\n\tString() string\n}\n"), 0o644), qt.IsNil)
+	uri := lsp.DocumentURI(workspaceURI("", filename))
+	c.Assert(string(uri), qt.Contains, "my%20module")
+
+	loc := func(line, start, end int) *lsp.Location {
+		return &lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: line, Character: start}, End: lsp.Position{Line: line, Character: end}}}
+	}
+	for _, test := range []struct {
+		loc  *lsp.Location
+		want string
+	}{
+		{loc(2, 5, 13), "Stringer"},
+		{loc(3, 1, 7), "String"},
+		{loc(3, 1, 70), ""},
+		{loc(9, 0, 1), ""},
+	} {
+		name, err := identAt(test.loc)
+		c.Assert(err, qt.IsNil)
+		c.Assert(name, qt.Equals, test.want)
+	}
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// issueLinkData is the data passed to the issue and source link templates, e.g.
+//
+//	https://jira.example.com/issues/?jql=text~"{{ .Symbol.Name | urlquery }}"
+//	https://github.com/acme/app/blob/main/{{ .Symbol.Filename }}#L{{ .Symbol.Line }}
+type issueLinkData struct {
+	// Symbol is the finding, e.g. .Symbol.Name, .Symbol.Filename and .Symbol.Code.
+	Symbol Finding
+
+	// Fingerprint is the stable identifier of the finding.
+	Fingerprint string
+
+	// Package is the package directory of the finding, e.g. internal/lib.
+	Package string
+}
+
+func compileIssueLink(text string) (*template.Template, error) {
+	return compileLink("issue", text)
+}
+
+func compileSourceLink(text string) (*template.Template, error) {
+	return compileLink("source", text)
+}
+
+// compileLink compiles the text of the kind of link template, e.g. issue, nil if empty.
+func compileLink(kind, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(kind + "-link").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s link template: %w", kind, err)
+	}
+	return tmpl, nil
+}
+
+// issueLink renders the issue or source link template tmpl for f.
+func issueLink(tmpl *template.Template, f Finding) (string, error) {
+	var sb strings.Builder
+	data := issueLinkData{Symbol: f, Fingerprint: f.Fingerprint(), Package: packageOf(f)}
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", strings.ReplaceAll(tmpl.Name(), "-", " "), err)
+	}
+	return sb.String(), nil
+}
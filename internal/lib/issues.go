@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// IssuesConfig configures FileIssues.
+type IssuesConfig struct {
+	// Repo is the GitHub repository to file the issues in, on the form owner/name.
+	Repo string
+
+	// Token is the GitHub token used to authenticate, e.g. from GITHUB_TOKEN.
+	// It is not needed with DryRun.
+	Token string
+
+	// APIURL is the GitHub API URL, defaults to https://api.github.com.
+	APIURL string
+
+	// Label is added to the issues created, and used to find the issues created in earlier runs.
+	// Defaults to "punused".
+	Label string
+
+	// DryRun prints the issues instead of creating or updating them.
+	DryRun bool
+
+	// Out receives a line per issue created or updated (or the issues, with DryRun).
+	Out io.Writer
+
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// FileIssues creates a GitHub issue per package with findings in the JSON reports in filenames,
+// listing the symbols and the number of lines removing them would delete.
+// An issue already created for the package in an earlier run, identified by a fingerprint
+// in the issue body, is updated instead.
+func FileIssues(ctx context.Context, cfg IssuesConfig, filenames ...string) error {
+	if cfg.Repo == "" {
+		return fmt.Errorf("Repo is required")
+	}
+	if cfg.Token == "" && !cfg.DryRun {
+		return fmt.Errorf("Token is required")
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.github.com"
+	}
+	if cfg.Label == "" {
+		cfg.Label = "punused"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	var findings []Finding
+	for _, filename := range filenames {
+		report, err := readJSONReport(filename)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, report.Findings...)
+	}
+
+	clusters := clusterFindings(findings)
+
+	if cfg.DryRun {
+		for _, c := range clusters {
+			fmt.Fprintf(cfg.Out, "# %s\n\n%s\n", c.title(), c.body())
+		}
+		return nil
+	}
+
+	gh := &githubClient{cfg: cfg}
+	existing, err := gh.listIssues(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range clusters {
+		issue := githubIssue{Title: c.title(), Body: c.body()}
+		if old, found := existing[c.fingerprint()]; found {
+			if old.Title == issue.Title && old.Body == issue.Body {
+				continue
+			}
+			if err := gh.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%d", cfg.Repo, old.Number), issue, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cfg.Out, "updated #%d %s\n", old.Number, issue.Title)
+			continue
+		}
+		newIssue := struct {
+			githubIssue
+			Labels []string `json:"labels"`
+		}{issue, []string{cfg.Label}}
+		var created githubIssue
+		if err := gh.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues", cfg.Repo), newIssue, &created); err != nil {
+			return err
+		}
+		fmt.Fprintf(cfg.Out, "created #%d %s\n", created.Number, issue.Title)
+	}
+
+	return nil
+}
+
+// findingCluster is the findings in a package.
+type findingCluster struct {
+	pkg      string
+	findings []Finding
+}
+
+// clusterFindings groups the findings by package, sorted by package.
+func clusterFindings(findings []Finding) []*findingCluster {
+	m := make(map[string]*findingCluster)
+	var clusters []*findingCluster
+	for _, f := range findings {
+		pkg := packageOf(f)
+		c, found := m[pkg]
+		if !found {
+			c = &findingCluster{pkg: pkg}
+			m[pkg] = c
+			clusters = append(clusters, c)
+		}
+		c.findings = append(c.findings, f)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].pkg < clusters[j].pkg })
+	return clusters
+}
+
+// fingerprint identifies the cluster across runs.
+func (c *findingCluster) fingerprint() string {
+	h := sha256.Sum256([]byte("package\x00" + c.pkg))
+	return hex.EncodeToString(h[:8])
+}
+
+func (c *findingCluster) title() string {
+	return fmt.Sprintf("Remove unused code in %s", c.pkg)
+}
+
+func (c *findingCluster) body() string {
+	var lines int
+	for _, f := range c.findings {
+		lines += f.Lines
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n\n", issueMarker(c.fingerprint()))
+	fmt.Fprintf(&sb, "punused found %s in `%s`", plural(len(c.findings), "finding"), c.pkg)
+	if lines > 0 {
+		fmt.Fprintf(&sb, ", removing them would delete about %s", plural(lines, "line"))
+	}
+	sb.WriteString(".\n\n| Symbol | Kind | Location | Finding |\n| --- | --- | --- | --- |\n")
+	for _, f := range c.findings {
+		fmt.Fprintf(&sb, "| `%s` | %s | %s:%d | %s (%s) |\n", f.Name, f.Kind, f.Filename, f.Line, f.Message(), f.Code)
+	}
+	return sb.String()
+}
+
+const issueMarkerPrefix, issueMarkerSuffix = "<!-- punused:", " -->"
+
+func issueMarker(fingerprint string) string {
+	return issueMarkerPrefix + fingerprint + issueMarkerSuffix
+}
+
+// issueFingerprint returns the fingerprint in the marker in body, if any.
+func issueFingerprint(body string) string {
+	_, after, found := strings.Cut(body, issueMarkerPrefix)
+	if !found {
+		return ""
+	}
+	fp, _, found := strings.Cut(after, issueMarkerSuffix)
+	if !found {
+		return ""
+	}
+	return fp
+}
+
+type githubIssue struct {
+	Number int    `json:"number,omitempty"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type githubClient struct {
+	cfg IssuesConfig
+}
+
+// listIssues returns the open issues with the configured label keyed by the fingerprint in their body.
+func (gh *githubClient) listIssues(ctx context.Context) (map[string]githubIssue, error) {
+	const perPage = 100
+	issues := make(map[string]githubIssue)
+	for page := 1; ; page++ {
+		var batch []githubIssue
+		q := url.Values{"labels": {gh.cfg.Label}, "state": {"open"}, "per_page": {fmt.Sprint(perPage)}, "page": {fmt.Sprint(page)}}
+		if err := gh.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues?%s", gh.cfg.Repo, q.Encode()), nil, &batch); err != nil {
+			return nil, err
+		}
+		for _, issue := range batch {
+			if fp := issueFingerprint(issue.Body); fp != "" {
+				issues[fp] = issue
+			}
+		}
+		if len(batch) < perPage {
+			return issues, nil
+		}
+	}
+}
+
+func (gh *githubClient) do(ctx context.Context, method, path string, body, result any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(gh.cfg.APIURL, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+gh.cfg.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := gh.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GitHub API %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
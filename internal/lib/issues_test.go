@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestFileIssues(t *testing.T) {
+	c := qt.New(t)
+
+	findings := []Finding{
+		{Filename: "a/a.go", Line: 3, Kind: "function", Name: "A", Code: codeUnused, Lines: 3},
+		{Filename: "b/b.go", Line: 5, Kind: "constant", Name: "B", Code: codeTestOnly, Lines: 1},
+	}
+	clusters := clusterFindings(findings)
+	c.Assert(clusters, qt.HasLen, 2)
+
+	filename := filepath.Join(t.TempDir(), "report.json")
+	var buf bytes.Buffer
+	c.Assert(writeJSON(&buf, Report{Findings: findings}), qt.IsNil)
+	c.Assert(os.WriteFile(filename, buf.Bytes(), 0o644), qt.IsNil)
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		c.Check(r.Header.Get("Authorization"), qt.Equals, "Bearer secret")
+		switch r.Method {
+		case http.MethodGet:
+			c.Check(r.URL.Query().Get("labels"), qt.Equals, "punused")
+			json.NewEncoder(w).Encode([]githubIssue{
+				{Number: 7, Title: "Old title", Body: issueMarker(clusters[0].fingerprint())},
+				{Number: 8, Title: "Unrelated"},
+			})
+		case http.MethodPost:
+			var issue struct {
+				Body   string   `json:"body"`
+				Labels []string `json:"labels"`
+			}
+			c.Check(json.NewDecoder(r.Body).Decode(&issue), qt.IsNil)
+			c.Check(issueFingerprint(issue.Body), qt.Equals, clusters[1].fingerprint())
+			c.Check(issue.Labels, qt.DeepEquals, []string{"punused"})
+			json.NewEncoder(w).Encode(githubIssue{Number: 9})
+		}
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	err := FileIssues(context.Background(), IssuesConfig{Repo: "o/r", Token: "secret", APIURL: srv.URL, Out: &out}, filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(requests, qt.DeepEquals, []string{"GET /repos/o/r/issues", "PATCH /repos/o/r/issues/7", "POST /repos/o/r/issues"})
+	c.Assert(out.String(), qt.Equals, "updated #7 Remove unused code in a\ncreated #9 Remove unused code in b\n")
+}
@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// asmSymbolRe matches the Go symbols in assembly files, e.g. ·Add or example.com∕m∕pkg·Add, with the package
+// path, if any, written with division slashes.
+var asmSymbolRe = regexp.MustCompile(`([\pL\pN_.\-∕]*)·([\pL_][\pL\pN_]*)`)
+
+// linkedSymbols returns the symbols in the workspace dir of module, as package directory and name joined, e.g. pkg/Add,
+// referenced in ways invisible to gopls: named in //go:linkname directives, in assembly (.s) files, e.g. the
+// declarations of the functions implemented there, or exported to C with //export. Removing them breaks the build,
+// so they're kept, see symbolFilter.kept.
+// Hidden directories, testdata and vendor are left out, as by the go tool.
+func linkedSymbols(dir, module string) (map[string]bool, error) {
+	linked := make(map[string]bool)
+	// add adds name in the package importPath, if in module, or else in the package directory pkgDir.
+	add := func(pkgDir, importPath, name string) {
+		if importPath != "" {
+			if importPath == module {
+				pkgDir = "."
+			} else if rel, found := strings.CutPrefix(importPath, module+"/"); found && module != "" {
+				pkgDir = rel
+			} else {
+				return
+			}
+		}
+		linked[path.Join(pkgDir, name)] = true
+	}
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); filename != dir && (strings.HasPrefix(name, ".") || name == "testdata" || name == vendorDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		isAsm := strings.HasSuffix(filename, ".s")
+		if !isAsm && !strings.HasSuffix(filename, ".go") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(filename))
+		if err != nil {
+			return err
+		}
+		pkgDir := filepath.ToSlash(rel)
+
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if isAsm {
+				for _, m := range asmSymbolRe.FindAllStringSubmatch(line, -1) {
+					add(pkgDir, strings.ReplaceAll(m[1], "∕", "/"), m[2])
+				}
+				continue
+			}
+			if args, found := strings.CutPrefix(line, "//go:linkname "); found {
+				fields := strings.Fields(args)
+				if len(fields) > 0 {
+					add(pkgDir, "", fields[0])
+				}
+				if len(fields) > 1 {
+					if importPath, name, ok := splitLinkname(fields[1]); ok {
+						add(pkgDir, importPath, name)
+					}
+				}
+			} else if name, found := strings.CutPrefix(line, "//export "); found {
+				add(pkgDir, "", strings.TrimSpace(name))
+			}
+		}
+		return scanner.Err()
+	})
+	return linked, err
+}
+
+// splitLinkname splits the target of a //go:linkname directive, e.g. example.com/m/pkg.(*T).m,
+// into the import path and the symbol name.
+func splitLinkname(target string) (string, string, bool) {
+	slash := strings.LastIndex(target, "/")
+	dot := strings.Index(target[slash+1:], ".")
+	if dot < 0 {
+		return "", "", false
+	}
+	i := slash + 1 + dot
+	return target[:i], target[i+1:], true
+}
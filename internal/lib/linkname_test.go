@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLinkedSymbols(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o777), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o666), qt.IsNil)
+	}
+	write("go.mod", "module example.com/m\n")
+	write("fast/add.go", "package fast\n\nfunc Add(a, b int) int\n")
+	write("fast/add_amd64.s", "TEXT ·Add(SB),NOSPLIT,$0\n\tCALL example.com∕m∕util·Helper(SB)\n\tCALL runtime·memmove(SB)\n\tRET\n")
+	write("clock/clock.go", `package clock
+
+import _ "unsafe"
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+//go:linkname Exposed
+func Exposed() {}
+
+//go:linkname pulled example.com/m/util.(*T).secret
+func pulled()
+`)
+	write("cgo/cgo.go", "package main\n\n// #include <stdlib.h>\nimport \"C\"\n\n//export GoCallback\nfunc GoCallback() {}\n")
+	write("testdata/skipped.go", "package p\n\n//export Skipped\nfunc Skipped() {}\n")
+
+	linked, err := linkedSymbols(dir, "example.com/m")
+	c.Assert(err, qt.IsNil)
+	for _, key := range []string{"fast/Add", "util/Helper", "clock/nanotime", "clock/Exposed", "clock/pulled", "util/(*T).secret", "cgo/GoCallback"} {
+		c.Assert(linked[key], qt.IsTrue, qt.Commentf(key))
+	}
+	c.Assert(linked, qt.HasLen, 7)
+
+	sf, err := newSymbolFilter(nil, nil)
+	c.Assert(err, qt.IsNil)
+	sf.linked = linked
+	c.Assert(sf.kept("Add", "fast/add.go"), qt.IsTrue)
+	c.Assert(sf.kept("Add", "slow/add.go"), qt.IsFalse)
+}
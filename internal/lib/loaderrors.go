@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// LoadError is an error reported by gopls for a package in the workspace, e.g. a missing dependency or
+// a build error. Findings in or around broken packages are unreliable, as references may be missing.
+type LoadError struct {
+	// Package is the package directory, empty for errors not tied to a file.
+	Package  string `json:"package,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+
+	// Excluded is set if gopls excluded the file or directory from its view, e.g. by directoryFilters
+	// or for being in a module outside the workspace, so the references from it are all missing.
+	Excluded bool `json:"excluded,omitempty"`
+}
+
+// exclusionRe matches the messages of gopls on the files or directories left out of its view.
+var exclusionRe = regexp.MustCompile(`(?i)no packages found for open file|not included in (your|a) workspace|excluded by (the )?directoryFilters|too many files`)
+
+// isExclusion reports whether msg, a diagnostic or a message shown by gopls, tells that files were left out of its view.
+func isExclusion(msg string) bool {
+	return exclusionRe.MatchString(msg)
+}
+
+// collectLoadErrors records the errors gopls has reported for the files in the workspace so far.
+func (r *runner) collectLoadErrors() {
+	diagnostics, messages := r.client.Errors()
+	for uri, diags := range diagnostics {
+		rel, found := r.workspacePath(uri)
+		if !found {
+			continue
+		}
+		for _, d := range diags {
+			r.loadErrors = append(r.loadErrors, LoadError{
+				Package:  path.Join(r.prefix, path.Dir(rel)),
+				Filename: path.Join(r.prefix, rel),
+				Line:     d.Range.Start.Line + 1,
+				Message:  d.Message,
+				Excluded: isExclusion(d.Message),
+			})
+		}
+	}
+	for _, msg := range messages {
+		r.loadErrors = append(r.loadErrors, LoadError{Message: msg, Excluded: isExclusion(msg)})
+	}
+}
+
+// sortLoadErrors sorts errs by package and position and removes the duplicates,
+// e.g. reported for multiple build configurations.
+func sortLoadErrors(errs []LoadError) []LoadError {
+	sort.Slice(errs, func(i, j int) bool {
+		a, b := errs[i], errs[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Message < b.Message
+	})
+	var out []LoadError
+	for i, e := range errs {
+		if i > 0 && e == errs[i-1] {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// printLoadErrors writes the packages with errors and their errors to w, and then the exclusions.
+func printLoadErrors(w io.Writer, errs []LoadError) {
+	var failed, excluded []LoadError
+	for _, e := range errs {
+		if e.Excluded {
+			excluded = append(excluded, e)
+		} else {
+			failed = append(failed, e)
+		}
+	}
+	printLoadErrorList(w, "Packages with errors (findings in or around them may be unreliable):", failed)
+	printLoadErrorList(w, "Excluded from the gopls view (their references are missing, symbols they use may be reported as unused):", excluded)
+}
+
+func printLoadErrorList(w io.Writer, title string, errs []LoadError) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", title)
+	for _, e := range errs {
+		if e.Filename == "" {
+			fmt.Fprintf(w, "  %s\n", e.Message)
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %s:%d: %s\n", e.Package, e.Filename, e.Line, e.Message)
+	}
+}
+
+// warnLoadErrors logs a warning for every package with errors.
+func (r *runner) warnLoadErrors(errs []LoadError) {
+	byPackage := make(map[string][]LoadError)
+	var packages []string
+	for _, e := range errs {
+		if _, found := byPackage[e.Package]; !found {
+			packages = append(packages, e.Package)
+		}
+		byPackage[e.Package] = append(byPackage[e.Package], e)
+	}
+	for _, pkg := range packages {
+		pe := byPackage[pkg]
+		if pkg == "" {
+			pkg = "(workspace)"
+		}
+		var excluded bool
+		for _, e := range pe {
+			excluded = excluded || e.Excluded
+		}
+		if excluded {
+			r.cfg.Logger.Warn("excluded from the gopls view, findings of the symbols it uses may be wrong", "package", pkg, "errors", len(pe), "first", pe[0].Message)
+			continue
+		}
+		r.cfg.Logger.Warn("package has errors, findings may be unreliable", "package", pkg, "errors", len(pe), "first", pe[0].Message)
+	}
+}
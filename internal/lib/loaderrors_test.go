@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestExcludedFromView(t *testing.T) {
+	c := qt.New(t)
+
+	client := &GoplsClient{}
+	client.notify(response{Method: "textDocument/publishDiagnostics", Params: []byte(`{"uri": "file:///m/tools/gen.go", "diagnostics": [
+		{"severity": 2, "message": "No packages found for open file /m/tools/gen.go."},
+		{"severity": 2, "message": "unusedparams"}
+	]}`)})
+	client.notify(response{Method: "window/showMessage", Params: []byte(`{"type": 2, "message": "This file is within module \"./legacy\", which is not included in your workspace."}`)})
+	client.notify(response{Method: "window/showMessage", Params: []byte(`{"type": 3, "message": "Loading packages..."}`)})
+	diagnostics, messages := client.Errors()
+	c.Assert(diagnostics[lsp.DocumentURI("file:///m/tools/gen.go")], qt.HasLen, 1)
+	c.Assert(messages, qt.HasLen, 1)
+
+	errs := []LoadError{
+		{Package: "p", Filename: "p/p.go", Line: 3, Message: "undefined: x"},
+		{Package: "tools", Filename: "tools/gen.go", Line: 1, Message: "No packages found for open file /m/tools/gen.go.", Excluded: true},
+	}
+	var b bytes.Buffer
+	printLoadErrors(&b, errs)
+	c.Assert(b.String(), qt.Equals, `
+Packages with errors (findings in or around them may be unreliable):
+  p: p/p.go:3: undefined: x
+
+Excluded from the gopls view (their references are missing, symbols they use may be reported as unused):
+  tools: tools/gen.go:1: No packages found for open file /m/tools/gen.go.
+`)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := Summary{LoadErrors: errs}
+	c.Assert(gate(RunConfig{Logger: logger}, nil, nil, s), qt.IsNil)
+	c.Assert(gate(RunConfig{Logger: logger, FailOnExcluded: true}, nil, nil, s), qt.ErrorMatches, `check failed: gopls excluded 1 file or directory from its view`)
+	c.Assert(gate(RunConfig{Logger: logger, FailOnExcluded: true}, nil, nil, Summary{LoadErrors: errs[:1]}), qt.IsNil)
+}
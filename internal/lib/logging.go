@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// logWriter is an io.Writer that logs each line written to it, used to route
+// the stderr of subprocesses through the logger.
+type logWriter struct {
+	logger *slog.Logger
+	level  slog.Level
+
+	// keep, if set, is the number of the last lines kept, see lines.
+	keep int
+
+	mu   sync.Mutex
+	buf  []byte
+	last []string
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimSpace(w.buf[:i]); len(line) > 0 {
+			w.logger.Log(context.Background(), w.level, string(line))
+			if w.keep > 0 {
+				w.last = append(w.last, string(line))
+				if len(w.last) > w.keep {
+					w.last = w.last[1:]
+				}
+			}
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// lines returns the last lines written, see keep.
+func (w *logWriter) lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.last...)
+}
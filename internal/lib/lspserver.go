@@ -0,0 +1,464 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// lspDebounce is the time waited for more edits before re-analyzing the open files.
+const lspDebounce = 500 * time.Millisecond
+
+// ServeLSP runs a language server speaking LSP on in and out, e.g. stdin and stdout, until the client exits
+// or ctx is done. It relays the documents the client opens and edits to a gopls of its own, and publishes
+// the findings in the open documents as diagnostics, re-analyzing them when they change, unsaved edits included.
+// The workspace is the root of the client, if set, else cfg.WorkspaceDir.
+// As with Watch, only the checks analyzing a file at a time are supported.
+func ServeLSP(ctx context.Context, cfg RunConfig, in io.Reader, out io.Writer) error {
+	if err := cfg.init(); err != nil {
+		return err
+	}
+	if err := cfg.validateWatch(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := &lspServer{
+		ctx:     ctx,
+		cfg:     cfg,
+		out:     out,
+		docs:    make(map[string]*lspDocument),
+		changed: make(chan struct{}, 1),
+	}
+	if len(cfg.BuildConfigs) == 1 {
+		s.build = &cfg.BuildConfigs[0]
+	}
+	defer s.stop()
+
+	msgs := make(chan lspMessage)
+	readErr := make(chan error, 1)
+	go func() {
+		br := bufio.NewReader(in)
+		for {
+			msg, err := readLSPMessage(br)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case msg := <-msgs:
+			exit, err := s.handle(msg)
+			if err != nil || exit {
+				return err
+			}
+		case <-s.changed:
+			fire = time.After(lspDebounce)
+		case <-fire:
+			fire = nil
+			if err := s.analyze(); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				// Typically transient, e.g. code not compiling while typing.
+				cfg.Logger.Error("failed to analyze the open files", "error", err)
+			}
+		}
+	}
+}
+
+// lspServer holds the state of ServeLSP.
+type lspServer struct {
+	ctx    context.Context
+	cfg    RunConfig
+	build  *BuildConfig
+	runner *runner
+
+	// out is written to by one message at a time.
+	outMu sync.Mutex
+	out   io.Writer
+
+	// docs holds the documents open in the client by filename, relative to the workspace.
+	docs map[string]*lspDocument
+
+	// changed signals that the open documents changed since the last analysis.
+	changed chan struct{}
+
+	// shutdown is set when the client asks the server to shut down, before it exits.
+	shutdown bool
+}
+
+// lspDocument is a document open in the client.
+type lspDocument struct {
+	uri  lsp.DocumentURI
+	text string
+
+	// published is set when diagnostics were published for the document, cleared when closed.
+	published bool
+}
+
+// lspMessage is a JSON-RPC message, a request or notification from the client, or a response to it.
+type lspMessage struct {
+	RPCVersion string           `json:"jsonrpc"`
+	ID         *json.RawMessage `json:"id,omitempty"`
+	Method     string           `json:"method,omitempty"`
+	Params     json.RawMessage  `json:"params,omitempty"`
+	Result     any              `json:"result,omitempty"`
+	Error      *responseError   `json:"error,omitempty"`
+}
+
+// The JSON-RPC error codes returned to the client.
+const (
+	lspMethodNotFound       = -32601
+	lspInvalidParams        = -32602
+	lspServerNotInitialized = -32002
+	lspRequestFailed        = -32803
+)
+
+// handle handles the message msg from the client, returning exit set when the client asks the server to exit.
+func (s *lspServer) handle(msg lspMessage) (exit bool, err error) {
+	switch msg.Method {
+	case "initialize":
+		var params lsp.InitializeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return false, s.respondError(msg, lspInvalidParams, err.Error())
+		}
+		if err := s.initialize(params); err != nil {
+			return false, s.respondError(msg, lspRequestFailed, err.Error())
+		}
+		return false, s.respond(msg, lsp.InitializeResult{Capabilities: lsp.ServerCapabilities{
+			TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{Options: &lsp.TextDocumentSyncOptions{OpenClose: true, Change: lsp.TDSKFull}},
+		}})
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return false, nil
+	case "shutdown":
+		s.shutdown = true
+		return false, s.respond(msg, nil)
+	case "exit":
+		if !s.shutdown {
+			return true, errors.New("exit without shutdown")
+		}
+		return true, nil
+	}
+
+	if s.runner == nil {
+		if msg.ID != nil {
+			return false, s.respondError(msg, lspServerNotInitialized, "not initialized")
+		}
+		return false, nil
+	}
+
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params lsp.DidOpenTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return false, err
+		}
+		filename, ok := s.filename(params.TextDocument.URI)
+		if !ok {
+			return false, nil
+		}
+		if err := s.runner.client.open(s.ctx, params); err != nil {
+			return false, err
+		}
+		s.docs[filename] = &lspDocument{uri: params.TextDocument.URI, text: params.TextDocument.Text}
+		s.touch()
+	case "textDocument/didChange":
+		var params lsp.DidChangeTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return false, err
+		}
+		filename, _ := s.filename(params.TextDocument.URI)
+		doc := s.docs[filename]
+		if doc == nil || len(params.ContentChanges) == 0 {
+			return false, nil
+		}
+		// Full sync, the last change is the content.
+		doc.text = params.ContentChanges[len(params.ContentChanges)-1].Text
+		if err := s.runner.client.change(s.ctx, params.TextDocument.URI, params.TextDocument.Version, doc.text); err != nil {
+			return false, err
+		}
+		s.touch()
+	case "textDocument/didSave":
+		s.touch()
+	case "textDocument/didClose":
+		var params lsp.DidCloseTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return false, err
+		}
+		filename, _ := s.filename(params.TextDocument.URI)
+		doc := s.docs[filename]
+		if doc == nil {
+			return false, nil
+		}
+		delete(s.docs, filename)
+		if err := s.runner.client.closeDocument(s.ctx, doc.uri); err != nil {
+			return false, err
+		}
+		if doc.published {
+			if err := s.publish(doc.uri, nil); err != nil {
+				return false, err
+			}
+		}
+		// The symbols of the others may be used in it, or no longer.
+		s.touch()
+	default:
+		if msg.ID != nil {
+			return false, s.respondError(msg, lspMethodNotFound, "method not supported: "+msg.Method)
+		}
+	}
+	return false, nil
+}
+
+// initialize starts gopls for the workspace of the client, the root in params, if set.
+func (s *lspServer) initialize(params lsp.InitializeParams) error {
+	if s.runner != nil {
+		return errors.New("already initialized")
+	}
+	if params.RootURI != "" || params.RootPath != "" {
+		dir, err := uriFilename(params.Root())
+		if err != nil {
+			return err
+		}
+		s.cfg.WorkspaceDir = dir
+	}
+	if err := checkModule(s.cfg.WorkspaceDir); err != nil {
+		return err
+	}
+	r, err := newRunner(s.ctx, s.cfg, s.build)
+	if err != nil {
+		return err
+	}
+	r.build = s.build
+	if err := r.startGopls(); err != nil {
+		return err
+	}
+	s.runner = r
+	return nil
+}
+
+// stop stops gopls, if started.
+func (s *lspServer) stop() {
+	if s.runner != nil {
+		s.runner.Stop()
+	}
+}
+
+// touch schedules an analysis of the open documents.
+func (s *lspServer) touch() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// filename returns the filename of uri relative to the workspace, if a Go file in it.
+func (s *lspServer) filename(uri lsp.DocumentURI) (string, bool) {
+	filename, err := uriFilename(uri)
+	if err != nil || !strings.HasSuffix(filename, ".go") {
+		return "", false
+	}
+	rel, err := filepath.Rel(s.cfg.WorkspaceDir, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// uriFilename returns the filename of the file URI uri.
+func uriFilename(uri lsp.DocumentURI) (string, error) {
+	u, err := url.Parse(string(uri))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI %s, must be a file", uri)
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path)), nil
+}
+
+// analyze analyzes the open documents, their unsaved content included, sharing gopls,
+// and publishes their findings as diagnostics.
+func (s *lspServer) analyze() error {
+	if len(s.docs) == 0 {
+		return nil
+	}
+	cfg := s.cfg
+	cfg.Overlay = make(map[string]string, len(s.docs))
+	only := make(map[string]bool, len(s.docs))
+	for filename, doc := range s.docs {
+		cfg.Overlay[filepath.Join(cfg.WorkspaceDir, filepath.FromSlash(filename))] = doc.text
+		only[filename] = true
+	}
+	r, err := newRunner(s.ctx, cfg, s.build)
+	if err != nil {
+		return err
+	}
+	r.client, r.root, r.build, r.only = s.runner.client, cfg.WorkspaceDir, s.build, only
+	if err := r.Walk(); err != nil {
+		return err
+	}
+
+	byFile := make(map[string][]Finding)
+	for _, f := range r.findings {
+		byFile[f.Filename] = append(byFile[f.Filename], f)
+	}
+	filenames := make([]string, 0, len(s.docs))
+	for filename := range s.docs {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		doc := s.docs[filename]
+		findings := byFile[filename]
+		if len(findings) == 0 && !doc.published {
+			continue
+		}
+		diagnostics := make([]lsp.Diagnostic, len(findings))
+		for i, f := range findings {
+			diagnostics[i] = findingDiagnostic(f, cfg.CodePrefix)
+		}
+		if err := s.publish(doc.uri, diagnostics); err != nil {
+			return err
+		}
+		doc.published = len(findings) > 0
+	}
+	return nil
+}
+
+// findingDiagnostic returns the finding f as a diagnostic on the name of its symbol, with its code prefixed
+// with prefix, if set.
+func findingDiagnostic(f Finding, prefix string) lsp.Diagnostic {
+	start := lsp.Position{Line: f.Line - 1, Character: f.Column - 1}
+	end := start
+	end.Character += len(symbolBase(f.Name))
+	severity := lsp.DiagnosticSeverity(lsp.Warning)
+	switch f.Severity {
+	case SeverityError:
+		severity = lsp.Error
+	case SeverityInfo:
+		severity = lsp.Information
+	}
+	return lsp.Diagnostic{
+		Range:    lsp.Range{Start: start, End: end},
+		Severity: severity,
+		Code:     prefixedCode(prefix, f.Code),
+		Source:   "punused",
+		Message:  f.Kind + " " + f.Name + " " + f.Message(),
+	}
+}
+
+// publish publishes the diagnostics of the document uri, replacing those published before, if any.
+func (s *lspServer) publish(uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) error {
+	if diagnostics == nil {
+		diagnostics = []lsp.Diagnostic{}
+	}
+	return s.write(lspMessage{Method: "textDocument/publishDiagnostics", Params: mustMarshal(lsp.PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})})
+}
+
+// respond responds to the request msg with result, which may be nil.
+func (s *lspServer) respond(msg lspMessage, result any) error {
+	if msg.ID == nil {
+		return nil
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return s.write(lspMessage{ID: msg.ID, Result: result})
+}
+
+// respondError responds to the request msg with an error.
+func (s *lspServer) respondError(msg lspMessage, code int, message string) error {
+	if msg.ID == nil {
+		return nil
+	}
+	return s.write(lspMessage{ID: msg.ID, Error: &responseError{Code: code, Message: message}})
+}
+
+func (s *lspServer) write(msg lspMessage) error {
+	msg.RPCVersion = "2.0"
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(b)); err != nil {
+		return err
+	}
+	_, err = s.out.Write(b)
+	return err
+}
+
+// readLSPMessage reads the next message from br, its headers, of which only Content-Length is used, and content.
+func readLSPMessage(br *bufio.Reader) (lspMessage, error) {
+	var msg lspMessage
+	length := -1
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && line != "" {
+				err = io.ErrUnexpectedEOF
+			}
+			return msg, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return msg, fmt.Errorf("invalid Content-Length %q: %w", value, err)
+			}
+		}
+	}
+	if length < 0 {
+		return msg, errors.New("missing Content-Length header")
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return msg, err
+	}
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return msg, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, nil
+}
+
+func mustMarshal(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestServeLSP(t *testing.T) {
+	c := qt.New(t)
+
+	var in bytes.Buffer
+	send := func(content string) {
+		fmt.Fprintf(&in, "Content-Length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n%s", len(content), content)
+	}
+	send(`{"jsonrpc":"2.0","id":1,"method":"textDocument/hover","params":{}}`)
+	send(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{}}`)
+	send(`{"jsonrpc":"2.0","id":"two","method":"shutdown"}`)
+	send(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	var out bytes.Buffer
+	cfg := RunConfig{WorkspaceDir: t.TempDir(), FilenamePatterns: []string{"**/*.go"}, Out: io.Discard, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	c.Assert(ServeLSP(context.Background(), cfg, &in, &out), qt.IsNil)
+
+	br := bufio.NewReader(&out)
+	msg, err := readLSPMessage(br)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(*msg.ID), qt.Equals, "1")
+	c.Assert(msg.Error, qt.DeepEquals, &responseError{Code: lspServerNotInitialized, Message: "not initialized"})
+	msg, err = readLSPMessage(br)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(*msg.ID), qt.Equals, `"two"`)
+	c.Assert(msg.Error == nil, qt.IsTrue)
+	_, err = readLSPMessage(br)
+	c.Assert(err, qt.Equals, io.EOF)
+
+	// Exit without shutdown.
+	in.Reset()
+	send(`{"jsonrpc":"2.0","method":"exit"}`)
+	c.Assert(ServeLSP(context.Background(), cfg, &in, &out), qt.ErrorMatches, "exit without shutdown")
+}
+
+func TestReadLSPMessage(t *testing.T) {
+	c := qt.New(t)
+
+	_, err := readLSPMessage(bufio.NewReader(strings.NewReader("Content-Type: text\r\n\r\n{}")))
+	c.Assert(err, qt.ErrorMatches, "missing Content-Length header")
+	_, err = readLSPMessage(bufio.NewReader(strings.NewReader("Content-Length: 10\r\n\r\n{}")))
+	c.Assert(err, qt.Equals, io.ErrUnexpectedEOF)
+}
+
+func TestFindingDiagnostic(t *testing.T) {
+	c := qt.New(t)
+
+	f := Finding{Filename: "a.go", Line: 3, Column: 17, Kind: "method", Name: "(*T).Dead", Code: codeUnused, Severity: SeverityError}
+	c.Assert(findingDiagnostic(f, "ACME"), qt.DeepEquals, lsp.Diagnostic{
+		Range:    lsp.Range{Start: lsp.Position{Line: 2, Character: 16}, End: lsp.Position{Line: 2, Character: 20}},
+		Severity: lsp.Error,
+		Code:     "ACME1002",
+		Source:   "punused",
+		Message:  "method (*T).Dead is unused",
+	})
+}
@@ -0,0 +1,33 @@
+package lib
+
+import "fmt"
+
+// The policies for the exported symbols declared in package main, see RunConfig.MainPackages.
+const (
+	// mainPackagesCheck checks them as any other exported symbol, the default.
+	mainPackagesCheck = "check"
+
+	// mainPackagesSkip leaves them out, e.g. the cobra commands or wire providers of a program.
+	mainPackagesSkip = "skip"
+
+	// mainPackagesUnexportedRule checks them as unexported symbols, as they cannot be imported:
+	// Only those without any references are reported, and methods and fields never are.
+	mainPackagesUnexportedRule = "unexported-rule"
+)
+
+func validateMainPackages(policy string) error {
+	switch policy {
+	case "", mainPackagesCheck, mainPackagesSkip, mainPackagesUnexportedRule:
+		return nil
+	}
+	return fmt.Errorf("invalid main packages policy %q, one of check, skip or unexported-rule", policy)
+}
+
+// isMainPackage reports whether filename, relative to the workspace, is in package main.
+func (r *runner) isMainPackage(filename string) (bool, error) {
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	return src.file.Name.Name == "main", nil
+}
@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifest captures what an analysis ran on, so a later run can verify it operates on the same tree,
+// see RunConfig.ManifestOut and RunConfig.VerifyManifest.
+type manifest struct {
+	// ConfigHash is a hash of the configuration options affecting the findings.
+	ConfigHash string `json:"config_hash"`
+
+	// Files are the files analyzed, sorted by filename.
+	Files []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	// Filename is relative to the workspace root.
+	Filename string `json:"filename"`
+	SHA256   string `json:"sha256"`
+}
+
+// analysisHash returns a hash of the configuration options affecting the findings,
+// i.e. without the options only affecting the output or applying the fixes.
+func (cfg RunConfig) analysisHash() string {
+	cfg.Format, cfg.Verbose, cfg.Blame, cfg.Top, cfg.SnippetLines = "", false, false, 0, 0
+	cfg.AbsPaths, cfg.PathPrefix, cfg.CodePrefix = false, "", ""
+	cfg.Concurrency = 0
+	cfg.Fix, cfg.FixMinAge, cfg.FixReport, cfg.Iterations, cfg.Rename = false, 0, "", 0, false
+	return cfg.hash()
+}
+
+// manifest returns the manifest of the files analyzed.
+func (r *runner) manifest() (manifest, error) {
+	m := manifest{ConfigHash: r.cfg.analysisHash()}
+	seen := make(map[string]bool)
+	for _, filename := range r.walked {
+		if seen[filename] {
+			// Analyzed once per build configuration.
+			continue
+		}
+		seen[filename] = true
+		b, err := r.overlay.readFile(filepath.Join(r.root, filepath.FromSlash(filename)))
+		if err != nil {
+			return m, err
+		}
+		h := sha256.Sum256(b)
+		m.Files = append(m.Files, manifestFile{Filename: filename, SHA256: hex.EncodeToString(h[:])})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Filename < m.Files[j].Filename })
+	return m, nil
+}
+
+func (r *runner) writeManifest(filename string) error {
+	m, err := r.manifest()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0o644)
+}
+
+// verifyManifest returns an error if the files analyzed, their content or the configuration
+// differ from the manifest in filename.
+func (r *runner) verifyManifest(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var want manifest
+	if err := json.Unmarshal(b, &want); err != nil {
+		return fmt.Errorf("failed to read manifest %s: %w", filename, err)
+	}
+	got, err := r.manifest()
+	if err != nil {
+		return err
+	}
+	if diffs := diffManifests(want, got); len(diffs) > 0 {
+		return fmt.Errorf("the analysis does not match the manifest %s: %s", filename, strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// diffManifests describes the differences from want to got.
+func diffManifests(want, got manifest) []string {
+	var diffs []string
+	if want.ConfigHash != got.ConfigHash {
+		diffs = append(diffs, fmt.Sprintf("config changed (%s, was %s)", got.ConfigHash, want.ConfigHash))
+	}
+	hashes := make(map[string]string)
+	for _, f := range want.Files {
+		hashes[f.Filename] = f.SHA256
+	}
+	var added, changed []string
+	for _, f := range got.Files {
+		h, found := hashes[f.Filename]
+		switch {
+		case !found:
+			added = append(added, f.Filename)
+		case h != f.SHA256:
+			changed = append(changed, f.Filename)
+		}
+		delete(hashes, f.Filename)
+	}
+	var removed []string
+	for filename := range hashes {
+		removed = append(removed, filename)
+	}
+	sort.Strings(removed)
+
+	for _, d := range []struct {
+		what      string
+		filenames []string
+	}{{"changed", changed}, {"added", added}, {"removed", removed}} {
+		if len(d.filenames) > 0 {
+			diffs = append(diffs, fmt.Sprintf("%s %s: %s", plural(len(d.filenames), "file"), d.what, strings.Join(d.filenames, ", ")))
+		}
+	}
+	return diffs
+}
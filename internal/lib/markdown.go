@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const formatMarkdown = "md"
+
+// writeMarkdown writes report to w as Markdown, e.g. for a pull request comment or a CI job summary:
+// the summary followed by a table of the findings sorted by position.
+func writeMarkdown(w io.Writer, report Report) error {
+	var b bytes.Buffer
+	b.WriteString("# punused\n\n")
+	report.Summary.Print(&b)
+	if len(report.Findings) > 0 {
+		b.WriteString("\n| Location | Symbol | Finding | Code | Severity | Hint |\n|---|---|---|---|---|---|\n")
+		for _, f := range sortedByPosition(report.Findings) {
+			fmt.Fprintf(&b, "| `%s:%d:%d` | %s `%s` | %s | %s | %s | %s |\n", f.Filename, f.Line, f.Column, f.Kind,
+				markdownCell(f.Name), markdownCell(f.Message()), f.Code, f.Severity, markdownCell(f.Hint))
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// markdownCell escapes s for a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// globs is a list of compiled glob patterns.
+type globs []glob.Glob
+
+func compileGlobs(patterns []string) (globs, error) {
+	var g globs
+	for _, pattern := range patterns {
+		m, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+		g = append(g, m)
+	}
+	return g, nil
+}
+
+// Match reports whether any of the patterns match s.
+func (g globs) Match(s string) bool {
+	for _, m := range g {
+		if m.Match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// filenameMatcher matches filenames against a list of glob patterns, or Go package patterns,
+// where patterns prefixed with ! exclude the filenames matching them.
+type filenameMatcher struct {
+	include globs
+	exclude globs
+
+	// packages are the Go package patterns, e.g. ./internal/..., resolved to the directories of
+	// the packages they match, relative to the workspace, by resolvePackages.
+	packages                 []string
+	includeDirs, excludeDirs map[string]bool
+}
+
+// compileFilenamePatterns compiles patterns, e.g. "**/*.go" and "!**/*_gen.go".
+// The Go package patterns, e.g. "./internal/..." and "!./internal/gen", are left to resolvePackages.
+// If there are only exclude patterns, all filenames not excluded match.
+func compileFilenamePatterns(patterns []string) (filenameMatcher, error) {
+	var (
+		include, exclude []string
+		m                = filenameMatcher{includeDirs: make(map[string]bool), excludeDirs: make(map[string]bool)}
+		err              error
+	)
+	for _, pattern := range patterns {
+		if isPackagePattern(strings.TrimPrefix(pattern, "!")) {
+			m.packages = append(m.packages, pattern)
+		} else if p, found := strings.CutPrefix(pattern, "!"); found {
+			exclude = append(exclude, p)
+		} else {
+			include = append(include, pattern)
+		}
+	}
+	if m.include, err = compileGlobs(include); err != nil {
+		return m, err
+	}
+	if m.exclude, err = compileGlobs(exclude); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// Match reports whether filename matches any of the include patterns and none of the exclude patterns.
+func (m filenameMatcher) Match(filename string) bool {
+	dir := path.Dir(filename)
+	if (len(m.include) > 0 || len(m.includeDirs) > 0) && !m.include.Match(filename) && !m.includeDirs[dir] {
+		return false
+	}
+	return !m.exclude.Match(filename) && !m.excludeDirs[dir]
+}
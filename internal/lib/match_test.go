@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestFilenameMatcher(t *testing.T) {
+	c := qt.New(t)
+
+	m, err := compileFilenamePatterns([]string{"**/*.go", "!**/*_gen.go", "!vendor/**"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(m.Match("pkg/a.go"), qt.IsTrue)
+	c.Assert(m.Match("pkg/a_gen.go"), qt.IsFalse)
+	c.Assert(m.Match("vendor/x/a.go"), qt.IsFalse)
+	c.Assert(m.Match("pkg/a.txt"), qt.IsFalse)
+
+	m, err = compileFilenamePatterns([]string{"!**/*_gen.go"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(m.Match("pkg/a.go"), qt.IsTrue)
+	c.Assert(m.Match("pkg/a_gen.go"), qt.IsFalse)
+
+	_, err = compileFilenamePatterns([]string{"!["})
+	c.Assert(err, qt.Not(qt.IsNil))
+}
+
+func TestPackagePatterns(t *testing.T) {
+	c := qt.New(t)
+
+	for _, pattern := range []string{".", "./...", "./internal/...", "../other", "github.com/me/proj/pkg/api", "example.com/..."} {
+		c.Assert(isPackagePattern(pattern), qt.IsTrue, qt.Commentf(pattern))
+	}
+	for _, pattern := range []string{"**/*.go", "internal/**", "p/p.go", "./p/*.go", "internal"} {
+		c.Assert(isPackagePattern(pattern), qt.IsFalse, qt.Commentf(pattern))
+	}
+
+	// Resolved in the root of this module.
+	dir, err := filepath.Abs("../..")
+	c.Assert(err, qt.IsNil)
+	m, err := compileFilenamePatterns([]string{"./internal/lib/testpackages/...", "!github.com/bep/punused/internal/lib/testpackages/secondpackage", "main.go"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(m.packages, qt.HasLen, 2)
+	c.Assert(m.resolvePackages(dir), qt.IsNil)
+	c.Assert(m.Match("internal/lib/testpackages/firstpackage/firstpackage.go"), qt.IsTrue)
+	c.Assert(m.Match("internal/lib/testpackages/secondpackage/secondpackage.go"), qt.IsFalse)
+	c.Assert(m.Match("main.go"), qt.IsTrue)
+	c.Assert(m.Match("internal/lib/run.go"), qt.IsFalse)
+
+	m, err = compileFilenamePatterns([]string{"./internal/nosuchpackage/..."})
+	c.Assert(err, qt.IsNil)
+	c.Assert(m.resolvePackages(dir), qt.ErrorMatches, `(the package pattern \./internal/nosuchpackage/\.\.\. matched no packages|package \./internal/nosuchpackage/\.\.\. not found)`)
+	m, err = compileFilenamePatterns([]string{"github.com/bep/nosuchmodule"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(m.resolvePackages(dir), qt.ErrorMatches, `package github.com/bep/nosuchmodule not found`)
+	m, err = compileFilenamePatterns([]string{"github.com/gobwas/glob"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(m.resolvePackages(dir), qt.ErrorMatches, `package github.com/gobwas/glob is not in the workspace .*`)
+}
@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Merge reads the JSON reports in filenames (as written with -format=json), removes
+// duplicate findings by fingerprint and writes the combined report to w in the given format.
+// If format is empty, it is inferred from outFilename's extension, defaulting to text.
+func Merge(w io.Writer, format, outFilename string, filenames ...string) error {
+	if format == "" {
+		switch filepath.Ext(outFilename) {
+		case ".json":
+			format = formatJSON
+		case ".sarif":
+			format = formatSARIF
+		case ".md":
+			format = formatMarkdown
+		case ".html":
+			format = formatHTML
+		case ".xml":
+			format = formatCheckstyle
+		default:
+			format = formatText
+		}
+	}
+
+	var reports []Report
+	for _, filename := range filenames {
+		report, err := readJSONReport(filename)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	return writeCombinedReport(w, format, reports)
+}
+
+// writeCombinedReport writes the findings of reports, without duplicates by fingerprint, to w in the given format.
+func writeCombinedReport(w io.Writer, format string, reports []Report) error {
+	var (
+		findings []Finding
+		exported int
+	)
+	seen := make(map[string]bool)
+	for _, report := range reports {
+		// The reports are assumed to analyze different files.
+		exported += report.Summary.Exported
+		for _, f := range report.Findings {
+			fp := f.Fingerprint()
+			if seen[fp] {
+				continue
+			}
+			seen[fp] = true
+			findings = append(findings, f)
+		}
+	}
+
+	rep, err := newReporter(w, format, textOptions{})
+	if err != nil {
+		return err
+	}
+	if err := rep.Start(nil); err != nil {
+		return err
+	}
+	return rep.Finish(Report{Summary: summarize(findings, exported), Findings: findings})
+}
+
+func readJSONReport(filename string) (Report, error) {
+	var report Report
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return report, err
+	}
+	if err := json.Unmarshal(b, &report); err != nil {
+		return report, fmt.Errorf("failed to read report %s: %w", filename, err)
+	}
+	return report, nil
+}
@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// MethodUsage is the usage of a method of the type audited by Methods.
+type MethodUsage struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Name     string `json:"name"`
+	Code     string `json:"code,omitempty"`
+
+	// Usage is one of unused, test only, single use, used and widely used.
+	Usage string `json:"usage"`
+	Refs  int    `json:"refs"`
+}
+
+// Methods analyzes the exported symbols like Stats, but writes the usage classification of every
+// exported method of the struct or interface type typeName to cfg.Out, e.g. to shrink an interface.
+// The type name may be qualified with its package directory name, e.g. "lib.RunConfig".
+func Methods(ctx context.Context, cfg RunConfig, typeName string) error {
+	var (
+		methods []MethodUsage
+		members = make(map[*Symbol]bool)
+	)
+	matches := func(filename, name string) bool {
+		if pkg, name2, found := strings.Cut(typeName, "."); found {
+			return name == name2 && path.Base(path.Dir(filename)) == pkg
+		}
+		return name == typeName
+	}
+	observe := func(filename string, s *Symbol, code string, refs int) {
+		if s.Kind == lsp.SKInterface && matches(filename, s.Name) {
+			// The interface methods are observed next, without a receiver in their names.
+			for _, child := range s.Children {
+				members[child] = true
+			}
+		}
+		if s.Kind != lsp.SKMethod || !(members[s] || matches(filename, receiverType(s.Name))) {
+			return
+		}
+		f := newFinding(filename, s, code)
+		methods = append(methods, MethodUsage{
+			Filename: f.Filename,
+			Line:     f.Line,
+			Column:   f.Column,
+			Name:     f.Name,
+			Code:     code,
+			Usage:    usageClass(code, refs),
+			Refs:     refs,
+		})
+	}
+	if err := walkSymbols(ctx, cfg, observe); err != nil {
+		return err
+	}
+
+	// Least used first.
+	sort.SliceStable(methods, func(i, j int) bool { return methods[i].Refs < methods[j].Refs })
+
+	if cfg.Format == formatJSON {
+		if methods == nil {
+			methods = []MethodUsage{}
+		}
+		enc := json.NewEncoder(cfg.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(methods)
+	}
+	if len(methods) == 0 {
+		return fmt.Errorf("no exported methods of %s found", typeName)
+	}
+	tw := tabwriter.NewWriter(cfg.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tUSAGE\tREFS\tPOSITION\t")
+	for _, m := range methods {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s:%d:%d\t\n", m.Name, m.Usage, m.Refs, m.Filename, m.Line, m.Column)
+	}
+	return tw.Flush()
+}
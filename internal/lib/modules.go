@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// writeModuleReports writes the findings grouped by Go module to one file per module in ModulesDir.
+func (r *runner) writeModuleReports(info *RunInfo) error {
+	if err := os.MkdirAll(r.cfg.ModulesDir, 0o755); err != nil {
+		return err
+	}
+
+	modules := make(map[string]string)
+	byModule := make(map[string][]Finding)
+	for _, f := range r.findings {
+		mod := r.moduleOf(path.Dir(f.Filename), modules)
+		byModule[mod] = append(byModule[mod], f)
+	}
+	exported := make(map[string]int)
+	for pkg, n := range r.exportedByPackage {
+		exported[r.moduleOf(pkg, modules)] += n
+	}
+
+	for mod, findings := range byModule {
+		filename := filepath.Join(r.cfg.ModulesDir, moduleFilename(mod)+r.reportExt())
+		if err := r.writeReportFile(filename, info, summarize(findings, exported[mod]), findings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moduleOf returns the path of the module containing the package directory dir, relative to the
+// workspace root, i.e. of the closest go.mod, or an empty string if none. cache maps dirs to module paths.
+func (r *runner) moduleOf(dir string, cache map[string]string) string {
+	if mod, found := cache[dir]; found {
+		return mod
+	}
+	var mod string
+	if _, err := os.Stat(filepath.Join(r.root, filepath.FromSlash(dir), "go.mod")); err == nil {
+		mod = modulePath(filepath.Join(r.root, filepath.FromSlash(dir)))
+	} else if dir != "." && dir != "/" {
+		mod = r.moduleOf(path.Dir(dir), cache)
+	}
+	cache[dir] = mod
+	return mod
+}
+
+// moduleFilename returns the name of the report file for the module path mod,
+// e.g. "github.com-org-billing" for github.com/org/billing.
+func moduleFilename(mod string) string {
+	if mod == "" {
+		return "nomodule"
+	}
+	return ownerFilename(mod)
+}
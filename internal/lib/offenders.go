@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"text/tabwriter"
+)
+
+// Offender is a package or an author ranked by the number of findings.
+type Offender struct {
+	Name     string `json:"name"`
+	Unused   int    `json:"unused"`
+	TestOnly int    `json:"test_only"`
+	Lines    int    `json:"lines"`
+}
+
+// rankOffenders groups the findings by key and returns the n groups with the most
+// unused symbols (including those only used by unused code), falling back to
+// the number of test only symbols and then the name when equal.
+// Findings with an empty key are skipped.
+func rankOffenders(findings []Finding, n int, key func(f Finding) string) []Offender {
+	m := make(map[string]*Offender)
+	for _, f := range findings {
+		k := key(f)
+		if k == "" {
+			continue
+		}
+		o, found := m[k]
+		if !found {
+			o = &Offender{Name: k}
+			m[k] = o
+		}
+		if f.Code == codeTestOnly {
+			o.TestOnly++
+		} else {
+			o.Unused++
+		}
+		o.Lines += f.Lines
+	}
+
+	offenders := make([]Offender, 0, len(m))
+	for _, o := range m {
+		offenders = append(offenders, *o)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		a, b := offenders[i], offenders[j]
+		if a.Unused != b.Unused {
+			return a.Unused > b.Unused
+		}
+		if a.TestOnly != b.TestOnly {
+			return a.TestOnly > b.TestOnly
+		}
+		return a.Name < b.Name
+	})
+
+	if len(offenders) > n {
+		offenders = offenders[:n]
+	}
+	return offenders
+}
+
+func packageOf(f Finding) string {
+	return path.Dir(f.Filename)
+}
+
+func authorOf(f Finding) string {
+	if f.Blame == nil {
+		return ""
+	}
+	return f.Blame.Author
+}
+
+// printOffenders writes the offenders as a table with the given title.
+func printOffenders(w io.Writer, title string, offenders []Offender) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tUNUSED\tTEST ONLY\tLINES\t")
+	for _, o := range offenders {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t\n", o.Name, o.Unused, o.TestOnly, o.Lines)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRankOffenders(t *testing.T) {
+	c := qt.New(t)
+
+	findings := []Finding{
+		{Filename: "a/a.go", Code: codeUnused, Lines: 3},
+		{Filename: "b/b.go", Code: codeUnused, Lines: 1},
+		{Filename: "b/b2.go", Code: codeTransitive, Lines: 2},
+		{Filename: "c/c.go", Code: codeTestOnly},
+		{Filename: "d/d.go", Code: codeUnused},
+	}
+
+	c.Assert(rankOffenders(findings, 3, packageOf), qt.DeepEquals, []Offender{
+		{Name: "b", Unused: 2, Lines: 3},
+		{Name: "a", Unused: 1, Lines: 3},
+		{Name: "d", Unused: 1},
+	})
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+// OutputIndex is the index of the reports written to RunConfig.OutputDir, with the totals of the run.
+type OutputIndex struct {
+	Summary  Summary             `json:"summary"`
+	Packages []OutputIndexReport `json:"packages"`
+}
+
+// OutputIndexReport is the report of a package in an OutputIndex.
+type OutputIndexReport struct {
+	Package  string         `json:"package"`
+	File     string         `json:"file"`
+	Findings int            `json:"findings"`
+	ByCode   map[string]int `json:"by_code"`
+}
+
+// writePackageReports writes the findings grouped by package to one file per package with findings in OutputDir,
+// and the index of the files, index.json with Format json, else index.txt, sorted by package.
+func (r *runner) writePackageReports(info *RunInfo) error {
+	if err := os.MkdirAll(r.cfg.OutputDir, 0o755); err != nil {
+		return err
+	}
+
+	byPackage := make(map[string][]Finding)
+	for _, f := range r.findings {
+		pkg := packageOf(f)
+		byPackage[pkg] = append(byPackage[pkg], f)
+	}
+
+	index := OutputIndex{Summary: r.summary()}
+	for pkg, findings := range byPackage {
+		name := packageFilename(pkg) + r.reportExt()
+		summary := r.outputSummary(summarize(findings, r.exportedByPackage[pkg]))
+		if err := r.writeReportFile(filepath.Join(r.cfg.OutputDir, name), info, summary, findings); err != nil {
+			return err
+		}
+		index.Packages = append(index.Packages, OutputIndexReport{Package: pkg, File: name, Findings: summary.Findings, ByCode: summary.ByCode})
+	}
+	sort.Slice(index.Packages, func(i, j int) bool { return index.Packages[i].Package < index.Packages[j].Package })
+
+	name := "index.txt"
+	if r.cfg.Format == formatJSON {
+		name = "index.json"
+	}
+	out, err := os.Create(filepath.Join(r.cfg.OutputDir, name))
+	if err != nil {
+		return err
+	}
+	if r.cfg.Format == formatJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(index)
+	} else {
+		err = printOutputIndex(out, index)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// printOutputIndex writes index as a table, one package per line, followed by the totals.
+func printOutputIndex(w io.Writer, index OutputIndex) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tFINDINGS\tFILE")
+	for _, p := range index.Packages {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", p.Package, p.Findings, p.File)
+	}
+	fmt.Fprintf(tw, "total\t%d\n", index.Summary.Findings)
+	return tw.Flush()
+}
+
+// packageFilename returns the name of the report file for the package directory pkg,
+// e.g. "internal-lib" for internal/lib, or "root" for the workspace root.
+func packageFilename(pkg string) string {
+	if pkg == "." {
+		return "root"
+	}
+	return ownerFilename(pkg)
+}
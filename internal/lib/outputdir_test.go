@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestPackageFilename(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(packageFilename("internal/lib"), qt.Equals, "internal-lib")
+	c.Assert(packageFilename("."), qt.Equals, "root")
+}
+
+func TestPrintOutputIndex(t *testing.T) {
+	c := qt.New(t)
+
+	var b strings.Builder
+	c.Assert(printOutputIndex(&b, OutputIndex{
+		Summary: Summary{Findings: 3},
+		Packages: []OutputIndexReport{
+			{Package: ".", File: "root.txt", Findings: 1},
+			{Package: "internal/lib", File: "internal-lib.txt", Findings: 2},
+		},
+	}), qt.IsNil)
+	c.Assert(b.String(), qt.Equals, `PACKAGE       FINDINGS  FILE
+.             1         root.txt
+internal/lib  2         internal-lib.txt
+total         3
+`)
+}
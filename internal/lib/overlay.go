@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// LoadOverlay reads an overlay from filename: a JSON object mapping filenames, absolute or
+// relative to the workspace, to their content, e.g. the unsaved buffers of an editor, see RunConfig.Overlay.
+func LoadOverlay(filename string) (map[string]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to read overlay %s: %w", filename, err)
+	}
+	return m, nil
+}
+
+// overlay maps absolute, clean filenames to the content to use instead of the content on disk.
+type overlay map[string]string
+
+// newOverlay returns m with the filenames relative to dir made absolute.
+func newOverlay(dir string, m map[string]string) overlay {
+	if len(m) == 0 {
+		return nil
+	}
+	o := make(overlay, len(m))
+	for filename, content := range m {
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filename)
+		}
+		o[filepath.Clean(filename)] = content
+	}
+	return o
+}
+
+// readFile returns the content of filename in the overlay, if set, else on disk.
+func (o overlay) readFile(filename string) ([]byte, error) {
+	if content, found := o[filepath.Clean(filename)]; found {
+		return []byte(content), nil
+	}
+	return os.ReadFile(filename)
+}
+
+// parseSourceFile parses filename with its content in the overlay, if set, else on disk.
+func (o overlay) parseSourceFile(filename string) (*sourceFile, error) {
+	content, err := o.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseSource(filename, content)
+}
+
+// glob returns the filenames matching pattern on disk or in the overlay, sorted.
+func (o overlay) glob(pattern string) ([]string, error) {
+	filenames, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(filenames))
+	for _, filename := range filenames {
+		seen[filepath.Clean(filename)] = true
+	}
+	for filename := range o {
+		if matched, _ := filepath.Match(pattern, filename); matched && !seen[filename] {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// open sends the content in the overlay to the backend as open documents, so it's analyzed instead of the files on disk.
+func (o overlay) open(ctx context.Context, client backend) error {
+	filenames := make([]string, 0, len(o))
+	for filename := range o {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		params := lsp.DidOpenTextDocumentParams{
+			TextDocument: lsp.TextDocumentItem{
+				URI:        lsp.DocumentURI(client.documentURI(filename)),
+				LanguageID: "go",
+				Version:    1,
+				Text:       o[filename],
+			},
+		}
+		if err := client.open(ctx, params); err != nil {
+			return fmt.Errorf("failed to open overlay %s: %w", filename, err)
+		}
+	}
+	return nil
+}
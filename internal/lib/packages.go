@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+// PackageUsage is the proportion of the exported API of a package used only by tests versus by production code.
+type PackageUsage struct {
+	Package  string `json:"package"`
+	Exported int    `json:"exported"`
+	TestOnly int    `json:"test_only"`
+	Unused   int    `json:"unused"`
+
+	// TestOnlyPercent is the percentage of the exported symbols only used by tests.
+	TestOnlyPercent float64 `json:"test_only_percent"`
+
+	// ProductionPercent is the percentage of the exported symbols without findings, i.e. used by production code.
+	ProductionPercent float64 `json:"production_percent"`
+}
+
+// packageUsages returns the usage per package given the number of exported symbols analyzed per package,
+// sorted with the highest proportion of test only symbols first, i.e. the likely test-only facades.
+func packageUsages(findings []Finding, exported map[string]int) []PackageUsage {
+	m := make(map[string]*PackageUsage)
+	for pkg, n := range exported {
+		if n > 0 {
+			m[pkg] = &PackageUsage{Package: pkg, Exported: n}
+		}
+	}
+
+	withFindings := make(map[string]int)
+	for _, f := range findings {
+		pkg := packageOf(f)
+		u, found := m[pkg]
+		if !found {
+			continue
+		}
+		withFindings[pkg]++
+		switch f.Code {
+		case codeTestOnly:
+			u.TestOnly++
+		case codeUnused, codeTransitive, codeGenerated, codeReexport:
+			u.Unused++
+		}
+	}
+
+	usages := make([]PackageUsage, 0, len(m))
+	for pkg, u := range m {
+		u.TestOnlyPercent = percent(u.TestOnly, u.Exported)
+		u.ProductionPercent = percent(u.Exported-withFindings[pkg], u.Exported)
+		usages = append(usages, *u)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		a, b := usages[i], usages[j]
+		if a.TestOnlyPercent != b.TestOnlyPercent {
+			return a.TestOnlyPercent > b.TestOnlyPercent
+		}
+		return a.Package < b.Package
+	})
+	return usages
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(total)*10000) / 100
+}
+
+// printPackageUsages writes the package usages as a table.
+func printPackageUsages(w io.Writer, usages []PackageUsage) {
+	fmt.Fprint(w, "\nPackages:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tEXPORTED\tTEST ONLY\tUNUSED\tTEST ONLY %\tPRODUCTION %\t")
+	for _, u := range usages {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f\t%.2f\t\n", u.Package, u.Exported, u.TestOnly, u.Unused, u.TestOnlyPercent, u.ProductionPercent)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,748 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/packages"
+)
+
+// packagesClient is the backend analyzing the workspace without gopls, see BackendPackages.
+// The packages of the workspace, with their tests, are loaded and type checked with go/packages on the first
+// query, and again after the open documents change, and the references are those recorded by the type checker.
+// The symbols are those of the syntax of the files, as returned by gopls.
+type packagesClient struct {
+	workspaceDir string
+	logger       *slog.Logger
+
+	// buildFlags and env are passed to go list, see newPackagesClient.
+	buildFlags []string
+	env        []string
+
+	// overlay holds the content of the open documents by absolute filename.
+	overlayMu sync.Mutex
+	overlay   map[string][]byte
+
+	// index holds the packages loaded, nil until the first query and after the overlay changes.
+	mu    sync.Mutex
+	index *packagesIndex
+}
+
+// packagesIndex holds the references in the packages loaded by packagesClient.
+type packagesIndex struct {
+	fset *token.FileSet
+
+	// idents maps the position of every identifier, defining or referencing an object, to the object, by objectKey,
+	// and refs the objects to the locations of the identifiers referencing them.
+	idents  map[identKey]string
+	objects map[string]types.Object
+	refs    map[string][]*lsp.Location
+
+	// named holds the named types declared in the workspace, and interfaces the interfaces in the workspace
+	// and its dependencies, both by objectKey, for Implementation. As for objects, the first variant loaded
+	// of a package, e.g. with or without its tests, is kept, see implements.
+	named      map[string]*types.TypeName
+	interfaces map[string]*types.TypeName
+
+	// files holds the files of the packages loaded, by URI.
+	files map[lsp.DocumentURI]bool
+
+	diagnostics map[lsp.DocumentURI][]lsp.Diagnostic
+	messages    []string
+}
+
+// identKey is the position of an identifier.
+type identKey struct {
+	uri       lsp.DocumentURI
+	line, col int
+}
+
+// packagesLoadMode is what packagesClient loads of the packages of the workspace. The dependencies are type checked
+// from source, as the export data of the go command may be newer than what go/packages reads, but without the
+// bodies of their functions, see parseFile.
+const packagesLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports |
+	packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo
+
+// newPackagesClient returns a packagesClient for workspaceDir. settings are the gopls settings of the build
+// configuration, see BuildConfig.goplsSettings, whose build flags and env are passed on to go list, as is env.
+func newPackagesClient(workspaceDir string, logger *slog.Logger, settings map[string]any, env []string) *packagesClient {
+	c := &packagesClient{workspaceDir: path.Clean(filepath.ToSlash(workspaceDir)), logger: logger, overlay: make(map[string][]byte)}
+	c.buildFlags, _ = settings["buildFlags"].([]string)
+	c.env = append(os.Environ(), env...)
+	if m, ok := settings["env"].(map[string]string); ok {
+		for k, v := range m {
+			c.env = append(c.env, k+"="+v)
+		}
+	}
+	return c
+}
+
+func (c *packagesClient) documentURI(filename string) string {
+	return workspaceURI(c.workspaceDir, filename)
+}
+
+// load returns the index of the packages of the workspace, loading them if not done yet.
+func (c *packagesClient) load(ctx context.Context) (*packagesIndex, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.index != nil {
+		return c.index, nil
+	}
+
+	c.overlayMu.Lock()
+	overlay := make(map[string][]byte, len(c.overlay))
+	for filename, content := range c.overlay {
+		overlay[filename] = content
+	}
+	c.overlayMu.Unlock()
+	cfg := &packages.Config{
+		Mode:       packagesLoadMode,
+		Context:    ctx,
+		Dir:        c.workspaceDir,
+		Env:        c.env,
+		BuildFlags: c.buildFlags,
+		Tests:      true,
+		Overlay:    overlay,
+		ParseFile:  c.parseFile,
+		Logf: func(format string, args ...any) {
+			c.logger.Debug(fmt.Sprintf(format, args...))
+		},
+	}
+	c.logger.Debug("loading packages", "dir", c.workspaceDir)
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	idx := &packagesIndex{
+		idents:      make(map[identKey]string),
+		objects:     make(map[string]types.Object),
+		refs:        make(map[string][]*lsp.Location),
+		named:       make(map[string]*types.TypeName),
+		interfaces:  make(map[string]*types.TypeName),
+		files:       make(map[lsp.DocumentURI]bool),
+		diagnostics: make(map[lsp.DocumentURI][]lsp.Diagnostic),
+	}
+	sources := make(map[string][]byte)
+	seenErrors := make(map[string]bool)
+	seenRefs := make(map[identKey]bool)
+	seenScopes := make(map[*types.Package]bool)
+	for _, pkg := range pkgs {
+		if idx.fset == nil {
+			idx.fset = pkg.Fset
+		}
+		for _, e := range pkg.Errors {
+			if seenErrors[e.Pos+e.Msg] {
+				continue
+			}
+			seenErrors[e.Pos+e.Msg] = true
+			idx.addError(e)
+		}
+		if strings.HasSuffix(pkg.ID, ".test") || pkg.TypesInfo == nil {
+			// The generated test main package.
+			continue
+		}
+		for _, filename := range pkg.CompiledGoFiles {
+			idx.files[lsp.DocumentURI(c.documentURI(filename))] = true
+		}
+		c.indexScopes(idx, pkg.Types, seenScopes)
+
+		info := pkg.TypesInfo
+		for ident, obj := range info.Defs {
+			if key, ok := c.identKey(idx, sources, ident.Pos()); ok && obj != nil {
+				idx.add(key, obj)
+			}
+		}
+		for ident, obj := range info.Uses {
+			key, ok := c.identKey(idx, sources, ident.Pos())
+			if !ok || !strings.HasPrefix(string(key.uri), c.documentURI("")+"/") {
+				continue
+			}
+			okey := idx.add(key, obj)
+			if okey == "" || seenRefs[key] {
+				continue
+			}
+			seenRefs[key] = true
+			end := key.col + utf16Len(ident.Name)
+			idx.refs[okey] = append(idx.refs[okey], &lsp.Location{
+				URI:   key.uri,
+				Range: lsp.Range{Start: lsp.Position{Line: key.line, Character: key.col}, End: lsp.Position{Line: key.line, Character: end}},
+			})
+		}
+	}
+	if idx.fset == nil {
+		idx.fset = token.NewFileSet()
+	}
+	c.index = idx
+	return idx, nil
+}
+
+// parseFile parses the files for go/packages, dropping the function bodies of the files outside the workspace,
+// which only the types declared matter of.
+func (c *packagesClient) parseFile(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	if c.inWorkspace(filename) {
+		return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments|parser.SkipObjectResolution)
+	}
+	f, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if f != nil {
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				fn.Body = nil
+			}
+		}
+	}
+	return f, err
+}
+
+// indexScopes records the named types declared in pkg, if in the workspace, and the interfaces declared in pkg
+// and its imports, recursively, once per package.
+func (c *packagesClient) indexScopes(idx *packagesIndex, pkg *types.Package, seen map[*types.Package]bool) {
+	if pkg == nil || seen[pkg] {
+		return
+	}
+	seen[pkg] = true
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		key := objectKey(idx.fset, tn)
+		if key == "" {
+			continue
+		}
+		if _, found := idx.interfaces[key]; found {
+			continue
+		}
+		if _, found := idx.named[key]; found {
+			continue
+		}
+		if types.IsInterface(named) {
+			idx.interfaces[key] = tn
+		} else if c.inWorkspace(idx.fset.Position(tn.Pos()).Filename) {
+			idx.named[key] = tn
+		}
+	}
+	for _, imp := range pkg.Imports() {
+		c.indexScopes(idx, imp, seen)
+	}
+}
+
+// add records the identifier at key as defining or referencing obj, unless already recorded, e.g. by a definition,
+// and returns the key of obj, or an empty string for an object without a position, e.g. a builtin.
+func (idx *packagesIndex) add(key identKey, obj types.Object) string {
+	okey := objectKey(idx.fset, obj)
+	if okey == "" {
+		return ""
+	}
+	if _, found := idx.idents[key]; !found {
+		idx.idents[key] = okey
+	}
+	if _, found := idx.objects[okey]; !found {
+		idx.objects[okey] = obj
+	}
+	return okey
+}
+
+// addError records the package error e, as a diagnostic if it has a position.
+func (idx *packagesIndex) addError(e packages.Error) {
+	filename, line, ok := parseErrorPos(e.Pos)
+	if !ok {
+		idx.messages = append(idx.messages, e.Msg)
+		return
+	}
+	uri := lsp.DocumentURI(workspaceURI("", filename))
+	pos := lsp.Position{Line: line - 1}
+	idx.diagnostics[uri] = append(idx.diagnostics[uri], lsp.Diagnostic{Range: lsp.Range{Start: pos, End: pos}, Severity: lsp.Error, Message: e.Msg})
+}
+
+// parseErrorPos parses the position of a packages.Error, e.g. /ws/p/p.go:3:2 or /ws/p/p.go:3.
+func parseErrorPos(pos string) (string, int, bool) {
+	filename, line, ok := cutNumber(pos)
+	if !ok {
+		return "", 0, false
+	}
+	if f, n, ok := cutNumber(filename); ok {
+		filename, line = f, n
+	}
+	return filename, line, filename != ""
+}
+
+// cutNumber splits s after its last colon, e.g. p.go:3 into p.go and 3, reporting whether it ends with a number.
+func cutNumber(s string) (string, int, bool) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, 0, false
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	return s[:i], n, err == nil
+}
+
+// objectKey identifies obj across the packages loaded, e.g. a package and its test variant,
+// by the position of its declaration, or returns an empty string if it has none.
+func objectKey(fset *token.FileSet, obj types.Object) string {
+	if !obj.Pos().IsValid() {
+		return ""
+	}
+	pos := fset.Position(obj.Pos())
+	return fmt.Sprintf("%s:%d:%d:%s", pos.Filename, pos.Line, pos.Column, obj.Name())
+}
+
+// identKey returns the position of the identifier at pos, with the column in UTF-16 code units as in the protocol.
+func (c *packagesClient) identKey(idx *packagesIndex, sources map[string][]byte, pos token.Pos) (identKey, bool) {
+	tf := idx.fset.File(pos)
+	if tf == nil {
+		return identKey{}, false
+	}
+	p := tf.Position(pos)
+	src, found := sources[p.Filename]
+	if !found {
+		src, _ = c.source(p.Filename)
+		sources[p.Filename] = src
+	}
+	return identKey{uri: lsp.DocumentURI(c.documentURI(p.Filename)), line: p.Line - 1, col: utf16Column(src, tf, pos)}, true
+}
+
+// source returns the content of filename, from the overlay if open.
+func (c *packagesClient) source(filename string) ([]byte, error) {
+	c.overlayMu.Lock()
+	content, found := c.overlay[filename]
+	c.overlayMu.Unlock()
+	if found {
+		return content, nil
+	}
+	return os.ReadFile(filename)
+}
+
+// inWorkspace reports whether filename, an absolute filename, is in the workspace.
+func (c *packagesClient) inWorkspace(filename string) bool {
+	filename = filepath.ToSlash(filename)
+	return strings.HasPrefix(filename, c.workspaceDir+"/")
+}
+
+// utf16Column returns the column of pos in the file tf with content src, starting at 0, in UTF-16 code units.
+// It falls back to the byte offset in the line if src does not match tf.
+func utf16Column(src []byte, tf *token.File, pos token.Pos) int {
+	p := tf.Position(pos)
+	offset, start := tf.Offset(pos), tf.Offset(tf.LineStart(p.Line))
+	if offset > len(src) {
+		return p.Column - 1
+	}
+	return utf16Len(string(src[start:offset]))
+}
+
+// utf16Len returns the length of s in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// lookup returns the object of the identifier at loc, or an error if there is none.
+func (c *packagesClient) lookup(ctx context.Context, loc lsp.Location) (*packagesIndex, types.Object, error) {
+	idx, err := c.load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !idx.files[loc.URI] {
+		return nil, nil, fmt.Errorf("%s: %w", loc.URI, errNotInBuild)
+	}
+	key, found := idx.idents[identKey{uri: loc.URI, line: loc.Range.Start.Line, col: loc.Range.Start.Character}]
+	if !found {
+		return nil, nil, fmt.Errorf("no identifier found at %s:%d:%d", loc.URI, loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+	}
+	return idx, idx.objects[key], nil
+}
+
+// DocumentReferences returns the references to the object of the identifier at loc, not including its declaration.
+// For a method, these include the references to the methods it implements, or implementing it, as gopls.
+func (c *packagesClient) DocumentReferences(ctx context.Context, loc lsp.Location) ([]*lsp.Location, error) {
+	idx, obj, err := c.lookup(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	refs := idx.refs[objectKey(idx.fset, obj)]
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return refs, nil
+	}
+	related := idx.implementations(fn)
+	if len(related) == 0 {
+		return refs, nil
+	}
+	refs = append([]*lsp.Location(nil), refs...)
+	seen := make(map[lsp.Location]bool)
+	for _, ref := range refs {
+		seen[*ref] = true
+	}
+	for _, m := range related {
+		for _, ref := range idx.refs[objectKey(idx.fset, m)] {
+			if !seen[*ref] {
+				seen[*ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// Implementation returns the locations of the implementations of the method at loc: for a concrete method,
+// the methods of the interfaces it implements, in the workspace or its dependencies, or, for Error, the builtin
+// error type, and for an interface method, the methods of the types in the workspace implementing it, as gopls.
+func (c *packagesClient) Implementation(ctx context.Context, loc lsp.Location) ([]*lsp.Location, error) {
+	idx, obj, err := c.lookup(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil, nil
+	}
+	var locations []*lsp.Location
+	for _, impl := range idx.implementations(fn) {
+		if loc := c.objectLocation(idx.fset, impl); loc != nil {
+			locations = append(locations, loc)
+		}
+	}
+	if recv := methodRecv(fn); fn.Name() == "Error" && recv != nil && !types.IsInterface(recv) &&
+		types.Implements(types.NewPointer(recv), types.Universe.Lookup("error").Type().Underlying().(*types.Interface)) {
+		if loc := builtinErrorLocation(); loc != nil {
+			locations = append(locations, loc)
+		}
+	}
+	return locations, nil
+}
+
+// implementations returns, for the concrete method fn, the methods of the interfaces its type implements,
+// and for the interface method fn, the methods of the types in the workspace implementing its interface.
+func (idx *packagesIndex) implementations(fn *types.Func) []types.Object {
+	recv := methodRecv(fn)
+	if recv == nil {
+		return nil
+	}
+	var impls []types.Object
+	if types.IsInterface(recv) {
+		iface := recv.Underlying().(*types.Interface)
+		for _, tn := range idx.named {
+			if t := tn.Type(); implements(t, iface) {
+				if m, _, _ := types.LookupFieldOrMethod(t, true, fn.Pkg(), fn.Name()); m != nil {
+					impls = append(impls, m)
+				}
+			}
+		}
+		return impls
+	}
+	if named, ok := recv.(*types.Named); ok && named.TypeParams().Len() > 0 {
+		// Not instantiated.
+		return nil
+	}
+	for _, tn := range idx.interfaces {
+		iface := tn.Type().Underlying().(*types.Interface)
+		if !implements(recv, iface) {
+			continue
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			if m := iface.Method(i); m.Name() == fn.Name() {
+				impls = append(impls, m)
+			}
+		}
+	}
+	return impls
+}
+
+// implements reports whether t, or a pointer to t, implements iface. The types may come from different variants
+// of the same package, e.g. with and without its tests, which go/types sees as distinct, so the methods are matched
+// by name and by their signatures, with the named types qualified by their package path, instead of types.Implements.
+func implements(t types.Type, iface *types.Interface) bool {
+	if !iface.IsMethodSet() || iface.NumMethods() == 0 {
+		return false
+	}
+	qualifier := func(pkg *types.Package) string { return pkg.Path() }
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		obj, _, _ := types.LookupFieldOrMethod(t, true, m.Pkg(), m.Name())
+		fn, ok := obj.(*types.Func)
+		if !ok || types.TypeString(fn.Type(), qualifier) != types.TypeString(m.Type(), qualifier) {
+			return false
+		}
+	}
+	return true
+}
+
+// methodRecv returns the type of the receiver of the method fn, without the pointer, or nil if fn is a function.
+func methodRecv(fn *types.Func) types.Type {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	if p, ok := recv.Type().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return recv.Type()
+}
+
+// objectLocation returns the location of the name of the declaration of obj, or nil if not known.
+func (c *packagesClient) objectLocation(fset *token.FileSet, obj types.Object) *lsp.Location {
+	tf := fset.File(obj.Pos())
+	if tf == nil {
+		return nil
+	}
+	p := tf.Position(obj.Pos())
+	src, _ := c.source(p.Filename)
+	col := utf16Column(src, tf, obj.Pos())
+	return &lsp.Location{
+		URI:   lsp.DocumentURI(c.documentURI(p.Filename)),
+		Range: lsp.Range{Start: lsp.Position{Line: p.Line - 1, Character: col}, End: lsp.Position{Line: p.Line - 1, Character: col + utf16Len(obj.Name())}},
+	}
+}
+
+var (
+	builtinErrorOnce sync.Once
+	builtinErrorLoc  *lsp.Location
+)
+
+// builtinErrorLocation returns the location of the declaration of the error type in the documentation of the
+// builtin package, as gopls, or nil if not found.
+func builtinErrorLocation() *lsp.Location {
+	builtinErrorOnce.Do(func() {
+		filename := filepath.Join(build.Default.GOROOT, "src", "builtin", "builtin.go")
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				if ts := spec.(*ast.TypeSpec); ts.Name.Name == "error" {
+					p := fset.Position(ts.Name.Pos())
+					builtinErrorLoc = &lsp.Location{
+						URI:   lsp.DocumentURI(workspaceURI("", filename)),
+						Range: lsp.Range{Start: lsp.Position{Line: p.Line - 1, Character: p.Column - 1}, End: lsp.Position{Line: p.Line - 1, Character: p.Column - 1 + len("error")}},
+					}
+				}
+			}
+		}
+	})
+	return builtinErrorLoc
+}
+
+// DocumentSymbol returns the symbols declared in filename, relative to the workspace or absolute,
+// as gopls: the functions, methods, named (*T).M, types, constants and variables, with the fields of the structs
+// and the methods of the interfaces as children.
+func (c *packagesClient) DocumentSymbol(ctx context.Context, filename string) ([]*Symbol, error) {
+	uri := lsp.DocumentURI(c.documentURI(filename))
+	filename, err := uriFilename(uri)
+	if err != nil {
+		return nil, err
+	}
+	src, err := c.source(filename)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if f == nil {
+		return nil, err
+	}
+	b := symbolBuilder{uri: uri, src: src, tf: fset.File(f.Pos())}
+
+	var symbols []*Symbol
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Name.Name == "_" {
+				continue
+			}
+			name, kind := decl.Name.Name, lsp.SKFunction
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				name, kind = "("+types.ExprString(decl.Recv.List[0].Type)+")."+name, lsp.SKMethod
+			}
+			symbols = append(symbols, b.symbol(name, kind, types.ExprString(decl.Type), decl, decl.Name))
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name == "_" {
+						continue
+					}
+					s := b.symbol(spec.Name.Name, typeSpecKind(spec), types.ExprString(spec.Type), spec, spec.Name)
+					s.Children = b.typeChildren(spec.Type)
+					symbols = append(symbols, s)
+				case *ast.ValueSpec:
+					kind := lsp.SKVariable
+					if decl.Tok == token.CONST {
+						kind = lsp.SKConstant
+					}
+					var detail string
+					if spec.Type != nil {
+						detail = types.ExprString(spec.Type)
+					}
+					for _, name := range spec.Names {
+						if name.Name != "_" {
+							symbols = append(symbols, b.symbol(name.Name, kind, detail, spec, name))
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// symbolBuilder builds the symbols of a file.
+type symbolBuilder struct {
+	uri lsp.DocumentURI
+	src []byte
+	tf  *token.File
+}
+
+// symbol returns the symbol name declared by the node decl, with its name at the node selection.
+func (b symbolBuilder) symbol(name string, kind lsp.SymbolKind, detail string, decl, selection ast.Node) *Symbol {
+	return &Symbol{
+		Name:     name,
+		Kind:     kind,
+		Detail:   detail,
+		Location: lsp.Location{URI: b.uri, Range: b.rng(selection)},
+		Range:    b.rng(decl),
+	}
+}
+
+func (b symbolBuilder) rng(n ast.Node) lsp.Range {
+	return lsp.Range{Start: b.position(n.Pos()), End: b.position(n.End())}
+}
+
+func (b symbolBuilder) position(pos token.Pos) lsp.Position {
+	return lsp.Position{Line: b.tf.Line(pos) - 1, Character: utf16Column(b.src, b.tf, pos)}
+}
+
+// typeChildren returns the fields of the struct type typ, the methods of the interface type typ,
+// or the fields of the struct types of its fields, recursively, as gopls.
+func (b symbolBuilder) typeChildren(typ ast.Expr) []*Symbol {
+	var children []*Symbol
+	switch typ := typ.(type) {
+	case *ast.StructType:
+		for _, field := range typ.Fields.List {
+			detail := types.ExprString(field.Type)
+			if len(field.Names) == 0 {
+				// Embedded, named by its type.
+				ident := embeddedIdent(field.Type)
+				if ident == nil {
+					continue
+				}
+				children = append(children, b.symbol(ident.Name, lsp.SKField, detail, field, ident))
+				continue
+			}
+			for _, name := range field.Names {
+				if name.Name == "_" {
+					continue
+				}
+				s := b.symbol(name.Name, lsp.SKField, detail, field, name)
+				s.Children = b.typeChildren(field.Type)
+				children = append(children, s)
+			}
+		}
+	case *ast.InterfaceType:
+		for _, method := range typ.Methods.List {
+			for _, name := range method.Names {
+				children = append(children, b.symbol(name.Name, lsp.SKMethod, types.ExprString(method.Type), method, name))
+			}
+		}
+	}
+	return children
+}
+
+// typeSpecKind returns the symbol kind of the type declared by spec, as gopls.
+func typeSpecKind(spec *ast.TypeSpec) lsp.SymbolKind {
+	switch spec.Type.(type) {
+	case *ast.InterfaceType:
+		return lsp.SKInterface
+	case *ast.StructType:
+		return lsp.SKStruct
+	case *ast.FuncType:
+		return lsp.SKFunction
+	}
+	return lsp.SKClass
+}
+
+// Rename is not supported without gopls.
+func (c *packagesClient) Rename(ctx context.Context, loc lsp.Location, newName string) (*WorkspaceEdit, error) {
+	return nil, fmt.Errorf("renaming requires the %s backend", BackendGopls)
+}
+
+// Errors returns the errors of the packages loaded, if any.
+func (c *packagesClient) Errors() (map[lsp.DocumentURI][]lsp.Diagnostic, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.index == nil {
+		return nil, nil
+	}
+	return c.index.diagnostics, c.index.messages
+}
+
+func (c *packagesClient) Close() error {
+	return nil
+}
+
+// open sets the content of the document in params, loading the packages again on the next query.
+func (c *packagesClient) open(ctx context.Context, params lsp.DidOpenTextDocumentParams) error {
+	return c.setOverlay(params.TextDocument.URI, []byte(params.TextDocument.Text))
+}
+
+func (c *packagesClient) change(ctx context.Context, uri lsp.DocumentURI, version int, text string) error {
+	return c.setOverlay(uri, []byte(text))
+}
+
+func (c *packagesClient) closeDocument(ctx context.Context, uri lsp.DocumentURI) error {
+	return c.setOverlay(uri, nil)
+}
+
+// setOverlay sets the content of the document uri, or removes it if nil, invalidating the index.
+func (c *packagesClient) setOverlay(uri lsp.DocumentURI, content []byte) error {
+	filename, err := uriFilename(uri)
+	if err != nil {
+		return err
+	}
+	c.overlayMu.Lock()
+	if content == nil {
+		delete(c.overlay, filename)
+	} else {
+		c.overlay[filename] = content
+	}
+	c.overlayMu.Unlock()
+	c.mu.Lock()
+	c.index = nil
+	c.mu.Unlock()
+	return nil
+}
+
+var (
+	_ backend = (*GoplsClient)(nil)
+	_ backend = (*packagesClient)(nil)
+)
@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// patchContext is the number of unchanged lines around the changes in the patch, as in git diff.
+const patchContext = 3
+
+// writePatch writes the files changed in patched, i.e. by Fix or Rename with RunConfig.Patch set,
+// to w as a unified diff against their content on disk, applicable with git apply. The filenames are
+// relative to the root of the git repository dir is in, as git apply expects, if any, else to dir.
+func writePatch(w io.Writer, dir string, patched overlay) error {
+	// Not an error outside of a git repository.
+	prefix, _ := git(dir, "rev-parse", "--show-prefix")
+
+	filenames := make([]string, 0, len(patched))
+	for filename := range patched {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	bw := bufio.NewWriter(w)
+	for _, filename := range filenames {
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return err
+		}
+		rel = path.Join(prefix, filepath.ToSlash(rel))
+		old, err := os.ReadFile(filename)
+		created := os.IsNotExist(err)
+		if err != nil && !created {
+			return err
+		}
+		hunks := diffHunks(splitLines(string(old)), splitLines(patched[filename]))
+		if len(hunks) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "diff --git a/%s b/%s\n", rel, rel)
+		if created {
+			fmt.Fprintf(bw, "new file mode 100644\n--- /dev/null\n")
+		} else {
+			fmt.Fprintf(bw, "--- a/%s\n", rel)
+		}
+		fmt.Fprintf(bw, "+++ b/%s\n", rel)
+		for _, h := range hunks {
+			h.write(bw)
+		}
+	}
+	return bw.Flush()
+}
+
+// splitLines splits s into lines, keeping the line endings, so a missing newline at the end is preserved.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		lines = append(lines, s[:i])
+		s = s[i:]
+	}
+	return lines
+}
+
+// diffOp is a line of the edit script: kept (' '), removed ('-') or added ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines returns the shortest edit script turning a into b, using Myers' algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Backtrack from the end, collecting the script in reverse.
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+			prevK = k + 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// hunk is a group of changes with their context, starting at line aStart in the old and bStart in the new file.
+type hunk struct {
+	aStart, aLen int
+	bStart, bLen int
+	ops          []diffOp
+}
+
+// diffHunks returns the hunks of the changes turning a into b, with patchContext lines of context,
+// merging the hunks whose context overlaps.
+func diffHunks(a, b []string) []hunk {
+	ops := diffLines(a, b)
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+
+	// The lines of a and b before each op.
+	aPos, bPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if op.kind != '+' {
+			aPos[i+1]++
+		}
+		if op.kind != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	var hunks []hunk
+	for i := 0; i < len(changes); {
+		first, last := changes[i], changes[i]
+		for i++; i < len(changes) && changes[i]-last <= 2*patchContext; i++ {
+			last = changes[i]
+		}
+		from, to := first-patchContext, last+patchContext+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(ops) {
+			to = len(ops)
+		}
+		h := hunk{
+			aStart: aPos[from] + 1, aLen: aPos[to] - aPos[from],
+			bStart: bPos[from] + 1, bLen: bPos[to] - bPos[from],
+			ops: ops[from:to],
+		}
+		// An empty range starts at the line before it.
+		if h.aLen == 0 {
+			h.aStart--
+		}
+		if h.bLen == 0 {
+			h.bStart--
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+func (h hunk) write(w io.Writer) {
+	fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(h.aStart, h.aLen), hunkRange(h.bStart, h.bLen))
+	for _, op := range h.ops {
+		fmt.Fprintf(w, "%c%s", op.kind, op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			fmt.Fprint(w, "\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, n int) string {
+	if n == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
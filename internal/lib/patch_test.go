@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWritePatch(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	old := "package p\n\nimport \"fmt\"\n\nfunc A() {}\n\nfunc B() {}\n\nfunc C() { fmt.Println() }\n\n// D is unused.\nfunc D() {}\n"
+	c.Assert(os.WriteFile(filepath.Join(dir, "p.go"), []byte(old), 0o644), qt.IsNil)
+
+	patched := overlay{
+		filepath.Join(dir, "p.go"):      strings.Replace(old, "\n// D is unused.\nfunc D() {}\n", "", 1),
+		filepath.Join(dir, "p_test.go"): "package p\n\nfunc D() {}",
+	}
+	var buf bytes.Buffer
+	c.Assert(writePatch(&buf, dir, patched), qt.IsNil)
+	c.Assert(buf.String(), qt.Equals, `diff --git a/p.go b/p.go
+--- a/p.go
++++ b/p.go
+@@ -7,6 +7,3 @@
+ func B() {}
+ 
+ func C() { fmt.Println() }
+-
+-// D is unused.
+-func D() {}
+diff --git a/p_test.go b/p_test.go
+new file mode 100644
+--- /dev/null
++++ b/p_test.go
+@@ -0,0 +1,3 @@
++package p
++
++func D() {}
+\ No newline at end of file
+`)
+}
+
+func TestDiffHunks(t *testing.T) {
+	c := qt.New(t)
+
+	lines := func(s string) []string { return splitLines(strings.ReplaceAll(s, " ", "\n")) }
+	a := lines("1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 ")
+	b := lines("1 2 x 4 5 6 7 8 9 10 11 12 13 14 15 16 17 19 20 y ")
+
+	hunks := diffHunks(a, b)
+	c.Assert(hunks, qt.HasLen, 2)
+	c.Assert([]int{hunks[0].aStart, hunks[0].aLen, hunks[0].bStart, hunks[0].bLen}, qt.DeepEquals, []int{1, 6, 1, 6})
+	c.Assert([]int{hunks[1].aStart, hunks[1].aLen, hunks[1].bStart, hunks[1].bLen}, qt.DeepEquals, []int{15, 6, 15, 6})
+
+	c.Assert(diffHunks(a, a), qt.HasLen, 0)
+	c.Assert(diffHunks(nil, nil), qt.HasLen, 0)
+}
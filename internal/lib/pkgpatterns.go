@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// isPackagePattern reports whether pattern, without any ! prefix, is a Go package pattern as given to the go tool,
+// e.g. ., ./internal/... or github.com/me/proj/pkg/api, rather than a filename glob, e.g. internal/** or p/p.go.
+func isPackagePattern(pattern string) bool {
+	if strings.ContainsAny(pattern, "*?[{") || strings.HasSuffix(pattern, ".go") {
+		return false
+	}
+	if pattern == "." || pattern == ".." || strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") {
+		return true
+	}
+	// An import path, whose first element is a domain name, or ends with a wildcard, e.g. example.com/....
+	first, _, _ := strings.Cut(pattern, "/")
+	return strings.Contains(first, ".") || strings.Contains(pattern, "...")
+}
+
+// resolvePackages resolves the package patterns of m with go list in the workspace root dir to the package
+// directories, relative to dir, whose Go files they match.
+func (m *filenameMatcher) resolvePackages(dir string) error {
+	for _, pattern := range m.packages {
+		p, exclude := strings.CutPrefix(pattern, "!")
+		dirs, err := listPackageDirs(dir, p)
+		if err != nil {
+			return err
+		}
+		for _, d := range dirs {
+			if exclude {
+				m.excludeDirs[d] = true
+			} else {
+				m.includeDirs[d] = true
+			}
+		}
+	}
+	return nil
+}
+
+// listPackageDirs returns the directories of the packages matching pattern, relative to the workspace root dir.
+func listPackageDirs(dir, pattern string) ([]string, error) {
+	cmd := exec.Command("go", "list", "-e", "-f", "{{.ImportPath}}\t{{.Dir}}", pattern)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the package pattern %s: %s", pattern, firstLine(stderr.String()))
+	}
+
+	roots := []string{dir}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil && resolved != dir {
+		roots = append(roots, resolved)
+	}
+	var dirs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		importPath, pkgDir, _ := strings.Cut(line, "\t")
+		if importPath == "" {
+			continue
+		}
+		if pkgDir == "" {
+			return nil, fmt.Errorf("package %s not found", importPath)
+		}
+		rel, ok := relativeTo(roots, pkgDir)
+		if !ok {
+			return nil, fmt.Errorf("package %s is not in the workspace %s", importPath, dir)
+		}
+		dirs = append(dirs, rel)
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("the package pattern %s matched no packages", pattern)
+	}
+	return dirs, nil
+}
+
+// relativeTo returns dir, with slashes, relative to the first of roots it's in.
+func relativeTo(roots []string, dir string) (string, bool) {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	// progressLogInterval is the interval between the progress log lines, if not on a terminal.
+	progressLogInterval = 5 * time.Second
+
+	// progressDrawInterval is the interval between the redraws of the progress line on a terminal.
+	progressDrawInterval = 100 * time.Millisecond
+)
+
+// progress reports the progress of the walk of a workspace to RunConfig.Progress: redrawn in place
+// if it's a terminal, else as a log line every progressLogInterval.
+type progress struct {
+	w      io.Writer
+	tty    bool
+	logger *slog.Logger
+
+	// workspace is the workspace prefix, if any, see runner.prefix.
+	workspace string
+
+	total, files int
+	last         time.Time
+	drawn        bool
+}
+
+func newProgress(w io.Writer, logger *slog.Logger, workspace string, total int) *progress {
+	return &progress{w: w, tty: isTerminal(w), logger: logger, workspace: workspace, total: total}
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// file reports that filename is being analyzed, with the symbols checked and the findings so far.
+func (p *progress) file(filename string, symbols, findings int) {
+	p.files++
+	now := time.Now()
+	interval := progressLogInterval
+	if p.tty {
+		interval = progressDrawInterval
+	}
+	if p.files > 1 && p.files < p.total && now.Sub(p.last) < interval {
+		return
+	}
+	p.last = now
+	if !p.tty {
+		args := []any{"files", fmt.Sprintf("%d/%d", p.files, p.total), "symbols", symbols, "findings", findings, "filename", filename}
+		if p.workspace != "" {
+			args = append([]any{"workspace", p.workspace}, args...)
+		}
+		p.logger.Info("analyzing", args...)
+		return
+	}
+	percent := 100 * p.files / p.total
+	fmt.Fprintf(p.w, "\r\x1b[K%s %3d%% %d/%d files, %d symbols, %d findings, %s", progressBar(percent), percent, p.files, p.total, symbols, findings, filename)
+	p.drawn = true
+}
+
+// done clears the progress line, if drawn.
+func (p *progress) done() {
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\x1b[K")
+		p.drawn = false
+	}
+}
+
+// progressBar returns a bar of 20 characters filled to percent.
+func progressBar(percent int) string {
+	const width = 20
+	filled := percent * width / 100
+	bar := make([]byte, 0, width+2)
+	bar = append(bar, '[')
+	for i := 0; i < width; i++ {
+		if i < filled {
+			bar = append(bar, '=')
+		} else {
+			bar = append(bar, ' ')
+		}
+	}
+	return string(append(bar, ']'))
+}
@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestProgress(t *testing.T) {
+	c := qt.New(t)
+
+	var logs bytes.Buffer
+	p := newProgress(io.Discard, slog.New(slog.NewTextHandler(&logs, nil)), "", 3)
+	c.Assert(p.tty, qt.IsFalse)
+	p.file("a.go", 0, 0)
+	p.file("b.go", 4, 1)
+	p.file("c.go", 7, 2)
+	// The first and the last file, the rest within the log interval.
+	c.Assert(strings.Count(logs.String(), "msg=analyzing"), qt.Equals, 2)
+	c.Assert(logs.String(), qt.Contains, "files=3/3 symbols=7 findings=2 filename=c.go")
+
+	var out bytes.Buffer
+	p = &progress{w: &out, tty: true, total: 4}
+	p.file("a.go", 2, 1)
+	c.Assert(out.String(), qt.Equals, "\r\x1b[K[=====               ]  25% 1/4 files, 2 symbols, 1 findings, a.go")
+	p.done()
+	c.Assert(strings.HasSuffix(out.String(), "a.go\r\x1b[K"), qt.IsTrue)
+}
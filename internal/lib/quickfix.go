@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const formatQuickfix = "quickfix"
+
+// writeQuickfix writes the findings to w sorted by position, one per line on the form
+//
+//	internal/lib/gopls.go:125:2: warning: field Detail is unused (EU1002)
+//
+// which Vim's :cfile and Emacs' compilation-mode understand out of the box.
+// Unlike the text output, this format is kept stable.
+func writeQuickfix(w io.Writer, findings []Finding) error {
+	for _, f := range sortedByPosition(findings) {
+		sev := f.Severity
+		if sev == "" {
+			sev = SeverityWarning
+		}
+		msg := fmt.Sprintf("%s %s %s (%s)", f.Kind, f.Name, f.Message(), f.Code)
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", f.Filename, f.Line, f.Column, sev, strings.Join(strings.Fields(msg), " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedByPosition returns a copy of findings sorted by filename, line, column and check code.
+func sortedByPosition(findings []Finding) []Finding {
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Code < b.Code
+	})
+	return sorted
+}
@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWriteQuickfix(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	c.Assert(writeQuickfix(&buf, []Finding{
+		{Filename: "b.go", Line: 3, Column: 6, Kind: "function", Name: "B", Code: codeUnused, Severity: SeverityError},
+		{Filename: "a.go", Line: 12, Column: 2, Kind: "field", Name: "F", Code: codeTestOnly},
+		{Filename: "a.go", Line: 7, Column: 6, Kind: "method", Name: "(*T).M", Code: codeDynamic, Severity: SeverityInfo},
+	}), qt.IsNil)
+
+	c.Assert(buf.String(), qt.Equals, `a.go:7:6: info: method (*T).M is unused, but dynamic usage is suspected (EU1008)
+a.go:12:2: warning: field F is used in test only (EU1001)
+b.go:3:6: error: function B is unused (EU1002)
+`)
+}
+
+func TestSortedByPosition(t *testing.T) {
+	c := qt.New(t)
+
+	findings := []Finding{
+		{Filename: "b.go", Line: 1, Column: 6, Code: codeUnused},
+		{Filename: "a.go", Line: 10, Column: 2, Code: codeUnused},
+		{Filename: "a.go", Line: 2, Column: 6, Code: codeUnused},
+		{Filename: "a.go", Line: 2, Column: 6, Code: codeTestOnly},
+		{Filename: "a.go", Line: 2, Column: 1, Code: codeUnused},
+	}
+	sorted := sortedByPosition(findings)
+	c.Assert(sorted, qt.DeepEquals, []Finding{
+		{Filename: "a.go", Line: 2, Column: 1, Code: codeUnused},
+		{Filename: "a.go", Line: 2, Column: 6, Code: codeTestOnly},
+		{Filename: "a.go", Line: 2, Column: 6, Code: codeUnused},
+		{Filename: "a.go", Line: 10, Column: 2, Code: codeUnused},
+		{Filename: "b.go", Line: 1, Column: 6, Code: codeUnused},
+	})
+	// A copy.
+	c.Assert(findings[0].Filename, qt.Equals, "b.go")
+}
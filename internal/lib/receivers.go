@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"strings"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// receiverType returns the name of the receiver type of the method symbol name, e.g. MyType
+// for (*MyType).MyMethod or (MyType[K]).MyMethod, or "" for interface methods.
+func receiverType(name string) string {
+	if !strings.HasPrefix(name, "(") {
+		return ""
+	}
+	i := strings.Index(name, ").")
+	if i < 0 {
+		return ""
+	}
+	recv := strings.TrimPrefix(name[1:i], "*")
+	if j := strings.Index(recv, "["); j >= 0 {
+		recv = recv[:j]
+	}
+	return recv
+}
+
+// isIgnoredReceiver reports whether s declared in filename is a method on a receiver type
+// matching one of the configured receivers patterns, e.g. "ent.*" for all the types in package ent.
+func (r *runner) isIgnoredReceiver(filename string, s *Symbol) (bool, error) {
+	if len(r.receivers) == 0 || s.Kind != lsp.SKMethod {
+		return false, nil
+	}
+	recv := receiverType(s.Name)
+	if recv == "" {
+		return false, nil
+	}
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	return r.receivers.Match(src.file.Name.Name + "." + recv), nil
+}
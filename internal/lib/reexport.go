@@ -0,0 +1,202 @@
+package lib
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// markReexports marks the symbols only referenced by re-export shims in other packages, per isShim,
+// themselves dead or deprecated, as dead, unless kept, until no more symbols are found, and returns them
+// in the order found, with the shims in usedBy. A shim marked dead makes the chain longer,
+// e.g. a.F forwarded by b.F forwarded by c.F.
+func markReexports(analyzed []*analyzedSymbol, isShim func(b *analyzedSymbol, ref *lsp.Location) bool, kept func(a *analyzedSymbol) bool) []*analyzedSymbol {
+	var marked []*analyzedSymbol
+
+	for changed := true; changed; {
+		changed = false
+		for _, a := range analyzed {
+			if a.dead || a.embedded || len(a.refs) == 0 {
+				continue
+			}
+			var usedBy []*analyzedSymbol
+			live := false
+		refs:
+			for _, ref := range a.refs {
+				if a.contains(ref) {
+					continue
+				}
+				for _, b := range analyzed {
+					if b != a && b.contains(ref) && path.Dir(b.filename) != path.Dir(a.filename) && isShim(b, ref) {
+						usedBy = appendUnique(usedBy, b)
+						continue refs
+					}
+				}
+				live = true
+				break
+			}
+			if !live && len(usedBy) > 0 && !kept(a) {
+				a.dead, a.usedBy = true, usedBy
+				marked = append(marked, a)
+				changed = true
+			}
+		}
+	}
+
+	return marked
+}
+
+// reportReexports reports the symbols only re-exported by unused or deprecated shims, see RunConfig.Reexports.
+func (r *runner) reportReexports() error {
+	sources := make(map[string]*sourceFile)
+	source := func(filename string) *sourceFile {
+		src, found := sources[filename]
+		if !found {
+			content, err := r.overlay.readFile(filepath.Join(r.cfg.WorkspaceDir, filepath.FromSlash(filename)))
+			if err == nil {
+				src, _ = parseSource(filename, content)
+			}
+			sources[filename] = src
+		}
+		return src
+	}
+	isShim := func(b *analyzedSymbol, ref *lsp.Location) bool {
+		if strings.HasSuffix(b.filename, "_test.go") {
+			return false
+		}
+		src := source(b.filename)
+		if src == nil {
+			return false
+		}
+		line := b.symbol.Location.Range.Start.Line + 1
+		target, ok := src.shimTarget(line, b.base)
+		if !ok || target.Line != ref.Range.Start.Line+1 || target.Column != ref.Range.Start.Character+1 {
+			return false
+		}
+		return b.dead || src.deprecated(line, b.base)
+	}
+
+	var err error
+	kept := func(a *analyzedSymbol) bool {
+		if err != nil {
+			return true
+		}
+		var suppressed bool
+		suppressed, err = r.isSuppressed(a.filename, a.symbol.Location.Range.Start.Line+1, a.base, codeReexport)
+		return suppressed || err != nil || r.ignored[findingID(codeReexport, path.Join(r.prefix, a.filename), a.symbol.Name)]
+	}
+
+	marked := markReexports(r.analyzed, isShim, kept)
+	if err != nil {
+		return err
+	}
+	for _, a := range marked {
+		f := newFinding(a.filename, a.symbol, codeReexport)
+		for _, b := range a.usedBy {
+			// Qualified by the package directory, as the shims are in other packages, e.g. internal/compat.Parse.
+			f.UsedBy = append(f.UsedBy, path.Join(r.prefix, path.Dir(b.filename))+"."+b.symbol.Name)
+		}
+		if err := r.reportFinding(f, a.base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shimTarget returns the position of the symbol re-exported by the identifier name declared on the given 1-based line,
+// if it's a re-export shim of a symbol in an imported package: a function whose body is a single call of pkg.Name,
+// returned or not, a variable or constant set to pkg.Name, or an alias of the type pkg.Name.
+func (f *sourceFile) shimTarget(line int, name string) (token.Position, bool) {
+	at := func(ident *ast.Ident) bool {
+		return ident.Name == name && f.fset.Position(ident.Pos()).Line == line
+	}
+	var target ast.Expr
+	for _, decl := range f.file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && at(d.Name) {
+				target = forwardedCall(d.Body)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if at(s.Name) && s.Assign.IsValid() {
+						target = s.Type
+					}
+				case *ast.ValueSpec:
+					for i, ident := range s.Names {
+						if at(ident) && len(s.Values) == len(s.Names) {
+							target = s.Values[i]
+						}
+					}
+				}
+			}
+		}
+	}
+	sel := f.qualified(target)
+	if sel == nil {
+		return token.Position{}, false
+	}
+	return f.fset.Position(sel.Sel.Pos()), true
+}
+
+// forwardedCall returns the function called by body if it's its only statement, e.g. pkg.F in return pkg.F(a, b).
+func forwardedCall(body *ast.BlockStmt) ast.Expr {
+	if body == nil || len(body.List) != 1 {
+		return nil
+	}
+	var x ast.Expr
+	switch s := body.List[0].(type) {
+	case *ast.ReturnStmt:
+		if len(s.Results) == 1 {
+			x = s.Results[0]
+		}
+	case *ast.ExprStmt:
+		x = s.X
+	}
+	if call, ok := x.(*ast.CallExpr); ok {
+		return call.Fun
+	}
+	return nil
+}
+
+// qualified returns x as a selector if it's a qualified identifier of an imported package, e.g. pkg.Name,
+// possibly instantiated, e.g. pkg.Name[T].
+func (f *sourceFile) qualified(x ast.Expr) *ast.SelectorExpr {
+	switch e := x.(type) {
+	case *ast.IndexExpr:
+		x = e.X
+	case *ast.IndexListExpr:
+		x = e.X
+	}
+	sel, ok := x.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	for _, imp := range f.file.Imports {
+		if importUsed(imp, map[string]bool{pkg.Name: true}, nil) {
+			return sel
+		}
+	}
+	return nil
+}
+
+// deprecated reports whether the doc comment of the identifier name declared on the given 1-based line
+// has a "Deprecated: " paragraph.
+func (f *sourceFile) deprecated(line int, name string) bool {
+	d := f.decls[declKey{line: line, name: name}]
+	if d == nil || d.doc == nil {
+		return false
+	}
+	text := d.doc.Text()
+	return strings.HasPrefix(text, "Deprecated: ") || strings.Contains(text, "\nDeprecated: ")
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestShimTarget(t *testing.T) {
+	c := qt.New(t)
+
+	src, err := parseSource("compat.go", []byte(`package compat
+
+import (
+	"example.com/m/internal/parse"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func Parse(s string) (*parse.Doc, error) { return parse.Parse(s) }
+
+// Deprecated: Use parse.Must.
+func Must(s string) { parse.Must(s) }
+
+var Default = parse.Default
+
+const Version = parse.Version
+
+type Doc = parse.Doc
+
+type Node parse.Node
+
+func Wrapped(s string) error {
+	_, err := parse.Parse(s)
+	return err
+}
+
+var local = struct{ X int }{}
+
+var Field = local.X
+
+var Marshal = yaml.Marshal
+`))
+	c.Assert(err, qt.IsNil)
+
+	target := func(line int, name string) int {
+		pos, ok := src.shimTarget(line, name)
+		if !ok {
+			return 0
+		}
+		return pos.Column
+	}
+	c.Assert(target(8, "Parse"), qt.Equals, 57)
+	c.Assert(target(11, "Must"), qt.Equals, 29)
+	c.Assert(target(13, "Default"), qt.Equals, 21)
+	c.Assert(target(15, "Version"), qt.Equals, 23)
+	c.Assert(target(17, "Doc"), qt.Equals, 18)
+	c.Assert(target(19, "Node"), qt.Equals, 0)
+	c.Assert(target(21, "Wrapped"), qt.Equals, 0)
+	c.Assert(target(28, "Field"), qt.Equals, 0)
+	c.Assert(target(30, "Marshal"), qt.Equals, 20)
+
+	c.Assert(src.deprecated(11, "Must"), qt.IsTrue)
+	c.Assert(src.deprecated(8, "Parse"), qt.IsFalse)
+}
+
+func TestMarkReexports(t *testing.T) {
+	c := qt.New(t)
+
+	// sym creates a symbol declared in filename on the lines [start, end], referenced from the lines refs in other.go.
+	sym := func(filename, name string, start, end int, dead bool, refs ...int) *analyzedSymbol {
+		uri := lsp.DocumentURI("file:///m/" + filename)
+		a := &analyzedSymbol{
+			filename: filename,
+			base:     name,
+			symbol: &Symbol{
+				Name:     name,
+				Location: lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: start, Character: 5}}},
+				Range:    lsp.Range{Start: lsp.Position{Line: start}, End: lsp.Position{Line: end, Character: 1}},
+			},
+			dead: dead,
+		}
+		for _, line := range refs {
+			a.refs = append(a.refs, &lsp.Location{URI: "file:///m/compat/compat.go", Range: lsp.Range{Start: lsp.Position{Line: line, Character: 2}}})
+		}
+		return a
+	}
+
+	shimUnused := sym("compat/compat.go", "Parse", 0, 2, true)
+	shimUsed := sym("compat/compat.go", "Live", 4, 6, false, 40)
+	chained := sym("compat/compat.go", "Chained", 8, 10, false, 1)
+	parse := sym("parse/parse.go", "Parse", 0, 10, false, 1)
+	live := sym("parse/parse.go", "Live", 12, 20, false, 5)
+	kept := sym("parse/parse.go", "Kept", 22, 30, false, 1)
+
+	analyzed := []*analyzedSymbol{parse, live, kept, shimUnused, shimUsed, chained}
+	isShim := func(b *analyzedSymbol, ref *lsp.Location) bool { return b.dead }
+	keep := func(a *analyzedSymbol) bool { return a == kept }
+
+	marked := markReexports(analyzed, isShim, keep)
+	var names []string
+	for _, a := range marked {
+		names = append(names, a.filename+":"+a.base)
+	}
+	// Chained is in the same package as its shim and Live's shim is used.
+	c.Assert(names, qt.DeepEquals, []string{"parse/parse.go:Parse"})
+	c.Assert(parse.usedBy, qt.HasLen, 1)
+	c.Assert(parse.usedBy[0], qt.Equals, shimUnused)
+	c.Assert(live.dead, qt.IsFalse)
+	c.Assert(kept.dead, qt.IsFalse)
+	c.Assert(chained.dead, qt.IsFalse)
+}
@@ -0,0 +1,272 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// testOnlySuffix is appended to the unexported name of a symbol used in test only
+// when the unexported name is already taken in its package.
+const testOnlySuffix = "ForTest"
+
+// WorkspaceEdit holds the changes to the workspace, e.g. for a rename.
+// gopls sends them as documentChanges, other servers as changes.
+type WorkspaceEdit struct {
+	Changes         map[lsp.DocumentURI][]lsp.TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit                 `json:"documentChanges,omitempty"`
+}
+
+// TextDocumentEdit holds the edits to a single document.
+type TextDocumentEdit struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+	Edits        []lsp.TextEdit             `json:"edits"`
+}
+
+// edits returns the edits by document.
+func (we *WorkspaceEdit) edits() map[lsp.DocumentURI][]lsp.TextEdit {
+	edits := make(map[lsp.DocumentURI][]lsp.TextEdit)
+	for uri, tes := range we.Changes {
+		edits[uri] = append(edits[uri], tes...)
+	}
+	for _, dc := range we.DocumentChanges {
+		edits[dc.TextDocument.URI] = append(edits[dc.TextDocument.URI], dc.Edits...)
+	}
+	return edits
+}
+
+// Rename returns the edits renaming the symbol at loc to newName in all the files referencing it.
+// It fails if the rename is not possible, e.g. because of a conflict with another identifier.
+func (s *GoplsClient) Rename(ctx context.Context, loc lsp.Location, newName string) (*WorkspaceEdit, error) {
+	params := &lsp.RenameParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: loc.URI,
+		},
+		Position: loc.Range.Start,
+		NewName:  newName,
+	}
+
+	var result WorkspaceEdit
+
+	if err := s.Call(ctx, "textDocument/rename", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// unexportedName returns name with its leading upper case letters, except the last one
+// if followed by a lower case letter, made lower case, e.g. urlParser for URLParser and id for ID.
+func unexportedName(name string) string {
+	runes := []rune(name)
+	i := 0
+	for i < len(runes) && unicode.IsUpper(runes[i]) {
+		i++
+	}
+	if i > 1 && i < len(runes) && unicode.IsLower(runes[i]) {
+		i--
+	}
+	for j := 0; j < i; j++ {
+		runes[j] = unicode.ToLower(runes[j])
+	}
+	return string(runes)
+}
+
+// renameSuggestion returns the name to rename the symbol base of the finding f to: its unexported name
+// or, if that's taken in its package and it's used in test only, its unexported name suffixed with ForTest.
+// It returns "" for symbols that are not declared at the top level, e.g. methods and fields, or if taken.
+func (r *runner) renameSuggestion(f Finding, base string) (string, error) {
+	if base != f.Name {
+		// A method.
+		return "", nil
+	}
+	src, err := r.files.source(f.Filename)
+	if err != nil {
+		return "", err
+	}
+	d := src.decls[declKey{line: f.Line, name: base}]
+	if d == nil || d.tok == token.ILLEGAL {
+		return "", nil
+	}
+
+	scope, err := r.packageScope(path.Dir(f.Filename), src.file.Name.Name)
+	if err != nil {
+		return "", err
+	}
+	names := []string{unexportedName(base)}
+	if f.Code == codeTestOnly {
+		names = append(names, unexportedName(base)+testOnlySuffix)
+	}
+	for _, name := range names {
+		if !scope[name] && token.Lookup(name) == token.IDENT {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// packageScope returns the top level identifiers, including the file scoped imports,
+// declared in the files of package pkg in dir, relative to the workspace.
+func (r *runner) packageScope(dir, pkg string) (map[string]bool, error) {
+	key := dir + ":" + pkg
+	if scope, found := r.scopes[key]; found {
+		return scope, nil
+	}
+
+	filenames, err := filepath.Glob(filepath.Join(r.cfg.WorkspaceDir, filepath.FromSlash(dir), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	scope := make(map[string]bool)
+	for _, filename := range filenames {
+		file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if file.Name.Name != pkg {
+			continue
+		}
+		for _, spec := range file.Imports {
+			for _, name := range importNames(spec, nil) {
+				scope[name] = true
+			}
+		}
+		for name := range topLevelNames(file) {
+			scope[name] = true
+		}
+	}
+	r.scopes[key] = scope
+	return scope, nil
+}
+
+// topLevelNames returns the identifiers declared at the top level of file, excluding methods.
+func topLevelNames(file *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						names[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// renameSymbols renames the symbols of the findings to their suggested names using gopls: with Rename all of them,
+// with Fix those only used in their declaring package (EU1003) whose declaration has not been modified within FixMinAge.
+// All the renames are computed before any file is modified, so the positions stay valid.
+func (r *runner) renameSymbols() error {
+	edits := make(map[lsp.DocumentURI][]lsp.TextEdit)
+	for _, f := range r.findings {
+		if f.Rename == "" || !r.cfg.Rename && f.Code != codeSamePkg {
+			continue
+		}
+		filename := strings.TrimPrefix(strings.TrimPrefix(f.Filename, r.prefix), "/")
+		if r.cfg.Fix && r.cfg.FixMinAge > 0 {
+			g := f
+			g.Filename = filename
+			recent, err := r.modifiedWithin(r.files, g, symbolBase(f.Name), r.cfg.FixMinAge)
+			if err != nil {
+				return err
+			}
+			if recent {
+				r.cfg.Logger.Info("recently modified symbol not renamed", "symbol", f.Name, "filename", f.Filename)
+				continue
+			}
+		}
+		loc := lsp.Location{
+			URI:   lsp.DocumentURI(r.client.documentURI(filename)),
+			Range: lsp.Range{Start: lsp.Position{Line: f.Line - 1, Character: f.Column - 1}},
+		}
+		we, err := r.client.Rename(r.ctx, loc, f.Rename)
+		if err != nil {
+			r.cfg.Logger.Warn("rename failed", "symbol", f.Name, "filename", f.Filename, "to", f.Rename, "error", err)
+			continue
+		}
+		for uri, tes := range we.edits() {
+			edits[uri] = append(edits[uri], tes...)
+		}
+		r.cfg.Logger.Info("renamed symbol", "symbol", f.Name, "filename", f.Filename, "to", f.Rename)
+		if r.cfg.Fix {
+			r.renamed = append(r.renamed, FixEdit{Filename: f.Filename, StartLine: f.Line, EndLine: f.Line, Symbol: f.Name, Action: fixActionRenamed, Target: f.Rename})
+		}
+	}
+
+	for uri, tes := range edits {
+		filename, err := uriFilename(uri)
+		if err != nil {
+			return err
+		}
+		if err := applyTextEdits(filename, tes, r.cfg.patched); err != nil {
+			return fmt.Errorf("failed to rename in %s: %w", uri, err)
+		}
+	}
+	return nil
+}
+
+// applyTextEdits applies the non-overlapping edits to filename, or its content in patched, if set.
+func applyTextEdits(filename string, edits []lsp.TextEdit, patched overlay) error {
+	content, err := patched.readFile(filename)
+	if err != nil {
+		return err
+	}
+	lineStarts := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(p lsp.Position) (int, error) {
+		if p.Line >= len(lineStarts) {
+			return 0, fmt.Errorf("line %d out of range", p.Line+1)
+		}
+		return lineStarts[p.Line] + p.Character, nil
+	}
+
+	// Apply bottom up to keep the offsets valid.
+	sort.Slice(edits, func(i, j int) bool {
+		a, b := edits[i].Range.Start, edits[j].Range.Start
+		return a.Line > b.Line || a.Line == b.Line && a.Character > b.Character
+	})
+	for _, e := range edits {
+		start, err := offset(e.Range.Start)
+		if err != nil {
+			return err
+		}
+		end, err := offset(e.Range.End)
+		if err != nil {
+			return err
+		}
+		if start > end || end > len(content) {
+			return fmt.Errorf("invalid edit range %v", e.Range)
+		}
+		content = bytes.Join([][]byte{content[:start], []byte(e.NewText), content[end:]}, nil)
+	}
+	if patched != nil {
+		patched[filepath.Clean(filename)] = string(content)
+		return nil
+	}
+	return os.WriteFile(filename, content, 0o644)
+}
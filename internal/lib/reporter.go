@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Reporter writes the findings of a run in one of the output formats.
+// The methods are safe for concurrent use, e.g. by parallel workers.
+type Reporter interface {
+	// Start is called before the analysis starts. info may be nil.
+	Start(info *RunInfo) error
+
+	// Report is called for each finding when found.
+	Report(f Finding) error
+
+	// Finish is called with the complete report when the analysis is done,
+	// i.e. with the findings merged across workspaces and build configurations.
+	Finish(report Report) error
+}
+
+// textOptions configures the text reporter.
+type textOptions struct {
+	// verbose prints the signature and doc summary of each finding.
+	verbose bool
+
+	// stream prints the findings when reported, else they're printed in Finish.
+	stream bool
+
+	// summary and packages print the summary and the package usages in Finish.
+	summary, packages bool
+}
+
+// newReporter returns the Reporter writing to w in format, one of text, json, sarif, quickfix, md, html or checkstyle.
+func newReporter(w io.Writer, format string, text textOptions) (Reporter, error) {
+	switch format {
+	case formatText, "":
+		return &textReporter{w: w, textOptions: text}, nil
+	case formatJSON:
+		return &jsonReporter{w: w}, nil
+	case formatSARIF:
+		return &sarifReporter{w: w}, nil
+	case formatQuickfix:
+		return &quickfixReporter{w: w}, nil
+	case formatMarkdown:
+		return &markdownReporter{w: w}, nil
+	case formatHTML:
+		return &htmlReporter{w: w}, nil
+	case formatCheckstyle:
+		return &checkstyleReporter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+type textReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+	textOptions
+}
+
+func (t *textReporter) Start(info *RunInfo) error { return nil }
+
+func (t *textReporter) Report(f Finding) error {
+	if !t.stream {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f.Print(t.w, t.verbose)
+	return nil
+}
+
+func (t *textReporter) Finish(report Report) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.stream {
+		for _, f := range report.Findings {
+			f.Print(t.w, t.verbose)
+		}
+	}
+	if report.Symbols != nil {
+		printSymbols(t.w, report.Symbols)
+	}
+	if t.summary {
+		report.Summary.Print(t.w)
+	}
+	if t.packages {
+		printPackageUsages(t.w, report.Packages)
+	}
+	if report.TopPackages != nil {
+		printOffenders(t.w, "Top packages", report.TopPackages)
+	}
+	if report.TopAuthors != nil {
+		printOffenders(t.w, "Top authors", report.TopAuthors)
+	}
+	if report.TopOwners != nil {
+		printOffenders(t.w, "Top owners", report.TopOwners)
+	}
+	return nil
+}
+
+// The JSON, SARIF, quickfix, Markdown, HTML and Checkstyle reporters write everything in Finish.
+
+type jsonReporter struct {
+	w    io.Writer
+	info *RunInfo
+}
+
+func (j *jsonReporter) Start(info *RunInfo) error { j.info = info; return nil }
+func (j *jsonReporter) Report(f Finding) error    { return nil }
+
+func (j *jsonReporter) Finish(report Report) error {
+	report.Run = j.info
+	return writeJSON(j.w, report)
+}
+
+type sarifReporter struct {
+	w    io.Writer
+	info *RunInfo
+}
+
+func (s *sarifReporter) Start(info *RunInfo) error { s.info = info; return nil }
+func (s *sarifReporter) Report(f Finding) error    { return nil }
+
+func (s *sarifReporter) Finish(report Report) error {
+	return writeSARIF(s.w, s.info, report.Findings)
+}
+
+type quickfixReporter struct {
+	w io.Writer
+}
+
+func (q *quickfixReporter) Start(info *RunInfo) error { return nil }
+func (q *quickfixReporter) Report(f Finding) error    { return nil }
+
+func (q *quickfixReporter) Finish(report Report) error {
+	return writeQuickfix(q.w, report.Findings)
+}
+
+type markdownReporter struct {
+	w io.Writer
+}
+
+func (m *markdownReporter) Start(info *RunInfo) error { return nil }
+func (m *markdownReporter) Report(f Finding) error    { return nil }
+
+func (m *markdownReporter) Finish(report Report) error {
+	return writeMarkdown(m.w, report)
+}
+
+type htmlReporter struct {
+	w    io.Writer
+	info *RunInfo
+}
+
+func (h *htmlReporter) Start(info *RunInfo) error { h.info = info; return nil }
+func (h *htmlReporter) Report(f Finding) error    { return nil }
+
+func (h *htmlReporter) Finish(report Report) error {
+	return writeHTML(h.w, h.info, report)
+}
+
+type checkstyleReporter struct {
+	w io.Writer
+}
+
+func (c *checkstyleReporter) Start(info *RunInfo) error { return nil }
+func (c *checkstyleReporter) Report(f Finding) error    { return nil }
+
+func (c *checkstyleReporter) Finish(report Report) error {
+	return writeCheckstyle(c.w, report.Findings)
+}
@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestTextReporter(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	rep, err := newReporter(&buf, formatText, textOptions{stream: true})
+	c.Assert(err, qt.IsNil)
+	c.Assert(rep.Start(nil), qt.IsNil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Check(rep.Report(Finding{Filename: "a.go", Line: i + 1, Column: 6, Kind: "function", Name: fmt.Sprintf("F%d", i), Code: codeUnused}), qt.IsNil)
+		}(i)
+	}
+	wg.Wait()
+	// The findings were printed when reported.
+	c.Assert(rep.Finish(Report{Findings: []Finding{{Name: "Ignored"}}}), qt.IsNil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, qt.HasLen, 50)
+	for _, line := range lines {
+		c.Assert(line, qt.Matches, `a\.go:\d+:6 function F\d+ is unused \(EU1002\)`)
+	}
+
+	buf.Reset()
+	rep, err = newReporter(&buf, formatText, textOptions{})
+	c.Assert(err, qt.IsNil)
+	c.Assert(rep.Report(Finding{Name: "Ignored"}), qt.IsNil)
+	c.Assert(rep.Finish(Report{Findings: []Finding{{Filename: "a.go", Line: 3, Column: 6, Kind: "function", Name: "F", Code: codeUnused}}}), qt.IsNil)
+	c.Assert(buf.String(), qt.Equals, "a.go:3:6 function F is unused (EU1002)\n")
+
+	_, err = newReporter(&buf, "xml", textOptions{})
+	c.Assert(err, qt.ErrorMatches, `unsupported format "xml"`)
+}
+
+func TestWriteHTML(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	c.Assert(writeHTML(&buf, nil, Report{Findings: []Finding{
+		{Filename: "p/z.go", Line: 3, Column: 6, Kind: "function", Name: "Z", Code: codeUnused, Snippet: "func Z() {}"},
+		{Filename: "p/q/q.go", Line: 4, Column: 6, Kind: "function", Name: "Q", Code: codeTestOnly},
+		{Filename: "p/a.go", Line: 5, Column: 2, Kind: "field", Name: "A", Code: codeUnused, SourceURL: "https://example.com/p/a.go#L5"},
+	}}), qt.IsNil)
+	out := buf.String()
+	c.Assert(strings.Count(out, `<h2 id="p">`), qt.Equals, 1)
+	c.Assert(strings.Index(out, `<h2 id="p">`) < strings.Index(out, `<h2 id="p/q">`), qt.IsTrue)
+	c.Assert(strings.Index(out, "p/a.go") < strings.Index(out, "p/z.go"), qt.IsTrue)
+	c.Assert(out, qt.Contains, `<tr><td>function</td><td>2</td><td><span class="bar" style="width: 20em"></span></td></tr>`)
+	c.Assert(out, qt.Contains, `<tr><td>EU1001 Exported symbol is used in test only</td><td>1</td><td><span class="bar" style="width: 10em"></span></td></tr>`)
+	c.Assert(out, qt.Contains, `<a href="https://example.com/p/a.go#L5">p/a.go:5:2</a>`)
+	c.Assert(out, qt.Contains, `<a href="https://github.com/bep/punused#eu1002">EU1002</a>`)
+	c.Assert(out, qt.Contains, `<pre><code>func Z() {}</code></pre>`)
+}
+
+func TestPrintHint(t *testing.T) {
+	c := qt.New(t)
+
+	f := Finding{Filename: "a.go", Line: 3, Column: 6, Kind: "function", Name: "F", Code: codeUnused, Hint: "Delete it."}
+	var buf bytes.Buffer
+	f.Print(&buf, false)
+	c.Assert(buf.String(), qt.Equals, "a.go:3:6 function F is unused (EU1002)\n")
+	buf.Reset()
+	f.Print(&buf, true)
+	c.Assert(buf.String(), qt.Contains, "a.go:3:6 function F is unused (EU1002)\n\thint: Delete it.\n")
+}
+
+func TestPrintRarelyUsed(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	Finding{Filename: "p/p.go", Line: 7, Column: 6, Kind: "function", Name: "Helper", Code: codeRarelyUsed, References: 1}.Print(&buf, false)
+	Finding{Filename: "p/p.go", Line: 9, Column: 6, Kind: "function", Name: "Other", Code: codeRarelyUsed, References: 2}.Print(&buf, false)
+	c.Assert(buf.String(), qt.Equals, "p/p.go:7:6 function Helper has only 1 reference (EU1004)\np/p.go:9:6 function Other has only 2 references (EU1004)\n")
+
+	check, found := checkByCode("eu1004")
+	c.Assert(found, qt.IsTrue)
+	c.Assert(check.DefaultSeverity, qt.Equals, SeverityInfo)
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// ReportFile writes the JSON report in filename, e.g. the raw results written by punused analyze, to cfg.Out
+// in cfg.Format, and checks its findings against the gates in cfg, i.e. FailOn, FailIntroducedOnly, FailOwners,
+// MaxFindings, MaxUnusedPercent and Thresholds, like Check, so the analysis runs once and any number of reports, and gates,
+// are derived from its results. Nothing is analyzed, i.e. the other options do not apply.
+// MaxUnusedPercent cannot be combined with FailOwners, as the report does not count the exported symbols per owner.
+func ReportFile(cfg RunConfig, filename string) (Result, error) {
+	switch cfg.Format {
+	case "", formatText, formatJSON, formatSARIF, formatQuickfix, formatMarkdown, formatHTML, formatCheckstyle:
+	default:
+		return Result{}, fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+	if err := validateFailOn(cfg.FailOn); err != nil {
+		return Result{}, err
+	}
+	if cfg.MaxUnusedPercent != nil && len(cfg.FailOwners) > 0 {
+		return Result{}, fmt.Errorf("MaxUnusedPercent cannot be combined with FailOwners")
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	thresholds, err := compileThresholds(cfg.Thresholds)
+	if err != nil {
+		return Result{}, err
+	}
+	report, err := readJSONReport(filename)
+	if err != nil {
+		return Result{}, err
+	}
+
+	rep, err := newReporter(cfg.Out, cfg.Format, textOptions{
+		verbose:  cfg.Verbose,
+		summary:  cfg.Verbose || cfg.Packages,
+		packages: cfg.Verbose || cfg.Packages,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if err := rep.Start(report.Run); err != nil {
+		return Result{}, err
+	}
+	if err := rep.Finish(report); err != nil {
+		return Result{}, err
+	}
+	return resultOf(report.Findings), gate(cfg, thresholds, report.Findings, report.Summary)
+}
@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReportFile(t *testing.T) {
+	c := qt.New(t)
+
+	var raw bytes.Buffer
+	c.Assert(writeJSON(&raw, Report{
+		Run:     &RunInfo{Module: "example.com/p"},
+		Summary: summarize([]Finding{{Code: codeUnused}, {Code: codeTestOnly}}, 4),
+		Findings: []Finding{
+			{Filename: "p/b.go", Line: 3, Column: 6, Kind: "function", Name: "B", Code: codeUnused, Severity: SeverityWarning},
+			{Filename: "p/a.go", Line: 7, Column: 6, Kind: "function", Name: "A|B", Code: codeTestOnly, Severity: SeverityWarning},
+		},
+	}), qt.IsNil)
+	filename := filepath.Join(c.TempDir(), "raw.json")
+	c.Assert(os.WriteFile(filename, raw.Bytes(), 0o644), qt.IsNil)
+
+	var out bytes.Buffer
+	res, err := ReportFile(RunConfig{Out: &out, Format: formatMarkdown}, filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ExitCode(res, err), qt.Equals, ExitWarnings)
+	c.Assert(out.String(), qt.Contains, "2 findings (1 unused, 1 used in test only) in 4 exported symbols (50.00%")
+	// Sorted by position, with the cells escaped.
+	c.Assert(strings.Index(out.String(), "p/a.go") < strings.Index(out.String(), "p/b.go"), qt.IsTrue)
+	c.Assert(out.String(), qt.Contains, "| `p/a.go:7:6` | function `A\\|B` | is used in test only | EU1001 | warning |")
+
+	out.Reset()
+	_, err = ReportFile(RunConfig{Out: &out, Format: formatHTML}, filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out.String(), qt.Contains, "<title>punused example.com/p</title>")
+	c.Assert(out.String(), qt.Contains, "<code>A|B</code>")
+
+	// The gates apply to the raw results.
+	max := 25.0
+	res, err = ReportFile(RunConfig{Out: &out, FailOn: []string{codeUnused}, MaxUnusedPercent: &max}, filename)
+	c.Assert(err, qt.ErrorMatches, `check failed: 1 findings with code EU1002; 50.00% of the exported symbols have findings, exceeds the maximum of 25.00%`)
+	c.Assert(ExitCode(res, err), qt.Equals, ExitErrors)
+
+	// The run passes within the budget.
+	budget := 2
+	_, err = ReportFile(RunConfig{Out: &out, MaxFindings: &budget}, filename)
+	c.Assert(err, qt.IsNil)
+	budget = 1
+	_, err = ReportFile(RunConfig{Out: &out, MaxFindings: &budget}, filename)
+	c.Assert(err, qt.ErrorMatches, `check failed: 2 findings exceeds the maximum of 1`)
+
+	out.Reset()
+	_, err = ReportFile(RunConfig{Out: &out, Packages: true}, filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out.String(), qt.Contains, "2 findings (1 unused, 1 used in test only)")
+
+	_, err = ReportFile(RunConfig{Out: &out, Format: "pdf"}, filename)
+	c.Assert(err, qt.ErrorMatches, `unsupported format "pdf"`)
+}
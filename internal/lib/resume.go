@@ -0,0 +1,171 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateSaveInterval is the minimum time between two saves of the run state, see RunConfig.Resume.
+const stateSaveInterval = 10 * time.Second
+
+// runState is the progress of a run, saved so an interrupted run can be resumed, see RunConfig.Resume.
+type runState struct {
+	// ConfigHash is a hash of the configuration options affecting the findings.
+	ConfigHash string `json:"config_hash"`
+
+	// TreeHash is a hash of the Go files in the workspace when the run started.
+	TreeHash string `json:"tree_hash"`
+
+	// Files maps the files analyzed, relative to the workspace, to their number of exported symbols.
+	Files map[string]int `json:"files"`
+
+	Findings []Finding `json:"findings"`
+
+	// done holds the Files restored from the state file, which are not analyzed again.
+	done map[string]bool
+
+	// findings is the number of findings when the last file analyzed was completed,
+	// i.e. without those of a file interrupted.
+	findings int
+	saved    time.Time
+}
+
+// resumed reports whether filename was analyzed by the run resumed.
+func (s *runState) resumed(filename string) bool {
+	return s != nil && s.done[filename]
+}
+
+// loadState reads the state of an interrupted run from the Resume file, if any, and restores its findings.
+// A run with another configuration or on a tree changed since starts over, as its findings may no longer hold.
+func (r *runner) loadState() error {
+	treeHash, err := r.treeHash()
+	if err != nil {
+		return err
+	}
+	r.state = &runState{ConfigHash: r.cfg.analysisHash(), TreeHash: treeHash, Files: make(map[string]int), saved: time.Now()}
+
+	b, err := os.ReadFile(r.cfg.Resume)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var saved runState
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("failed to read the run state %s: %w", r.cfg.Resume, err)
+	}
+	switch {
+	case saved.ConfigHash != r.state.ConfigHash:
+		r.cfg.Logger.Warn("the configuration changed since the run was interrupted, starting over", "state", r.cfg.Resume)
+		return nil
+	case saved.TreeHash != r.state.TreeHash:
+		r.cfg.Logger.Warn("the Go files changed since the run was interrupted, starting over", "state", r.cfg.Resume)
+		return nil
+	}
+
+	r.cfg.Logger.Info("resuming the run", "state", r.cfg.Resume, "files", len(saved.Files), "findings", len(saved.Findings))
+	saved.done = make(map[string]bool, len(saved.Files))
+	for filename, n := range saved.Files {
+		saved.done[filename] = true
+		r.walk(filename)
+		r.exported += n
+		r.exportedByOwner[ownerGroup(r.codeOwners.owners(filename))] += n
+		r.exportedByPackage[path.Join(r.prefix, path.Dir(filename))] += n
+	}
+	saved.findings, saved.saved = len(saved.Findings), r.state.saved
+	r.state = &saved
+	for _, f := range saved.Findings {
+		// With the output options of this run, e.g. -v.
+		if err := r.decorate(&f, symbolBase(f.Name)); err != nil {
+			return err
+		}
+		r.findings = append(r.findings, f)
+		if err := r.emit(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completed records filename, with its number of exported symbols, as analyzed in the run state,
+// saved at most every stateSaveInterval.
+func (r *runner) completed(filename string, exported int) error {
+	if r.state == nil {
+		return nil
+	}
+	r.state.Files[filename] = exported
+	r.state.findings = len(r.findings)
+	if time.Since(r.state.saved) < stateSaveInterval {
+		return nil
+	}
+	return r.saveState()
+}
+
+// saveState writes the run state to the Resume file.
+func (r *runner) saveState() error {
+	s := r.state
+	s.Findings = r.findings[:s.findings]
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file first, so an interruption never leaves it half written.
+	tmp := r.cfg.Resume + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("failed to save the run state: %w", err)
+	}
+	if err := os.Rename(tmp, r.cfg.Resume); err != nil {
+		return fmt.Errorf("failed to save the run state: %w", err)
+	}
+	s.saved = time.Now()
+	return nil
+}
+
+// treeHash returns a hash of the names and content of the Go files in the workspace, outside hidden directories,
+// and, with FollowSymlinks, in the directories linked.
+func (r *runner) treeHash() (string, error) {
+	var files []manifestFile
+	err := walkTree(r.cfg.WorkspaceDir, r.cfg.FollowSymlinks, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") && path != r.cfg.WorkspaceDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		b, err := r.overlay.readFile(path)
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(b)
+		rel, _ := filepath.Rel(r.cfg.WorkspaceDir, path)
+		files = append(files, manifestFile{Filename: filepath.ToSlash(rel), SHA256: hex.EncodeToString(h[:])})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	b, err := json.Marshal(files)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:8]), nil
+}
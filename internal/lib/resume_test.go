@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRunState(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0o644), qt.IsNil)
+	newTestRunner := func() *runner {
+		cfg := RunConfig{WorkspaceDir: dir, Resume: filepath.Join(dir, "state.json"), Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+		return &runner{cfg: cfg, exportedByOwner: make(map[string]int), exportedByPackage: make(map[string]int)}
+	}
+
+	r := newTestRunner()
+	c.Assert(r.loadState(), qt.IsNil)
+	c.Assert(r.state.resumed("a.go"), qt.IsFalse)
+	r.exported = 3
+	c.Assert(r.completed("a.go", 3), qt.IsNil)
+	c.Assert(r.saveState(), qt.IsNil)
+
+	r = newTestRunner()
+	c.Assert(r.loadState(), qt.IsNil)
+	c.Assert(r.state.resumed("a.go"), qt.IsTrue)
+	c.Assert(r.exported, qt.Equals, 3)
+	c.Assert(r.exportedByPackage, qt.DeepEquals, map[string]int{".": 3})
+	c.Assert(r.walked, qt.DeepEquals, []string{"a.go"})
+
+	// Changed since, starts over.
+	c.Assert(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nfunc A() {}\n"), 0o644), qt.IsNil)
+	r = newTestRunner()
+	c.Assert(r.loadState(), qt.IsNil)
+	c.Assert(r.state.resumed("a.go"), qt.IsFalse)
+	c.Assert(r.exported, qt.Equals, 0)
+}
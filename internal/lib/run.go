@@ -2,93 +2,1835 @@ package lib
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
+	"math"
+	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/gobwas/glob"
 	"github.com/sourcegraph/go-lsp"
 )
 
-func Run(ctx context.Context, cfg RunConfig) (err error) {
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// Run analyzes the workspace and writes the findings to cfg.Out.
+// A *FailedError is returned if the findings fail the configured gates.
+func Run(ctx context.Context, cfg RunConfig) error {
+	_, err := Check(ctx, cfg)
+	return err
+}
+
+// Check is like Run, but also returns the outcome of the analysis, see ExitCode.
+func Check(ctx context.Context, cfg RunConfig) (Result, error) {
+	if err := cfg.init(); err != nil {
+		return Result{}, err
+	}
+
+	var info *RunInfo
+	if cfg.Format == formatJSON || cfg.Format == formatSARIF || cfg.Format == formatHTML || cfg.SummaryOut != "" {
+		info = newRunInfo(cfg)
+	}
+
+	// Unless streamed, the findings are sorted before they're printed. With multiple build configurations,
+	// they're merged first, as are the accessors collapsed and the findings compared with the Base.
+	rep, err := newReporter(cfg.Out, cfg.Format, textOptions{
+		verbose:  cfg.Verbose,
+		stream:   cfg.Stream && len(cfg.BuildConfigs) <= 1 && !cfg.CollapseAccessors && cfg.Base == "",
+		summary:  cfg.Verbose || cfg.Packages,
+		packages: cfg.Verbose || cfg.Packages,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if err := rep.Start(info); err != nil {
+		return Result{}, err
+	}
+
+	dirs := cfg.workspaceDirs()
+	r, err := analyzeWorkspaces(ctx, cfg, rep, dirs)
+	if err != nil {
+		if r == nil {
+			return Result{}, err
+		}
+		// Interrupted: flush the findings collected so far, but do not act on them, e.g. fix them.
+		if ferr := r.Finish(rep, info); ferr != nil {
+			return Result{}, ferr
+		}
+		return r.result(), err
+	}
+
+	if cfg.Base != "" {
+		if err := r.markOrigins(ctx); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if cfg.VerifyManifest != "" {
+		if err := r.verifyManifest(cfg.VerifyManifest); err != nil {
+			return Result{}, err
+		}
+	}
+	if cfg.ManifestOut != "" {
+		if err := r.writeManifest(cfg.ManifestOut); err != nil {
+			return Result{}, fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+
+	if err := r.Finish(rep, info); err != nil {
+		return Result{}, err
+	}
+
+	if cfg.Resume != "" && !r.stopped {
+		// Completed, nothing to resume.
+		if err := os.Remove(cfg.Resume); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return Result{}, err
+		}
+	}
+
+	if cfg.Fix {
+		if err := r.fix(cfg.WorkspaceDir, 1); err != nil {
+			return Result{}, err
+		}
+		if err := fixRounds(ctx, cfg, dirs, r); err != nil {
+			return Result{}, err
+		}
+	}
+	if cfg.Patch != nil {
+		if err := writePatch(cfg.Patch, cfg.WorkspaceDir, cfg.patched); err != nil {
+			return Result{}, fmt.Errorf("failed to write patch: %w", err)
+		}
+	}
+	if cfg.Script != nil {
+		if err := writeScript(cfg.Script, r.fixReport.Edits); err != nil {
+			return Result{}, fmt.Errorf("failed to write script: %w", err)
+		}
+	}
+
+	cfg.Logger.Debug("run finished", "findings", len(r.findings))
+
+	if cfg.FailFast && len(r.findings) > 0 {
+		err = &FailedError{Reasons: []string{"stopped at the first finding"}}
+	} else {
+		err = r.gate()
+	}
+
+	if cfg.SummaryOut != "" {
+		if werr := r.writeSummaryFile(cfg.SummaryOut, info, err); werr != nil {
+			return Result{}, fmt.Errorf("failed to write summary: %w", werr)
+		}
+	}
+
+	return r.result(), err
+}
+
+// Findings analyzes the workspace like Run, but returns the findings instead of writing them to cfg.Out,
+// which may be io.Discard. The files are never changed, i.e. Fix, Rename, Patch and Script are ignored.
+func Findings(ctx context.Context, cfg RunConfig) ([]Finding, error) {
+	cfg.Fix, cfg.Rename, cfg.Patch, cfg.Script = false, false, nil, nil
+	if err := cfg.init(); err != nil {
+		return nil, err
+	}
+	r, err := analyzeWorkspaces(ctx, cfg, nil, cfg.workspaceDirs())
+	if err != nil {
+		return nil, err
+	}
+	return r.outputFindings(r.findings), nil
+}
+
+// workspaceDirs returns the workspace roots to analyze, WorkspaceDirs relative to WorkspaceDir, if set.
+func (cfg RunConfig) workspaceDirs() []string {
+	if len(cfg.WorkspaceDirs) == 0 {
+		return []string{cfg.WorkspaceDir}
+	}
+	var dirs []string
+	for _, dir := range cfg.WorkspaceDirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cfg.WorkspaceDir, dir)
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs
+}
+
+// analyzeWorkspaces collects the findings in the workspace root dirs, reporting them to rep,
+// and returns them merged, also when interrupted, i.e. ctx is done, along with the error.
+func analyzeWorkspaces(ctx context.Context, cfg RunConfig, rep Reporter, dirs []string) (*runner, error) {
+	var r *runner
+	for _, dir := range dirs {
+		wr, err := analyzeWorkspace(ctx, cfg, rep, dir)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			r = wr
+		} else {
+			r.merge(wr)
+		}
+		if wr.stopped {
+			r.stopped, r.interrupted = true, wr.interrupted
+			break
+		}
+	}
+	if r.interrupted {
+		return r, fmt.Errorf("interrupted, the findings are incomplete: %w", ctx.Err())
+	}
+	return r, nil
+}
+
+// analyzeWorkspace collects the findings in the workspace root dir, once per build configuration, if any,
+// reporting them to rep.
+func analyzeWorkspace(ctx context.Context, cfg RunConfig, rep Reporter, dir string) (*runner, error) {
+	// This needs to be run from the rooot of a Go Module to get correct results.
+	if err := checkModule(dir); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.BuildConfigs) == 0 {
+		return analyzeBuild(ctx, cfg, rep, dir, nil)
+	}
+
+	var (
+		r        *runner
+		analyzed []map[string]bool
+	)
+	for i := range cfg.BuildConfigs {
+		br, err := analyzeBuild(ctx, cfg, rep, dir, &cfg.BuildConfigs[i])
+		if err != nil {
+			return nil, err
+		}
+		analyzed = append(analyzed, br.analyzedFiles())
+		if r == nil {
+			r = br
+		} else {
+			r.mergeBuild(br)
+		}
+		if br.stopped {
+			r.stopped, r.interrupted = true, br.interrupted
+			break
+		}
+	}
+	if cfg.AllBuildConfigs && !r.stopped {
+		r.keepInAllBuilds(analyzed)
+	}
+	if len(cfg.BuildConfigs) > 1 {
+		r.consolidateSites()
+	}
+	return r, nil
+}
+
+// analyzeBuild collects the findings in the workspace root dir with the given build configuration, which may be nil.
+// If dir is not cfg.WorkspaceDir, the filenames in the findings are made relative to cfg.WorkspaceDir.
+func analyzeBuild(ctx context.Context, cfg RunConfig, rep Reporter, dir string, build *BuildConfig) (r *runner, err error) {
+	var prefix string
+	if dir != cfg.WorkspaceDir {
+		rel, err := filepath.Rel(cfg.WorkspaceDir, dir)
+		if err != nil {
+			rel = dir
+		}
+		prefix = filepath.ToSlash(rel)
+	}
+	root := cfg.WorkspaceDir
+	cfg.WorkspaceDir = dir
+
+	r, err = newRunner(ctx, cfg, build)
+	if err != nil {
+		return nil, err
+	}
+	r.prefix = prefix
+	r.root = root
+	r.build = build
+	r.reporter = rep
+
+	if r.cacheable() {
+		if found, err := r.loadCache(); err != nil || found {
+			return r, err
+		}
+	}
+
+	if err = r.startGopls(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.collectLoadErrors()
+		if stopErr := r.Stop(); err == nil {
+			err = stopErr
+		}
+		if err == nil && !r.stopped && r.cache != nil {
+			if cerr := r.saveCache(); cerr != nil {
+				r.cfg.Logger.Warn("failed to cache the results", "error", cerr)
+			}
+		}
+	}()
+
+	if r.cfg.Resume != "" {
+		if err = r.loadState(); err != nil {
+			return
+		}
+	}
+
+	if err = r.Walk(); err != nil {
+		if errors.Is(err, errFailFast) {
+			r.stopped, err = true, nil
+		} else if ctx.Err() != nil {
+			// The findings so far are still reported, see analyzeWorkspaces.
+			r.stopped, r.interrupted, err = true, true, nil
+		}
+		return
+	}
+
+	if r.cfg.Reexports {
+		if err = r.reportReexports(); err != nil {
+			return
+		}
+	}
+
+	if r.cfg.Transitive {
+		if err = r.reportTransitive(); err != nil {
+			return
+		}
+	}
+
+	if r.cfg.DuplicateThreshold > 0 {
+		if err = r.reportDuplicates(); err != nil {
+			return
+		}
+	}
+
+	if r.cfg.CollapseAccessors {
+		if err = r.collapseAccessors(); err != nil {
+			return
+		}
+	}
+
+	if r.cfg.Rename || r.cfg.Fix && !r.cfg.FixTestOnly {
+		err = r.renameSymbols()
+	}
+
+	return
+}
+
+func checkModule(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+		// A go.work workspace of multiple modules.
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		return fmt.Errorf("workspace %s is not a Go module (go.mod is missing): %w", dir, err)
+	}
+	return nil
+}
+
+// merge adds the findings collected in another workspace to r.
+func (r *runner) merge(o *runner) {
+	r.findings = append(r.findings, o.findings...)
+	r.analyzed = append(r.analyzed, o.analyzed...)
+	r.skipped = append(r.skipped, o.skipped...)
+	r.walked = append(r.walked, o.walked...)
+	r.renamed = append(r.renamed, o.renamed...)
+	r.annotations = append(r.annotations, o.annotations...)
+	r.loadErrors = append(r.loadErrors, o.loadErrors...)
+	r.exported += o.exported
+	for owner, n := range o.exportedByOwner {
+		r.exportedByOwner[owner] += n
+	}
+	for pkg, n := range o.exportedByPackage {
+		r.exportedByPackage[pkg] += n
+	}
+	if r.codeOwners == nil {
+		r.codeOwners = o.codeOwners
+	}
+}
+
+// newRunner returns a runner for the workspace in cfg.WorkspaceDir analyzed with the given build configuration,
+// which may be nil. gopls is not started until startGopls.
+func newRunner(ctx context.Context, cfg RunConfig, build *BuildConfig) (*runner, error) {
+	matcher, err := compileFilenamePatterns(cfg.FilenamePatterns)
+	if err != nil {
+		return nil, err
+	}
+	if err := matcher.resolvePackages(cfg.WorkspaceDir); err != nil {
+		return nil, err
+	}
+
+	thresholds, err := compileThresholds(cfg.Thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	entryPoints, err := compileGlobs(cfg.EntryPoints)
+	if err != nil {
+		return nil, fmt.Errorf("entry points: %w", err)
+	}
+
+	testPackages, err := compileGlobs(cfg.TestPackages)
+	if err != nil {
+		return nil, fmt.Errorf("test packages: %w", err)
+	}
+
+	deadTargets, err := compileGlobs(cfg.DeadTargets)
+	if err != nil {
+		return nil, fmt.Errorf("dead targets: %w", err)
+	}
+
+	weakRefs, err := compileGlobs(cfg.WeakRefs)
+	if err != nil {
+		return nil, fmt.Errorf("weak refs: %w", err)
+	}
+
+	excludeDirs, err := compileGlobs(cfg.ExcludeDirs)
+	if err != nil {
+		return nil, fmt.Errorf("exclude dirs: %w", err)
+	}
+
+	var vendored map[string]bool
+	if cfg.IncludeVendored {
+		forks, err := compileGlobs(cfg.VendoredForks)
+		if err != nil {
+			return nil, fmt.Errorf("vendored forks: %w", err)
+		}
+		if vendored, err = patchedVendorDirs(cfg, forks); err != nil {
+			return nil, err
+		}
+	}
+
+	symbols, err := newSymbolFilter(cfg.Keep, cfg.Kinds)
+	if err != nil {
+		return nil, err
+	}
+	if symbols.linked, err = linkedSymbols(cfg.WorkspaceDir, modulePath(cfg.WorkspaceDir)); err != nil {
+		return nil, fmt.Errorf("failed to scan for linked symbols: %w", err)
+	}
+
+	skipHeaders, err := compileHeaderMatchers(cfg.SkipHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	receivers, err := compileGlobs(cfg.Receivers)
+	if err != nil {
+		return nil, fmt.Errorf("receivers: %w", err)
+	}
+
+	issueLink, err := compileIssueLink(cfg.IssueLink)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceLink, err := compileSourceLink(cfg.SourceLink)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamic, err := newDynamicUsage(cfg.DynamicUsageNames, cfg.DynamicUsageCalls)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := compileGlobs(cfg.EmbeddedTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("embedded templates: %w", err)
+	}
+	templateNames, err := embeddedTemplateNames(cfg.WorkspaceDir, templates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the embedded templates: %w", err)
+	}
+
+	downstream, err := loadDownstreamRefs(ctx, cfg.WorkspaceDir, cfg.Downstream)
+	if err != nil {
+		return nil, err
+	}
+
+	ignored, err := loadIgnoreFile(cfg.IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	codeOwners, err := loadCodeOwners(cfg.WorkspaceDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Owners) > 0 && codeOwners == nil {
+		return nil, fmt.Errorf("Owners requires a CODEOWNERS file, none found in %s (%s)", cfg.WorkspaceDir, strings.Join(codeOwnersLocations, ", "))
+	}
+
+	var sampler *rand.Rand
+	if cfg.Sample > 0 {
+		sampler = rand.New(rand.NewSource(cfg.Seed))
+	}
+
+	unsaved := overlay(cfg.Overlay)
+	return &runner{
+		ctx:           ctx,
+		sampler:       sampler,
+		codeOwners:    codeOwners,
+		issueLink:     issueLink,
+		sourceLink:    sourceLink,
+		dynamic:       dynamic,
+		templateNames: templateNames,
+		downstream:    downstream,
+		ignored:       ignored,
+		thresholds:    thresholds,
+		entryPoints:   entryPoints,
+		testPackages:  testPackages,
+		deadTargets:   deadTargets,
+		weakRefs:      weakRefs,
+		excludeDirs:   excludeDirs,
+		vendored:      vendored,
+		workModules:   workspaceModules(cfg.WorkspaceDir),
+		symbols:       symbols,
+		skipHeaders:   skipHeaders,
+		receivers:     receivers,
+		cfg:           cfg,
+		filematcher:   matcher,
+		overlay:       unsaved,
+		files:         &fileCache{workspaceDir: cfg.WorkspaceDir, overlay: unsaved},
+		generated:     make(map[lsp.DocumentURI]bool),
+		packageNames:  make(map[lsp.DocumentURI]string),
+		caseOnly:      make(map[lsp.DocumentURI]map[declKey]bool),
+		scopes:        make(map[string]map[string]bool),
+		inits:         make(map[string]map[string]bool),
+
+		exportedByOwner:   make(map[string]int),
+		exportedByPackage: make(map[string]int),
+	}, nil
+}
+
+// startGopls starts gopls, or the backend of RunConfig.Backend, for the workspace with the build configuration of r,
+// opening the overlay, if any.
+func (r *runner) startGopls() error {
+	cfg := r.cfg
+	var (
+		binary string
+		err    error
+	)
+	if cfg.Backend != BackendPackages {
+		if binary, err = resolveGopls(r.ctx, cfg.Gopls, cfg.GoplsInstall, cfg.GoplsCacheDir); err != nil {
+			return err
+		}
+	}
+	settings := r.build.goplsSettings()
+	var modfile string
+	if cfg.GoVersion != "" || cfg.ReadOnly {
+		if modfile, err = goVersionModfile(cfg.WorkspaceDir, cfg.GoVersion); err != nil {
+			if cfg.ReadOnly {
+				return fmt.Errorf("failed to copy the go.mod file: %w", err)
+			}
+			return fmt.Errorf("failed to set the Go version: %w", err)
+		}
+		settings = withBuildFlag(settings, "-modfile="+modfile)
+	}
+
+	cacheDir := cfg.GoplsCacheDir
+	if cacheDir == "" && cfg.ReadOnly {
+		// Removed with the modfile.
+		cacheDir = filepath.Join(filepath.Dir(modfile), "cache")
+	}
+	var env []string
+	if cacheDir != "" {
+		env = []string{"GOPLSCACHE=" + filepath.Join(cacheDir, "gopls"), "GOCACHE=" + filepath.Join(cacheDir, "go-build")}
+	}
+
+	var client backend
+	if cfg.Backend == BackendPackages {
+		client = newPackagesClient(cfg.WorkspaceDir, cfg.Logger, settings, env)
+	} else {
+		gopls, err := newClient(r.ctx, binary, cfg.WorkspaceDir, cfg.GoplsRemote, cfg.Logger, settings, env)
+		if err != nil {
+			removeModfile(modfile)
+			return err
+		}
+		gopls.retries, gopls.backoff = cfg.Retries, cfg.RetryBackoff
+		gopls.timeout, gopls.restarts = cfg.GoplsTimeout, cfg.GoplsRestarts
+		if cfg.GoplsWatchdog > 0 {
+			gopls.watch(cfg.GoplsWatchdog, cfg.GoplsWatchdogRestart)
+		}
+		client = gopls
+	}
+
+	opened := r.overlay
+	if modfile != "" {
+		if opened, err = moduleFiles(cfg.WorkspaceDir); err != nil {
+			client.Close()
+			removeModfile(modfile)
+			return err
+		}
+		for filename, content := range r.overlay {
+			opened[filename] = content
+		}
+	}
+	if err := opened.open(r.ctx, client); err != nil {
+		client.Close()
+		removeModfile(modfile)
+		return err
+	}
+	r.client, r.modfile = client, modfile
+	return nil
+}
+
+// RunConfig configures a run.
+// Fields not affecting the findings are excluded from the JSON encoding used for the configuration hash in RunInfo.
+type RunConfig struct {
+	WorkspaceDir string `json:"-"`
+
+	// WorkspaceDirs, if set, are the workspace roots to analyze instead of WorkspaceDir,
+	// relative to WorkspaceDir unless absolute, e.g. multiple unrelated module checkouts.
+	// The findings are collected in one report, with filenames relative to WorkspaceDir.
+	WorkspaceDirs []string `json:"-"`
+
+	// FilenamePatterns are the globs matching the Go files to check, relative to the workspace,
+	// e.g. "**/*.go". Patterns prefixed with ! exclude the files matching them, e.g. "!**/*_gen.go".
+	// Go package patterns, e.g. "./internal/..." or "github.com/me/proj/pkg/api", match the files
+	// of the packages go list resolves them to.
+	FilenamePatterns []string
+
+	Out io.Writer `json:"-"`
+
+	// Format is the output format, "text" (default), "json", "sarif", "quickfix", "md", "html" or "checkstyle".
+	Format string
+
+	// Severity maps check codes to severities, defaults to warning.
+	Severity map[string]Severity
+
+	// TestOnlySeverity, if set, is the severity of the symbols used in test only (EU1001), overriding Severity,
+	// e.g. info for the exit code to only reflect the unused symbols. See IgnoreTestOnly to not report them at all.
+	TestOnlySeverity Severity
+
+	// IgnoreTestOnly considers the symbols used in test only used, so they're never reported as EU1001.
+	IgnoreTestOnly bool
+
+	// Hints maps check codes to the hints of their findings, e.g. "see go/dead-code", replacing the default ones
+	// of the checks, see punused explain. An empty hint leaves it out.
+	Hints map[string]string
+
+	// IgnoreGeneratedRefs makes references from generated files (e.g. mocks) not count as usage.
+	// Symbols only referenced from generated files are reported as EU1006.
+	IgnoreGeneratedRefs bool
+
+	// TaggedFields reports the unused fields with serialization struct tags, e.g. `json:"name"`,
+	// as EU1009 instead of EU1002, as they may still be part of a wire format.
+	TaggedFields bool
+
+	// CaseOnly reports the exported constants only used as switch case expressions or in blank assignments,
+	// e.g. _ = Red, as EU1012, instead of counting them as used.
+	CaseOnly bool
+
+	// SkipSerializedFields skips the fields of the structs likely (un)marshaled via reflection, i.e. with a field
+	// with a serialization struct tag, or passed, directly or as a variable, to a call like json.Marshal or Decode.
+	SkipSerializedFields bool
+
+	// DuplicateThreshold, if > 0, also reports the exported functions with the same signature as,
+	// and a body at least this similar (from 0 to 1) to, an exported function in another package (EU1010).
+	DuplicateThreshold float64
+
+	// StdlibClones, if > 0, also reports the exported top level functions with at most this many references
+	// whose name and signature match a standard library function, e.g. a clone of slices.Contains, available
+	// with the Go version of the workspace (EU1013), see GoVersion.
+	StdlibClones int
+
+	// MinRefs, if > 1, also reports the exported symbols in use with fewer references than this (EU1004),
+	// e.g. 3 for those with one or two, candidates for inlining or unexporting.
+	MinRefs int
+
+	// SameFile also reports symbols only referenced from the file declaring them (EU1007).
+	SameFile bool
+
+	// SamePackage also reports symbols only referenced from the package declaring them (EU1003),
+	// with their unexported name as the suggested rename, applied with Rename or Fix.
+	SamePackage bool
+
+	// IncludeTestdata includes the files in testdata directories, both as declarations
+	// and as references. These are ignored by default, as by the go tool.
+	IncludeTestdata bool
+
+	// IncludeGenerated analyzes the symbols declared in generated files, those with the standard
+	// "// Code generated ... DO NOT EDIT." header, e.g. protobuf messages, stringers or mocks.
+	// These are skipped by default, as removing their symbols gets undone the next time the code is generated.
+	// References from generated files still count, unless IgnoreGeneratedRefs is set.
+	IncludeGenerated bool
+
+	// IncludeUnexported also reports the unexported functions, types, constants and variables without
+	// any references (EU1002), e.g. in package main programs, where nothing is exported.
+	IncludeUnexported bool
+
+	// MainPackages is the policy for the exported symbols declared in package main, e.g. cobra command variables,
+	// which nothing can import: check (the default) checks them as any other, skip leaves them out,
+	// and unexported-rule only reports those without any references, leaving out their methods and fields,
+	// as with IncludeUnexported.
+	MainPackages string
+
+	// EnumPolicy is the policy for the constants of an enum, i.e. a const group with implicit values or using iota,
+	// whose members are typically kept together: each (the default) checks each constant on its own,
+	// and any-used leaves them all out if any of them has references.
+	EnumPolicy string
+
+	// CollapseAccessors reports the unused accessors of a type, e.g. generated GetX and SetX methods
+	// for its fields, as a single finding if all of them are unused.
+	CollapseAccessors bool
+
+	// AnnotateAll also lists every exported symbol analyzed, used or not, with its number of references
+	// and usage status, in the JSON and text output, see Report.Symbols.
+	AnnotateAll bool
+
+	// AbsPaths prints the filenames in the findings as absolute paths instead of relative to the workspace.
+	AbsPaths bool
+
+	// PathPrefix, if set, is prepended to the filenames in the findings instead, e.g. the location
+	// of the workspace on the host when the analysis runs in a container.
+	PathPrefix string
+
+	// Sample, if > 0, only analyzes this fraction of the eligible files, chosen at random
+	// using Seed, e.g. 0.1 for a quick estimate of the dead code levels in a huge repository.
+	Sample float64
+	Seed   int64
+
+	// OnFinding, if set, is called with each finding, as printed, as soon as it's found, e.g. to publish diagnostics
+	// while the analysis runs. It's called from the goroutine walking the files, one finding at a time, which waits
+	// for it to return. With multiple BuildConfigs, it's called with the findings of each, before they're merged,
+	// and with Fix and Iterations, with those of the first round only.
+	OnFinding func(Finding) `json:"-"`
+
+	// FailFast stops the run at the first finding and fails it.
+	FailFast bool
+
+	// MinConfidence, if set, only reports the findings with at least this confidence,
+	// which also limits what Fix and Rename change.
+	MinConfidence Confidence
+
+	// Rename renames the symbols used in test only (EU1001) to their unexported name using gopls,
+	// suffixed with ForTest if that's taken in the package, and those only used in their declaring
+	// package (EU1003) to their unexported name, if not taken.
+	Rename bool
+
+	// ShowRefs lists the locations referencing the symbol beneath the findings still referenced,
+	// e.g. the tests keeping a symbol used in test only (EU1001) alive.
+	ShowRefs bool
+
+	// SnippetLines, if > 0, includes the first lines of each flagged declaration in the JSON output.
+	SnippetLines int
+
+	// Fix enables changing the files, which is never done by default.
+	// It moves the declarations of the symbols used in test only (EU1001) to a _test.go file
+	// in the same package, e.g. foo_test.go for foo.go, or export_test.go, and removes the unused types
+	// with all their methods and unused constructors. Types with methods are only found with Transitive.
+	Fix bool
+
+	// FixTestOnly limits Fix to moving the declarations of the symbols used in test only (EU1001) to a _test.go file,
+	// leaving the unused types and the symbols only used in their declaring package (EU1003) alone.
+	FixTestOnly bool
+
+	// FixVerify is how the code changed by Fix is verified after each round: build (the default) runs
+	// go build ./..., vet also go vet ./..., test also compiles the tests, running none, and none skips it.
+	// The packages failing are rolled back, their findings only reported. It is skipped with Patch or Script,
+	// and if the workspace does not build before the fixes.
+	FixVerify string `json:"-"`
+
+	// Iterations, if > 1, re-analyzes the workspace after Fix and fixes it again, as removing code may leave
+	// other code unused, until a round changes nothing or Iterations rounds are done.
+	// The findings reported are those of the first round.
+	Iterations int
+
+	// FixReport, if set, is the file to write the edits made by Fix to, as JSON, see FixReport.
+	FixReport string
+
+	// FixMessage, if set, is the file to write a commit message for the edits made by Fix to,
+	// listing them with their fingerprints, followed by git trailers with a digest of the edits.
+	FixMessage string
+
+	// IncludeTestFiles also analyzes the exported symbols declared in _test.go files, e.g. test helpers,
+	// except the test, benchmark, example and fuzz functions. References from test files always count.
+	IncludeTestFiles bool
+
+	// Concurrency, if > 1, is the number of files whose symbols and references are fetched from gopls
+	// concurrently. The files are still handled, and the findings reported, in order.
+	Concurrency int
+
+	// Pipeline, if > 1, is the number of reference requests for the symbols of a file sent to gopls at once,
+	// without waiting for the responses, instead of one at a time, per file fetched with Concurrency.
+	Pipeline int `json:"-"`
+
+	// MaxFileSize and MaxSymbolsPerFile, if > 0, skips files bigger than the limits,
+	// typically generated files that would take a long time to analyze.
+	MaxFileSize       int64
+	MaxSymbolsPerFile int
+
+	// FailOnExcluded fails the run if gopls reports files or directories excluded from its view, e.g. by
+	// directoryFilters or for being in a module outside the workspace, as the symbols only they use are reported
+	// unused. The exclusions are listed in the summary either way, see LoadError.
+	FailOnExcluded bool
+
+	// MaxFindings, if set, fails the run when the number of findings exceeds it, e.g. the budget of a cleanup
+	// tracked over time. The findings with severity error only fail the run when it's exceeded.
+	MaxFindings *int
+
+	// MaxUnusedPercent, if set, fails the run when the percentage of the exported symbols
+	// with findings exceeds it.
+	MaxUnusedPercent *float64
+
+	// EntryPoints are globs matching package directories (relative to the workspace, e.g. "cmd/**")
+	// whose exported symbols are entry points, e.g. plugins or magefiles.
+	// These are never reported.
+	EntryPoints []string
+
+	// TestPackages are globs matching package directories (relative to the workspace, e.g. "internal/fakes")
+	// of test infrastructure, whose exported symbols used in test only are not reported, as the tests of other
+	// packages use them. Packages named e.g. testutil, and files importing "testing" or only built with a test build
+	// tag, e.g. integration, are recognized as such without it. Their unused symbols are still reported.
+	TestPackages []string
+
+	// ExcludeDirs are globs matching directories (relative to the workspace, e.g. "third_party/**")
+	// not walked. References from the files in them still count.
+	ExcludeDirs []string
+
+	// IncludeVendored includes the vendored packages patched locally, i.e. forks carried in the tree:
+	// those of the modules matching VendoredForks, replaced by a directory in go.mod, or whose files differ
+	// from the module cache. The vendor directories are otherwise not walked, as by the go tool.
+	// References from the vendored files always count.
+	IncludeVendored bool
+
+	// FollowSymlinks follows the symbolic links to directories when walking the workspace, e.g. source shared
+	// between repositories, walking each directory once. These are otherwise skipped, as by filepath.Walk.
+	FollowSymlinks bool
+
+	// IncludeSubmodules includes the directories with a go.mod file of their own below the workspace root,
+	// the nested modules, which are otherwise skipped, as they're not loaded by the gopls session of the
+	// workspace, unless used by its go.work file, and their symbols would be reported without their references.
+	IncludeSubmodules bool
+
+	// VendoredForks are globs matching the paths of the vendored modules, e.g. "github.com/acme/*",
+	// included with IncludeVendored.
+	VendoredForks []string
+
+	// SkipHeaders are regular expressions matching the header comments, before the package clause,
+	// of files not analyzed, e.g. the license headers of third-party code copied outside vendor.
+	// References from these files still count.
+	SkipHeaders []string
+
+	// Keep are regular expressions matching the names of symbols never reported, nor changed by Fix,
+	// e.g. "^Must" or `^\(\*Server\)\.`, see Finding.Name. An expression prefixed by a glob and =
+	// only keeps the symbols in the package directories (relative to the workspace) matching it,
+	// e.g. "pkg/api/**=^New", or "pkg/api/...=^New" to include pkg/api itself.
+	Keep []string
+
+	// Kinds, if set, limits the symbols checked to these kinds, as in the findings, e.g. "function" or "field",
+	// or Go's, e.g. "type" for struct, interface and class, excluding those prefixed with !, e.g. "!const".
+	Kinds []string
+
+	// DeadTargets are globs matching package directories (relative to the workspace) of deprecated
+	// or excluded build targets, e.g. "cmd/legacy-*". Symbols only referenced from these are
+	// reported as only used by unused code (EU1005), with the targets in UsedBy.
+	DeadTargets []string
+
+	// WeakRefs are globs matching the files (relative to the workspace) whose references are weak usage,
+	// e.g. "examples/**" or "tools/**". Symbols only referenced from these are reported as EU1014.
+	WeakRefs []string
+
+	// Receivers are globs matching receiver types, as package name and type name, e.g. "ent.*",
+	// whose methods are never reported, e.g. the accessors of generated API structs or ORM models.
+	Receivers []string
+
+	// DynamicUsageNames are globs matching the names of symbols used by name or via reflection,
+	// e.g. "Handle*". Unused symbols matching them are reported as EU1008.
+	DynamicUsageNames []string
+
+	// DynamicUsageCalls are globs matching calls, as written, whose arguments' methods are used via reflection,
+	// e.g. "rpc.Register". Unused methods on types passed to them are reported as EU1008.
+	// If nil, the net/rpc and encoding/gob registrations and template executions are used.
+	DynamicUsageCalls []string
+
+	// Progress, if set, receives the progress of the analysis: the files analyzed, the symbols checked,
+	// the findings so far and the current file, redrawn in place if it's a terminal, else logged with Logger
+	// every few seconds.
+	Progress io.Writer `json:"-"`
+
+	// IgnoreFile, if set, is a file listing the IDs of the findings not reported, see IgnoreFilename and Suppress.
+	IgnoreFile string `json:"-"`
+
+	// EmbeddedTemplates are globs matching the files, relative to the workspace, embedded with //go:embed,
+	// e.g. "**/*.go.tmpl", that are code templates: the symbols whose names appear in them are not reported,
+	// as used by the generated code.
+	EmbeddedTemplates []string
+
+	// Downstream lists the consumers of the workspace's API, directories, relative to the workspace,
+	// or module paths, optionally with a version, e.g. "github.com/acme/app@v1.2.0", fetched with go get
+	// into a temporary module. The symbols they reference, matched by package and name, are not reported.
+	Downstream []string
+
+	// IssueLink, if set, is a text/template rendered into each finding's IssueURL,
+	// with the finding available as .Symbol.
+	IssueLink string
+
+	// SourceLink, if set, is a text/template rendered into each finding's SourceURL, like IssueLink,
+	// e.g. linking the findings of the HTML report to the repository browser.
+	SourceLink string
+
+	// Thresholds maps filename globs to the finding budget for the files matching them.
+	Thresholds map[string]Threshold
+
+	// FailOn lists check codes, e.g. EU1002, whose findings fail the run whatever their severity
+	// and the thresholds, e.g. to block on unused symbols but not on symbols used in test only.
+	FailOn []string
+
+	// Owners, if set, only analyzes the files owned, per CODEOWNERS, by one of these owners, matched as with
+	// FailOwners, e.g. to drive the cleanup of a team's code. The references from the other files still count.
+	Owners []string
+
+	// FailOwners, if set, only counts the findings owned, per CODEOWNERS, by one of these owners in the gates,
+	// e.g. the team whose pipeline runs a shared CI job. An owner matches as in CODEOWNERS, e.g. @org/payments,
+	// or by its name, e.g. payments, and unowned matches the files without an owner.
+	FailOwners []string
+
+	// Resume, if set, is the file the progress of the run is saved to while walking the files, at least
+	// every 10 seconds and when interrupted, for a later run to resume from, skipping the files already analyzed.
+	// A run with another configuration, or on a tree with changed Go files, starts over. It's removed when
+	// the run completes. It cannot be combined with Transitive, DuplicateThreshold, WorkspaceDirs or multiple
+	// BuildConfigs, whose findings need the symbols of all the files.
+	Resume string `json:"-"`
+
+	// CodePrefix, if set, replaces the EU prefix of the check codes in the output, e.g. ACME1002 instead of EU1002.
+	// The codes in Severity, FailOn and the suppression directives may have either prefix.
+	CodePrefix string
+
+	// BuildConfigs, if set, are the build configurations (GOOS, GOARCH and build tags) to analyze
+	// the workspace with, one after the other. A finding appearing with multiple configurations is
+	// reported once, with BuildConfigs listing them, as is a symbol declared in multiple files for different
+	// configurations, e.g. foo_linux.go and foo_windows.go, with Sites listing the declarations.
+	BuildConfigs []BuildConfig
+
+	// AllBuildConfigs, with multiple BuildConfigs, only reports the findings appearing with every configuration
+	// analyzing the file declaring the symbol, e.g. not a symbol only referenced from linux files as unused on windows.
+	AllBuildConfigs bool
+
+	// GoVersion, if set, is the Go language version, e.g. 1.18, to analyze the workspace with instead of the one
+	// of the go directive in its go.mod file, e.g. the version of the oldest toolchain building it.
+	// It cannot be used with a go.work workspace.
+	GoVersion string
+
+	// ReadOnly analyzes a workspace on a read-only file system, e.g. a Bazel sandbox or a CI cache mount:
+	// its go.mod and go.sum files are copied to a temporary directory passed to the go command with -modfile,
+	// as with GoVersion, and the gopls and go build caches are kept there too, unless GoplsCacheDir is set.
+	// It cannot be used with a go.work workspace, nor with Fix or Rename.
+	ReadOnly bool `json:"-"`
+
+	// Backend is the backend answering the queries of the analysis, BackendGopls, the default if not set,
+	// or BackendPackages to analyze the workspace without gopls, e.g. in a CI container without it,
+	// type checking it with golang.org/x/tools/go/packages. Rename, and Watch, require gopls.
+	Backend string
+
+	// Gopls is the gopls binary, a path or a name looked up in PATH, the one named by the PUNUSED_GOPLS
+	// environment variable, see GoplsEnv, if not set, else gopls. Its version is verified before the analysis.
+	Gopls string `json:"-"`
+
+	// GoplsInstall installs gopls, at a version known to work, into GoplsCacheDir, or the user cache directory,
+	// with go install, if Gopls is missing or its version is not supported, instead of failing the run.
+	GoplsInstall bool `json:"-"`
+
+	// GoplsCacheDir, if set, is the directory of the gopls file cache (GOPLSCACHE) and the go build cache (GOCACHE)
+	// of the gopls processes started, e.g. a writable scratch directory when the user cache directory is not.
+	// It does not apply to a daemon with GoplsRemote, which keeps its own caches.
+	GoplsCacheDir string `json:"-"`
+
+	// Retries is the number of times a gopls request failing (typically transiently, right
+	// after the workspace is loaded) is retried, waiting RetryBackoff, doubled for every attempt, in between.
+	Retries      int           `json:"-"`
+	RetryBackoff time.Duration `json:"-"`
+
+	// GoplsTimeout, if set, is the time a gopls request may take before gopls is considered hung.
+	// GoplsRestarts is the number of times gopls is restarted, when it exits or hangs, before giving up,
+	// the analysis resuming with the request failing.
+	GoplsTimeout  time.Duration `json:"-"`
+	GoplsRestarts int           `json:"-"`
+
+	// GoplsWatchdog, if set, is the stall threshold of the watchdog pinging gopls, every GoplsWatchdog,
+	// logging when it does not respond within it, and, with GoplsWatchdogRestart, restarting it,
+	// counted as one of GoplsRestarts, the analysis resuming with the requests in flight.
+	GoplsWatchdog        time.Duration `json:"-"`
+	GoplsWatchdogRestart bool          `json:"-"`
+
+	// Logger receives the diagnostics, defaults to slog.Default().
+	// The findings are only ever written to Out.
+	Logger *slog.Logger `json:"-"`
+
+	// Verbose adds the symbol's signature and doc summary to the text output.
+	Verbose bool
+
+	// Stream keeps the findings in the order of the walk, printed in the text format as they're found,
+	// instead of sorted by filename, position and check code when the analysis is done.
+	Stream bool `json:"-"`
+
+	// Packages adds the summary, and the number of exported symbols analyzed, used in test only and unused
+	// per package, to the end of the text output, as Verbose does, e.g. to track the progress of a cleanup.
+	Packages bool
+
+	// Blame annotates each finding with the last author and modification time from git blame.
+	Blame bool
+
+	// Transitive also reports symbols only referenced by unused code (EU1005).
+	Transitive bool
+
+	// Reexports also reports symbols only referenced by re-export shims in other packages (EU1011), e.g. a function
+	// only calling it or a type alias, that are themselves unused or deprecated, so both layers are removed together.
+	Reexports bool
+
+	// MinAge, if > 0, only reports symbols whose declaration has not been modified within MinAge
+	// according to git blame, filtering out new API that has not gained its users yet.
+	MinAge time.Duration
+
+	// ChangedLines, if set, only reports symbols whose declaration, including its doc comment,
+	// intersects the lines changed, e.g. those added in a pull request, see ParseDiff.
+	ChangedLines ChangedLines
+
+	// Base, if set, is a git revision, e.g. origin/main, whose merge base with HEAD is analyzed too,
+	// in a temporary worktree, to mark each finding as introduced on the branch or pre-existing, see Finding.Origin.
+	Base string `json:"-"`
+
+	// FailIntroducedOnly, with Base, only counts the findings introduced on the branch in the gates,
+	// i.e. severity error, FailOn and the Thresholds.
+	FailIntroducedOnly bool
+
+	// FixMinAge, if > 0, limits Fix to the symbols whose declaration has not been modified within FixMinAge
+	// according to git blame, so long dead code is removed while recent additions are still reported, but left alone.
+	FixMinAge time.Duration
+
+	// Top, if > 0, adds a ranking of the Top packages (and authors, if Blame is set)
+	// with the most unused symbols to the output.
+	Top int
+
+	// HistoryFile, if set, is a JSON Lines file a summary of the run gets appended to,
+	// or its URL in a bucket, e.g. s3://bucket/punused/history.jsonl, as with Cache. See Trend.
+	HistoryFile string `json:"-"`
+
+	// SummaryOut, if set, is a file the summary of the run, including the durations and
+	// the outcome of the gates, is written to as JSON, whatever the output format.
+	SummaryOut string `json:"-"`
+
+	// Overlay maps filenames, absolute or relative to the workspace, to content analyzed instead
+	// of the content on disk, e.g. the unsaved buffers of an editor or the files changed in a pull request.
+	// Only files on disk are analyzed, i.e. the overlay cannot add new files.
+	Overlay map[string]string `json:"-"`
+
+	// ManifestOut, if set, is the file to write the analysis manifest to: the files analyzed
+	// with their hashes and a hash of the configuration used, see VerifyManifest.
+	ManifestOut string `json:"-"`
+
+	// VerifyManifest, if set, is a manifest written with ManifestOut the run must match,
+	// e.g. to make sure a fix run operates on the same tree and configuration as the analysis
+	// the findings were reviewed from. The run fails before any files are changed if not.
+	VerifyManifest string `json:"-"`
+
+	// OwnersDir, if set, is a directory one report per CODEOWNERS owner group is written to,
+	// in Format, e.g. "org-team.json". Findings without an owner are written to "unowned".
+	OwnersDir string `json:"-"`
+
+	// ModulesDir, if set, is a directory one report per Go module with findings is written to,
+	// in Format, named by the module path, e.g. "github.com-org-billing.json",
+	// for analyses of multiple modules, e.g. in a go.work workspace.
+	ModulesDir string `json:"-"`
+
+	// OutputDir, if set, is a directory one report per package with findings is written to,
+	// in Format, named by the package directory, e.g. "internal-lib.json", along with an index
+	// of the reports and the totals of the run, "index.json" in Format json, else "index.txt".
+	OutputDir string `json:"-"`
+
+	// DotOut, if set, receives the reference graph of the unused symbols in DOT format.
+	// It requires Transitive.
+	DotOut io.Writer `json:"-"`
+
+	// Patch, if set, receives the changes made by Fix or Rename as a unified diff, applicable with git apply,
+	// instead of them being written to the files. It cannot be combined with Iterations > 1.
+	Patch io.Writer `json:"-"`
+
+	// Script, if set, receives the changes made by Fix as a shell script of rf (rsc.io/rf) commands, for review and
+	// later application, instead of them being written to the files. It cannot be combined with Patch, nor with
+	// Iterations > 1.
+	Script io.Writer `json:"-"`
+
+	// GoplsRemote, if set, is passed to gopls as -remote, connecting to a shared gopls daemon, started if needed,
+	// instead of running gopls in process, e.g. "auto". The daemon keeps its caches between the runs, see Fleet.
+	GoplsRemote string `json:"-"`
+
+	// Cache, if set, is the directory, or the bucket, as a URL, e.g. s3://bucket/punused or gs://bucket/punused,
+	// shared by ephemeral CI runners, the results of the analysis are cached in, keyed by a hash of the
+	// configuration, the build configuration and the content of the Go files and go.mod, see cachedResult,
+	// so a run on an unchanged tree reuses them without starting gopls. Runs with Fix, Rename, Resume, Blame,
+	// MinAge, FailFast or DotOut are never cached, as they need gopls or the git history.
+	Cache string `json:"-"`
+
+	// patched holds the content of the files changed with Patch set, shared by all the runners, see init.
+	patched overlay
+}
+
+// init validates cfg and sets the defaults.
+func (cfg *RunConfig) init() error {
 	if err := cfg.validate(); err != nil {
 		return err
 	}
+	if cfg.Format == "" {
+		cfg.Format = formatText
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	cfg.Severity, cfg.Hints, cfg.FailOn = canonicalKeys(cfg.Severity), canonicalKeys(cfg.Hints), canonicalCodes(cfg.FailOn)
+	if cfg.TestOnlySeverity != "" {
+		if cfg.Severity == nil {
+			cfg.Severity = make(map[string]Severity)
+		}
+		cfg.Severity[codeTestOnly] = cfg.TestOnlySeverity
+	}
+	cfg.Overlay = newOverlay(cfg.WorkspaceDir, cfg.Overlay)
+	if cfg.Patch != nil || cfg.Script != nil {
+		cfg.patched = make(overlay)
+	}
+	return nil
+}
+
+func (cfg RunConfig) validate() error {
+	if cfg.WorkspaceDir == "" {
+		return fmt.Errorf("WorkspaceDir is required")
+	}
+	if len(cfg.FilenamePatterns) == 0 {
+		return fmt.Errorf("FilenamePatterns is required")
+	}
+	if cfg.Out == nil {
+		return fmt.Errorf("Out is required")
+	}
+	if cfg.Backend != "" && cfg.Backend != BackendGopls && cfg.Backend != BackendPackages {
+		return fmt.Errorf("invalid Backend %q, must be one of %s or %s", cfg.Backend, BackendGopls, BackendPackages)
+	}
+	if cfg.Backend == BackendPackages && cfg.Rename {
+		return fmt.Errorf("Rename requires the %s backend", BackendGopls)
+	}
+	if err := validateSeverities(cfg.Severity); err != nil {
+		return err
+	}
+	if cfg.TestOnlySeverity != "" {
+		if err := validateSeverities(map[string]Severity{codeTestOnly: cfg.TestOnlySeverity}); err != nil {
+			return err
+		}
+	}
+	if err := validateFailOn(cfg.FailOn); err != nil {
+		return err
+	}
+	if err := validateCodePrefix(cfg.CodePrefix); err != nil {
+		return err
+	}
+	if err := validateGoVersion(cfg.GoVersion); err != nil {
+		return err
+	}
+	if err := validateFixVerify(cfg.FixVerify); err != nil {
+		return err
+	}
+	if err := validateEnumPolicy(cfg.EnumPolicy); err != nil {
+		return err
+	}
+	if err := validateMainPackages(cfg.MainPackages); err != nil {
+		return err
+	}
+	if cfg.ReadOnly && (cfg.Fix || cfg.Rename) {
+		return fmt.Errorf("ReadOnly cannot be combined with Fix or Rename, which write to the workspace")
+	}
+	if cfg.DuplicateThreshold < 0 || cfg.DuplicateThreshold > 1 {
+		return fmt.Errorf("DuplicateThreshold must be between 0 and 1")
+	}
+	if cfg.FailIntroducedOnly && cfg.Base == "" {
+		return fmt.Errorf("FailIntroducedOnly requires Base")
+	}
+	if cfg.Iterations < 0 {
+		return fmt.Errorf("Iterations must be >= 0")
+	}
+	if cfg.Rename && (cfg.Fix || len(cfg.BuildConfigs) > 1) {
+		return fmt.Errorf("Rename cannot be combined with Fix or multiple BuildConfigs")
+	}
+	if len(cfg.Overlay) > 0 && (cfg.Fix || cfg.Rename) {
+		return fmt.Errorf("Overlay cannot be combined with Fix or Rename")
+	}
+	if cfg.AbsPaths && cfg.PathPrefix != "" {
+		return fmt.Errorf("AbsPaths and PathPrefix are mutually exclusive")
+	}
+	if cfg.Sample < 0 || cfg.Sample > 1 {
+		return fmt.Errorf("Sample must be between 0 and 1, got %v", cfg.Sample)
+	}
+	if cfg.Resume != "" && (cfg.Transitive || cfg.Reexports || cfg.DuplicateThreshold > 0 || cfg.AnnotateAll || len(cfg.WorkspaceDirs) > 0 || len(cfg.BuildConfigs) > 1) {
+		return fmt.Errorf("Resume cannot be combined with Transitive, Reexports, DuplicateThreshold, AnnotateAll, WorkspaceDirs or multiple BuildConfigs")
+	}
+	if cfg.Patch != nil && (!cfg.Fix && !cfg.Rename || cfg.Iterations > 1) {
+		return fmt.Errorf("Patch requires Fix or Rename, and cannot be combined with Iterations > 1")
+	}
+	if cfg.Script != nil && (!cfg.Fix || cfg.Patch != nil || cfg.Iterations > 1) {
+		return fmt.Errorf("Script requires Fix, and cannot be combined with Patch or Iterations > 1")
+	}
+	if cfg.FixTestOnly && (!cfg.Fix || cfg.IgnoreTestOnly) {
+		return fmt.Errorf("FixTestOnly requires Fix, and cannot be combined with IgnoreTestOnly")
+	}
+	if cfg.DotOut != nil && !cfg.Transitive {
+		return fmt.Errorf("DotOut requires Transitive")
+	}
+	switch cfg.Format {
+	case "", formatText, formatJSON, formatSARIF, formatQuickfix, formatMarkdown, formatHTML, formatCheckstyle:
+	default:
+		return fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+	return nil
+}
+
+type runner struct {
+	ctx          context.Context
+	cfg          RunConfig
+	prefix       string
+	root         string
+	build        *BuildConfig
+	filematcher  filenameMatcher
+	thresholds   []threshold
+	entryPoints  globs
+	testPackages globs
+	deadTargets  globs
+	weakRefs     globs
+	excludeDirs  globs
+
+	// vendored holds the directories, relative to the workspace, of the vendored packages analyzed
+	// with IncludeVendored, see patchedVendorDirs.
+	vendored map[string]bool
+
+	// workModules holds the directories, relative to the workspace, of the modules used by its go.work file,
+	// walked even without IncludeSubmodules.
+	workModules map[string]bool
+	symbols     symbolFilter
+	skipHeaders headerMatchers
+	receivers   globs
+	codeOwners  codeOwners
+	issueLink   *template.Template
+	sourceLink  *template.Template
+	dynamic     *dynamicUsage
+
+	// templateNames are the identifiers in the embedded templates, see RunConfig.EmbeddedTemplates.
+	templateNames map[string]bool
+
+	// downstream holds the references of the downstream consumers, see RunConfig.Downstream.
+	downstream downstreamRefs
+
+	// ignored are the IDs of the findings in the ignore file, see RunConfig.IgnoreFile.
+	ignored map[string]bool
+
+	// progress reports the progress of the walk, if RunConfig.Progress is set.
+	progress *progress
+	client   backend
+
+	// files caches information about the file currently being handled.
+	files *fileCache
+
+	// imported caches the names of the packages imported by the files fixed, see importedNames.
+	imported *importedNames
+
+	// analyzed holds all the analyzed symbols when transitive analysis, or Reexports, is enabled.
+	analyzed []*analyzedSymbol
+
+	// findings collected during the walk.
+	findings []Finding
+
+	// exported is the number of exported symbols analyzed.
+	exported int
+
+	// exportedByOwner is the number of exported symbols analyzed per CODEOWNERS owner group.
+	exportedByOwner map[string]int
+
+	// exportedByPackage is the number of exported symbols analyzed per package directory.
+	exportedByPackage map[string]int
+
+	// sampler picks the files to analyze when sampling.
+	sampler *rand.Rand
+
+	// scopes caches the top level identifiers per package, see packageScope.
+	scopes map[string]map[string]bool
+
+	// loadErrors holds the errors gopls reported for the packages in the workspace.
+	loadErrors []LoadError
+
+	// stopped is set when the walk stopped at the first finding, or was interrupted,
+	// i.e. the context canceled, with interrupted set.
+	stopped     bool
+	interrupted bool
+
+	// skipped holds the files not analyzed, e.g. because they're too big.
+	skipped []SkippedFile
+
+	// prefetched holds the symbols and references of the file handled, if fetched concurrently.
+	prefetched *prefetched
+
+	// reporter writes the findings, nil if they're not written.
+	reporter Reporter
+
+	// fixReport holds the edits made by fix.
+	fixReport FixReport
+
+	// renamed holds the renames made with Fix while analyzing, added to the fix report by fix.
+	renamed []FixEdit
+
+	// fixVerified is set once the workspace is verified to build before fixing it, see snapshotFix,
+	// and reportOnly holds the files whose fixes were rolled back, not fixed again, see verifyFix.
+	fixVerified bool
+	reportOnly  map[string]bool
+
+	// annotations holds the symbols analyzed with AnnotateAll, see annotate.
+	annotations []InventorySymbol
+
+	// overlay holds the unsaved content of files, see RunConfig.Overlay.
+	overlay overlay
+
+	// modfile is the go.mod file with the go directive set to GoVersion, or copied with ReadOnly, if set,
+	// see goVersionModfile.
+	modfile string
+
+	// walked holds the files analyzed, relative to the workspace root, see manifest.
+	walked []string
+
+	// grpc caches whether the workspace has gRPC generated files, see hasGRPCFiles.
+	grpc *bool
+
+	// module caches the module path of the workspace, see importPath.
+	module *string
+
+	// langVersion caches the Go language version of the workspace, see stdlibClone.
+	langVersion *string
+
+	// blankImports caches the import paths of the packages imported for their side effects
+	// and inits the identifiers referenced from the init functions per package, see isRegisteredInInit.
+	blankImports map[string]bool
+	inits        map[string]map[string]bool
+
+	// observe, if set, is called for each analyzed exported symbol with its check code
+	// (empty if used) and the number of references counting as usage, see Stats.
+	observe func(filename string, s *Symbol, code string, refs int)
+
+	// generated caches whether a referencing file is generated.
+	generated map[lsp.DocumentURI]bool
+
+	// packageNames caches the package names of the referencing files, see packageName.
+	packageNames map[lsp.DocumentURI]string
+
+	// caseOnly caches the uses of the identifiers in the referencing files, see caseUses.
+	caseOnly map[lsp.DocumentURI]map[declKey]bool
+
+	// state is the progress of the run saved with Resume, nil if not set.
+	state *runState
+
+	// cache is the store the results are cached in, see RunConfig.Cache, and cacheObject their key, nil if not cached.
+	cache       store
+	cacheObject string
+
+	// only, if set, limits the walk to these files, relative to the workspace, see Watch.
+	only map[string]bool
+
+	// referencing, if set, collects the files referencing the symbols declared in each file analyzed, see Watch.
+	referencing map[string]map[string]bool
+}
+
+func (r *runner) Stop() error {
+	removeModfile(r.modfile)
+	if r.client == nil {
+		// Not started, e.g. the results were cached.
+		return nil
+	}
+	return r.client.Close()
+}
+
+// Finish writes the reports and any other output collected during the walk, finishing rep.
+// info is included in the machine readable formats.
+func (r *runner) Finish(rep Reporter, info *RunInfo) error {
+	r.warnLoadErrors(sortLoadErrors(r.loadErrors))
+	if !r.cfg.Stream {
+		r.findings = sortedByPosition(r.findings)
+	}
+	if r.cfg.DotOut != nil {
+		if err := writeDOT(r.cfg.DotOut, r.analyzed); err != nil {
+			return err
+		}
+	}
+	if r.cfg.HistoryFile != "" {
+		if err := appendHistory(r.ctx, r.cfg.HistoryFile, newHistoryRecord(r.summary(), r.findings)); err != nil {
+			return fmt.Errorf("failed to append to history: %w", err)
+		}
+	}
+
+	if r.cfg.OwnersDir != "" {
+		if err := r.writeOwnerReports(info); err != nil {
+			return fmt.Errorf("failed to write owner reports: %w", err)
+		}
+	}
+	if r.cfg.ModulesDir != "" {
+		if err := r.writeModuleReports(info); err != nil {
+			return fmt.Errorf("failed to write module reports: %w", err)
+		}
+	}
+	if r.cfg.OutputDir != "" {
+		if err := r.writePackageReports(info); err != nil {
+			return fmt.Errorf("failed to write package reports: %w", err)
+		}
+	}
+
+	report := Report{
+		Summary:  r.summary(),
+		Packages: packageUsages(r.findings, r.exportedByPackage),
+		Findings: r.outputFindings(r.findings),
+	}
+	if r.cfg.AnnotateAll {
+		report.Symbols = r.annotatedSymbols()
+	}
+	if r.cfg.Top > 0 {
+		report.TopPackages = rankOffenders(r.findings, r.cfg.Top, packageOf)
+		if r.cfg.Blame {
+			report.TopAuthors = rankOffenders(r.findings, r.cfg.Top, authorOf)
+		}
+		if r.codeOwners != nil {
+			report.TopOwners = rankOffenders(r.findings, r.cfg.Top, ownerOf)
+		}
+	}
+	if info != nil {
+		info.finish()
+	}
+	return rep.Finish(report)
+}
+
+// writeOwnerReports writes the findings grouped by CODEOWNERS owner group to one file per group in OwnersDir.
+func (r *runner) writeOwnerReports(info *RunInfo) error {
+	if err := os.MkdirAll(r.cfg.OwnersDir, 0o755); err != nil {
+		return err
+	}
+
+	byOwner := make(map[string][]Finding)
+	for _, f := range r.findings {
+		owner := ownerOf(f)
+		byOwner[owner] = append(byOwner[owner], f)
+	}
+
+	for owner, findings := range byOwner {
+		filename := filepath.Join(r.cfg.OwnersDir, ownerFilename(owner)+r.reportExt())
+		if err := r.writeReportFile(filename, info, summarize(findings, r.exportedByOwner[owner]), findings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportExt returns the filename extension of the report files written in Format.
+func (r *runner) reportExt() string {
+	switch r.cfg.Format {
+	case formatJSON:
+		return ".json"
+	case formatSARIF:
+		return ".sarif"
+	case formatCheckstyle:
+		return ".xml"
+	default:
+		return ".txt"
+	}
+}
+
+// writeReportFile writes a report of the findings with summary to filename in Format.
+func (r *runner) writeReportFile(filename string, info *RunInfo, summary Summary, findings []Finding) error {
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	rep, err := newReporter(out, r.cfg.Format, textOptions{verbose: r.cfg.Verbose, summary: true})
+	if err == nil {
+		if err = rep.Start(info); err == nil {
+			err = rep.Finish(Report{Summary: r.outputSummary(summary), Findings: r.outputFindings(findings)})
+		}
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// reportFinding decorates and reports f, unless its declaration is outside of ChangedLines
+// or has been modified within MinAge.
+func (r *runner) reportFinding(f Finding, base string) error {
+	if r.ignored[findingID(f.Code, path.Join(r.prefix, f.Filename), f.Name)] {
+		return nil
+	}
+	if r.cfg.ChangedLines != nil {
+		changed, err := r.changed(f, base)
+		if err != nil || !changed {
+			return err
+		}
+	}
+	if r.cfg.MinAge > 0 {
+		recent, err := r.modifiedWithin(r.files, f, base, r.cfg.MinAge)
+		if err != nil || recent {
+			return err
+		}
+	}
+	if err := r.decorate(&f, base); err != nil {
+		return err
+	}
+	if f.Confidence.rank() < r.cfg.MinConfidence.rank() {
+		return nil
+	}
+	if err := r.report(f); err != nil {
+		return err
+	}
+	if r.cfg.FailFast {
+		return errFailFast
+	}
+	return nil
+}
 
-	// This needs to be run from the rooot of a Go Module to get correct results.
-	if _, err := os.Stat(filepath.Join(cfg.WorkspaceDir, "go.mod")); err != nil {
-		return fmt.Errorf("workspace %s is not a Go module (go.mod is missing): %w", cfg.WorkspaceDir, err)
+// errFailFast stops the walk at the first finding.
+var errFailFast = errors.New("stopped at the first finding")
+
+// modifiedWithin reports whether any line of the declaration of f, read from files, has been modified
+// within d according to git blame. Lines not yet committed count as modified. It fails if git blame does,
+// e.g. outside of a git repository, rather than counting every line as modified.
+func (r *runner) modifiedWithin(files *fileCache, f Finding, base string, d time.Duration) (bool, error) {
+	src, err := files.source(f.Filename)
+	if err != nil {
+		return false, err
+	}
+	start, end := src.declRange(f.Line, base)
+	if start == 0 {
+		start, end = f.Line, f.Line
+	}
+	blame, err := files.blame(f.Filename)
+	if err != nil {
+		return false, err
+	}
+	for line := start; line <= end; line++ {
+		b := blame[line]
+		if b == nil || b.Age() < d {
+			return true, nil
+		}
 	}
+	return false, nil
+}
 
-	r, err := newRunner(ctx, cfg)
+// decorate adds the information not provided by gopls to f.
+func (r *runner) decorate(f *Finding, base string) error {
+	// The doc comments and declaration sizes are only needed when we print them.
+	if r.needsSource() {
+		src, err := r.files.source(f.Filename)
+		if err != nil {
+			return err
+		}
+		f.Doc = src.docSummary(f.Line, base)
+		f.Lines = src.declLines(f.Line, base)
+		n := r.cfg.SnippetLines
+		if n == 0 && r.cfg.Format == formatHTML {
+			n = htmlSnippetLines
+		}
+		if n > 0 {
+			f.Snippet = src.snippet(f.Line, base, n)
+		}
+	}
+	if f.Code == codeSamePkg || f.Code == codeTestOnly && r.needsSource() {
+		rename, err := r.renameSuggestion(*f, base)
+		if err != nil {
+			return err
+		}
+		f.Rename = rename
+	}
+	confidence, err := r.confidence(*f)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		err = r.Stop()
-	}()
+	f.Confidence = confidence
+	f.Owners = r.codeOwners.owners(f.Filename)
+	if r.cfg.Blame {
+		blame, err := r.files.blame(f.Filename)
+		if err != nil {
+			// Typically a file not yet committed.
+			r.cfg.Logger.Warn("git blame failed", "filename", f.Filename, "error", err)
+		} else {
+			f.Blame = blame[f.Line]
+		}
+	}
+	if r.issueLink != nil {
+		link, err := r.renderLink(r.issueLink, *f)
+		if err != nil {
+			return err
+		}
+		f.IssueURL = link
+	}
+	if r.sourceLink != nil {
+		link, err := r.renderLink(r.sourceLink, *f)
+		if err != nil {
+			return err
+		}
+		f.SourceURL = link
+	}
+	return nil
+}
 
-	err = r.Walk()
+// renderLink renders the link template tmpl for f, with its code as printed.
+func (r *runner) renderLink(tmpl *template.Template, f Finding) (string, error) {
+	f.Code = prefixedCode(r.cfg.CodePrefix, f.Code)
+	return issueLink(tmpl, f)
+}
 
-	return
+func (r *runner) summary() Summary {
+	s := r.outputSummary(summarize(r.findings, r.exported))
+	s.Skipped = r.skipped
+	s.LoadErrors = sortLoadErrors(r.loadErrors)
+	if r.cfg.Sample > 0 {
+		s.Sample = r.cfg.Sample
+		s.EstimatedFindings = int(math.Round(float64(s.Findings) / r.cfg.Sample))
+	}
+	return s
 }
 
-func newRunner(ctx context.Context, cfg RunConfig) (*runner, error) {
-	matcher, err := glob.Compile(cfg.FilenamePattern)
-	if err != nil {
-		return nil, fmt.Errorf("invalid glob pattern: %w", err)
+// skip records that filename was not analyzed.
+func (r *runner) skip(filename, reason string) {
+	r.cfg.Logger.Warn("skipping file", "filename", filename, "reason", reason)
+	r.skipped = append(r.skipped, SkippedFile{Filename: path.Join(r.prefix, filename), Reason: reason})
+}
+
+// walk records filename, relative to the workspace, as analyzed.
+func (r *runner) walk(filename string) {
+	r.walked = append(r.walked, path.Join(r.prefix, filename))
+}
+
+func countSymbols(symbols []*Symbol) int {
+	n := len(symbols)
+	for _, s := range symbols {
+		n += countSymbols(s.Children)
+	}
+	return n
+}
+
+func (r *runner) needsSource() bool {
+	return r.cfg.Verbose || r.cfg.Format == formatJSON || r.cfg.Format == formatHTML || r.cfg.Top > 0 || r.cfg.HistoryFile != "" || r.cfg.SummaryOut != "" || r.cfg.SnippetLines > 0 || r.cfg.Rename
+}
+
+func (r *runner) report(f Finding) error {
+	f.Severity, f.Hint = SeverityWarning, ""
+	if c, found := checkByCode(f.Code); found {
+		if c.DefaultSeverity != "" {
+			f.Severity = c.DefaultSeverity
+		}
+		f.Hint = c.Hint
+	}
+	if sev, found := r.cfg.Severity[f.Code]; found {
+		f.Severity = sev
+	}
+	if hint, found := r.cfg.Hints[f.Code]; found {
+		f.Hint = hint
+	}
+	f.Filename = path.Join(r.prefix, f.Filename)
+	f.ID = findingID(f.Code, f.Filename, f.Name)
+	f.Refs = joinPaths(r.prefix, f.Refs)
+	f.Duplicates = joinPaths(r.prefix, f.Duplicates)
+	if r.build != nil {
+		f.BuildConfigs = []string{r.build.Name}
+	}
+	r.findings = append(r.findings, f)
+	return r.emit(f)
+}
+
+// emit passes the finding f, as printed, to OnFinding, if set, and writes it to the reporter, if any.
+func (r *runner) emit(f Finding) error {
+	f = r.outputFinding(f)
+	if r.cfg.OnFinding != nil {
+		r.cfg.OnFinding(f)
+	}
+	if r.reporter == nil {
+		// Not written, see walkSymbols.
+		return nil
+	}
+	return r.reporter.Report(f)
+}
+
+// joinPaths returns the filenames, or locations, joined with prefix.
+func joinPaths(prefix string, filenames []string) []string {
+	if prefix == "" || filenames == nil {
+		return filenames
+	}
+	joined := make([]string, len(filenames))
+	for i, filename := range filenames {
+		if path.IsAbs(filename) {
+			joined[i] = filename
+			continue
+		}
+		joined[i] = path.Join(prefix, filename)
 	}
+	return joined
+}
 
-	client, err := newClient(ctx, cfg.WorkspaceDir)
+// workspacePath returns the filename of uri relative to the workspace, if inside it.
+func (r *runner) workspacePath(uri lsp.DocumentURI) (string, bool) {
+	filename, err := uriFilename(uri)
 	if err != nil {
-		return nil, err
+		return "", false
 	}
+	filename = filepath.ToSlash(filename)
+	rel := strings.TrimPrefix(filename, strings.TrimSuffix(filepath.ToSlash(r.cfg.WorkspaceDir), "/")+"/")
+	return rel, rel != filename
+}
 
-	return &runner{ctx: ctx, client: client, cfg: cfg, filematcher: matcher}, nil
+// refLocations returns the references as sorted file:line:column locations relative to the workspace.
+func (r *runner) refLocations(refs []*lsp.Location) []string {
+	sorted := make([]*lsp.Location, len(refs))
+	copy(sorted, refs)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.URI != b.URI {
+			return a.URI < b.URI
+		}
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return a.Range.Start.Line < b.Range.Start.Line
+		}
+		return a.Range.Start.Character < b.Range.Start.Character
+	})
+	var locs []string
+	for _, ref := range sorted {
+		filename, ok := r.workspacePath(ref.URI)
+		if !ok {
+			filename = string(ref.URI)
+			if name, err := uriFilename(ref.URI); err == nil {
+				filename = filepath.ToSlash(name)
+			}
+		}
+		locs = append(locs, fmt.Sprintf("%s:%d:%d", filename, ref.Range.Start.Line+1, ref.Range.Start.Character+1))
+	}
+	return locs
 }
 
-type RunConfig struct {
-	WorkspaceDir    string
-	FilenamePattern string
-	Out             io.Writer
+// outputPath returns filename, relative to the workspace, as printed in the output, see AbsPaths and PathPrefix.
+func (r *runner) outputPath(filename string) string {
+	switch {
+	case r.cfg.AbsPaths:
+		return filepath.Join(r.root, filepath.FromSlash(filename))
+	case r.cfg.PathPrefix != "":
+		return strings.TrimSuffix(r.cfg.PathPrefix, "/") + "/" + filename
+	default:
+		return filename
+	}
 }
 
-func (cfg RunConfig) validate() error {
-	if cfg.WorkspaceDir == "" {
-		return fmt.Errorf("WorkspaceDir is required")
+// outputFindings returns findings with the filenames and codes as printed in the output.
+func (r *runner) outputFindings(findings []Finding) []Finding {
+	if !r.cfg.AbsPaths && r.cfg.PathPrefix == "" && r.cfg.CodePrefix == "" {
+		return findings
 	}
-	if cfg.FilenamePattern == "" {
-		return fmt.Errorf("FilenamePattern is required")
+	out := make([]Finding, len(findings))
+	for i, f := range findings {
+		out[i] = r.outputFinding(f)
 	}
-	if cfg.Out == nil {
-		return fmt.Errorf("Out is required")
+	return out
+}
+
+// outputSummary returns s with the codes as printed in the output.
+func (r *runner) outputSummary(s Summary) Summary {
+	if r.cfg.CodePrefix == "" {
+		return s
 	}
-	return nil
+	byCode := make(map[string]int, len(s.ByCode))
+	for code, n := range s.ByCode {
+		byCode[prefixedCode(r.cfg.CodePrefix, code)] = n
+	}
+	s.ByCode = byCode
+	return s
 }
 
-type runner struct {
-	ctx         context.Context
-	cfg         RunConfig
-	filematcher glob.Glob
-	client      *GoplsClient
+// outputFinding returns f with the filenames and code as printed in the output.
+func (r *runner) outputFinding(f Finding) Finding {
+	f.Code = prefixedCode(r.cfg.CodePrefix, f.Code)
+	f.Filename = r.outputPath(f.Filename)
+	if f.Refs != nil {
+		refs := make([]string, len(f.Refs))
+		for i, ref := range f.Refs {
+			refs[i] = r.outputPath(ref)
+		}
+		f.Refs = refs
+	}
+	if f.Duplicates != nil {
+		dups := make([]string, len(f.Duplicates))
+		for i, dup := range f.Duplicates {
+			dups[i] = r.outputPath(dup)
+		}
+		f.Duplicates = dups
+	}
+	return f
 }
 
-func (r *runner) Stop() error {
-	return r.client.Close()
+// Walk analyzes the files in the workspace, see RunConfig.Concurrency.
+func (r *runner) Walk() (err error) {
+	if r.state != nil {
+		// Saved when interrupted too.
+		defer func() {
+			if serr := r.saveState(); err == nil {
+				err = serr
+			}
+		}()
+	}
+	if r.cfg.Concurrency <= 1 && r.cfg.Progress == nil {
+		return r.walkFiles(r.analyzeFile)
+	}
+	var filenames []string
+	if err := r.walkFiles(func(filename string) error {
+		filenames = append(filenames, filename)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if r.cfg.Progress != nil {
+		r.progress = newProgress(r.cfg.Progress, r.cfg.Logger, r.prefix, len(filenames))
+		defer r.progress.done()
+	}
+	if r.cfg.Concurrency <= 1 {
+		for _, filename := range filenames {
+			if err := r.analyzeFile(filename); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.handleFiles(filenames)
 }
 
-func (r *runner) Walk() error {
-	return filepath.Walk(r.cfg.WorkspaceDir, func(path string, info fs.FileInfo, err error) error {
+// walkFiles calls handle with the files to analyze in the workspace, relative to it, in lexical order.
+func (r *runner) walkFiles(handle func(filename string) error) error {
+	return walkTree(r.cfg.WorkspaceDir, r.cfg.FollowSymlinks, func(path string, info fs.FileInfo, err error) error {
 		if info == nil {
 			return nil
 		}
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
 		if info.IsDir() {
 			if strings.HasPrefix(info.Name(), ".") {
 				return filepath.SkipDir
 			}
+			if info.Name() == "testdata" && !r.cfg.IncludeTestdata {
+				// Ignored by the go tool.
+				return filepath.SkipDir
+			}
+			rel := strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(path, r.cfg.WorkspaceDir)), "/")
+			// With the trailing slash, third_party/** also matches third_party.
+			if rel != "" && (r.excludeDirs.Match(rel) || r.excludeDirs.Match(rel+"/")) {
+				return filepath.SkipDir
+			}
+			if info.Name() == vendorDir && (rel != vendorDir || len(r.vendored) == 0) {
+				// Ignored by the go tool, see IncludeVendored.
+				return filepath.SkipDir
+			}
+			if rel != "" && !r.cfg.IncludeSubmodules && !r.workModules[rel] && isModuleRoot(path) {
+				// A nested module, not loaded by gopls.
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -102,71 +1844,338 @@ func (r *runner) Walk() error {
 			return nil
 		}
 
-		return r.handleFile(base)
+		if r.only != nil && !r.only[base] {
+			return nil
+		}
+
+		if len(r.cfg.Owners) > 0 && !ownedBy(r.codeOwners.owners(base), r.cfg.Owners) {
+			return nil
+		}
+
+		if strings.HasPrefix(base, vendorDir+"/") && !r.vendored[filepath.ToSlash(filepath.Dir(base))] {
+			return nil
+		}
+
+		if !r.cfg.IncludeTestFiles && strings.HasSuffix(base, "_test.go") {
+			return nil
+		}
+
+		if r.entryPoints.Match(filepath.ToSlash(filepath.Dir(base))) {
+			// The exported symbols in entry point packages are roots and never reported.
+			return nil
+		}
+
+		if r.sampler != nil && r.sampler.Float64() >= r.cfg.Sample {
+			return nil
+		}
+
+		if r.cfg.MaxFileSize > 0 && info.Size() > r.cfg.MaxFileSize {
+			r.skip(base, fmt.Sprintf("file size %d bytes exceeds the maximum of %d", info.Size(), r.cfg.MaxFileSize))
+			return nil
+		}
+
+		if len(r.skipHeaders) > 0 {
+			content, err := r.overlay.readFile(path)
+			if err != nil {
+				return err
+			}
+			if expr := r.skipHeaders.match(content); expr != "" {
+				r.skip(base, fmt.Sprintf("header matches %q", expr))
+				return nil
+			}
+		}
+
+		if !r.cfg.IncludeGenerated {
+			generated, err := r.isGenerated(lsp.DocumentURI(r.client.documentURI(base)))
+			if err != nil {
+				return err
+			}
+			if generated {
+				r.cfg.Logger.Debug("skipping generated file", "filename", base)
+				return nil
+			}
+		}
+
+		if r.state.resumed(base) {
+			return nil
+		}
+
+		return handle(base)
 	})
 }
 
-func (r *runner) handleFile(filename string) error {
-	if strings.HasSuffix(filename, "_test.go") {
+func (r *runner) analyzeFile(filename string) error {
+	r.cfg.Logger.Debug("analyzing file", "filename", filename)
+	if r.progress != nil {
+		r.progress.file(filename, r.exported, len(r.findings))
+	}
+	r.walk(filename)
+	skipped, exported := len(r.skipped), r.exported
+	if err := r.handleFile(filename); err != nil {
+		return err
+	}
+	if len(r.skipped) > skipped {
+		// Analyzed again when resuming, to record why it's skipped.
 		return nil
 	}
+	return r.completed(filename, r.exported-exported)
+}
 
-	symbols, err := r.client.DocumentSymbol(r.ctx, filename)
+func (r *runner) handleFile(filename string) error {
+	isTestFile := strings.HasSuffix(filename, "_test.go")
+
+	symbols, err := r.documentSymbols(filename)
 	if err != nil {
-		fmt.Errorf("failed to get symbols: %w", err)
+		return fmt.Errorf("failed to get symbols: %w", err)
 	}
 
-	var handleSymbol func(s *Symbol) error
-	handleSymbol = func(s *Symbol) error {
-		base := s.Name
-		if s.Kind == lsp.SKMethod && strings.Contains(base, ".") {
-			// Struct methods' Name comes on the form  (MyType).MyMethod.
-			base = s.Name[strings.Index(s.Name, ".")+1:]
+	if max := r.cfg.MaxSymbolsPerFile; max > 0 {
+		if n := countSymbols(symbols); n > max {
+			r.skip(filename, fmt.Sprintf("%d symbols exceeds the maximum of %d", n, max))
+			return nil
 		}
+	}
+	if p := r.prefetched; r.cfg.Pipeline > 1 && (p == nil || p.filename != filename) {
+		r.prefetched = &prefetched{filename: filename, symbols: symbols, refs: r.fetchReferences(r.ctx, filename, symbols)}
+		defer func() { r.prefetched = nil }()
+	}
 
-		if !isExported(base) {
+	var handleSymbol, handleChildren func(s *Symbol) error
+	owner := ownerGroup(r.codeOwners.owners(filename))
+	pkg := path.Join(r.prefix, path.Dir(filename))
+	enumUsed := make(map[int]bool)
+
+	handleSymbol = func(s *Symbol) error {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		base := symbolBaseName(s)
+		if !r.analyzes(s, base) {
 			return nil
 		}
-
-		refs, err := r.client.DocumentReferences(r.ctx, s.Location)
+		var inMain bool
+		if policy := r.cfg.MainPackages; policy != "" && policy != mainPackagesCheck && isExported(base) {
+			main, err := r.isMainPackage(filename)
+			if err != nil {
+				return err
+			}
+			inMain = main
+			if main && (policy == mainPackagesSkip || s.Kind == lsp.SKMethod || s.Kind == lsp.SKField) {
+				return nil
+			}
+		}
+		if isTestFile && s.Kind == lsp.SKFunction && isTestFunc(base) {
+			// Invoked by go test.
+			return nil
+		}
+		if r.symbols.kept(s.Name, filename) {
+			return nil
+		}
+		if !r.symbols.checks(strings.ToLower(s.Kind.String())) {
+			// Its fields may still be.
+			if skip, err := r.skipsFields(filename, s, base, nil); err != nil || skip {
+				return err
+			}
+			return handleChildren(s)
+		}
+		if ignored, err := r.isIgnoredReceiver(filename, s); err != nil || ignored {
+			return err
+		}
+		if s.Kind == lsp.SKField {
+			embedded, err := r.isEmbeddedField(filename, s, base)
+			if err != nil {
+				return err
+			}
+			if embedded {
+				// It forwards the embedded type's fields and methods, used without referencing it.
+				if r.cfg.Transitive {
+					r.analyzed = append(r.analyzed, &analyzedSymbol{workspace: r.prefix, filename: filename, base: base, symbol: s, embedded: true})
+				}
+				return nil
+			}
+		}
+		refs, err := r.references(s.Location)
 		if err != nil {
+			if isNotInBuild(err) {
+				return errNotInBuild
+			}
 			return fmt.Errorf("failed to get references: %w", err)
 		}
+		if r.referencing != nil {
+			r.addReferencing(filename, refs)
+		}
 
-		var unused bool
-		var testOnly bool
-		if len(refs) == 0 {
-			unused = true
-		} else {
-			testOnly = true
-			for _, ref := range refs {
-				if !strings.HasSuffix(string(ref.URI), "_test.go") {
-					testOnly = false
-					break
-				}
+		r.exported++
+		r.exportedByOwner[owner]++
+		r.exportedByPackage[pkg]++
+
+		refs, deadTargets := r.withoutDeadTargets(s.Location.URI, refs)
+		code, refs, err := r.classify(isTestFile, s.Location.URI, refs)
+		if err != nil {
+			return err
+		}
+		if code == "" && r.cfg.CaseOnly && s.Kind == lsp.SKConstant && isExported(base) && len(refs) > 0 {
+			caseOnly, err := r.onlyInCases(base, refs)
+			if err != nil {
+				return err
+			}
+			if caseOnly {
+				code = codeCaseOnly
+			}
+		}
+		var stdlib string
+		if code == "" && r.cfg.StdlibClones > 0 && s.Kind == lsp.SKFunction && isExported(base) && len(refs) <= r.cfg.StdlibClones {
+			if stdlib, err = r.stdlibClone(filename, base); err != nil {
+				return err
+			}
+			if stdlib != "" {
+				code = codeStdlib
+			}
+		}
+		if code == "" && r.cfg.MinRefs > 1 && len(refs) > 0 && len(refs) < r.cfg.MinRefs {
+			code = codeRarelyUsed
+		}
+		if code != codeUnused && (!isExported(base) || inMain) {
+			// E.g. used in test only or in its declaring file, as unexported symbols are.
+			code = ""
+		}
+		if code == codeTestOnly {
+			testSupport, err := r.isTestSupport(filename)
+			if err != nil {
+				return err
+			}
+			if testSupport {
+				r.cfg.Logger.Debug("skipping a test helper used in test only", "filename", filename, "symbol", s.Name)
+				code = ""
+			}
+		}
+
+		if code != "" {
+			framework, err := r.isFrameworkAPI(filename, s, base)
+			if err != nil {
+				return err
+			}
+			if framework {
+				code = ""
+			} else if implements, err := r.implementsInterface(s, base); err != nil {
+				return err
+			} else if implements {
+				r.cfg.Logger.Debug("skipping a method implementing an interface", "filename", filename, "symbol", s.Name)
+				code = ""
+			} else if used, err := r.enumUsed(filename, s, symbols, enumUsed); err != nil {
+				return err
+			} else if used {
+				r.cfg.Logger.Debug("skipping a constant of an enum in use", "filename", filename, "symbol", s.Name)
+				code = ""
+			} else if r.templateNames[base] {
+				r.cfg.Logger.Debug("skipping a symbol named in an embedded template", "filename", filename, "symbol", s.Name)
+				code = ""
+			} else if r.downstream.used(r.importPath(path.Dir(filename)), base) {
+				r.cfg.Logger.Debug("skipping a symbol used downstream", "filename", filename, "symbol", s.Name)
+				code = ""
+			}
+		}
+
+		if code == codeUnused {
+			dynamic, err := r.isDynamicallyUsed(filename, s, base)
+			if err != nil {
+				return err
+			}
+			if dynamic {
+				code = codeDynamic
+			} else if len(deadTargets) > 0 {
+				// Only used by dead build targets.
+				code = codeTransitive
+			}
+		}
+
+		if code == codeUnused && r.cfg.TaggedFields && s.Kind == lsp.SKField {
+			tagged, err := r.hasSerializationTag(filename, s.Location.Range.Start.Line+1, base)
+			if err != nil {
+				return err
+			}
+			if tagged {
+				code = codeTagged
+			}
+		}
+
+		var suppressed bool
+		if code != "" || r.cfg.Transitive {
+			// With Transitive, the symbols in use may still be reported as only used by unused code.
+			check := code
+			if check == "" {
+				check = codeTransitive
+			}
+			if suppressed, err = r.isSuppressed(filename, s.Location.Range.Start.Line+1, base, check); err != nil {
+				return err
+			}
+			suppressed = suppressed || r.ignored[findingID(check, path.Join(r.prefix, filename), s.Name)]
+			if suppressed {
+				code = ""
 			}
 		}
 
-		if unused || testOnly {
-			e := usage{
-				Filename:   filename,
-				Symbol:     s,
-				IsTestOnly: testOnly,
+		if r.observe != nil {
+			r.observe(filename, s, code, len(refs))
+		}
+		if r.cfg.AnnotateAll {
+			r.annotate(filename, s, code, len(refs))
+		}
+
+		if r.cfg.Transitive || r.cfg.Reexports {
+			dead := code == codeUnused || code == codeGenerated || code == codeTransitive
+			a := &analyzedSymbol{workspace: r.prefix, filename: filename, base: base, symbol: s, refs: refs, dead: dead, deadTargets: deadTargets}
+			if suppressed {
+				// Without references it's never marked dead, keeping the symbols it uses alive.
+				a.refs = nil
+			}
+			r.analyzed = append(r.analyzed, a)
+		}
+
+		if code != "" {
+			f := newFinding(filename, s, code)
+			if code == codeTransitive {
+				f.UsedBy = deadTargets
+			}
+			if code == codeStdlib {
+				f.Stdlib = stdlib
+			}
+			if code == codeRarelyUsed {
+				f.References = len(refs)
 			}
-			e.Print(r.cfg.Out)
+			if r.cfg.ShowRefs {
+				f.Refs = r.refLocations(refs)
+			}
+			if err := r.reportFinding(f, base); err != nil {
+				return err
+			}
+		}
+
+		if skip, err := r.skipsFields(filename, s, base, refs); err != nil || skip {
+			return err
 		}
+		return handleChildren(s)
+	}
 
+	handleChildren = func(s *Symbol) error {
 		for _, child := range s.Children {
 			if err := handleSymbol(child); err != nil {
 				return err
 			}
 		}
-
 		return nil
 	}
 
 	for _, s := range symbols {
 		if err := handleSymbol(s); err != nil {
+			if err == errNotInBuild {
+				reason := "excluded by build constraints"
+				if r.build != nil {
+					reason += " (" + r.build.Name + ")"
+				}
+				r.skip(filename, reason)
+				return nil
+			}
 			return err
 		}
 	}
@@ -174,22 +2183,34 @@ func (r *runner) handleFile(filename string) error {
 	return nil
 }
 
-type usage struct {
-	Filename   string
-	Symbol     *Symbol
-	IsTestOnly bool
+// isTestFunc reports whether name is a test, benchmark, example or fuzz function name
+// as recognized by go test.
+func isTestFunc(name string) bool {
+	if name == "TestMain" {
+		return true
+	}
+	for _, prefix := range []string{"Test", "Benchmark", "Example", "Fuzz"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		if rest == "" || prefix == "Example" && rest[0] == '_' {
+			return true
+		}
+		if r, _ := utf8.DecodeRuneInString(rest); !unicode.IsLower(r) {
+			return true
+		}
+	}
+	return false
 }
 
-func (u usage) Print(w io.Writer) {
-	s := u.Symbol
-	loc := s.Location
-	kind := strings.ToLower(string(s.Kind.String()))
-	line, col := loc.Range.Start.Line+1, loc.Range.Start.Character+1
-	if u.IsTestOnly {
-		fmt.Fprintf(w, "%s:%d:%d %s %s is used in test only (EU1001)\n", u.Filename, line, col, kind, s.Name)
-	} else {
-		fmt.Fprintf(w, "%s:%d:%d %s %s is unused (EU1002)\n", u.Filename, line, col, kind, s.Name)
+// symbolBaseName returns the name of s without any receiver.
+func symbolBaseName(s *Symbol) string {
+	if s.Kind == lsp.SKMethod && strings.Contains(s.Name, ".") {
+		// Struct methods' Name comes on the form  (MyType).MyMethod.
+		return s.Name[strings.Index(s.Name, ".")+1:]
 	}
+	return s.Name
 }
 
 func isExported(s string) bool {
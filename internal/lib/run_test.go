@@ -2,6 +2,10 @@ package lib
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,46 +13,238 @@ import (
 
 	qt "github.com/frankban/quicktest"
 	"github.com/google/go-cmp/cmp"
+	lsp "github.com/sourcegraph/go-lsp"
 	"golang.org/x/net/context"
 )
 
+// runGolden holds the findings of Run in the testpackages.
+const runGolden = `
+internal/lib/testpackages/firstpackage/code1.go:7:2 variable UnusedVar is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:12:2 constant UnusedConst is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:19:6 function UnusedFunction is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:25:2 field UnusedField is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:32:15 method (MyType).UnusedMethod is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:36:6 interface UnusedInterfaceWithUsedAndUnusedMethod is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:37:2 method UsedInterfaceMethodReturningInt is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:38:2 method UnusedInterfaceMethodReturningInt is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:41:6 interface UnusedInterface is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:42:2 method UnusedInterfaceReturningInt is unused (EU1002)
+internal/lib/testpackages/firstpackage/code1.go:45:6 interface UsedInterface is unused (EU1002)
+internal/lib/testpackages/firstpackage/testlib1.go:4:2 constant OnlyUsedInTestConst is used in test only (EU1001)
+`
+
 func TestRun(t *testing.T) {
 	c := qt.New(t)
 
-	var buff bytes.Buffer
-
 	// The WorkDir needs to be a the module (workspace) root.
 	wd, _ := os.Getwd()
 	wd = filepath.Join(wd, "..", "..")
 
+	// The same findings, in the same order, with the reference requests pipelined.
+	for _, pipeline := range []int{0, 4} {
+		var buff bytes.Buffer
+		c.Assert(
+			Run(
+				context.Background(),
+				RunConfig{
+					WorkspaceDir:     wd,
+					FilenamePatterns: []string{"**/testpackages/**.go"},
+					Out:              &buff,
+					Pipeline:         pipeline,
+				},
+			),
+			qt.IsNil,
+		)
+
+		if diff := cmp.Diff(strings.TrimSpace(buff.String()), strings.TrimSpace(runGolden)); diff != "" {
+			c.Fatal("unexpected output\n", diff+"\n\n"+buff.String())
+		}
+	}
+}
+
+func TestRunPackagesBackend(t *testing.T) {
+	c := qt.New(t)
+
+	wd, _ := os.Getwd()
+	wd = filepath.Join(wd, "..", "..")
+
+	var buff bytes.Buffer
 	c.Assert(
 		Run(
 			context.Background(),
 			RunConfig{
-				WorkspaceDir:    wd,
-				FilenamePattern: "**/testpackages/**.go",
-				Out:             &buff,
+				WorkspaceDir:     wd,
+				FilenamePatterns: []string{"**/testpackages/**.go"},
+				Out:              &buff,
+				Backend:          BackendPackages,
 			},
 		),
 		qt.IsNil,
 	)
 
-	golden := `
-internal/lib/testpackages/firstpackage/code1.go:7:2 variable UnusedVar is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:12:2 constant UnusedConst is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:19:6 function UnusedFunction is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:25:2 field UnusedField is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:32:15 method (MyType).UnusedMethod is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:36:6 interface UnusedInterfaceWithUsedAndUnusedMethod is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:38:2 method UnusedInterfaceMethodReturningInt is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:37:2 method UsedInterfaceMethodReturningInt is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:41:6 interface UnusedInterface is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:42:2 method UnusedInterfaceReturningInt is unused (EU1002)
-internal/lib/testpackages/firstpackage/code1.go:45:6 interface UsedInterface is unused (EU1002)
-internal/lib/testpackages/firstpackage/testlib1.go:4:2 constant OnlyUsedInTestConst is used in test only (EU1001)
-`
-
-	if diff := cmp.Diff(strings.TrimSpace(buff.String()), strings.TrimSpace(golden)); diff != "" {
+	if diff := cmp.Diff(strings.TrimSpace(buff.String()), strings.TrimSpace(runGolden)); diff != "" {
 		c.Fatal("unexpected output\n", diff+"\n\n"+buff.String())
 	}
 }
+
+func TestRunPackagesBackendSpaceInPath(t *testing.T) {
+	c := qt.New(t)
+
+	dir := filepath.Join(t.TempDir(), "my module")
+	c.Assert(os.MkdirAll(filepath.Join(dir, "p"), 0o755), qt.IsNil)
+	for filename, content := range map[string]string{
+		"go.mod":      "module example.com/m\n\ngo 1.23\n",
+		"p/p.go":      "package p\n\nfunc Unused() {}\n\nfunc Helper() {}\n",
+		"p/p_test.go": "package p\n\nimport \"testing\"\n\nfunc TestHelper(t *testing.T) { Helper() }\n",
+	} {
+		c.Assert(os.WriteFile(filepath.Join(dir, filename), []byte(content), 0o644), qt.IsNil)
+	}
+
+	var buff bytes.Buffer
+	c.Assert(Run(context.Background(), RunConfig{WorkspaceDir: dir, FilenamePatterns: []string{"**.go"}, Out: &buff, Backend: BackendPackages}), qt.IsNil)
+	c.Assert(buff.String(), qt.Equals, "p/p.go:3:6 function Unused is unused (EU1002)\np/p.go:5:6 function Helper is used in test only (EU1001)\n")
+}
+
+func TestRunPackagesBackendTestVariants(t *testing.T) {
+	c := qt.New(t)
+
+	// The package is loaded with and without its tests, and the method, with a type of the package in its
+	// signature, is only called through the interface.
+	dir := t.TempDir()
+	c.Assert(os.MkdirAll(filepath.Join(dir, "p"), 0o755), qt.IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(dir, "cmd"), 0o755), qt.IsNil)
+	for filename, content := range map[string]string{
+		"go.mod":      "module example.com/m\n\ngo 1.23\n",
+		"p/p.go":      "package p\n\ntype Info struct{}\n\ntype Doer interface{ Do(*Info) }\n\ntype Impl struct{}\n\nfunc (*Impl) Do(*Info) {}\n\nfunc Run(d Doer) { d.Do(&Info{}) }\n",
+		"p/p_test.go": "package p\n\nimport \"testing\"\n\nfunc TestRun(t *testing.T) { Run(&Impl{}) }\n",
+		"cmd/main.go": "package main\n\nimport \"example.com/m/p\"\n\nfunc main() { p.Run(&p.Impl{}) }\n",
+	} {
+		c.Assert(os.WriteFile(filepath.Join(dir, filename), []byte(content), 0o644), qt.IsNil)
+	}
+
+	var buff bytes.Buffer
+	c.Assert(Run(context.Background(), RunConfig{WorkspaceDir: dir, FilenamePatterns: []string{"**.go"}, Out: &buff, Backend: BackendPackages}), qt.IsNil)
+	c.Assert(buff.String(), qt.Equals, "")
+}
+
+func TestIsTestFunc(t *testing.T) {
+	c := qt.New(t)
+
+	for _, name := range []string{"TestMain", "Test", "TestFoo", "Test_foo", "BenchmarkFoo", "Example", "ExampleFoo", "Example_foo", "FuzzFoo"} {
+		c.Assert(isTestFunc(name), qt.IsTrue, qt.Commentf(name))
+	}
+	for _, name := range []string{"Testify", "Benchmarks", "Examples", "Fuzzy", "NewTest", "Helper"} {
+		c.Assert(isTestFunc(name), qt.IsFalse, qt.Commentf(name))
+	}
+}
+
+func TestReceiverType(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(receiverType("(MyType).MyMethod"), qt.Equals, "MyType")
+	c.Assert(receiverType("(*MyType).MyMethod"), qt.Equals, "MyType")
+	c.Assert(receiverType("(*MyType[K, V]).MyMethod"), qt.Equals, "MyType")
+	c.Assert(receiverType("MyMethod"), qt.Equals, "")
+}
+
+func TestUnexportedName(t *testing.T) {
+	c := qt.New(t)
+
+	for name, expect := range map[string]string{
+		"Helper":    "helper",
+		"URLParser": "urlParser",
+		"ID":        "id",
+		"X":         "x",
+		"HTTPS":     "https",
+	} {
+		c.Assert(unexportedName(name), qt.Equals, expect, qt.Commentf(name))
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(ExitCode(Result{}, nil), qt.Equals, ExitClean)
+	c.Assert(ExitCode(Result{Findings: 2, Severity: SeverityInfo}, nil), qt.Equals, ExitClean)
+	c.Assert(ExitCode(Result{Findings: 2, Severity: SeverityWarning}, nil), qt.Equals, ExitWarnings)
+	c.Assert(ExitCode(Result{Findings: 2, Severity: SeverityError}, &FailedError{Reasons: []string{"2 findings with severity error"}}), qt.Equals, ExitErrors)
+	c.Assert(ExitCode(Result{}, errors.New("gopls failed")), qt.Equals, ExitFailure)
+}
+
+func TestDiffManifests(t *testing.T) {
+	c := qt.New(t)
+
+	want := manifest{ConfigHash: "c1", Files: []manifestFile{{"a.go", "1"}, {"b.go", "2"}, {"c.go", "3"}}}
+	c.Assert(diffManifests(want, want), qt.HasLen, 0)
+
+	got := manifest{ConfigHash: "c2", Files: []manifestFile{{"a.go", "1"}, {"b.go", "changed"}, {"d.go", "4"}}}
+	c.Assert(diffManifests(want, got), qt.DeepEquals, []string{
+		"config changed (c2, was c1)",
+		"1 file changed: b.go",
+		"1 file added: d.go",
+		"1 file removed: c.go",
+	})
+}
+
+func TestAnalyzes(t *testing.T) {
+	c := qt.New(t)
+
+	r := &runner{}
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKFunction}, "Exported"), qt.IsTrue)
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKFunction}, "unexported"), qt.IsFalse)
+
+	r.cfg.IncludeUnexported = true
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKFunction}, "unexported"), qt.IsTrue)
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKConstant}, "unexported"), qt.IsTrue)
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKMethod}, "unexported"), qt.IsFalse)
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKField}, "unexported"), qt.IsFalse)
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKFunction}, "main"), qt.IsFalse)
+	c.Assert(r.analyzes(&Symbol{Kind: lsp.SKFunction}, "init"), qt.IsFalse)
+}
+
+// cancelHandler cancels the run when the nth file is analyzed.
+type cancelHandler struct {
+	slog.Handler
+	n      *int
+	cancel func()
+}
+
+func (h cancelHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if rec.Message == "analyzing file" {
+		if *h.n--; *h.n == 0 {
+			h.cancel()
+		}
+	}
+	return nil
+}
+
+func TestRunInterrupted(t *testing.T) {
+	c := qt.New(t)
+
+	wd, _ := os.Getwd()
+	wd = filepath.Join(wd, "..", "..")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 2
+	logger := slog.New(cancelHandler{Handler: slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}), n: &n, cancel: cancel})
+
+	var buff bytes.Buffer
+	res, err := Check(ctx, RunConfig{
+		WorkspaceDir:     wd,
+		FilenamePatterns: []string{"**/testpackages/firstpackage/*.go"},
+		Out:              &buff,
+		Format:           formatJSON,
+		Logger:           logger,
+	})
+	c.Assert(err, qt.ErrorMatches, "interrupted, the findings are incomplete: context canceled")
+	c.Assert(ExitCode(res, err), qt.Equals, ExitFailure)
+
+	// The findings of the first file are flushed.
+	var report Report
+	c.Assert(json.Unmarshal(buff.Bytes(), &report), qt.IsNil)
+	c.Assert(report.Findings, qt.Not(qt.HasLen), 0)
+	for _, f := range report.Findings {
+		c.Assert(f.Filename, qt.Equals, "internal/lib/testpackages/firstpackage/code1.go")
+	}
+}
@@ -0,0 +1,192 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunInfo describes how and when a set of findings was produced.
+type RunInfo struct {
+	// Version is the punused version, and Revision the VCS revision it was built from, if known.
+	Version      string `json:"version"`
+	Revision     string `json:"revision,omitempty"`
+	GoplsVersion string `json:"gopls_version,omitempty"`
+	GoVersion    string `json:"go_version"`
+
+	// Backend is the backend the workspace was analyzed with, see RunConfig.Backend.
+	Backend string `json:"backend"`
+
+	// Workspace is the absolute path of the workspace analyzed, and Module its module path.
+	Workspace string `json:"workspace"`
+	Module    string `json:"module"`
+
+	// ConfigHash is a hash of the configuration options used.
+	ConfigHash string `json:"config_hash"`
+
+	// CodePrefix is the prefix of the check codes, if not EU.
+	CodePrefix string `json:"code_prefix,omitempty"`
+
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	// Duration is the wall time of the run in seconds.
+	Duration float64 `json:"duration_seconds"`
+}
+
+func newRunInfo(cfg RunConfig) *RunInfo {
+	var gopls string
+	if cfg.Backend != BackendPackages {
+		gopls = goplsVersion(goplsBinary(cfg.Gopls))
+	}
+	backend := cfg.Backend
+	if backend == "" {
+		backend = BackendGopls
+	}
+	workspace, err := filepath.Abs(cfg.WorkspaceDir)
+	if err != nil {
+		workspace = cfg.WorkspaceDir
+	}
+	bi := ReadBuildInfo()
+	return &RunInfo{
+		Version:      bi.Version,
+		Revision:     bi.Revision,
+		GoplsVersion: gopls,
+		GoVersion:    goVersion(cfg.WorkspaceDir),
+		Backend:      backend,
+		Workspace:    workspace,
+		Module:       modulePath(cfg.WorkspaceDir),
+		ConfigHash:   cfg.hash(),
+		CodePrefix:   cfg.CodePrefix,
+		Started:      time.Now().UTC(),
+	}
+}
+
+// finish records the end of the run.
+func (info *RunInfo) finish() {
+	info.Finished = time.Now().UTC()
+	info.Duration = info.Finished.Sub(info.Started).Seconds()
+}
+
+// BuildInfo describes the punused binary, as embedded by the Go toolchain, see debug.ReadBuildInfo.
+type BuildInfo struct {
+	// Version is the version of the punused module, "(devel)" when built from source.
+	Version string `json:"version"`
+
+	// Revision and Time are the VCS revision built from and its commit time, if known,
+	// and Modified is set if the working tree had local changes.
+	Revision string `json:"revision,omitempty"`
+	Time     string `json:"time,omitempty"`
+	Modified bool   `json:"modified,omitempty"`
+
+	// GoVersion is the version of the Go toolchain punused was built with.
+	GoVersion string `json:"go_version"`
+}
+
+// ReadBuildInfo returns the build information of the running binary.
+func ReadBuildInfo() BuildInfo {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{Version: "(unknown)", GoVersion: runtime.Version()}
+	}
+	info := BuildInfo{Version: bi.Main.Version, GoVersion: bi.GoVersion}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// String returns the build information as printed by -version, e.g.
+// punused v1.2.0 (revision 4f3a2b1, 2024-05-01T10:00:00Z) built with go1.22.2.
+func (b BuildInfo) String() string {
+	s := "punused " + b.Version
+	if b.Revision != "" {
+		details := []string{b.Revision}
+		if b.Time != "" {
+			details = append(details, b.Time)
+		}
+		if b.Modified {
+			details = append(details, "modified")
+		}
+		s += " (revision " + strings.Join(details, ", ") + ")"
+	}
+	return s + " built with " + b.GoVersion
+}
+
+// toolVersion returns the version of the punused module, "(devel)" when built from source.
+func toolVersion() string {
+	return ReadBuildInfo().Version
+}
+
+// goplsVersion returns the version of the gopls binary, e.g. "v0.7.3", or an empty string if not available.
+func goplsVersion(binary string) string {
+	out, err := exec.Command(binary, "version").Output()
+	if err != nil {
+		return ""
+	}
+	// golang.org/x/tools/gopls v0.7.3
+	//    golang.org/x/tools/gopls@v0.7.3 h1:...
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// goVersion returns the version of the Go toolchain used in dir, falling back to
+// the version punused was built with.
+func goVersion(dir string) string {
+	cmd := exec.Command("go", "env", "GOVERSION")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return runtime.Version()
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// modulePath returns the module path declared in dir's go.mod, or an empty string.
+func modulePath(dir string) string {
+	b, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			if p, err := strconv.Unquote(fields[1]); err == nil {
+				return p
+			}
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// hash returns a short hash of the configuration options that affect the findings.
+func (cfg RunConfig) hash() string {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		panic(err)
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestBuildInfoString(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(BuildInfo{Version: "(devel)", GoVersion: "go1.22.2"}.String(), qt.Equals, "punused (devel) built with go1.22.2")
+	c.Assert(
+		BuildInfo{Version: "v1.2.0", Revision: "4f3a2b1", Time: "2024-05-01T10:00:00Z", Modified: true, GoVersion: "go1.22.2"}.String(),
+		qt.Equals,
+		"punused v1.2.0 (revision 4f3a2b1, 2024-05-01T10:00:00Z, modified) built with go1.22.2",
+	)
+}
+
+func TestNewRunInfo(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	info := newRunInfo(RunConfig{WorkspaceDir: dir, Backend: BackendPackages})
+	c.Assert(info.Backend, qt.Equals, BackendPackages)
+	c.Assert(info.Workspace, qt.Equals, dir)
+	c.Assert(info.GoplsVersion, qt.Equals, "")
+	c.Assert(info.Version, qt.Not(qt.Equals), "")
+
+	info.finish()
+	c.Assert(info.Finished.Before(info.Started), qt.IsFalse)
+	c.Assert(info.Duration >= 0, qt.IsTrue)
+}
@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const formatSARIF = "sarif"
+
+// The SARIF 2.1.0 types, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+// Only the parts we use are included.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations,omitempty"`
+	Results     []sarifResult     `json:"results"`
+	Properties  map[string]string `json:"properties,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool      `json:"executionSuccessful"`
+	StartTimeUTC        time.Time `json:"startTimeUtc"`
+	EndTimeUTC          time.Time `json:"endTimeUtc"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	HelpURI          string       `json:"helpUri"`
+	Help             sarifMessage `json:"help"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+// sarifRules returns the rules for the checks, with the codes prefixed with prefix, if set.
+func sarifRules(prefix string) []sarifRule {
+	rules := make([]sarifRule, len(checks))
+	for i, c := range checks {
+		code := prefixedCode(prefix, c.Code)
+		rules[i] = sarifRule{
+			ID:               code,
+			Name:             c.Name,
+			ShortDescription: sarifMessage{Text: c.Short},
+			FullDescription:  sarifMessage{Text: c.Description},
+			HelpURI:          c.URL(),
+			Help:             sarifMessage{Text: fmt.Sprintf("%s Run punused explain %s for details.", c.Suppression, code)},
+		}
+	}
+	return rules
+}
+
+// writeSARIF writes findings to w as a SARIF log. info may be nil.
+func writeSARIF(w io.Writer, info *RunInfo, findings []Finding) error {
+	var prefix string
+	if info != nil {
+		prefix = info.CodePrefix
+	}
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		result := sarifResult{
+			RuleID:  f.Code,
+			Level:   f.Severity.sarifLevel(),
+			Message: sarifMessage{Text: f.Kind + " " + f.Name + " " + f.Message()},
+			Locations: []sarifLocation{
+				{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: f.Filename, URIBaseID: "%SRCROOT%"},
+						Region:           sarifRegion{StartLine: f.Line, StartColumn: f.Column},
+					},
+				},
+			},
+			PartialFingerprints: map[string]string{"punused/v1": f.Fingerprint()},
+		}
+		if f.Hint != "" {
+			result.Message.Text += ". " + f.Hint
+		}
+		if f.IssueURL != "" {
+			result.Message.Markdown = fmt.Sprintf("%s ([track](%s))", result.Message.Text, f.IssueURL)
+			result.Properties = map[string]string{"issueUrl": f.IssueURL}
+		}
+		results = append(results, result)
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "punused",
+				InformationURI: "https://github.com/bep/punused",
+				Rules:          sarifRules(prefix),
+			},
+		},
+		Results: results,
+	}
+
+	if info != nil {
+		run.Tool.Driver.Version = info.Version
+		run.Invocations = []sarifInvocation{
+			{ExecutionSuccessful: true, StartTimeUTC: info.Started, EndTimeUTC: info.Finished},
+		}
+		run.Properties = map[string]string{
+			"revision":     info.Revision,
+			"goplsVersion": info.GoplsVersion,
+			"goVersion":    info.GoVersion,
+			"backend":      info.Backend,
+			"workspace":    info.Workspace,
+			"module":       info.Module,
+			"configHash":   info.ConfigHash,
+			"duration":     strconv.FormatFloat(info.Duration, 'f', 3, 64),
+		}
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strings"
+)
+
+// writeScript writes the edits made by Fix with RunConfig.Script set to w as a shell script of rf
+// (rsc.io/rf) commands to run from the workspace root, one rf invocation per package directory,
+// with its commands in the order of the edits: rm for the declarations removed, mv to the file
+// for those moved, and mv to the new name for the symbols renamed.
+func writeScript(w io.Writer, edits []FixEdit) error {
+	var (
+		dirs     []string
+		commands = make(map[string][]string)
+	)
+	for _, e := range edits {
+		var command string
+		switch e.Action {
+		case fixActionRemoved:
+			command = "rm " + rfNames(e.Symbol)
+		case fixActionMoved:
+			command = "mv " + rfNames(e.Symbol) + " " + path.Base(e.Target)
+		case fixActionRenamed:
+			command = "mv " + rfNames(e.Symbol) + " " + e.Target
+		default:
+			continue
+		}
+		dir := path.Dir(e.Filename)
+		if _, found := commands[dir]; !found {
+			dirs = append(dirs, dir)
+		}
+		commands[dir] = append(commands[dir], command)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#!/bin/sh\n")
+	bw.WriteString("# The changes of punused -fix as rf commands, see https://pkg.go.dev/rsc.io/rf.\n")
+	bw.WriteString("# Review, then run from the workspace root with rf installed: go install rsc.io/rf@latest\n")
+	bw.WriteString("set -e\n")
+	for _, dir := range dirs {
+		bw.WriteString("\n")
+		if dir != "." {
+			bw.WriteString("(cd " + dir + " && ")
+		}
+		bw.WriteString("rf '\n")
+		for _, command := range commands[dir] {
+			bw.WriteString("\t" + command + "\n")
+		}
+		bw.WriteString("'")
+		if dir != "." {
+			bw.WriteString(")")
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// rfNames returns the symbols, e.g. (*MyType).MyMethod, or the names of a type declaration group,
+// e.g. A, B, as named in rf commands, e.g. MyType.MyMethod, or A B.
+func rfNames(symbol string) string {
+	var names []string
+	for _, name := range strings.Split(symbol, ", ") {
+		if recv, method, found := strings.Cut(name, ")."); found && strings.HasPrefix(recv, "(") {
+			name = strings.TrimPrefix(strings.TrimPrefix(recv, "("), "*") + "." + method
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
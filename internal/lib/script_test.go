@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWriteScript(t *testing.T) {
+	c := qt.New(t)
+
+	var b strings.Builder
+	c.Assert(writeScript(&b, []FixEdit{
+		{Filename: "main.go", Symbol: "unusedFunc", Action: fixActionRemoved},
+		{Filename: "pkg/a.go", Symbol: "(*T).M, U", Action: fixActionRemoved},
+		{Filename: "pkg/b.go", Symbol: "helper", Action: fixActionMoved, Target: "pkg/b_test.go"},
+		{Filename: "main.go", Symbol: "Old", Action: fixActionRenamed, Target: "old"},
+	}), qt.IsNil)
+	c.Assert(b.String(), qt.Equals, `#!/bin/sh
+# The changes of punused -fix as rf commands, see https://pkg.go.dev/rsc.io/rf.
+# Review, then run from the workspace root with rf installed: go install rsc.io/rf@latest
+set -e
+
+rf '
+	rm unusedFunc
+	mv Old old
+'
+
+(cd pkg && rf '
+	rm T.M U
+	mv helper b_test.go
+')
+`)
+}
+
+func TestRFNames(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(rfNames("T"), qt.Equals, "T")
+	c.Assert(rfNames("(*T).M"), qt.Equals, "T.M")
+	c.Assert(rfNames("(T).M"), qt.Equals, "T.M")
+	c.Assert(rfNames("A, B"), qt.Equals, "A B")
+}
@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// marshalCalls are the calls, as written, whose arguments are typically marshaled or unmarshaled via reflection,
+// e.g. json.Marshal(v), json.NewDecoder(r).Decode(&v) or c.ShouldBindJSON(&v).
+var marshalCalls = mustCompileGlobs(
+	"*.Marshal",
+	"*.MarshalIndent",
+	"*.Unmarshal",
+	"*.Encode",
+	"*.Decode",
+	"*.StructScan",
+	"*.Bind*",
+	"*.ShouldBind*",
+)
+
+func mustCompileGlobs(patterns ...string) globs {
+	g, err := compileGlobs(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// skipsFields reports whether the fields of s, named base and referenced at refs, if known, are skipped
+// with SkipSerializedFields, see isSerializedStruct.
+func (r *runner) skipsFields(filename string, s *Symbol, base string, refs []*lsp.Location) (bool, error) {
+	if !r.cfg.SkipSerializedFields || s.Kind != lsp.SKStruct {
+		return false, nil
+	}
+	reason, err := r.isSerializedStruct(filename, s, base, refs)
+	if err != nil || reason == "" {
+		return false, err
+	}
+	r.cfg.Logger.Debug("skipping the fields of a serialized struct", "filename", filename, "symbol", s.Name, "reason", reason)
+	return true, nil
+}
+
+// isSerializedStruct reports whether the fields of the struct s, named base and referenced at refs, are likely
+// set or read via reflection, i.e. any of its fields has a serialization tag, see serializationTags,
+// or a value of it is passed to one of the marshalCalls. It returns the reason, if so.
+func (r *runner) isSerializedStruct(filename string, s *Symbol, base string, refs []*lsp.Location) (string, error) {
+	src, err := r.files.source(filename)
+	if err != nil {
+		return "", err
+	}
+	if st := structTypeAt(src, s.Location.Range.Start.Line+1, base); st != nil {
+		for _, field := range st.Fields.List {
+			if serializationTagged(field.Tag) {
+				return "serialization tags", nil
+			}
+		}
+	}
+	if refs == nil {
+		if refs, err = r.references(s.Location); err != nil {
+			return "", fmt.Errorf("failed to get references: %w", err)
+		}
+	}
+	for _, ref := range refs {
+		f, err := r.dynamic.parse(ref.URI)
+		if err != nil {
+			return "", err
+		}
+		if f.marshaledAt(ref.Range.Start) {
+			return "passed to a marshaling call", nil
+		}
+	}
+	return "", nil
+}
+
+// structTypeAt returns the struct type named name declared on the given 1-based line, if any.
+func structTypeAt(src *sourceFile, line int, name string) *ast.StructType {
+	var st *ast.StructType
+	ast.Inspect(src.file, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return st == nil
+		}
+		if ts.Name.Name == name && src.fset.Position(ts.Name.Pos()).Line == line {
+			st, _ = ts.Type.(*ast.StructType)
+		}
+		return false
+	})
+	return st
+}
+
+// marshaledAt reports whether the type referenced at pos is marshaled: The reference is within an argument
+// to one of the marshalCalls, e.g. json.Marshal(Config{}), or declares variables, e.g. var c Config,
+// c := &Config{} or func save(c *Config), passed to one of them in the same function, or file if at the top level.
+func (f *parsedFile) marshaledAt(pos lsp.Position) bool {
+	if f.inCallArg(pos, marshalCalls) {
+		return true
+	}
+	tf := f.fset.File(f.file.Pos())
+	if pos.Line+1 > tf.LineCount() {
+		return false
+	}
+	p := tf.LineStart(pos.Line+1) + token.Pos(pos.Character)
+
+	// The nodes enclosing p, innermost last.
+	var path []ast.Node
+	ast.Inspect(f.file, func(n ast.Node) bool {
+		if n == nil || p < n.Pos() || p > n.End() {
+			return false
+		}
+		path = append(path, n)
+		return true
+	})
+
+	var (
+		names = make(map[string]bool)
+		scope ast.Node
+	)
+	scope = f.file
+	for i := len(path) - 1; i >= 0; i-- {
+		switch n := path[i].(type) {
+		case *ast.ValueSpec:
+			if len(names) == 0 {
+				addIdents(names, n.Names)
+			}
+		case *ast.AssignStmt:
+			if len(names) == 0 {
+				for _, rhs := range n.Rhs {
+					if p >= rhs.Pos() && p <= rhs.End() {
+						addIdents(names, exprIdents(n.Lhs))
+					}
+				}
+			}
+		case *ast.Field:
+			if len(names) == 0 {
+				addIdents(names, n.Names)
+			}
+		case *ast.FuncDecl, *ast.FuncLit:
+			if scope == f.file {
+				scope = n
+			}
+		}
+	}
+	if len(names) == 0 {
+		return false
+	}
+
+	found := false
+	ast.Inspect(scope, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !marshalCalls.Match(types.ExprString(call.Fun)) {
+			return true
+		}
+		for _, arg := range call.Args {
+			ast.Inspect(arg, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && names[id.Name] {
+					found = true
+				}
+				return !found
+			})
+		}
+		return !found
+	})
+	return found
+}
+
+func addIdents(names map[string]bool, idents []*ast.Ident) {
+	for _, id := range idents {
+		if id.Name != "_" {
+			names[id.Name] = true
+		}
+	}
+}
+
+func exprIdents(exprs []ast.Expr) []*ast.Ident {
+	var idents []*ast.Ident
+	for _, e := range exprs {
+		if id, ok := e.(*ast.Ident); ok {
+			idents = append(idents, id)
+		}
+	}
+	return idents
+}
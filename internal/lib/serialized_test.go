@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestMarshaledAt(t *testing.T) {
+	c := qt.New(t)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "code.go", `package p
+
+func direct() { json.Marshal(Config{}) }
+
+func variable() {
+	var c Config
+	json.NewDecoder(r).Decode(&c)
+}
+
+func assigned() {
+	c := &Config{}
+	yaml.Unmarshal(b, c)
+}
+
+func param(c *Config) { ctx.ShouldBindJSON(c) }
+
+func other(c Config) { fmt.Println(c) }
+`, 0)
+	c.Assert(err, qt.IsNil)
+	f := &parsedFile{fset: fset, file: file}
+
+	// The 0-based positions of the references to Config.
+	for _, pos := range []lsp.Position{{Line: 2, Character: 29}, {Line: 5, Character: 7}, {Line: 10, Character: 8}, {Line: 14, Character: 14}} {
+		c.Assert(f.marshaledAt(pos), qt.IsTrue, qt.Commentf("%v", pos))
+	}
+	c.Assert(f.marshaledAt(lsp.Position{Line: 16, Character: 14}), qt.IsFalse)
+}
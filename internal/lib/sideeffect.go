@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// isRegisteredInInit reports whether the method s is on a type referenced from an init function
+// in a package only imported for its side effects somewhere in the workspace, e.g. a database driver
+// registered with sql.Register(&Driver{}) when imported as _ "example.com/driver".
+// Such methods are invoked via the registration, invisible to gopls.
+func (r *runner) isRegisteredInInit(filename string, s *Symbol) (bool, error) {
+	if s.Kind != lsp.SKMethod {
+		return false, nil
+	}
+	dir := path.Dir(filename)
+	if !r.blankImported()[r.importPath(dir)] {
+		return false, nil
+	}
+	recv := receiverType(s.Name)
+	if recv == "" {
+		return false, nil
+	}
+	refs, err := r.initRefs(dir)
+	if err != nil {
+		return false, err
+	}
+	return refs[recv], nil
+}
+
+// importPath returns the import path of the package in dir, relative to the workspace.
+func (r *runner) importPath(dir string) string {
+	if r.module == nil {
+		module := modulePath(r.cfg.WorkspaceDir)
+		r.module = &module
+	}
+	if dir == "." {
+		return *r.module
+	}
+	return *r.module + "/" + dir
+}
+
+// blankImported returns the import paths of the packages imported for their side effects only,
+// i.e. as _ "path", anywhere in the workspace.
+func (r *runner) blankImported() map[string]bool {
+	if r.blankImports == nil {
+		r.blankImports = make(map[string]bool)
+		filepath.WalkDir(r.cfg.WorkspaceDir, func(filename string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && (strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata") && filename != r.cfg.WorkspaceDir {
+				return filepath.SkipDir
+			}
+			if d.IsDir() || !strings.HasSuffix(filename, ".go") {
+				return nil
+			}
+			file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ImportsOnly)
+			if err != nil {
+				return nil
+			}
+			for _, spec := range file.Imports {
+				if spec.Name != nil && spec.Name.Name == "_" {
+					if p, err := strconv.Unquote(spec.Path.Value); err == nil {
+						r.blankImports[p] = true
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return r.blankImports
+}
+
+// initRefs returns the identifiers referenced from the init functions of the non-test files in dir,
+// relative to the workspace.
+func (r *runner) initRefs(dir string) (map[string]bool, error) {
+	if refs, found := r.inits[dir]; found {
+		return refs, nil
+	}
+	filenames, err := filepath.Glob(filepath.Join(r.cfg.WorkspaceDir, filepath.FromSlash(dir), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]bool)
+	for _, filename := range filenames {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil || fd.Name.Name != "init" || fd.Body == nil {
+				continue
+			}
+			ast.Inspect(fd.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					refs[id.Name] = true
+				}
+				return true
+			})
+		}
+	}
+	r.inits[dir] = refs
+	return refs, nil
+}
@@ -0,0 +1,256 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceFile is a parsed Go file used to look up information gopls does not give us,
+// e.g. doc comments and the full extent of a declaration.
+type sourceFile struct {
+	fset    *token.FileSet
+	file    *ast.File
+	content []byte
+
+	// decls maps the position of a declared identifier to its declaration.
+	decls map[declKey]*decl
+}
+
+// decl is the declaration of an identifier.
+type decl struct {
+	doc *ast.CommentGroup
+
+	// start and end is the extent of the declaration, including its doc comment.
+	start, end token.Pos
+
+	// tok is the declaration keyword, e.g. token.FUNC or token.CONST,
+	// token.ILLEGAL for struct fields and interface methods.
+	tok token.Token
+
+	// grouped is set for a spec in a parenthesized declaration, e.g. var ( ... ).
+	grouped bool
+
+	// shared is set when the spec declares other identifiers too, or is a constant in
+	// a group with implicit values (e.g. iota), i.e. it cannot be moved on its own.
+	shared bool
+
+	// spec and ident are the spec declaring a constant or variable and its name in it,
+	// and group the parenthesized declaration of the spec, if any, see removeValue.
+	spec  *ast.ValueSpec
+	ident *ast.Ident
+	group *ast.GenDecl
+
+	// tag is the struct tag of a field, if any.
+	tag *ast.BasicLit
+
+	// embedded is set for an embedded field, e.g. the Inner in struct{ *Inner }.
+	embedded bool
+}
+
+// declKey identifies a declared identifier by its 1-based line and its name.
+type declKey struct {
+	line int
+	name string
+}
+
+// fileCache caches the parsed source and the git blame of the last file asked for.
+type fileCache struct {
+	workspaceDir string
+	overlay      overlay
+
+	filename string
+	src      *sourceFile
+	lines    map[int]*Blame
+	blameErr error
+}
+
+func (c *fileCache) reset(filename string) {
+	if c.filename != filename {
+		*c = fileCache{workspaceDir: c.workspaceDir, overlay: c.overlay, filename: filename}
+	}
+}
+
+// source returns the parsed source of filename, relative to the workspace.
+func (c *fileCache) source(filename string) (*sourceFile, error) {
+	c.reset(filename)
+	if c.src == nil {
+		content, err := c.overlay.readFile(filepath.Join(c.workspaceDir, filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		src, err := parseSource(filename, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		c.src = src
+	}
+	return c.src, nil
+}
+
+// blame returns the git blame of filename, relative to the workspace.
+// A failure is returned on every call for the file.
+func (c *fileCache) blame(filename string) (map[int]*Blame, error) {
+	c.reset(filename)
+	if c.lines == nil && c.blameErr == nil {
+		c.lines, c.blameErr = gitBlame(c.workspaceDir, filename)
+	}
+	return c.lines, c.blameErr
+}
+
+func parseSourceFile(filename string) (*sourceFile, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseSource(filename, content)
+}
+
+func parseSource(filename string, content []byte) (*sourceFile, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &sourceFile{fset: fset, file: file, content: content, decls: make(map[declKey]*decl)}
+	f.collectDecls()
+
+	return f, nil
+}
+
+// docSummary returns the first sentence of the doc comment for the identifier
+// name declared on the given 1-based line.
+func (f *sourceFile) docSummary(line int, name string) string {
+	d := f.decls[declKey{line: line, name: name}]
+	if d == nil || d.doc == nil {
+		return ""
+	}
+	return doc.Synopsis(d.doc.Text())
+}
+
+// declLines returns the number of lines, including the doc comment, of the declaration
+// of the identifier name declared on the given 1-based line.
+// It returns 0 if the declaration is not found.
+func (f *sourceFile) declLines(line int, name string) int {
+	start, end := f.declRange(line, name)
+	if start == 0 {
+		return 0
+	}
+	return end - start + 1
+}
+
+// declRange returns the first and last 1-based line, including the doc comment, of the
+// declaration of the identifier name declared on the given 1-based line.
+// It returns 0, 0 if the declaration is not found.
+func (f *sourceFile) declRange(line int, name string) (start, end int) {
+	d := f.decls[declKey{line: line, name: name}]
+	if d == nil {
+		return 0, 0
+	}
+	return f.fset.Position(d.start).Line, f.fset.Position(d.end).Line
+}
+
+// snippet returns the first n lines, including the doc comment, of the declaration
+// of the identifier name declared on the given 1-based line.
+// It returns "" if the declaration is not found.
+func (f *sourceFile) snippet(line int, name string, n int) string {
+	d := f.decls[declKey{line: line, name: name}]
+	if d == nil {
+		return ""
+	}
+	text := string(f.content[f.fset.Position(d.start).Offset:f.fset.Position(d.end).Offset])
+	lines := strings.SplitAfterN(text, "\n", n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return strings.TrimRight(strings.Join(lines, ""), "\n")
+}
+
+func (f *sourceFile) collectDecls() {
+	add := func(ident *ast.Ident, node ast.Node, docs ...*ast.CommentGroup) *decl {
+		d := &decl{start: node.Pos(), end: node.End()}
+		for _, cg := range docs {
+			if cg != nil {
+				d.doc = cg
+				break
+			}
+		}
+		if d.doc != nil && d.doc.Pos() < d.start {
+			d.start = d.doc.Pos()
+		}
+		f.decls[declKey{line: f.fset.Position(ident.Pos()).Line, name: ident.Name}] = d
+		return d
+	}
+
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				add(name, field, field.Doc, field.Comment).tag = field.Tag
+			}
+			if len(field.Names) == 0 {
+				if ident := embeddedIdent(field.Type); ident != nil {
+					d := add(ident, field, field.Doc, field.Comment)
+					d.tag, d.embedded = field.Tag, true
+				}
+			}
+		}
+	}
+
+	for _, decl := range f.file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			add(d.Name, d, d.Doc).tok = token.FUNC
+		case *ast.GenDecl:
+			// The doc comment of an ungrouped declaration is attached to the GenDecl,
+			// and removing the symbol means removing the entire GenDecl.
+			grouped := d.Lparen.IsValid()
+			var declDoc *ast.CommentGroup
+			if !grouped {
+				declDoc = d.Doc
+			}
+			// Constants with implicit values depend on the specs before them in the group.
+			implicit := false
+			for _, spec := range d.Specs {
+				if s, ok := spec.(*ast.ValueSpec); ok && d.Tok == token.CONST && len(s.Values) == 0 {
+					implicit = true
+				}
+			}
+			for _, spec := range d.Specs {
+				var node ast.Node = spec
+				if !grouped {
+					node = d
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					td := add(s.Name, node, s.Doc, declDoc, s.Comment)
+					td.tok, td.grouped = d.Tok, grouped
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						addFields(t.Fields)
+					case *ast.InterfaceType:
+						addFields(t.Methods)
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						vd := add(name, node, s.Doc, declDoc, s.Comment)
+						vd.tok, vd.grouped = d.Tok, grouped
+						vd.shared = len(s.Names) > 1 || implicit
+						vd.spec, vd.ident = s, name
+						if grouped {
+							vd.group = d
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"go/ast"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSourceFile(t *testing.T) {
+	c := qt.New(t)
+
+	filename := filepath.Join(t.TempDir(), "code.go")
+	c.Assert(os.WriteFile(filename, []byte(`package p
+
+// MyFunc does things. It does them well.
+func MyFunc() {}
+
+// MyConst is a constant.
+const MyConst = 1
+
+var (
+	// MyVar is a variable.
+	MyVar = 2
+	NoDoc = 3
+)
+
+type MyType struct {
+	// MyField is a field.
+	MyField string
+}
+
+func (MyType) MyMethod() {} // MyMethod has a trailing comment.
+`), 0o644), qt.IsNil)
+
+	src, err := parseSourceFile(filename)
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(src.docSummary(4, "MyFunc"), qt.Equals, "MyFunc does things.")
+	c.Assert(src.docSummary(7, "MyConst"), qt.Equals, "MyConst is a constant.")
+	c.Assert(src.docSummary(11, "MyVar"), qt.Equals, "MyVar is a variable.")
+	c.Assert(src.docSummary(12, "NoDoc"), qt.Equals, "")
+	c.Assert(src.docSummary(17, "MyField"), qt.Equals, "MyField is a field.")
+	c.Assert(src.docSummary(20, "MyMethod"), qt.Equals, "")
+
+	c.Assert(src.declLines(4, "MyFunc"), qt.Equals, 2)
+	c.Assert(src.declLines(11, "MyVar"), qt.Equals, 2)
+	c.Assert(src.declLines(12, "NoDoc"), qt.Equals, 1)
+	c.Assert(src.declLines(15, "MyType"), qt.Equals, 4)
+	c.Assert(src.declLines(1, "Missing"), qt.Equals, 0)
+
+	c.Assert(src.snippet(15, "MyType", 2), qt.Equals, "type MyType struct {\n\t// MyField is a field.")
+	c.Assert(src.snippet(4, "MyFunc", 5), qt.Equals, "// MyFunc does things. It does them well.\nfunc MyFunc() {}")
+	c.Assert(src.snippet(1, "Missing", 5), qt.Equals, "")
+}
+
+func TestOverlay(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0o644), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "b.go"), []byte("package b\n"), 0o644), qt.IsNil)
+
+	o := newOverlay(dir, map[string]string{"a.go": "package unsaved\n"})
+	c.Assert(o, qt.DeepEquals, overlay{filepath.Join(dir, "a.go"): "package unsaved\n"})
+
+	b, err := o.readFile(filepath.Join(dir, "a.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "package unsaved\n")
+	b, err = o.readFile(filepath.Join(dir, "b.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Equals, "package b\n")
+}
+
+func TestSuppresses(t *testing.T) {
+	c := qt.New(t)
+
+	for _, text := range []string{
+		"//punused:ignore",
+		"//punused:ignore used via reflection",
+		"//punused:ignore EU1002",
+		"//punused:ignore EU1001,EU1002 loaded as a plugin",
+		"//nolint:punused",
+		"//nolint:errcheck,punused // loaded as a plugin",
+	} {
+		c.Assert(suppresses(text, codeUnused), qt.IsTrue, qt.Commentf(text))
+	}
+	for _, text := range []string{
+		"// punused:ignore",
+		"//punused:ignored",
+		"//punused:ignore EU1001",
+		"//nolint",
+		"//nolint:errcheck",
+		"// MyFunc does things.",
+	} {
+		c.Assert(suppresses(text, codeUnused), qt.IsFalse, qt.Commentf(text))
+	}
+}
+
+func TestSerializationTagged(t *testing.T) {
+	c := qt.New(t)
+
+	for tag, want := range map[string]bool{
+		"`json:\"name\"`":                  true,
+		"`json:\"name,omitempty\"`":        true,
+		"`yaml:\"name\" validate:\"req\"`": true,
+		"`json:\"-\"`":                     false,
+		"`validate:\"required\"`":          false,
+		"`json:\"-\" xml:\"name\"`":        true,
+	} {
+		c.Assert(serializationTagged(&ast.BasicLit{Value: tag}), qt.Equals, want, qt.Commentf(tag))
+	}
+	c.Assert(serializationTagged(nil), qt.IsFalse)
+}
+
+func TestHeaderMatchers(t *testing.T) {
+	c := qt.New(t)
+
+	m, err := compileHeaderMatchers([]string{`Copyright \d+ The Go Authors`})
+	c.Assert(err, qt.IsNil)
+
+	copied := "// Copyright 2009 The Go Authors. All rights reserved.\n\n// Package p does things.\npackage p\n"
+	c.Assert(m.match([]byte(copied)), qt.Equals, `Copyright \d+ The Go Authors`)
+	c.Assert(m.match([]byte("// Package p does things.\npackage p\n\n// Copyright 2009 The Go Authors\n")), qt.Equals, "")
+	c.Assert(headerMatchers(nil).match([]byte(copied)), qt.Equals, "")
+
+	_, err = compileHeaderMatchers([]string{"("})
+	c.Assert(err, qt.ErrorMatches, `skip-headers: invalid regular expression.*`)
+}
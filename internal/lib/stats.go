@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// widelyUsedRefs is the number of references from which a symbol is considered widely used.
+const widelyUsedRefs = 10
+
+// Usage counts exported symbols by how much they're used.
+type Usage struct {
+	Total      int `json:"total"`
+	Unused     int `json:"unused"`
+	TestOnly   int `json:"test_only"`
+	SingleUse  int `json:"single_use"`
+	Used       int `json:"used"`
+	WidelyUsed int `json:"widely_used"`
+}
+
+// The usage classes of a symbol, see usageClass.
+const (
+	usageUnused     = "unused"
+	usageTestOnly   = "test only"
+	usageSingleUse  = "single use"
+	usageUsed       = "used"
+	usageWidelyUsed = "widely used"
+)
+
+// usageClass returns the usage class of a symbol with the given check code and number of references.
+func usageClass(code string, refs int) string {
+	switch {
+	case code == codeUnused || code == codeGenerated:
+		return usageUnused
+	case code == codeTestOnly:
+		return usageTestOnly
+	case refs == 1:
+		return usageSingleUse
+	case refs >= widelyUsedRefs:
+		return usageWidelyUsed
+	default:
+		return usageUsed
+	}
+}
+
+// inventory holds the usage of the exported symbols grouped by kind and package.
+type inventory struct {
+	Total     Usage            `json:"total"`
+	ByKind    map[string]Usage `json:"by_kind"`
+	ByPackage map[string]Usage `json:"by_package"`
+}
+
+func newInventory() *inventory {
+	return &inventory{ByKind: make(map[string]Usage), ByPackage: make(map[string]Usage)}
+}
+
+// add adds the symbol s declared in filename with the given check code and number of references.
+func (inv *inventory) add(filename string, s *Symbol, code string, refs int) {
+	f := newFinding(filename, s, code)
+	kind := inv.ByKind[f.Kind]
+	pkg := inv.ByPackage[packageOf(f)]
+	class := usageClass(code, refs)
+	for _, u := range []*Usage{&inv.Total, &kind, &pkg} {
+		u.Total++
+		switch class {
+		case usageUnused:
+			u.Unused++
+		case usageTestOnly:
+			u.TestOnly++
+		case usageSingleUse:
+			u.SingleUse++
+		case usageWidelyUsed:
+			u.WidelyUsed++
+		default:
+			u.Used++
+		}
+	}
+	inv.ByKind[f.Kind] = kind
+	inv.ByPackage[packageOf(f)] = pkg
+}
+
+// Stats analyzes the exported symbols like Run, but writes an inventory of them to cfg.Out:
+// the number of symbols by kind and package and how much they're used
+// (unused, used in test only, used once, used and widely used, i.e. 10 or more references).
+func Stats(ctx context.Context, cfg RunConfig) error {
+	inv := newInventory()
+	if err := walkSymbols(ctx, cfg, inv.add); err != nil {
+		return err
+	}
+
+	if cfg.Format == formatJSON {
+		enc := json.NewEncoder(cfg.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inv)
+	}
+	inv.print(cfg.Out)
+	return nil
+}
+
+// walkSymbols analyzes the exported symbols in the workspace, calling observe for each of them.
+// The findings are not written.
+func walkSymbols(ctx context.Context, cfg RunConfig, observe func(filename string, s *Symbol, code string, refs int)) (err error) {
+	if err := cfg.init(); err != nil {
+		return err
+	}
+	if err := checkModule(cfg.WorkspaceDir); err != nil {
+		return err
+	}
+	cfg.Out = io.Discard
+
+	r, err := newRunner(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+	if err := r.startGopls(); err != nil {
+		return err
+	}
+	defer func() {
+		if stopErr := r.Stop(); err == nil {
+			err = stopErr
+		}
+	}()
+	r.observe = observe
+
+	return r.Walk()
+}
+
+func (inv *inventory) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	printUsage := func(title string, m map[string]Usage) {
+		fmt.Fprintf(tw, "%s\tTOTAL\tUNUSED\tTEST ONLY\tSINGLE USE\tUSED\tWIDELY USED\t\n", strings.ToUpper(title))
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			u := m[k]
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n", k, u.Total, u.Unused, u.TestOnly, u.SingleUse, u.Used, u.WidelyUsed)
+		}
+		fmt.Fprintln(tw, "\t\t\t\t\t\t\t")
+	}
+	printUsage("kind", inv.ByKind)
+	printUsage("package", inv.ByPackage)
+	printUsage("", map[string]Usage{"total": inv.Total})
+	tw.Flush()
+}
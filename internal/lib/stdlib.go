@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"go/version"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// stdlibFunc is a standard library function, or builtin, added in a recent Go version that exported helpers
+// written before it often duplicate, see RunConfig.StdlibClones.
+type stdlibFunc struct {
+	// Name is the qualified name, e.g. slices.Contains.
+	Name string
+
+	// GoVersion is the Go version it was added in, e.g. 1.21.
+	GoVersion string
+
+	// Names are the names of the helpers duplicating it, e.g. Contains, also matching the names with a type
+	// suffix, e.g. ContainsString.
+	Names []string
+
+	// Signature is the signature of the helpers, where T, K, V and E match any type, the same each time it appears,
+	// e.g. func([]E, E) bool.
+	Signature string
+}
+
+// stdlibFuncs is the catalog of standard library functions looked for.
+var stdlibFuncs = []stdlibFunc{
+	{Name: "slices.Contains", GoVersion: "1.21", Names: []string{"Contains", "SliceContains", "InSlice", "Includes"}, Signature: "func([]E, E) bool"},
+	{Name: "slices.ContainsFunc", GoVersion: "1.21", Names: []string{"ContainsFunc", "Any"}, Signature: "func([]E, func(E) bool) bool"},
+	{Name: "slices.Index", GoVersion: "1.21", Names: []string{"Index", "IndexOf"}, Signature: "func([]E, E) int"},
+	{Name: "slices.IndexFunc", GoVersion: "1.21", Names: []string{"IndexFunc", "FindIndex"}, Signature: "func([]E, func(E) bool) int"},
+	{Name: "slices.Equal", GoVersion: "1.21", Names: []string{"Equal", "SliceEqual", "EqualSlices"}, Signature: "func([]E, []E) bool"},
+	{Name: "slices.Reverse", GoVersion: "1.21", Names: []string{"Reverse", "ReverseSlice"}, Signature: "func([]E)"},
+	{Name: "slices.Clone", GoVersion: "1.21", Names: []string{"Clone", "Copy", "CloneSlice", "CopySlice"}, Signature: "func([]E) []E"},
+	{Name: "slices.Compact", GoVersion: "1.21", Names: []string{"Compact", "Dedup", "Dedupe"}, Signature: "func([]E) []E"},
+	{Name: "slices.Max", GoVersion: "1.21", Names: []string{"Max", "MaxOf", "SliceMax"}, Signature: "func([]E) E"},
+	{Name: "slices.Min", GoVersion: "1.21", Names: []string{"Min", "MinOf", "SliceMin"}, Signature: "func([]E) E"},
+	{Name: "slices.Concat", GoVersion: "1.22", Names: []string{"Concat", "ConcatSlices"}, Signature: "func(...[]E) []E"},
+	{Name: "maps.Clone", GoVersion: "1.21", Names: []string{"Clone", "Copy", "CloneMap", "CopyMap"}, Signature: "func(map[K]V) map[K]V"},
+	{Name: "maps.Copy", GoVersion: "1.21", Names: []string{"Copy", "Merge", "CopyMap", "MergeMaps"}, Signature: "func(map[K]V, map[K]V)"},
+	{Name: "maps.Equal", GoVersion: "1.21", Names: []string{"Equal", "MapEqual", "EqualMaps"}, Signature: "func(map[K]V, map[K]V) bool"},
+	{Name: "slices.Collect(maps.Keys(m))", GoVersion: "1.23", Names: []string{"Keys", "MapKeys"}, Signature: "func(map[K]V) []K"},
+	{Name: "slices.Collect(maps.Values(m))", GoVersion: "1.23", Names: []string{"Values", "MapValues"}, Signature: "func(map[K]V) []V"},
+	{Name: "min", GoVersion: "1.21", Names: []string{"Min"}, Signature: "func(T, T) T"},
+	{Name: "max", GoVersion: "1.21", Names: []string{"Max"}, Signature: "func(T, T) T"},
+	{Name: "cmp.Compare", GoVersion: "1.21", Names: []string{"Compare"}, Signature: "func(T, T) int"},
+	{Name: "cmp.Or", GoVersion: "1.22", Names: []string{"Or", "Coalesce", "FirstNonZero", "FirstNonEmpty"}, Signature: "func(...T) T"},
+	{Name: "strings.CutPrefix", GoVersion: "1.20", Names: []string{"CutPrefix", "TrimPrefixOK"}, Signature: "func(string, string) (string, bool)"},
+	{Name: "strings.CutSuffix", GoVersion: "1.20", Names: []string{"CutSuffix", "TrimSuffixOK"}, Signature: "func(string, string) (string, bool)"},
+	{Name: "errors.Join", GoVersion: "1.20", Names: []string{"Join", "JoinErrors", "Combine", "CombineErrors"}, Signature: "func(...error) error"},
+	{Name: "sync.OnceFunc", GoVersion: "1.21", Names: []string{"OnceFunc", "Once"}, Signature: "func(func()) func()"},
+	{Name: "sync.OnceValue", GoVersion: "1.21", Names: []string{"OnceValue", "Once", "Lazy"}, Signature: "func(func() T) func() T"},
+	{Name: "new", GoVersion: "1.26", Names: []string{"Ptr", "ToPtr", "PtrTo", "PointerTo", "Ref"}, Signature: "func(T) *T"},
+}
+
+// stdlibTypeVars are the names matching any type in stdlibFunc.Signature.
+var stdlibTypeVars = map[string]bool{"T": true, "K": true, "V": true, "E": true}
+
+// stdlibDuplicate returns the standard library function, available with the Go language version goVersion,
+// e.g. 1.21, or any if not set, that fd duplicates, if any.
+func stdlibDuplicate(fd *ast.FuncDecl, goVersion string) (stdlibFunc, bool) {
+	if fd.Recv != nil {
+		return stdlibFunc{}, false
+	}
+	for _, sf := range stdlibFuncs {
+		if goVersion != "" && version.Compare("go"+goVersion, "go"+sf.GoVersion) < 0 {
+			continue
+		}
+		if !sf.matchesName(fd.Name.Name) {
+			continue
+		}
+		pattern, err := parser.ParseExpr(sf.Signature)
+		if err != nil {
+			panic(err)
+		}
+		if unifyTypes(pattern.(*ast.FuncType), fd.Type, make(map[string]string)) {
+			return sf, true
+		}
+	}
+	return stdlibFunc{}, false
+}
+
+// matchesName reports whether name is one of sf.Names, optionally with a type suffix, e.g. ContainsString or MaxInt64.
+func (sf stdlibFunc) matchesName(name string) bool {
+	for _, n := range sf.Names {
+		if suffix, found := strings.CutPrefix(name, n); found && (suffix == "" || unicode.IsUpper(rune(suffix[0]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// unifyTypes reports whether the type expression expr matches pattern, binding the type variables in pattern,
+// see stdlibTypeVars, to the types they match in bound.
+func unifyTypes(pattern, expr ast.Expr, bound map[string]string) bool {
+	switch p := pattern.(type) {
+	case *ast.Ident:
+		if stdlibTypeVars[p.Name] {
+			s := types.ExprString(expr)
+			if b, found := bound[p.Name]; found {
+				return b == s
+			}
+			bound[p.Name] = s
+			return true
+		}
+		e, ok := expr.(*ast.Ident)
+		return ok && e.Name == p.Name
+	case *ast.ArrayType:
+		e, ok := expr.(*ast.ArrayType)
+		return ok && p.Len == nil && e.Len == nil && unifyTypes(p.Elt, e.Elt, bound)
+	case *ast.MapType:
+		e, ok := expr.(*ast.MapType)
+		return ok && unifyTypes(p.Key, e.Key, bound) && unifyTypes(p.Value, e.Value, bound)
+	case *ast.StarExpr:
+		e, ok := expr.(*ast.StarExpr)
+		return ok && unifyTypes(p.X, e.X, bound)
+	case *ast.Ellipsis:
+		e, ok := expr.(*ast.Ellipsis)
+		return ok && unifyTypes(p.Elt, e.Elt, bound)
+	case *ast.FuncType:
+		e, ok := expr.(*ast.FuncType)
+		return ok && unifyFields(p.Params, e.Params, bound) && unifyFields(p.Results, e.Results, bound)
+	default:
+		return types.ExprString(pattern) == types.ExprString(expr)
+	}
+}
+
+// unifyFields unifies the types of the parameters, or results, in pattern and fl, one per name.
+func unifyFields(pattern, fl *ast.FieldList, bound map[string]string) bool {
+	p, e := fieldTypes(pattern), fieldTypes(fl)
+	if len(p) != len(e) {
+		return false
+	}
+	for i := range p {
+		if !unifyTypes(p[i], e[i], bound) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldTypes returns the types in fl, one per name, e.g. string twice for (a, b string).
+func fieldTypes(fl *ast.FieldList) []ast.Expr {
+	if fl == nil {
+		return nil
+	}
+	var exprs []ast.Expr
+	for _, field := range fl.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			exprs = append(exprs, field.Type)
+		}
+	}
+	return exprs
+}
+
+// stdlibClone returns the standard library function the exported top level function base in filename,
+// relative to the workspace, duplicates, if any, given the Go language version of the workspace.
+func (r *runner) stdlibClone(filename, base string) (string, error) {
+	src, err := r.files.source(filename)
+	if err != nil {
+		return "", err
+	}
+	if r.langVersion == nil {
+		v := r.cfg.GoVersion
+		if v == "" {
+			v = goDirective(filepath.Join(r.cfg.WorkspaceDir, "go.mod"))
+		}
+		r.langVersion = &v
+	}
+	for _, decl := range src.file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == base {
+			if sf, found := stdlibDuplicate(fd, *r.langVersion); found {
+				return sf.Name, nil
+			}
+			break
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStdlibDuplicate(t *testing.T) {
+	c := qt.New(t)
+
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", `package p
+
+func ContainsString(s []string, v string) bool { return false }
+func Contains[T comparable](s []T, v T) bool { return false }
+func ContainsFold(s []string, v string) bool { return false }
+func Containsx(s []string, v string) bool { return false }
+func Contains2(s []string, v int) bool { return false }
+func MaxInt(a, b int) int { return a }
+func Keys(m map[string]int) []string { return nil }
+func Values(m map[string]int) []string { return nil }
+func Ptr[T any](v T) *T { return &v }
+func Join(errs ...error) error { return nil }
+func (T) Contains(s []string, v string) bool { return false }
+`, 0)
+	c.Assert(err, qt.IsNil)
+
+	for _, test := range []struct {
+		name, goVersion, want string
+	}{
+		{"ContainsString", "", "slices.Contains"},
+		{"Contains", "1.21", "slices.Contains"},
+		{"Contains", "1.20", ""},
+		// A false positive, Fold taken for a type suffix, see the check.
+		{"ContainsFold", "", "slices.Contains"},
+		{"Containsx", "", ""},
+		{"Contains2", "", ""},
+		{"MaxInt", "", "max"},
+		{"Keys", "1.23.0", "slices.Collect(maps.Keys(m))"},
+		{"Keys", "1.22", ""},
+		{"Values", "", ""},
+		{"Ptr", "1.26", "new"},
+		{"Ptr", "1.25", ""},
+		{"Join", "1.20", "errors.Join"},
+	} {
+		var fd *ast.FuncDecl
+		for _, decl := range file.Decls {
+			if d := decl.(*ast.FuncDecl); d.Recv == nil && d.Name.Name == test.name {
+				fd = d
+			}
+		}
+		sf, found := stdlibDuplicate(fd, test.goVersion)
+		c.Assert(found, qt.Equals, test.want != "", qt.Commentf("%s %s", test.name, test.goVersion))
+		c.Assert(sf.Name, qt.Equals, test.want, qt.Commentf("%s %s", test.name, test.goVersion))
+	}
+
+	method := file.Decls[len(file.Decls)-1].(*ast.FuncDecl)
+	_, found := stdlibDuplicate(method, "")
+	c.Assert(found, qt.IsFalse)
+}
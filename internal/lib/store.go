@@ -0,0 +1,355 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// store persists the state shared between runs, e.g. the cached results and the history,
+// in a directory or a bucket, so ephemeral CI runners can share it, see openStore.
+type store interface {
+	// Get returns the content of the object key, or an error matching fs.ErrNotExist if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put writes b to the object key, replacing it as a whole.
+	Put(ctx context.Context, key string, b []byte) error
+}
+
+// storeURLs describes the locations of the stores, for the errors.
+const storeURLs = "a directory, file://dir, s3://bucket/prefix or gs://bucket/prefix"
+
+// openStore returns the store at loc, a directory, or a URL: file://dir, s3://bucket/prefix
+// for Amazon S3 or any S3 compatible storage, or gs://bucket/prefix for Google Cloud Storage.
+//
+// The S3 store is configured as the AWS CLI, from the environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, AWS_REGION (or AWS_DEFAULT_REGION, defaulting to us-east-1) and, for other storages,
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL, addressing the buckets by path.
+// The GCS store authenticates with GOOGLE_OAUTH_ACCESS_TOKEN, e.g. from gcloud auth print-access-token,
+// or else the service account of the GCE metadata server, as on Cloud Build or a GKE runner,
+// and talks to STORAGE_EMULATOR_HOST instead, if set.
+func openStore(loc string) (store, error) {
+	scheme, rest, found := strings.Cut(loc, "://")
+	if !found {
+		return dirStore{dir: loc}, nil
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if scheme != "file" && bucket == "" {
+		return nil, fmt.Errorf("store %q: missing bucket", loc)
+	}
+	switch scheme {
+	case "file":
+		return dirStore{dir: filepath.FromSlash(rest)}, nil
+	case "s3":
+		return newS3Store(bucket, strings.Trim(prefix, "/"))
+	case "gs":
+		return newGCSStore(bucket, strings.Trim(prefix, "/")), nil
+	default:
+		return nil, fmt.Errorf("store %q: unsupported scheme %q, expected %s", loc, scheme, storeURLs)
+	}
+}
+
+// openStoreFile returns the store holding the file at loc, a filename or a URL as with openStore, and its key.
+func openStoreFile(loc string) (store, string, error) {
+	if !strings.Contains(loc, "://") {
+		return dirStore{dir: filepath.Dir(loc)}, filepath.Base(loc), nil
+	}
+	i := strings.LastIndex(loc, "/")
+	key := loc[i+1:]
+	if key == "" || strings.HasSuffix(loc[:i+1], "://") {
+		return nil, "", fmt.Errorf("store %q: missing object name", loc)
+	}
+	s, err := openStore(loc[:i])
+	return s, key, err
+}
+
+// appender is implemented by the stores appending to an object without reading it, see appendObject.
+type appender interface {
+	Append(ctx context.Context, key string, b []byte) error
+}
+
+// appendObject appends b to the object key of s, created if needed.
+// Unless s is an appender, concurrent appends may be lost, the object being read and written back.
+func appendObject(ctx context.Context, s store, key string, b []byte) error {
+	if a, ok := s.(appender); ok {
+		return a.Append(ctx, key, b)
+	}
+	old, err := s.Get(ctx, key)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return s.Put(ctx, key, append(old, b...))
+}
+
+// dirStore is a store in a local directory, created if needed.
+type dirStore struct {
+	dir string
+}
+
+func (s dirStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, key))
+}
+
+func (s dirStore) Put(ctx context.Context, key string, b []byte) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	// Written to a temporary file first, as concurrent runs may share the directory.
+	f, err := os.CreateTemp(s.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.dir, key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s dirStore) Append(ctx context.Context, key string, b []byte) error {
+	f, err := os.OpenFile(filepath.Join(s.dir, key), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// objectStore is a store in a bucket of an object storage with an HTTP API, e.g. S3 or GCS.
+// The objects are named by the prefix and the key, e.g. punused/abc.json.
+type objectStore struct {
+	name     string // e.g. S3, for the errors.
+	endpoint string // e.g. https://bucket.s3.us-east-1.amazonaws.com, without the trailing slash.
+	bucket   string // in the path of the objects, if addressed by path, else empty.
+	prefix   string
+
+	// authorize authenticates req, whose body hashes to payloadHash, the hex encoded SHA-256.
+	authorize func(ctx context.Context, req *http.Request, payloadHash string) error
+
+	client *http.Client
+}
+
+func (s *objectStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var b []byte
+	err := s.do(ctx, http.MethodGet, key, nil, &b)
+	return b, err
+}
+
+func (s *objectStore) Put(ctx context.Context, key string, b []byte) error {
+	return s.do(ctx, http.MethodPut, key, b, nil)
+}
+
+func (s *objectStore) do(ctx context.Context, method, key string, body []byte, result *[]byte) error {
+	object := path.Join(s.bucket, s.prefix, key)
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+"/"+escapePath(object), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	if err := s.authorize(ctx, req, hex.EncodeToString(sum[:])); err != nil {
+		return fmt.Errorf("%s %s %s: %w", s.name, method, object, err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return fmt.Errorf("%s %s %s: %w", s.name, method, object, fs.ErrNotExist)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s %s: %s: %s", s.name, method, object, resp.Status, bytes.TrimSpace(msg))
+	}
+	if result == nil {
+		return nil
+	}
+	*result, err = io.ReadAll(resp.Body)
+	return err
+}
+
+// escapePath escapes the segments of the object path p, keeping the slashes.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCredentials are the credentials requests to S3 are signed with, see signAWS.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+func newS3Store(bucket, prefix string) (*objectStore, error) {
+	creds := awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return nil, fmt.Errorf("store s3://%s: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set", bucket)
+	}
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	s := &objectStore{name: "S3", prefix: prefix, client: http.DefaultClient}
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		// E.g. MinIO or R2, addressed by path.
+		s.endpoint, s.bucket = strings.TrimSuffix(endpoint, "/"), bucket
+	} else {
+		s.endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	}
+	s.authorize = func(ctx context.Context, req *http.Request, payloadHash string) error {
+		signAWS(req, payloadHash, creds, region, "s3", time.Now())
+		return nil
+	}
+	return s, nil
+}
+
+// signAWS signs req, whose body hashes to payloadHash, with AWS Signature Version 4 for service in region,
+// including the headers already set in the signature.
+func signAWS(req *http.Request, payloadHash string, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	canonicalRequest := strings.Join([]string{req.Method, uri, req.URL.Query().Encode(), canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + creds.secretAccessKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// gcsMetadataTokenURL is the URL of the access token of the default service account on the GCE metadata server.
+const gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+func newGCSStore(bucket, prefix string) *objectStore {
+	s := &objectStore{name: "GCS", endpoint: "https://storage.googleapis.com", bucket: bucket, prefix: prefix, client: http.DefaultClient}
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		s.endpoint = strings.TrimSuffix(host, "/")
+	}
+
+	var (
+		once  sync.Once
+		token string
+		err   error
+	)
+	s.authorize = func(ctx context.Context, req *http.Request, payloadHash string) error {
+		once.Do(func() {
+			if token = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token == "" {
+				token, err = gcsMetadataToken(ctx, s.client)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+	return s
+}
+
+// gcsMetadataToken returns an access token of the default service account from the GCE metadata server.
+func gcsMetadataToken(ctx context.Context, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN is not set and the metadata server is not available: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN is not set and the metadata server returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// firstEnv returns the value of the first of the environment variables set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
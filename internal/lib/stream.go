@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"context"
+	"iter"
+)
+
+// StreamFindings analyzes the workspace like Findings, but yields the findings as they're found,
+// the analysis waiting for each to be consumed. Breaking out of the loop stops the analysis.
+// An error, e.g. when ctx is done, ends the sequence, yielded with a zero Finding.
+// With multiple BuildConfigs or CollapseAccessors, the findings are merged first, so they're
+// yielded when the analysis is done.
+func StreamFindings(ctx context.Context, cfg RunConfig) iter.Seq2[Finding, error] {
+	return func(yield func(Finding, error) bool) {
+		if len(cfg.BuildConfigs) > 1 || cfg.CollapseAccessors {
+			findings, err := Findings(ctx, cfg)
+			if err != nil {
+				yield(Finding{}, err)
+				return
+			}
+			for _, f := range findings {
+				if !yield(f, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		cfg.Fix, cfg.Rename, cfg.Patch, cfg.Script = false, false, nil, nil
+		if err := cfg.init(); err != nil {
+			yield(Finding{}, err)
+			return
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		rep := &streamReporter{ctx: ctx, findings: make(chan Finding)}
+		done := make(chan error, 1)
+		go func() {
+			_, err := analyzeWorkspaces(ctx, cfg, rep, cfg.workspaceDirs())
+			done <- err
+		}()
+		for {
+			select {
+			case f := <-rep.findings:
+				if !yield(f, nil) {
+					cancel()
+					// Wait for gopls to shut down.
+					<-done
+					return
+				}
+			case err := <-done:
+				if err != nil {
+					yield(Finding{}, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// streamReporter hands the findings over to StreamFindings, one at a time.
+type streamReporter struct {
+	ctx      context.Context
+	findings chan Finding
+}
+
+func (s *streamReporter) Start(info *RunInfo) error { return nil }
+
+func (s *streamReporter) Report(f Finding) error {
+	select {
+	case s.findings <- f:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *streamReporter) Finish(report Report) error { return nil }
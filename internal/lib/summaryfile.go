@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// summaryFile is the summary of a run written to RunConfig.SummaryOut, independent of the output format.
+type summaryFile struct {
+	Run     *RunInfo `json:"run"`
+	Summary Summary  `json:"summary"`
+
+	// Duration is the wall time of the run in seconds.
+	Duration float64 `json:"duration_seconds"`
+
+	// BySeverity holds the number of findings per severity.
+	BySeverity map[Severity]int `json:"by_severity"`
+
+	// Passed is whether the findings passed the configured gates, see FailedError.
+	Passed  bool     `json:"passed"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// writeSummaryFile writes the summary of the run to filename, including the outcome of the gates in gateErr.
+func (r *runner) writeSummaryFile(filename string, info *RunInfo, gateErr error) error {
+	info.finish()
+	sf := summaryFile{
+		Run:        info,
+		Summary:    r.summary(),
+		Duration:   info.Duration,
+		BySeverity: make(map[Severity]int),
+		Passed:     gateErr == nil,
+	}
+	for _, f := range r.findings {
+		sf.BySeverity[f.Severity]++
+	}
+	var fe *FailedError
+	if errors.As(gateErr, &fe) {
+		sf.Reasons = fe.Reasons
+	}
+
+	b, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0o644)
+}
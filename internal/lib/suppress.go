@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"strings"
+)
+
+// isSuppressed reports whether the declaration of the symbol base on the given 1-based line in filename,
+// relative to the workspace, has a suppression directive covering code, see suppression.
+// The directive is looked for in the doc comment and in the comments on the declaration's line.
+func (r *runner) isSuppressed(filename string, line int, base, code string) (bool, error) {
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	if d := src.decls[declKey{line: line, name: base}]; d != nil && d.doc != nil {
+		for _, c := range d.doc.List {
+			if suppresses(c.Text, code) {
+				return true, nil
+			}
+		}
+	}
+	for _, cg := range src.file.Comments {
+		for _, c := range cg.List {
+			if src.fset.Position(c.Pos()).Line == line && suppresses(c.Text, code) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// suppresses reports whether the comment text is a suppression directive covering code, one of
+//
+//	//punused:ignore
+//	//punused:ignore EU1001,EU1002 optional reason
+//	//nolint:punused
+//	//nolint:errcheck,punused // optional reason
+func suppresses(text, code string) bool {
+	if rest, ok := strings.CutPrefix(text, "//punused:ignore"); ok {
+		if rest == "" {
+			return true
+		}
+		if rest[0] != ' ' && rest[0] != '\t' {
+			// E.g. //punused:ignored.
+			return false
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 || !isCheckCode(strings.Split(fields[0], ",")[0]) {
+			// Only a reason.
+			return true
+		}
+		for _, c := range strings.Split(fields[0], ",") {
+			if canonicalCode(c) == code {
+				return true
+			}
+		}
+		return false
+	}
+	if rest, ok := strings.CutPrefix(text, "//nolint:"); ok {
+		linters, _, _ := strings.Cut(rest, " ")
+		for _, linter := range strings.Split(linters, ",") {
+			if linter == "punused" {
+				return true
+			}
+		}
+	}
+	return false
+}
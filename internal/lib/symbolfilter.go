@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// symbolFilter selects the symbols to check, see RunConfig.Keep and RunConfig.Kinds.
+type symbolFilter struct {
+	keep  []keepRule
+	kinds map[string]bool
+
+	// linked holds the symbols kept as referenced by //go:linkname, assembly or //export, see linkedSymbols.
+	linked map[string]bool
+}
+
+// keepRule is a keep expression, optionally scoped to the package directories matching dirs.
+type keepRule struct {
+	dirs globs
+	re   *regexp.Regexp
+}
+
+func newSymbolFilter(keep, kinds []string) (symbolFilter, error) {
+	var sf symbolFilter
+	for _, expr := range keep {
+		var rule keepRule
+		// Symbol names never contain =, so it always separates the directory glob, e.g. pkg/api/**=^New.
+		if dir, name, found := strings.Cut(expr, "="); found {
+			dirs, err := compileGlobs(packagePatternGlobs(dir))
+			if err != nil {
+				return sf, fmt.Errorf("keep: invalid directory glob in %q: %w", expr, err)
+			}
+			rule.dirs, expr = dirs, name
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return sf, fmt.Errorf("keep: invalid regular expression %q: %w", expr, err)
+		}
+		rule.re = re
+		sf.keep = append(sf.keep, rule)
+	}
+	if len(kinds) > 0 {
+		sf.kinds = make(map[string]bool)
+		var excluded []string
+		for _, kind := range kinds {
+			kind, exclude := strings.CutPrefix(kind, "!")
+			expanded := expandKind(kind)
+			if expanded == nil {
+				return sf, fmt.Errorf("kinds: unknown kind %q, e.g. function, method, field, variable, constant, struct, interface or class, or func, type, var or const", kind)
+			}
+			if exclude {
+				excluded = append(excluded, expanded...)
+				continue
+			}
+			for _, k := range expanded {
+				sf.kinds[k] = true
+			}
+		}
+		if len(sf.kinds) == 0 {
+			// Only exclusions, e.g. !const.
+			for k := lsp.SKFile; k <= lsp.SKTypeParameter; k++ {
+				sf.kinds[strings.ToLower(k.String())] = true
+			}
+		}
+		for _, k := range excluded {
+			delete(sf.kinds, k)
+		}
+	}
+	return sf, nil
+}
+
+// kindAliases maps the short kind names, as in Go, to the kinds in the findings.
+var kindAliases = map[string][]string{
+	"func":  {"function"},
+	"type":  {"struct", "interface", "class"},
+	"var":   {"variable"},
+	"const": {"constant"},
+}
+
+// expandKind returns the kinds, as in the findings, for kind, either one of them or one of kindAliases, or nil if unknown.
+func expandKind(kind string) []string {
+	if kinds, found := kindAliases[kind]; found {
+		return kinds
+	}
+	if isSymbolKind(kind) {
+		return []string{kind}
+	}
+	return nil
+}
+
+// kept reports whether the symbol name, e.g. (*MyType).MyMethod, declared in filename, relative to the workspace,
+// matches one of the keep expressions, or is referenced by //go:linkname, assembly or //export.
+func (sf symbolFilter) kept(name, filename string) bool {
+	if sf.linked[path.Join(path.Dir(filename), name)] {
+		return true
+	}
+	for _, rule := range sf.keep {
+		if rule.dirs != nil && !rule.dirs.Match(path.Dir(filename)) {
+			continue
+		}
+		if rule.re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// packagePatternGlobs returns the globs for the package directory pattern p,
+// with a Go package pattern, e.g. pkg/api/..., matching the directory and the ones below it.
+func packagePatternGlobs(p string) []string {
+	if dir, found := strings.CutSuffix(p, "/..."); found {
+		return []string{dir, dir + "/**"}
+	}
+	return []string{p}
+}
+
+// checks reports whether symbols of kind, as in the findings, e.g. function, are checked.
+func (sf symbolFilter) checks(kind string) bool {
+	return sf.kinds == nil || sf.kinds[kind]
+}
+
+func isSymbolKind(kind string) bool {
+	for k := lsp.SKFile; k <= lsp.SKTypeParameter; k++ {
+		if strings.ToLower(k.String()) == kind {
+			return true
+		}
+	}
+	return false
+}
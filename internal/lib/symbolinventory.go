@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// symbolInventoryVersion is the version of the SymbolInventory schema, incremented on incompatible changes.
+const symbolInventoryVersion = 1
+
+// SymbolInventory lists every analyzed exported symbol with its usage, grouped by package,
+// for compliance tooling to track the growth of the public API from release to release.
+type SymbolInventory struct {
+	Schema        string    `json:"schema"`
+	SchemaVersion int       `json:"schema_version"`
+	Created       time.Time `json:"created"`
+	ToolVersion   string    `json:"tool_version"`
+
+	// Module is the module path of the workspace.
+	Module string `json:"module"`
+
+	// Release is the release the inventory was taken for, e.g. v1.2.0, if set.
+	Release string `json:"release,omitempty"`
+
+	Totals   Usage              `json:"totals"`
+	Packages []InventoryPackage `json:"packages"`
+}
+
+// InventoryPackage is a package in a SymbolInventory.
+type InventoryPackage struct {
+	// Path is the import path, Dir the directory relative to the workspace.
+	Path    string            `json:"path"`
+	Dir     string            `json:"dir"`
+	Symbols []InventorySymbol `json:"symbols"`
+}
+
+// InventorySymbol is an exported symbol in a SymbolInventory.
+type InventorySymbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Filename  string `json:"filename"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Signature string `json:"signature,omitempty"`
+
+	// Status is one of unused, test_only, single_use, used and widely_used, see Usage.
+	Status string `json:"status"`
+
+	// Code is the check code of the finding, empty if the symbol is used.
+	Code string `json:"code,omitempty"`
+
+	// Refs is the number of references counting as usage.
+	Refs int `json:"refs"`
+}
+
+// symbolInventoryBuilder collects the symbols of a SymbolInventory.
+type symbolInventoryBuilder struct {
+	inv      SymbolInventory
+	usage    *inventory
+	packages map[string]*InventoryPackage
+}
+
+func newSymbolInventoryBuilder(module, release string) *symbolInventoryBuilder {
+	return &symbolInventoryBuilder{
+		inv: SymbolInventory{
+			Schema:        "punused-inventory",
+			SchemaVersion: symbolInventoryVersion,
+			Created:       time.Now().UTC(),
+			ToolVersion:   toolVersion(),
+			Module:        module,
+			Release:       release,
+		},
+		usage:    newInventory(),
+		packages: make(map[string]*InventoryPackage),
+	}
+}
+
+// add adds the symbol s declared in filename with the given check code and number of references.
+func (b *symbolInventoryBuilder) add(filename string, s *Symbol, code string, refs int) {
+	b.usage.add(filename, s, code, refs)
+	f := newFinding(filename, s, code)
+	dir := packageOf(f)
+	pkg := b.packages[dir]
+	if pkg == nil {
+		importPath := b.inv.Module
+		if dir != "." {
+			importPath = path.Join(b.inv.Module, dir)
+		}
+		pkg = &InventoryPackage{Path: importPath, Dir: dir}
+		b.packages[dir] = pkg
+	}
+	pkg.Symbols = append(pkg.Symbols, newInventorySymbol(f, refs))
+}
+
+// newInventorySymbol returns the InventorySymbol for the symbol of f, with its check code, if any, and number of references.
+func newInventorySymbol(f Finding, refs int) InventorySymbol {
+	return InventorySymbol{
+		Name:      f.Name,
+		Kind:      f.Kind,
+		Filename:  f.Filename,
+		Line:      f.Line,
+		Column:    f.Column,
+		Signature: f.Signature,
+		Status:    strings.ReplaceAll(usageClass(canonicalCode(f.Code), refs), " ", "_"),
+		Code:      f.Code,
+		Refs:      refs,
+	}
+}
+
+// inventorySymbolLess orders the symbols by position.
+func inventorySymbolLess(si, sj InventorySymbol) bool {
+	if si.Filename != sj.Filename {
+		return si.Filename < sj.Filename
+	}
+	if si.Line != sj.Line {
+		return si.Line < sj.Line
+	}
+	return si.Column < sj.Column
+}
+
+// inventory returns the inventory with the packages sorted by path and the symbols by position.
+func (b *symbolInventoryBuilder) inventory() SymbolInventory {
+	inv := b.inv
+	inv.Totals = b.usage.Total
+	inv.Packages = []InventoryPackage{}
+	for _, pkg := range b.packages {
+		sort.Slice(pkg.Symbols, func(i, j int) bool { return inventorySymbolLess(pkg.Symbols[i], pkg.Symbols[j]) })
+		inv.Packages = append(inv.Packages, *pkg)
+	}
+	sort.Slice(inv.Packages, func(i, j int) bool { return inv.Packages[i].Path < inv.Packages[j].Path })
+	return inv
+}
+
+// ExportInventory analyzes the exported symbols like Run, but writes a SymbolInventory of all of them,
+// with or without findings, to cfg.Out as JSON. The release, if set, is recorded in it.
+func ExportInventory(ctx context.Context, cfg RunConfig, release string) error {
+	b := newSymbolInventoryBuilder(modulePath(cfg.WorkspaceDir), release)
+	if err := walkSymbols(ctx, cfg, b.add); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(cfg.Out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b.inventory())
+}
@@ -0,0 +1,35 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestSymbolInventory(t *testing.T) {
+	c := qt.New(t)
+
+	sym := func(name string, kind lsp.SymbolKind, line int) *Symbol {
+		return &Symbol{Name: name, Kind: kind, Location: lsp.Location{Range: lsp.Range{Start: lsp.Position{Line: line - 1, Character: 5}}}}
+	}
+
+	b := newSymbolInventoryBuilder("example.com/m", "v1.2.0")
+	b.add("p/p.go", sym("Used", lsp.SKFunction, 9), "", 3)
+	b.add("p/p.go", sym("Dead", lsp.SKFunction, 3), codeUnused, 0)
+	b.add("main.go", sym("Config", lsp.SKStruct, 5), codeTestOnly, 1)
+
+	inv := b.inventory()
+	c.Assert(inv.Module, qt.Equals, "example.com/m")
+	c.Assert(inv.Release, qt.Equals, "v1.2.0")
+	c.Assert(inv.Totals, qt.Equals, Usage{Total: 3, Unused: 1, TestOnly: 1, Used: 1})
+	c.Assert(inv.Packages, qt.DeepEquals, []InventoryPackage{
+		{Path: "example.com/m", Dir: ".", Symbols: []InventorySymbol{
+			{Name: "Config", Kind: "struct", Filename: "main.go", Line: 5, Column: 6, Status: "test_only", Code: codeTestOnly, Refs: 1},
+		}},
+		{Path: "example.com/m/p", Dir: "p", Symbols: []InventorySymbol{
+			{Name: "Dead", Kind: "function", Filename: "p/p.go", Line: 3, Column: 6, Status: "unused", Code: codeUnused},
+			{Name: "Used", Kind: "function", Filename: "p/p.go", Line: 9, Column: 6, Status: "used", Refs: 3},
+		}},
+	})
+}
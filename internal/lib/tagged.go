@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"go/ast"
+	"reflect"
+	"strconv"
+)
+
+// serializationTags are the struct tag keys of the common encodings.
+var serializationTags = []string{"json", "yaml", "xml", "toml", "bson", "msgpack", "protobuf", "mapstructure", "db", "csv", "form"}
+
+// hasSerializationTag reports whether the field base declared on the given 1-based line in filename,
+// relative to the workspace, has a struct tag for one of the serializationTags, e.g. `json:"name"`.
+func (r *runner) hasSerializationTag(filename string, line int, base string) (bool, error) {
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	d := src.decls[declKey{line: line, name: base}]
+	if d == nil {
+		return false, nil
+	}
+	return serializationTagged(d.tag), nil
+}
+
+// serializationTagged reports whether tag, if any, has a key of the serializationTags not set to "-".
+func serializationTagged(tag *ast.BasicLit) bool {
+	if tag == nil {
+		return false
+	}
+	s, err := strconv.Unquote(tag.Value)
+	if err != nil {
+		return false
+	}
+	for _, key := range serializationTags {
+		if v, found := reflect.StructTag(s).Lookup(key); found && v != "-" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"go/build/constraint"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+// testSupportDirRe matches the directory names of the packages conventionally only imported by tests.
+var testSupportDirRe = regexp.MustCompile(`^(testutils?|testhelpers?|testsupport|testenv|testing)$`)
+
+// testSupportTags are the build tags of the files only built for tests, e.g. //go:build integration.
+var testSupportTags = map[string]bool{
+	"test":        true,
+	"testing":     true,
+	"testutil":    true,
+	"testutils":   true,
+	"integration": true,
+	"e2e":         true,
+}
+
+// isTestSupport reports whether filename, relative to the workspace and not a _test.go file, is test
+// infrastructure, whose exported symbols are meant to be used by the tests of other packages:
+// it's in a package directory matching TestPackages or named e.g. testutil, imports "testing",
+// or is only built with a test build tag, e.g. //go:build integration.
+func (r *runner) isTestSupport(filename string) (bool, error) {
+	dir := path.Dir(filename)
+	if testSupportDirRe.MatchString(path.Base(dir)) || r.testPackages.Match(dir) {
+		return true, nil
+	}
+	src, err := r.files.source(filename)
+	if err != nil {
+		return false, err
+	}
+	return src.importsTesting() || src.hasTestBuildTag(), nil
+}
+
+// importsTesting reports whether the file imports the testing package.
+func (f *sourceFile) importsTesting() bool {
+	for _, imp := range f.file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && p == "testing" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTestBuildTag reports whether the //go:build line of the file requires one of testSupportTags.
+func (f *sourceFile) hasTestBuildTag() bool {
+	for _, cg := range f.file.Comments {
+		if cg.Pos() > f.file.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				continue
+			}
+			if requiresTag(expr, testSupportTags) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiresTag reports whether expr can only be satisfied with one of tags set, e.g. e2e && linux.
+func requiresTag(expr constraint.Expr, tags map[string]bool) bool {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		return tags[e.Tag]
+	case *constraint.AndExpr:
+		return requiresTag(e.X, tags) || requiresTag(e.Y, tags)
+	case *constraint.OrExpr:
+		return requiresTag(e.X, tags) && requiresTag(e.Y, tags)
+	}
+	// A negation is satisfied without the tag.
+	return false
+}
@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestIsTestSupport(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"internal/testutil/server.go": "package testutil\n",
+		"internal/fakes/store.go":     "package fakes\n",
+		"p/assert.go":                 "package p\n\nimport \"testing\"\n\nfunc Equal(t testing.TB) {}\n",
+		"p/e2e.go":                    "//go:build e2e && linux\n\npackage p\n",
+		"p/either.go":                 "//go:build e2e || linux\n\npackage p\n",
+		"p/not.go":                    "//go:build !integration\n\npackage p\n",
+		"p/p.go":                      "package p\n\nimport \"net/http/httptest\"\n\nvar _ = httptest.NewServer\n",
+	}
+	for filename, content := range files {
+		filename = filepath.Join(dir, filepath.FromSlash(filename))
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, []byte(content), 0o644), qt.IsNil)
+	}
+
+	testPackages, err := compileGlobs([]string{"internal/fakes"})
+	c.Assert(err, qt.IsNil)
+	r := &runner{files: &fileCache{workspaceDir: dir}, testPackages: testPackages}
+	for filename, want := range map[string]bool{
+		"internal/testutil/server.go": true,
+		"internal/fakes/store.go":     true,
+		"p/assert.go":                 true,
+		"p/e2e.go":                    true,
+		"p/either.go":                 false,
+		"p/not.go":                    false,
+		"p/p.go":                      false,
+	} {
+		testSupport, err := r.isTestSupport(filename)
+		c.Assert(err, qt.IsNil)
+		c.Assert(testSupport, qt.Equals, want, qt.Commentf(filename))
+	}
+}
@@ -0,0 +1,302 @@
+package lib
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// analyzedSymbol is an exported symbol with its references, kept for transitive analysis.
+type analyzedSymbol struct {
+	// workspace is the prefix of filename when analyzing multiple workspaces.
+	workspace string
+	filename  string
+	base      string
+	symbol    *Symbol
+	refs      []*lsp.Location
+
+	// dead is set when the symbol is unused or only used by dead symbols.
+	dead bool
+
+	// usedBy holds the dead symbols referencing this symbol.
+	usedBy []*analyzedSymbol
+
+	// deadTargets holds the package directories of the dead build targets referencing this symbol.
+	deadTargets []string
+
+	// embedded is set for an embedded field. It forwards the fields and methods of the embedded type,
+	// used without referencing the field, so it's never marked dead on its own.
+	embedded bool
+
+	// embeddedBy is the dead symbol, if any, embedding this type, with embeddingPath
+	// the path of embedded fields to it, e.g. Outer.Middle.Inner.
+	embeddedBy    *analyzedSymbol
+	embeddingPath string
+}
+
+func (a *analyzedSymbol) contains(loc *lsp.Location) bool {
+	return a.symbol.Location.URI == loc.URI && rangeContains(a.symbol.Range, loc.Range.Start)
+}
+
+// usedByName returns the name of b, one of the symbols in usedBy, shown in the findings.
+func (a *analyzedSymbol) usedByName(b *analyzedSymbol) string {
+	if b == a.embeddedBy {
+		return a.embeddingPath
+	}
+	return b.symbol.Name
+}
+
+// pathName returns the embedding path of a, if embedded by a dead symbol, else its name.
+func (a *analyzedSymbol) pathName() string {
+	if a.embeddingPath != "" {
+		return a.embeddingPath
+	}
+	return a.symbol.Name
+}
+
+func (a *analyzedSymbol) String() string {
+	return fmt.Sprintf("%s:%d %s", path.Join(a.workspace, a.filename), a.symbol.Location.Range.Start.Line+1, a.symbol.Name)
+}
+
+// markTransitive marks symbols that are only referenced from within dead symbols as dead
+// until no more symbols are found, and returns the newly marked symbols in the order found.
+// References from within the symbol itself (recursion) do not count as usage, so a
+// symbol only referenced by itself is marked dead with an empty usedBy.
+// A type only referenced by embedded fields is marked dead with the symbols embedding it.
+// The symbols containing each reference are looked up once, see symbolIndex, and a symbol is only
+// checked again when one of them is marked dead, so each round only checks the symbols affected.
+func markTransitive(analyzed []*analyzedSymbol) []*analyzedSymbol {
+	idx := newSymbolIndex(analyzed)
+
+	// containers holds, by symbol and reference, the symbols containing the reference, in analyzed order,
+	// or nil for references from within the symbol itself. dependents holds the symbols referencing each symbol.
+	containers := make([][][]int, len(analyzed))
+	dependents := make([][]int, len(analyzed))
+	pending := make([]bool, len(analyzed))
+	for i, a := range analyzed {
+		if a.dead || len(a.refs) == 0 {
+			continue
+		}
+		pending[i] = true
+		containers[i] = make([][]int, len(a.refs))
+		for k, ref := range a.refs {
+			if a.contains(ref) {
+				continue
+			}
+			containers[i][k] = idx.containing(ref)
+			for _, j := range containers[i][k] {
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	// The symbols to check in this round and the next, in analyzed order, as the symbols
+	// after the one marked are checked in the same round.
+	var marked []*analyzedSymbol
+	var round, next intHeap
+	for i := range analyzed {
+		if pending[i] {
+			round = append(round, i)
+		}
+	}
+	for len(round) > 0 {
+		for len(round) > 0 {
+			i := heap.Pop(&round).(int)
+			pending[i] = false
+			a := analyzed[i]
+			var (
+				usedBy        []*analyzedSymbol
+				embeddedBy    *analyzedSymbol
+				embeddingPath string
+			)
+			live := false
+		refs:
+			for k, ref := range a.refs {
+				if a.contains(ref) {
+					continue
+				}
+				for _, j := range containers[i][k] {
+					if b := analyzed[j]; b.dead {
+						usedBy = appendUnique(usedBy, b)
+						if e := idx.embeddedFieldAt(containers[i][k]); e != nil && embeddedBy == nil {
+							embeddedBy, embeddingPath = b, b.pathName()+"."+e.base
+						}
+						continue refs
+					}
+				}
+				live = true
+				break
+			}
+			if live {
+				continue
+			}
+			a.dead = true
+			a.usedBy = usedBy
+			a.embeddedBy, a.embeddingPath = embeddedBy, embeddingPath
+			marked = append(marked, a)
+			for _, d := range dependents[i] {
+				if pending[d] || analyzed[d].dead {
+					continue
+				}
+				pending[d] = true
+				if d > i {
+					heap.Push(&round, d)
+				} else {
+					heap.Push(&next, d)
+				}
+			}
+		}
+		round, next = next, nil
+	}
+
+	return marked
+}
+
+// symbolIndex indexes the analyzed symbols by URI, sorted by the start of their range,
+// to look up the symbols containing a location.
+type symbolIndex struct {
+	analyzed []*analyzedSymbol
+	byURI    map[lsp.DocumentURI][]int
+
+	// maxEnd holds, by URI, the greatest end of the ranges of the symbols up to each in byURI,
+	// bounding the search for the symbols enclosing a location.
+	maxEnd map[lsp.DocumentURI][]lsp.Position
+}
+
+func newSymbolIndex(analyzed []*analyzedSymbol) *symbolIndex {
+	idx := &symbolIndex{analyzed: analyzed, byURI: make(map[lsp.DocumentURI][]int), maxEnd: make(map[lsp.DocumentURI][]lsp.Position)}
+	for i, a := range analyzed {
+		uri := a.symbol.Location.URI
+		idx.byURI[uri] = append(idx.byURI[uri], i)
+	}
+	for uri, list := range idx.byURI {
+		sort.SliceStable(list, func(i, j int) bool {
+			return positionBefore(analyzed[list[i]].symbol.Range.Start, analyzed[list[j]].symbol.Range.Start)
+		})
+		maxEnd := make([]lsp.Position, len(list))
+		for i, j := range list {
+			maxEnd[i] = analyzed[j].symbol.Range.End
+			if i > 0 && positionBefore(maxEnd[i], maxEnd[i-1]) {
+				maxEnd[i] = maxEnd[i-1]
+			}
+		}
+		idx.maxEnd[uri] = maxEnd
+	}
+	return idx
+}
+
+// containing returns the symbols containing loc, as indexes into analyzed, in ascending order.
+func (idx *symbolIndex) containing(loc *lsp.Location) []int {
+	list, maxEnd := idx.byURI[loc.URI], idx.maxEnd[loc.URI]
+	pos := loc.Range.Start
+	// The symbols starting at or before pos.
+	n := sort.Search(len(list), func(i int) bool { return positionBefore(pos, idx.analyzed[list[i]].symbol.Range.Start) })
+	var found []int
+	for i := n - 1; i >= 0 && !positionBefore(maxEnd[i], pos); i-- {
+		if idx.analyzed[list[i]].contains(loc) {
+			found = append(found, list[i])
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+// embeddedFieldAt returns the embedded field among containers, the symbols containing a location, if any.
+func (idx *symbolIndex) embeddedFieldAt(containers []int) *analyzedSymbol {
+	for _, j := range containers {
+		if e := idx.analyzed[j]; e.embedded {
+			return e
+		}
+	}
+	return nil
+}
+
+// intHeap is a min-heap of ints, see container/heap.
+type intHeap []int
+
+func (h intHeap) Len() int           { return len(h) }
+func (h intHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x any)        { *h = append(*h, x.(int)) }
+
+func (h *intHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func positionBefore(a, b lsp.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
+
+func appendUnique(list []*analyzedSymbol, a *analyzedSymbol) []*analyzedSymbol {
+	for _, b := range list {
+		if a == b {
+			return list
+		}
+	}
+	return append(list, a)
+}
+
+func rangeContains(r lsp.Range, pos lsp.Position) bool {
+	if pos.Line < r.Start.Line || pos.Line > r.End.Line {
+		return false
+	}
+	if pos.Line == r.Start.Line && pos.Character < r.Start.Character {
+		return false
+	}
+	if pos.Line == r.End.Line && pos.Character > r.End.Character {
+		return false
+	}
+	return true
+}
+
+// reportTransitive reports the symbols only used by unused code.
+func (r *runner) reportTransitive() error {
+	for _, a := range markTransitive(r.analyzed) {
+		code := codeTransitive
+		if len(a.usedBy) == 0 && len(a.deadTargets) == 0 {
+			code = codeUnused
+		}
+		f := newFinding(a.filename, a.symbol, code)
+		for _, b := range a.usedBy {
+			f.UsedBy = append(f.UsedBy, a.usedByName(b))
+		}
+		f.UsedBy = append(f.UsedBy, a.deadTargets...)
+		if err := r.reportFinding(f, a.base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDOT writes the reference graph of the dead symbols to w in GraphViz DOT format.
+// Unused symbols are drawn in red, symbols only used by unused code in orange.
+func writeDOT(w io.Writer, analyzed []*analyzedSymbol) error {
+	if _, err := fmt.Fprintln(w, "digraph punused {\n\tnode [shape=box];"); err != nil {
+		return err
+	}
+	for _, a := range analyzed {
+		if !a.dead {
+			continue
+		}
+		color := "red"
+		if len(a.usedBy) > 0 {
+			color = "orange"
+		}
+		label := fmt.Sprintf("%s\n%s:%d", a.symbol.Name, path.Join(a.workspace, a.filename), a.symbol.Location.Range.Start.Line+1)
+		fmt.Fprintf(w, "\t%q [label=%q, color=%s];\n", a.String(), label, color)
+	}
+	for _, a := range analyzed {
+		for _, b := range a.usedBy {
+			fmt.Fprintf(w, "\t%q -> %q;\n", b.String(), a.String())
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
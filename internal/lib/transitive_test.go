@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestMarkTransitive(t *testing.T) {
+	c := qt.New(t)
+
+	const uri = "file:///p/code.go"
+
+	// sym creates a symbol declared on the lines [start, end].
+	sym := func(name string, start, end int, refs ...int) *analyzedSymbol {
+		a := &analyzedSymbol{
+			filename: "code.go",
+			base:     name,
+			symbol: &Symbol{
+				Name:     name,
+				Location: lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: start, Character: 5}}},
+				Range:    lsp.Range{Start: lsp.Position{Line: start}, End: lsp.Position{Line: end, Character: 1}},
+			},
+			dead: len(refs) == 0,
+		}
+		for _, line := range refs {
+			a.refs = append(a.refs, &lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: line, Character: 2}}})
+		}
+		return a
+	}
+
+	unused := sym("Unused", 0, 3)
+	onlyUsedByUnused := sym("OnlyUsedByUnused", 5, 8, 1)
+	chained := sym("Chained", 10, 12, 6)
+	recursive := sym("Recursive", 14, 16, 15)
+	live := sym("Live", 20, 22, 40)
+	usedByLiveAndUnused := sym("UsedByLiveAndUnused", 30, 32, 2, 21)
+
+	analyzed := []*analyzedSymbol{chained, unused, onlyUsedByUnused, recursive, live, usedByLiveAndUnused}
+
+	names := func(list []*analyzedSymbol) []string {
+		var s []string
+		for _, a := range list {
+			s = append(s, a.base)
+		}
+		return s
+	}
+
+	c.Assert(names(markTransitive(analyzed)), qt.DeepEquals, []string{"OnlyUsedByUnused", "Recursive", "Chained"})
+	c.Assert(names(chained.usedBy), qt.DeepEquals, []string{"OnlyUsedByUnused"})
+	c.Assert(recursive.usedBy, qt.HasLen, 0)
+	c.Assert(live.dead, qt.IsFalse)
+	c.Assert(usedByLiveAndUnused.dead, qt.IsFalse)
+
+	var buff bytes.Buffer
+	c.Assert(writeDOT(&buff, analyzed), qt.IsNil)
+	c.Assert(buff.String(), qt.Contains, `"code.go:6 OnlyUsedByUnused" -> "code.go:11 Chained";`)
+	c.Assert(strings.Count(buff.String(), "->"), qt.Equals, 2)
+}
+
+func TestMarkTransitiveChain(t *testing.T) {
+	c := qt.New(t)
+
+	const uri = "file:///p/code.go"
+
+	// A long chain, each symbol only referenced from the next, declared before it, with the last unused.
+	const n = 20000
+	analyzed := make([]*analyzedSymbol, n)
+	for i := range analyzed {
+		analyzed[i] = &analyzedSymbol{
+			base: fmt.Sprint("F", i),
+			symbol: &Symbol{
+				Location: lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: 2 * i, Character: 5}}},
+				Range:    lsp.Range{Start: lsp.Position{Line: 2 * i}, End: lsp.Position{Line: 2*i + 1, Character: 1}},
+			},
+			dead: i == n-1,
+		}
+		if i < n-1 {
+			analyzed[i].refs = []*lsp.Location{{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: 2*i + 2, Character: 2}}}}
+		}
+	}
+
+	marked := markTransitive(analyzed)
+	c.Assert(marked, qt.HasLen, n-1)
+	c.Assert(marked[0].base, qt.Equals, fmt.Sprint("F", n-2))
+	c.Assert(analyzed[0].usedBy[0], qt.Equals, analyzed[1])
+}
+
+func TestMarkTransitiveEmbedding(t *testing.T) {
+	c := qt.New(t)
+
+	const uri = "file:///p/code.go"
+
+	sym := func(name string, start, end int, refs ...int) *analyzedSymbol {
+		a := &analyzedSymbol{
+			filename: "code.go",
+			base:     name,
+			symbol: &Symbol{
+				Name:     name,
+				Location: lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: start, Character: 5}}},
+				Range:    lsp.Range{Start: lsp.Position{Line: start}, End: lsp.Position{Line: end, Character: 1}},
+			},
+			dead: len(refs) == 0,
+		}
+		for _, line := range refs {
+			a.refs = append(a.refs, &lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: line, Character: 1}}})
+		}
+		return a
+	}
+	embedded := func(name string, line int) *analyzedSymbol {
+		a := sym(name, line, line)
+		a.dead, a.embedded = false, true
+		return a
+	}
+
+	// type Outer struct { Middle }, unused.
+	outer, outerMiddle := sym("Outer", 0, 2), embedded("Middle", 1)
+	// type Middle struct { Inner }, only embedded by Outer.
+	middle, middleInner := sym("Middle", 4, 6, 1), embedded("Inner", 5)
+	// type Inner struct{}, only embedded by Middle.
+	inner := sym("Inner", 8, 8, 5)
+	// type Live struct { Base }, used.
+	live, liveBase := sym("Live", 10, 12, 40), embedded("Base", 11)
+	// type Base struct{}, only embedded by the live Live.
+	base := sym("Base", 14, 14, 11)
+
+	analyzed := []*analyzedSymbol{outer, outerMiddle, middle, middleInner, inner, live, liveBase, base}
+	marked := markTransitive(analyzed)
+	c.Assert(marked, qt.HasLen, 2)
+	c.Assert(marked[0] == middle && marked[1] == inner, qt.IsTrue)
+	c.Assert(middle.usedByName(middle.usedBy[0]), qt.Equals, "Outer.Middle")
+	c.Assert(inner.usedByName(inner.usedBy[0]), qt.Equals, "Outer.Middle.Inner")
+	c.Assert(outerMiddle.dead, qt.IsFalse)
+	c.Assert(base.dead, qt.IsFalse)
+}
+
+func TestWithoutDeadTargets(t *testing.T) {
+	c := qt.New(t)
+
+	deadTargets, err := compileGlobs([]string{"cmd/legacy-*"})
+	c.Assert(err, qt.IsNil)
+	r := &runner{cfg: RunConfig{WorkspaceDir: "/p"}, deadTargets: deadTargets}
+
+	loc := func(filename string) *lsp.Location {
+		return &lsp.Location{URI: lsp.DocumentURI("file:///p/" + filename)}
+	}
+	refs := []*lsp.Location{loc("cmd/legacy-b/main.go"), loc("cmd/tool/main.go"), loc("cmd/legacy-a/main.go"), loc("cmd/legacy-b/util.go")}
+
+	live, targets := r.withoutDeadTargets("file:///p/lib/lib.go", refs)
+	c.Assert(live, qt.DeepEquals, []*lsp.Location{loc("cmd/tool/main.go")})
+	c.Assert(targets, qt.DeepEquals, []string{"cmd/legacy-a", "cmd/legacy-b"})
+
+	// Symbols in a dead target are left to the other checks.
+	live, targets = r.withoutDeadTargets("file:///p/cmd/legacy-a/util.go", refs)
+	c.Assert(live, qt.HasLen, 4)
+	c.Assert(targets, qt.IsNil)
+}
@@ -0,0 +1,318 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// triagePreviewLines is the maximum number of lines of a declaration previewed.
+const triagePreviewLines = 15
+
+// Triage analyzes the workspace like Run, then walks through the findings interactively, reading an action
+// per finding from in and writing the findings, with a preview of the declaration, and the prompts to cfg.Out:
+//
+//   - s [reason] suppresses the finding with a //punused:ignore directive on the declaration,
+//   - k keeps the symbol, adding its name to the keep list of the config file in the workspace root,
+//   - f fixes it as -fix would, moving a symbol used in test only to a _test.go file or removing an unused type,
+//   - e opens the declaration in $EDITOR,
+//   - n, or an empty line, goes to the next finding and q quits.
+//
+// The decisions are written to the files right away.
+func Triage(ctx context.Context, cfg RunConfig, in io.Reader) error {
+	t, err := newTriage(ctx, cfg, in)
+	if err != nil {
+		return err
+	}
+	return t.run()
+}
+
+// newTriage analyzes the workspace of cfg for a walk through the findings reading the actions from in.
+func newTriage(ctx context.Context, cfg RunConfig, in io.Reader) (*triage, error) {
+	if err := cfg.init(); err != nil {
+		return nil, err
+	}
+	if len(cfg.WorkspaceDirs) > 0 || len(cfg.BuildConfigs) > 1 {
+		return nil, errors.New("triage does not support multiple workspaces or build configurations")
+	}
+	if cfg.Patch != nil || cfg.Script != nil {
+		return nil, errors.New("triage writes the files, it cannot be combined with Patch or Script")
+	}
+	rep, err := newReporter(io.Discard, formatText, textOptions{})
+	if err != nil {
+		return nil, err
+	}
+	r, err := analyzeWorkspaces(ctx, cfg, rep, []string{cfg.WorkspaceDir})
+	if err != nil {
+		return nil, err
+	}
+	return &triage{r: r, dir: cfg.WorkspaceDir, in: bufio.NewScanner(in), out: cfg.Out, openEditor: openEditor}, nil
+}
+
+type triage struct {
+	r   *runner
+	dir string
+	in  *bufio.Scanner
+	out io.Writer
+
+	openEditor func(filename string, line int) error
+
+	suppressed, kept, fixed int
+}
+
+func (t *triage) run() error {
+	findings := t.r.findings
+	if len(findings) == 0 {
+		fmt.Fprintln(t.out, "no findings")
+		return nil
+	}
+findings:
+	for i, f := range findings {
+		for {
+			line, err := t.locate(f)
+			if err != nil {
+				return err
+			}
+			if line == 0 {
+				fmt.Fprintf(t.out, "[%d/%d] %s is no longer declared in %s, skipped\n", i+1, len(findings), f.Name, f.Filename)
+				continue findings
+			}
+			f.Line = line
+
+			fmt.Fprintf(t.out, "\n[%d/%d] ", i+1, len(findings))
+			t.r.outputFinding(f).Print(t.out, false)
+			if err := t.preview(f); err != nil {
+				return err
+			}
+			fmt.Fprint(t.out, "[s]uppress [k]eep [f]ix [e]dit [n]ext [q]uit: ")
+			if !t.in.Scan() {
+				fmt.Fprintln(t.out)
+				break findings
+			}
+			action, arg, _ := strings.Cut(strings.TrimSpace(t.in.Text()), " ")
+			switch action {
+			case "", "n":
+				continue findings
+			case "q":
+				break findings
+			case "s":
+				if err := t.suppress(f, strings.TrimSpace(arg)); err != nil {
+					return err
+				}
+				continue findings
+			case "k":
+				if err := t.keep(f); err != nil {
+					return err
+				}
+				continue findings
+			case "f":
+				fixed, err := t.fix(f)
+				if err != nil {
+					fmt.Fprintf(t.out, "fix failed: %s\n", err)
+				}
+				if fixed {
+					continue findings
+				}
+			case "e":
+				if err := t.openEditor(filepath.Join(t.dir, f.Filename), f.Line); err != nil {
+					fmt.Fprintf(t.out, "failed to open the editor: %s\n", err)
+				}
+			default:
+				fmt.Fprintf(t.out, "unknown action %q\n", action)
+			}
+		}
+	}
+	fmt.Fprintf(t.out, "%d suppressed, %d kept, %d fixed\n", t.suppressed, t.kept, t.fixed)
+	return nil
+}
+
+// locate returns the 1-based line the symbol of f is declared on now, as the earlier actions may have moved it:
+// the declaration of the symbol closest to f.Line, or 0 if it's no longer declared.
+func (t *triage) locate(f Finding) (int, error) {
+	src, err := parseSourceFile(filepath.Join(t.dir, f.Filename))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	base, line, dist := symbolBase(f.Name), 0, -1
+	for key := range src.decls {
+		if key.name != base {
+			continue
+		}
+		d := key.line - f.Line
+		if d < 0 {
+			d = -d
+		}
+		if dist < 0 || d < dist {
+			line, dist = key.line, d
+		}
+	}
+	return line, nil
+}
+
+func (t *triage) preview(f Finding) error {
+	src, err := parseSourceFile(filepath.Join(t.dir, f.Filename))
+	if err != nil {
+		return err
+	}
+	snippet := src.snippet(f.Line, symbolBase(f.Name), triagePreviewLines)
+	if snippet == "" {
+		return nil
+	}
+	start, _ := src.declRange(f.Line, symbolBase(f.Name))
+	for i, line := range strings.Split(snippet, "\n") {
+		fmt.Fprintf(t.out, "%5d | %s\n", start+i, line)
+	}
+	return nil
+}
+
+func (t *triage) suppress(f Finding, reason string) error {
+	filename := filepath.Join(t.dir, f.Filename)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	directive := "//punused:ignore " + prefixedCode(t.r.cfg.CodePrefix, f.Code)
+	if reason != "" {
+		directive += " " + reason
+	}
+	if err := os.WriteFile(filename, insertLineBefore(content, f.Line, directive), 0o666); err != nil {
+		return err
+	}
+	t.suppressed++
+	fmt.Fprintf(t.out, "added %s to %s\n", directive, f.Filename)
+	return nil
+}
+
+// insertLineBefore returns content with text inserted as a line before the 1-based line, with the same indentation,
+// i.e. at the end of the doc comment of the declaration on it, if any.
+func insertLineBefore(content []byte, line int, text string) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return content
+	}
+	target := lines[line-1]
+	indent := target[:len(target)-len(bytes.TrimLeft(target, " \t"))]
+	var b bytes.Buffer
+	for i, l := range lines {
+		if i == line-1 {
+			b.Write(indent)
+			b.WriteString(text)
+			b.WriteByte('\n')
+		}
+		b.Write(l)
+	}
+	return b.Bytes()
+}
+
+func (t *triage) keep(f Finding) error {
+	filename := filepath.Join(t.dir, ConfigFilename)
+	expr := "^" + regexp.QuoteMeta(f.Name) + "$"
+	if err := addKeep(filename, expr); err != nil {
+		return err
+	}
+	t.kept++
+	fmt.Fprintf(t.out, "added %s to the keep list in %s\n", expr, ConfigFilename)
+	return nil
+}
+
+// addKeep adds expr to the keep list of the config file filename, created if it does not exist.
+// The comments and the order of the other settings are preserved.
+func addKeep(filename, expr string) error {
+	var doc yaml.Node
+	b, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse %s: not a mapping", filename)
+	}
+	var keep *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "keep" {
+			keep = root.Content[i+1]
+		}
+	}
+	if keep == nil {
+		keep = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "keep"}, keep)
+	}
+	if keep.Kind != yaml.SequenceNode {
+		// E.g. an empty keep:.
+		*keep = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	}
+	for _, n := range keep.Content {
+		if n.Value == expr {
+			return nil
+		}
+	}
+	keep.Content = append(keep.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: expr, Style: yaml.SingleQuotedStyle})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o666)
+}
+
+// fix applies the fix for f, if any, and reports whether the code was changed.
+func (t *triage) fix(f Finding) (bool, error) {
+	r := t.r
+	findings, fixReport := r.findings, r.fixReport
+	defer func() { r.findings, r.fixReport = findings, fixReport }()
+	r.findings, r.fixReport = []Finding{f}, FixReport{}
+	err := r.fix(t.dir, 1)
+	if len(r.fixReport.Edits) == 0 {
+		if err == nil {
+			fmt.Fprintln(t.out, "nothing to fix, only symbols used in test only and unused types are fixed")
+		}
+		return false, err
+	}
+	t.fixed++
+	for _, e := range r.fixReport.Edits {
+		if e.Action == fixActionMoved {
+			fmt.Fprintf(t.out, "moved %s to %s\n", e.Symbol, e.Target)
+		} else {
+			fmt.Fprintf(t.out, "removed %s from %s\n", e.Symbol, e.Filename)
+		}
+	}
+	return true, err
+}
+
+// openEditor opens filename at the 1-based line in $EDITOR, vi if not set.
+func openEditor(filename string, line int) error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	args = append(args, fmt.Sprintf("+%d", line), filename)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
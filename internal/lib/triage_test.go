@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestTriage(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	const code = `package p
+
+// Dead is unused.
+func Dead() {}
+
+// Gone is unused.
+func Gone() {}
+
+type T struct {
+	Field int
+}
+`
+	c.Assert(os.MkdirAll(filepath.Join(dir, "p"), 0o777), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p", "p.go"), []byte(code), 0o666), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, ConfigFilename), []byte("# Settings.\nfail-on: [EU1002]\n"), 0o666), qt.IsNil)
+
+	r := &runner{cfg: RunConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}}
+	r.findings = []Finding{
+		{Filename: "p/p.go", Line: 4, Column: 6, Kind: "function", Name: "Dead", Code: codeUnused},
+		{Filename: "p/p.go", Line: 7, Column: 6, Kind: "function", Name: "Gone", Code: codeUnused},
+		{Filename: "p/p.go", Line: 10, Column: 2, Kind: "field", Name: "Field", Code: codeUnused},
+	}
+	var out bytes.Buffer
+	var edited []int
+	tr := &triage{
+		r:   r,
+		dir: dir,
+		in:  newLineScanner("s plugin API\ne\nk\nq\n"),
+		out: &out,
+		openEditor: func(filename string, line int) error {
+			edited = append(edited, line)
+			return nil
+		},
+	}
+	c.Assert(tr.run(), qt.IsNil)
+
+	b, err := os.ReadFile(filepath.Join(dir, "p", "p.go"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Contains, "// Dead is unused.\n//punused:ignore EU1002 plugin API\nfunc Dead() {}\n")
+	// Gone was moved a line down by the suppression of Dead.
+	c.Assert(edited, qt.DeepEquals, []int{8})
+	c.Assert(out.String(), qt.Contains, "[2/3] p/p.go:8:6 function Gone is unused (EU1002)\n    7 | // Gone is unused.\n    8 | func Gone() {}\n")
+	c.Assert(out.String(), qt.Contains, "1 suppressed, 1 kept, 0 fixed\n")
+
+	conf, err := LoadConfig(filepath.Join(dir, ConfigFilename))
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.Keep, qt.DeepEquals, []string{"^Gone$"})
+	c.Assert(conf.FailOn, qt.DeepEquals, []string{"EU1002"})
+	b, err = os.ReadFile(filepath.Join(dir, ConfigFilename))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Contains, "# Settings.")
+}
+
+func TestInsertLineBefore(t *testing.T) {
+	c := qt.New(t)
+
+	content := []byte("type T struct {\n\t// A is a.\n\tA int\n}\n")
+	c.Assert(string(insertLineBefore(content, 3, "//punused:ignore")), qt.Equals, "type T struct {\n\t// A is a.\n\t//punused:ignore\n\tA int\n}\n")
+	c.Assert(string(insertLineBefore(content, 10, "//punused:ignore")), qt.Equals, string(content))
+}
+
+func TestAddKeep(t *testing.T) {
+	c := qt.New(t)
+
+	filename := filepath.Join(t.TempDir(), ConfigFilename)
+	c.Assert(addKeep(filename, "^Dead$"), qt.IsNil)
+	c.Assert(addKeep(filename, `^\(T\)\.M$`), qt.IsNil)
+	c.Assert(addKeep(filename, "^Dead$"), qt.IsNil)
+	conf, err := LoadConfig(filename)
+	c.Assert(err, qt.IsNil)
+	c.Assert(conf.Keep, qt.DeepEquals, []string{"^Dead$", `^\(T\)\.M$`})
+}
+
+func newLineScanner(s string) *bufio.Scanner {
+	return bufio.NewScanner(strings.NewReader(s))
+}
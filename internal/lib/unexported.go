@@ -0,0 +1,26 @@
+package lib
+
+import (
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// analyzes reports whether the symbol s, named base, is analyzed: It's exported or, with IncludeUnexported,
+// an unexported function, type, constant or variable other than main and init, which are invoked implicitly.
+func (r *runner) analyzes(s *Symbol, base string) bool {
+	if isExported(base) {
+		return true
+	}
+	if !r.cfg.IncludeUnexported {
+		return false
+	}
+	switch s.Kind {
+	case lsp.SKMethod, lsp.SKField:
+		// Typically implements an interface or is set via reflection.
+		return false
+	}
+	switch base {
+	case "main", "init", "_":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// vendorDir is the directory, relative to the workspace, holding the vendored packages.
+const vendorDir = "vendor"
+
+// vendoredModule is a module listed in vendor/modules.txt.
+type vendoredModule struct {
+	Path    string
+	Version string
+
+	// Replacement is the module path or directory, e.g. ../fork, replacing it, if any.
+	Replacement string
+
+	// Packages are the import paths of the packages vendored.
+	Packages []string
+}
+
+// replacedLocally reports whether m is replaced by a directory, i.e. a fork in the tree.
+func (m vendoredModule) replacedLocally() bool {
+	r := m.Replacement
+	return strings.HasPrefix(r, "./") || strings.HasPrefix(r, "../") || filepath.IsAbs(r)
+}
+
+// readVendoredModules parses the modules.txt file written by go mod vendor.
+func readVendoredModules(b []byte) []vendoredModule {
+	var (
+		modules []vendoredModule
+		current *vendoredModule
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "## "):
+			// Blank lines and annotations, e.g. ## explicit; go 1.21.
+		case strings.HasPrefix(line, "# "):
+			mod, repl, _ := strings.Cut(strings.TrimPrefix(line, "# "), "=>")
+			fields := strings.Fields(mod)
+			if len(fields) == 0 {
+				current = nil
+				continue
+			}
+			m := vendoredModule{Path: fields[0]}
+			if len(fields) > 1 {
+				m.Version = fields[1]
+			}
+			if fields := strings.Fields(repl); len(fields) > 0 {
+				m.Replacement = fields[0]
+			}
+			modules = append(modules, m)
+			current = &modules[len(modules)-1]
+		case current != nil:
+			current.Packages = append(current.Packages, line)
+		}
+	}
+	return modules
+}
+
+// patchedVendorDirs returns the directories, relative to the workspace, of the vendored packages patched locally:
+// those of the modules whose path matches forks, that are replaced by a directory in go.mod,
+// or whose vendored files differ from the copy in the module cache.
+func patchedVendorDirs(cfg RunConfig, forks globs) (map[string]bool, error) {
+	b, err := os.ReadFile(filepath.Join(cfg.WorkspaceDir, vendorDir, "modules.txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	modCache := goModCache(cfg.WorkspaceDir)
+	dirs := make(map[string]bool)
+	for _, m := range readVendoredModules(b) {
+		reason := ""
+		switch {
+		case forks.Match(m.Path):
+			reason = "listed as a fork"
+		case m.replacedLocally():
+			reason = "replaced by " + m.Replacement
+		default:
+			patched, err := m.differsFromCache(cfg.WorkspaceDir, modCache)
+			if err != nil {
+				return nil, err
+			}
+			if patched {
+				reason = "differs from the module cache"
+			}
+		}
+		if reason == "" {
+			continue
+		}
+		cfg.Logger.Info("including patched vendored module", "module", m.Path, "reason", reason)
+		for _, pkg := range m.Packages {
+			dirs[path.Join(vendorDir, pkg)] = true
+		}
+	}
+	return dirs, nil
+}
+
+// differsFromCache reports whether any Go file vendored for m differs from, or is missing in, the module cache.
+// Modules not in the cache, e.g. never downloaded, are considered unpatched.
+func (m vendoredModule) differsFromCache(workspaceDir, modCache string) (bool, error) {
+	if modCache == "" || m.Version == "" || m.Replacement != "" {
+		return false, nil
+	}
+	moduleDir := filepath.Join(modCache, escapeModulePath(m.Path)+"@"+m.Version)
+	if _, err := os.Stat(moduleDir); err != nil {
+		return false, nil
+	}
+	for _, pkg := range m.Packages {
+		rel := strings.TrimPrefix(strings.TrimPrefix(pkg, m.Path), "/")
+		vendored := filepath.Join(workspaceDir, vendorDir, filepath.FromSlash(pkg))
+		entries, err := os.ReadDir(vendored)
+		if err != nil {
+			return false, err
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(vendored, e.Name()))
+			if err != nil {
+				return false, err
+			}
+			orig, err := os.ReadFile(filepath.Join(moduleDir, filepath.FromSlash(rel), e.Name()))
+			if err != nil || !bytes.Equal(b, orig) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// escapeModulePath returns the module path as stored in the module cache, with each upper-case letter
+// replaced by an exclamation mark followed by the letter's lower-case, e.g. github.com/!burnt!sushi/toml.
+func escapeModulePath(p string) string {
+	var sb strings.Builder
+	for _, r := range p {
+		if unicode.IsUpper(r) {
+			sb.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// goModCache returns the module cache directory used in dir, or an empty string if unknown.
+func goModCache(dir string) string {
+	cmd := exec.Command("go", "env", "GOMODCACHE")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,29 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReadVendoredModules(t *testing.T) {
+	c := qt.New(t)
+
+	modules := readVendoredModules([]byte(`# github.com/BurntSushi/toml v1.3.2
+## explicit; go 1.16
+github.com/BurntSushi/toml
+github.com/BurntSushi/toml/internal
+# example.com/lib v1.0.0 => ./forks/lib
+## explicit
+example.com/lib
+# example.com/lib => ./forks/lib
+`))
+	c.Assert(modules, qt.DeepEquals, []vendoredModule{
+		{Path: "github.com/BurntSushi/toml", Version: "v1.3.2", Packages: []string{"github.com/BurntSushi/toml", "github.com/BurntSushi/toml/internal"}},
+		{Path: "example.com/lib", Version: "v1.0.0", Replacement: "./forks/lib", Packages: []string{"example.com/lib"}},
+		{Path: "example.com/lib", Replacement: "./forks/lib"},
+	})
+	c.Assert(modules[0].replacedLocally(), qt.IsFalse)
+	c.Assert(modules[1].replacedLocally(), qt.IsTrue)
+	c.Assert(escapeModulePath(modules[0].Path), qt.Equals, "github.com/!burnt!sushi/toml")
+}
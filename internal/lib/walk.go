@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkTree walks the file tree rooted at root as filepath.Walk, in lexical order, but following the symbolic links
+// to directories if followSymlinks is set, see RunConfig.FollowSymlinks. Each directory is then walked once,
+// whatever the links to it, e.g. a link to a parent, and the paths passed to fn are those through the links.
+func walkTree(root string, followSymlinks bool, fn filepath.WalkFunc) error {
+	if !followSymlinks {
+		return filepath.Walk(root, fn)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkFollowing(root, info, fn, make(map[string]bool))
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkFollowing walks path, with info as returned by os.Stat, i.e. of the target of a link,
+// skipping the directories in visited, by their real path.
+func walkFollowing(path string, info fs.FileInfo, fn filepath.WalkFunc, visited map[string]bool) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+	if visited[real] {
+		return nil
+	}
+	visited[real] = true
+
+	if err := fn(path, info, nil); err != nil {
+		if errors.Is(err, filepath.SkipDir) {
+			return nil
+		}
+		return err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+	for _, entry := range entries {
+		filename := filepath.Join(path, entry.Name())
+		info, err := os.Stat(filename)
+		if err != nil {
+			// E.g. a broken link.
+			if err := fn(filename, nil, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+			continue
+		}
+		if err := walkFollowing(filename, info, fn, visited); err != nil {
+			if !info.IsDir() && errors.Is(err, filepath.SkipDir) {
+				// Skips the rest of the directory, as filepath.Walk.
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// isModuleRoot reports whether the directory dir has a go.mod file of its own.
+func isModuleRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil
+}
+
+// workspaceModules returns the directories of the modules used by the go.work file in dir, if any,
+// relative to dir, e.g. tools for use ./tools, which belong to the workspace, see RunConfig.IncludeSubmodules.
+func workspaceModules(dir string) map[string]bool {
+	b, err := os.ReadFile(filepath.Join(dir, "go.work"))
+	if err != nil || os.Getenv("GOWORK") == "off" {
+		return nil
+	}
+	modules := make(map[string]bool)
+	add := func(p string) {
+		if p = filepath.ToSlash(filepath.Clean(strings.Trim(p, "\"`"))); p != "." {
+			modules[p] = true
+		}
+	}
+	var block bool
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case block && fields[0] == ")":
+			block = false
+		case block:
+			add(fields[0])
+		case fields[0] == "use(" || fields[0] == "use" && len(fields) > 1 && fields[1] == "(":
+			block = true
+		case fields[0] == "use" && len(fields) > 1:
+			add(fields[1])
+		}
+	}
+	return modules
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWalkTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links")
+	}
+	c := qt.New(t)
+
+	dir := c.TempDir()
+	shared := c.TempDir()
+	for _, filename := range []string{"a.go", "p/b.go", "skipped/c.go"} {
+		filename = filepath.Join(dir, filename)
+		c.Assert(os.MkdirAll(filepath.Dir(filename), 0o755), qt.IsNil)
+		c.Assert(os.WriteFile(filename, nil, 0o644), qt.IsNil)
+	}
+	c.Assert(os.WriteFile(filepath.Join(shared, "d.go"), nil, 0o644), qt.IsNil)
+	c.Assert(os.Symlink(shared, filepath.Join(dir, "linked")), qt.IsNil)
+	// A cycle.
+	c.Assert(os.Symlink(dir, filepath.Join(dir, "p", "parent")), qt.IsNil)
+	c.Assert(os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken")), qt.IsNil)
+
+	walk := func(followSymlinks bool) []string {
+		var files []string
+		err := walkTree(dir, followSymlinks, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if info.Name() == "skipped" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		c.Assert(err, qt.IsNil)
+		return files
+	}
+
+	c.Assert(walk(false), qt.DeepEquals, []string{"a.go", "broken", "linked", "p/b.go", "p/parent"})
+	c.Assert(walk(true), qt.DeepEquals, []string{"a.go", "linked/d.go", "p/b.go"})
+}
+
+func TestWorkspaceModules(t *testing.T) {
+	c := qt.New(t)
+
+	dir := c.TempDir()
+	c.Assert(workspaceModules(dir), qt.IsNil)
+
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.work"), []byte(`go 1.22
+
+use .
+use ./tools // The tools.
+
+use (
+	./cmd/app
+	"./lib"
+	../other
+)
+`), 0o644), qt.IsNil)
+	c.Assert(workspaceModules(dir), qt.DeepEquals, map[string]bool{"tools": true, "cmd/app": true, "lib": true, "../other": true})
+}
@@ -0,0 +1,339 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// watchDebounce is the time waited for more changes before re-analyzing, e.g. while a branch is checked out.
+const watchDebounce = 300 * time.Millisecond
+
+// Watch analyzes the workspace, writing the findings to cfg.Out, and keeps gopls running, re-analyzing the files
+// changed, and those declaring the symbols they reference or referenced, whenever Go files in the workspace change,
+// until ctx is done. Each round writes the findings new since the previous round in Format, and logs those resolved.
+// A change to go.mod, go.sum or go.work re-analyzes the whole workspace.
+func Watch(ctx context.Context, cfg RunConfig) error {
+	if err := cfg.init(); err != nil {
+		return err
+	}
+	if err := cfg.validateWatch(); err != nil {
+		return err
+	}
+	if err := checkModule(cfg.WorkspaceDir); err != nil {
+		return err
+	}
+
+	w := &watcher{
+		ctx:         ctx,
+		cfg:         cfg,
+		findings:    make(map[string][]Finding),
+		referencing: make(map[string]map[string]bool),
+	}
+	if len(cfg.BuildConfigs) == 1 {
+		w.build = &cfg.BuildConfigs[0]
+	}
+
+	r, err := newRunner(ctx, cfg, w.build)
+	if err != nil {
+		return err
+	}
+	r.build = w.build
+	if err := r.startGopls(); err != nil {
+		return err
+	}
+	defer r.Stop()
+	// Watch notifies gopls of the changes, see validateWatch.
+	w.client = r.client.(*GoplsClient)
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+	if err := w.addDirs(fsw, cfg.WorkspaceDir); err != nil {
+		return err
+	}
+
+	if err := w.round(nil); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	var (
+		changed = make(map[string]bool)
+		all     bool
+		fire    <-chan time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			cfg.Logger.Warn("failed to watch the workspace", "error", err)
+		case ev, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if err := w.addDirs(fsw, ev.Name); err != nil {
+						cfg.Logger.Warn("failed to watch the directory", "dir", ev.Name, "error", err)
+					}
+					continue
+				}
+			}
+			rel, err := filepath.Rel(cfg.WorkspaceDir, ev.Name)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			switch {
+			case rel == "go.mod" || rel == "go.sum" || rel == "go.work":
+				all = true
+			case strings.HasSuffix(rel, ".go"):
+			default:
+				continue
+			}
+			changed[rel] = true
+			fire = time.After(watchDebounce)
+		case <-fire:
+			cfg.Logger.Debug("workspace changed", "files", len(changed))
+			if err := w.notify(changed); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("failed to notify gopls of the changes: %w", err)
+			}
+			only := w.affected(changed)
+			if all {
+				only = nil
+			}
+			if err := w.round(only); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				// Typically transient, e.g. a file saved half written.
+				cfg.Logger.Error("failed to analyze the changes", "error", err)
+			}
+			changed, all, fire = make(map[string]bool), false, nil
+		}
+	}
+}
+
+// validateWatch checks that cfg can be used with Watch, whose rounds only analyze some of the files.
+func (cfg RunConfig) validateWatch() error {
+	if cfg.Fix || cfg.Rename || cfg.Patch != nil || cfg.Resume != "" || cfg.Base != "" || len(cfg.WorkspaceDirs) > 0 || len(cfg.BuildConfigs) > 1 || cfg.GoVersion != "" || cfg.ReadOnly || len(cfg.Overlay) > 0 {
+		return fmt.Errorf("Watch cannot be combined with Fix, Rename, Patch, Resume, Base, WorkspaceDirs, multiple BuildConfigs, GoVersion, ReadOnly or Overlay")
+	}
+	if cfg.Backend == BackendPackages {
+		return fmt.Errorf("Watch requires the gopls backend")
+	}
+	if cfg.Transitive || cfg.Reexports || cfg.DuplicateThreshold > 0 || cfg.CollapseAccessors || cfg.Sample > 0 {
+		return fmt.Errorf("Watch cannot be combined with Transitive, Reexports, DuplicateThreshold, CollapseAccessors or Sample, whose findings need the symbols of all the files")
+	}
+	return nil
+}
+
+// watcher holds the state of Watch between the rounds.
+type watcher struct {
+	ctx    context.Context
+	cfg    RunConfig
+	build  *BuildConfig
+	client *GoplsClient
+
+	// findings holds the findings of the last round analyzing each file, by filename.
+	findings map[string][]Finding
+
+	// referencing maps the files analyzed to the files referencing the symbols they declare.
+	referencing map[string]map[string]bool
+}
+
+// addDirs watches dir and the directories beneath it, but hidden directories and vendor, unless IncludeVendored.
+func (w *watcher) addDirs(fsw *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed since.
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != w.cfg.WorkspaceDir && (strings.HasPrefix(d.Name(), ".") || d.Name() == vendorDir && !w.cfg.IncludeVendored) {
+			return filepath.SkipDir
+		}
+		return fsw.Add(path)
+	})
+}
+
+// notify tells gopls about the files changed, relative to the workspace.
+func (w *watcher) notify(changed map[string]bool) error {
+	var params lsp.DidChangeWatchedFilesParams
+	for filename := range changed {
+		typ := lsp.Changed
+		if _, err := os.Stat(filepath.Join(w.cfg.WorkspaceDir, filepath.FromSlash(filename))); errors.Is(err, fs.ErrNotExist) {
+			typ = lsp.Deleted
+		}
+		params.Changes = append(params.Changes, lsp.FileEvent{URI: lsp.DocumentURI(w.client.documentURI(filename)), Type: int(typ)})
+	}
+	return w.client.Call(w.ctx, "workspace/didChangeWatchedFiles", params, nil)
+}
+
+// affected returns the files to analyze again after the files changed: the files changed, the files declaring symbols
+// they referenced, which may be unused now, and the files with findings for symbols named in them, which may be used now.
+func (w *watcher) affected(changed map[string]bool) map[string]bool {
+	only := make(map[string]bool)
+	names := make(map[string]bool)
+	for filename := range changed {
+		only[filename] = true
+		b, err := os.ReadFile(filepath.Join(w.cfg.WorkspaceDir, filepath.FromSlash(filename)))
+		if err != nil {
+			// Removed.
+			continue
+		}
+		for _, name := range identRe.FindAll(b, -1) {
+			names[string(name)] = true
+		}
+	}
+	for filename, referencing := range w.referencing {
+		for ref := range changed {
+			if referencing[ref] {
+				only[filename] = true
+				break
+			}
+		}
+	}
+	for filename, findings := range w.findings {
+		for _, f := range findings {
+			if names[f.Name[strings.LastIndex(f.Name, ".")+1:]] {
+				only[filename] = true
+				break
+			}
+		}
+	}
+	return only
+}
+
+// round analyzes the files in only, relative to the workspace, or all of them if nil, sharing the gopls client,
+// and writes the findings new since the last round analyzing their files to cfg.Out.
+func (w *watcher) round(only map[string]bool) error {
+	start := time.Now()
+	r, err := newRunner(w.ctx, w.cfg, w.build)
+	if err != nil {
+		return err
+	}
+	r.client, r.root, r.build = w.client, w.cfg.WorkspaceDir, w.build
+	r.only, r.referencing = only, make(map[string]map[string]bool)
+	if err := r.Walk(); err != nil {
+		return err
+	}
+
+	analyzed := make(map[string]bool)
+	if only == nil {
+		for filename := range w.findings {
+			analyzed[filename] = true
+		}
+		for filename := range w.referencing {
+			analyzed[filename] = true
+		}
+	}
+	for filename := range only {
+		analyzed[filename] = true
+	}
+	for _, filename := range r.walked {
+		analyzed[filename] = true
+	}
+	filenames := make([]string, 0, len(analyzed))
+	for filename := range analyzed {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	byFile := make(map[string][]Finding)
+	for _, f := range r.findings {
+		byFile[f.Filename] = append(byFile[f.Filename], f)
+	}
+	var added []Finding
+	var resolved int
+	for _, filename := range filenames {
+		seen := make(map[string]bool)
+		for _, f := range w.findings[filename] {
+			seen[f.Fingerprint()] = true
+		}
+		for _, f := range byFile[filename] {
+			if !seen[f.Fingerprint()] {
+				added = append(added, f)
+			}
+			delete(seen, f.Fingerprint())
+		}
+		for _, f := range w.findings[filename] {
+			if seen[f.Fingerprint()] {
+				w.cfg.Logger.Info("finding resolved", "filename", f.Filename, "line", f.Line, "name", f.Name, "code", f.Code)
+				resolved++
+			}
+		}
+
+		if findings := byFile[filename]; findings != nil {
+			w.findings[filename] = findings
+		} else {
+			delete(w.findings, filename)
+		}
+		if referencing := r.referencing[filename]; referencing != nil {
+			w.referencing[filename] = referencing
+		} else {
+			delete(w.referencing, filename)
+		}
+	}
+
+	var total int
+	for _, findings := range w.findings {
+		total += len(findings)
+	}
+	w.cfg.Logger.Info("analyzed", "files", len(r.walked), "findings", total, "new", len(added), "resolved", resolved, "duration", time.Since(start).Round(time.Millisecond))
+
+	if len(added) == 0 {
+		return nil
+	}
+	rep, err := newReporter(w.cfg.Out, w.cfg.Format, textOptions{verbose: w.cfg.Verbose})
+	if err != nil {
+		return err
+	}
+	if err := rep.Start(nil); err != nil {
+		return err
+	}
+	if !w.cfg.Stream {
+		added = sortedByPosition(added)
+	}
+	return rep.Finish(Report{Findings: r.outputFindings(added)})
+}
+
+// addReferencing records the files, relative to the workspace, of refs, the references to a symbol declared in filename.
+func (r *runner) addReferencing(filename string, refs []*lsp.Location) {
+	files := r.referencing[filename]
+	if files == nil {
+		files = make(map[string]bool)
+		r.referencing[filename] = files
+	}
+	for _, ref := range refs {
+		if rel, found := r.workspacePath(ref.URI); found && rel != filename {
+			files[rel] = true
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWatcherAffected(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nfunc A() { b.Dead() }\n"), 0o644), qt.IsNil)
+
+	w := &watcher{
+		cfg: RunConfig{WorkspaceDir: dir},
+		findings: map[string][]Finding{
+			"b/b.go": {{Filename: "b/b.go", Name: "Dead", Code: codeUnused}},
+			"c/c.go": {{Filename: "c/c.go", Name: "(*T).Other", Code: codeUnused}},
+		},
+		referencing: map[string]map[string]bool{
+			"d/d.go": {"a.go": true},
+			"e/e.go": {"c/c.go": true},
+		},
+	}
+	// a.go now uses Dead, and may no longer use what d.go declares.
+	c.Assert(w.affected(map[string]bool{"a.go": true, "removed.go": true}), qt.DeepEquals, map[string]bool{
+		"a.go":       true,
+		"removed.go": true,
+		"b/b.go":     true,
+		"d/d.go":     true,
+	})
+
+	c.Assert(RunConfig{Transitive: true}.validateWatch(), qt.IsNotNil)
+	c.Assert(RunConfig{ReadOnly: true}.validateWatch(), qt.IsNotNil)
+	c.Assert(RunConfig{SameFile: true}.validateWatch(), qt.IsNil)
+}
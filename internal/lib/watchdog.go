@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// pingMethod is the method called to check that gopls responds. It's unknown to gopls, i.e. answered with an error
+// right away, without doing any work.
+const pingMethod = "punused/ping"
+
+// watch starts pinging gopls every threshold, until Close, logging when it stalls, i.e. does not respond
+// within threshold, see RunConfig.GoplsWatchdog. If restart is set, a stalled gopls is restarted,
+// the requests in flight failing and being retried by callWithRetry, counted as one of c.restarts.
+func (c *GoplsClient) watch(threshold time.Duration, restart bool) {
+	go func() {
+		ticker := time.NewTicker(threshold)
+		defer ticker.Stop()
+		var stalled bool
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+			proc := c.process()
+			err := c.ping(proc, threshold)
+			select {
+			case <-c.stop:
+				return
+			default:
+			}
+			if err == nil {
+				if stalled {
+					c.logger.Info("gopls is responding again")
+					stalled = false
+				}
+				continue
+			}
+			if stalled && !restart {
+				continue
+			}
+			stalled = true
+			c.logger.Warn("gopls stalled", "threshold", threshold, "error", err)
+			if !restart {
+				continue
+			}
+			failed := &goplsError{proc: proc, err: fmt.Errorf("gopls stalled, no response in %s", threshold)}
+			if err := c.restart(context.Background(), failed); err != nil {
+				c.logger.Error("failed to restart gopls", "error", err)
+				// Fail the requests in flight rather than leaving them hanging.
+				proc.kill()
+			}
+			stalled = false
+		}
+	}()
+}
+
+// ping returns nil if proc responds within timeout.
+func (c *GoplsClient) ping(proc *goplsProcess, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := c.call(ctx, proc, pingMethod, nil, nil)
+	var respErr *responseError
+	if errors.As(err, &respErr) {
+		// Method not found, as expected.
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// stalledProcess starts a process never responding, standing in for a stalled gopls.
+func stalledProcess(c *qt.C, logger *slog.Logger) *goplsProcess {
+	cmd := exec.Command("sleep", "60")
+	stderr := &logWriter{logger: logger}
+	cmd.Stderr = stderr
+	conn, err := newConn(cmd)
+	c.Assert(err, qt.IsNil)
+	c.Assert(conn.Start(), qt.IsNil)
+	proc := &goplsProcess{conn: conn, stderr: stderr, done: make(chan struct{})}
+	c.Cleanup(proc.kill)
+	return proc
+}
+
+func TestGoplsClientWatch(t *testing.T) {
+	c := qt.New(t)
+
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	c.Run("stalled", func(c *qt.C) {
+		proc := stalledProcess(c, logger)
+		client := &GoplsClient{logger: logger, pending: make(map[uint64]chan response), proc: proc, stop: make(chan struct{})}
+		go client.readLoop(proc)
+		client.watch(20*time.Millisecond, false)
+		defer func() {
+			// Not to wait for it to shut down.
+			proc.kill()
+			client.Close()
+		}()
+
+		c.Assert(waitFor(func() bool { return strings.Contains(logs.String(), "gopls stalled") }), qt.IsTrue)
+		time.Sleep(100 * time.Millisecond)
+		// Logged once per stall.
+		c.Assert(strings.Count(logs.String(), "gopls stalled"), qt.Equals, 1)
+	})
+
+	c.Run("restart", func(c *qt.C) {
+		dir := c.TempDir()
+		c.Assert(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n\ngo 1.22\n"), 0o644), qt.IsNil)
+		c.Assert(os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\n\nfunc A() {}\n"), 0o644), qt.IsNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		client, err := newClient(ctx, "gopls", dir, "", logger, nil, nil)
+		c.Assert(err, qt.IsNil)
+		defer client.Close()
+		client.restarts = 1
+		c.Assert(client.ping(client.process(), time.Minute), qt.IsNil)
+
+		stalled := stalledProcess(c, logger)
+		go client.readLoop(stalled)
+		client.process().kill()
+		client.proc = stalled
+		client.watch(100*time.Millisecond, true)
+
+		// The request in flight is retried with gopls restarted.
+		symbols, err := client.DocumentSymbol(ctx, "p.go")
+		c.Assert(err, qt.IsNil)
+		c.Assert(symbols, qt.HasLen, 1)
+		c.Assert(client.restarted, qt.Equals, 1)
+	})
+}
+
+// waitFor reports whether cond is met within a few seconds.
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package lib
+
+import (
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// isWeak reports whether the file at uri matches one of the configured weak reference globs.
+func (r *runner) isWeak(uri lsp.DocumentURI) bool {
+	if len(r.weakRefs) == 0 {
+		return false
+	}
+	rel, ok := r.workspacePath(uri)
+	return ok && r.weakRefs.Match(rel)
+}
+
+// withoutWeakRefs returns the references to the symbol declared in uri from the files not matching
+// the weak reference globs, and the others, see RunConfig.WeakRefs.
+// References from within the weak files to their own symbols count as usage.
+func (r *runner) withoutWeakRefs(uri lsp.DocumentURI, refs []*lsp.Location) (strong, weak []*lsp.Location) {
+	if len(r.weakRefs) == 0 || r.isWeak(uri) {
+		return refs, nil
+	}
+	for _, ref := range refs {
+		if r.isWeak(ref.URI) {
+			weak = append(weak, ref)
+		} else {
+			strong = append(strong, ref)
+		}
+	}
+	return strong, weak
+}
@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func TestWithoutWeakRefs(t *testing.T) {
+	c := qt.New(t)
+
+	weakRefs, err := compileGlobs([]string{"examples/**", "tools/**"})
+	c.Assert(err, qt.IsNil)
+	r := &runner{cfg: RunConfig{WorkspaceDir: "/ws"}, weakRefs: weakRefs}
+	loc := func(filename string) *lsp.Location {
+		return &lsp.Location{URI: lsp.DocumentURI("file:///ws/" + filename)}
+	}
+
+	strong, weak := r.withoutWeakRefs(loc("api/api.go").URI, []*lsp.Location{loc("examples/basic/main.go"), loc("api/api_test.go"), loc("tools/gen.go")})
+	c.Assert(strong, qt.DeepEquals, []*lsp.Location{loc("api/api_test.go")})
+	c.Assert(weak, qt.HasLen, 2)
+
+	// Used by its own example.
+	strong, weak = r.withoutWeakRefs(loc("examples/basic/util.go").URI, []*lsp.Location{loc("examples/basic/main.go")})
+	c.Assert(strong, qt.HasLen, 1)
+	c.Assert(weak, qt.IsNil)
+
+	r.weakRefs = nil
+	strong, _ = r.withoutWeakRefs(loc("api/api.go").URI, []*lsp.Location{loc("examples/basic/main.go")})
+	c.Assert(strong, qt.HasLen, 1)
+}
@@ -2,34 +2,1468 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bep/punused/internal/lib"
 )
 
 func main() {
-	// Default to "every go file in the workspace".
-	pattern := "**/*.go"
 	if len(os.Args) > 1 {
-		pattern = os.Args[1]
+		switch os.Args[1] {
+		case "trend":
+			trend(os.Args[2:])
+			return
+		case "merge":
+			merge(os.Args[2:])
+			return
+		case "dashboard":
+			dashboard(os.Args[2:])
+			return
+		case "doctor":
+			doctor(os.Args[2:])
+			return
+		case "init":
+			initConfig(os.Args[2:])
+			return
+		case "serve-lsp":
+			serveLSP(os.Args[2:])
+			return
+		case "explain":
+			explain(os.Args[2:])
+			return
+		case "stats":
+			stats(os.Args[2:])
+			return
+		case "export-index":
+			exportIndex(os.Args[2:])
+			return
+		case "export-inventory":
+			exportInventory(os.Args[2:])
+			return
+		case "tui":
+			tui(os.Args[2:])
+			return
+		case "issues":
+			issues(os.Args[2:])
+			return
+		case "fleet":
+			os.Exit(fleet(os.Args[2:]))
+		case "suppress":
+			suppress(os.Args[2:])
+			return
+		case "analyze":
+			os.Exit(check(os.Args[2:], true))
+		case "report":
+			os.Exit(report(os.Args[2:]))
+		}
 	}
+	os.Exit(check(os.Args[1:], false))
+}
+
+// check runs the analysis, the default command, and returns the exit code, see lib.ExitCode.
+// With analyze, for punused analyze, the raw results are written as JSON without applying the gates,
+// which apply to the reports derived from them with punused report.
+func check(args []string, analyze bool) int {
+	name := "punused"
+	if analyze {
+		name = "punused analyze"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var (
+		version     = fs.Bool("version", false, "print the version of punused, with the VCS revision and the Go version it was built with, and exit")
+		out         = fs.String("o", "", "write the findings to this file instead of stdout")
+		format      = fs.String("format", "text", "output format, one of text, json, sarif, quickfix, md, html or checkstyle")
+		verbose     = fs.Bool("v", false, "include the hint, the symbol's signature and doc summary in the text output")
+		cpuProfile  = fs.String("cpuprofile", "", "write a CPU profile of the run to this file")
+		memProfile  = fs.String("memprofile", "", "write a heap profile at the end of the run to this file")
+		traceOut    = fs.String("trace", "", "write an execution trace of the run to this file, see go tool trace")
+		stream      = fs.Bool("stream", false, "print the findings in the text format as they're found, in the order of the walk, instead of sorted by file, position and check code at the end")
+		packages    = fs.Bool("packages", false, "print the summary and the exported, used in test only and unused symbols per package at the end of the text output")
+		blame       = fs.Bool("blame", false, "annotate findings with the last author and modification time from git blame")
+		transitive  = fs.Bool("transitive", false, "also report symbols only used by unused code")
+		reexports   = fs.Bool("reexports", false, "also report symbols only re-exported by unused or deprecated shims in other packages")
+		iterations  = fs.Int("iterations", 1, "with -fix, re-analyze and fix again, as removed code may leave other code unused, until nothing changes or this many rounds are done")
+		reportTest  = fs.Bool("report-test-only", true, "report the symbols used in test only (EU1001), else consider them used")
+		testOnlySev = fs.String("test-only-severity", "", "the severity of the symbols used in test only (EU1001), and so their effect on the exit code: error fails the run, info leaves it clean, overriding the config")
+		fixTestOnly = fs.String("fix-test-only", "", "with move, only move the declarations of the symbols used in test only (EU1001) to a _test.go file, the other fixes of -fix left out")
+		fixReport   = fs.String("fix-report", "", "with -fix, write the edits made, and the files that failed, to this file as JSON")
+		fixMessage  = fs.String("fix-message", "", "with -fix, write a commit message listing the edits made, with their fingerprints, and trailers with their digest to this file, e.g. for git commit -F")
+		fixVerify   = fs.String("fix-verify", "build", "with -fix, verify that the workspace still builds after the fixes, rolling back the packages failing: build runs go build, vet also go vet, test also compiles the tests, none skips it")
+		fixMinAge   = fs.String("fix-min-age", "", "with -fix, only fix symbols whose declaration has not been modified (per git blame) in this long, e.g. 180d")
+		diff        = fs.String("diff", "", "only report symbols whose declaration intersects the lines added in this unified diff, e.g. from git diff --relative, - for stdin, or since this git revision, e.g. origin/main")
+		staged      = fs.Bool("staged", false, "only analyze the Go files staged in git, as staged, and report the symbols whose declaration intersects the lines added, e.g. in a pre-commit hook")
+		minAge      = fs.String("min-age", "", "only report symbols whose declaration has not been modified (per git blame) in this long, e.g. 90d")
+		top         = fs.Int("top", 0, "rank the top N packages (and authors with -blame) by number of unused symbols")
+		history     = fs.String("history", "", "append a summary of the run to this JSON Lines file, or object, e.g. s3://bucket/punused/history.jsonl (see punused trend)")
+		ignoreGen   = fs.Bool("ignore-generated-refs", false, "references from generated files do not count as usage (reported as EU1006)")
+		tagged      = fs.Bool("tagged-fields", false, "report unused fields with serialization tags as EU1009")
+		caseOnly    = fs.Bool("case-only", false, "also report exported constants only used in switch cases or blank assignments (EU1012)")
+		stdlibClone = fs.Int("stdlib-clones", 0, "also report exported functions with at most this many references whose name and signature match a recent standard library function, e.g. slices.Contains (EU1013)")
+		minRefs     = fs.Int("min-refs", 0, "also report exported symbols in use with fewer references than this, e.g. 3 for those with one or two, with the number of references (EU1004), 0 disables")
+		serialized  = fs.Bool("skip-serialized-fields", false, "skip the fields of structs with serialization tags or passed to marshaling calls")
+		duplicates  = fs.Float64("duplicates", 0, "also report exported functions with the same signature as, and a body at least this similar (0 to 1, e.g. 0.9) to, one in another package (EU1010), 0 disables")
+		sameFile    = fs.Bool("same-file", false, "also report symbols only used in the file declaring them (EU1007)")
+		samePackage = fs.Bool("same-package", false, "also report exported symbols only used in their declaring package (EU1003)")
+		absPaths    = fs.Bool("abs-paths", false, "print absolute filenames in the findings instead of relative to the workspace")
+		pathPrefix  = fs.String("path-prefix", "", "prepend this to the filenames in the findings instead, e.g. the workspace location on the host")
+		summaryOut  = fs.String("summary-out", "", "write a JSON summary of the run, including whether it passed the gates, to this file")
+		overlayFn   = fs.String("overlay", "", "a JSON file mapping filenames to content to analyze instead of the files on disk, e.g. unsaved editor buffers")
+		manifest    = fs.String("manifest", "", "write the files analyzed with their hashes and a hash of the configuration to this file")
+		verifyMan   = fs.String("verify-manifest", "", "fail the run if the files analyzed or the configuration differ from this manifest, written with -manifest")
+		resume      = fs.String("resume", "", "save the progress to this file and resume an interrupted run from it")
+		cache       = fs.String("cache", "", "cache the results in this directory, or bucket, e.g. s3://bucket/punused or gs://bucket/punused, shared by CI runners (default in the user cache directory)")
+		noCache     = fs.Bool("no-cache", false, "do not use nor update the results cached for an unchanged tree and configuration")
+		sample      = fs.Float64("sample", 0, "only analyze this fraction of the files, chosen at random, e.g. 0.1 for a quick estimate")
+		seed        = fs.Int64("seed", 1, "the random seed used with -sample")
+		base        = fs.String("base", "", "mark each finding as introduced on the branch or pre-existing at the merge base with this git revision, e.g. origin/main")
+		failExcl    = fs.Bool("fail-on-excluded", false, "fail the run if gopls reports files or directories excluded from its view, whose references are missing")
+		failNewOnly = fs.Bool("fail-introduced-only", false, "with -base, only fail the run on the findings introduced on the branch")
+		failFast    = fs.Bool("fail-fast", false, "stop the run and exit non-zero at the first finding")
+		showRefs    = fs.Bool("show-refs", false, "list the locations referencing the symbol beneath the findings still referenced, e.g. used in test only")
+		snippet     = fs.Int("snippet", 0, "include the first N lines of each flagged declaration in the JSON output")
+		minConf     = fs.String("min-confidence", "", "only report (and fix) findings with at least this confidence, one of high, medium or low")
+		rename      = fs.Bool("rename", false, "rename the symbols used in test only (EU1001) to their unexported name, suffixed with ForTest if taken, using gopls")
+		fixScript   = fs.String("fix-script", "punused-fix.sh", "with -fix=script, write the changes as a shell script of rf (rsc.io/rf) commands to this file instead of changing the files, - for stdout (the findings are then printed to stderr)")
+		patch       = fs.String("patch", "", "write the changes -fix, or -rename, makes as a unified diff, applicable with git apply, to this file instead of changing the files, - for stdout (the findings are then printed to stderr)")
+		testdata    = fs.Bool("include-testdata", false, "include the files in testdata directories, ignored by default as by the go tool")
+		collapseAcc = fs.Bool("collapse-accessors", false, "report the unused getters and setters of a type, e.g. GetX and SetX for a field X, as one finding if all are unused")
+		annotateAll = fs.Bool("annotate-all", false, "also list every exported symbol analyzed with its number of references and usage status, e.g. for API audits")
+		symlinks    = fs.Bool("follow-symlinks", false, "follow the symbolic links to directories in the workspace, skipped by default")
+		submodules  = fs.Bool("include-submodules", false, "include the directories with a go.mod file of their own, the nested modules not used by the go.work file, skipped by default")
+		vendored    = fs.Bool("include-vendored", false, "include the vendored packages patched locally, i.e. forks carried in the tree")
+		generated   = fs.Bool("include-generated", false, "analyze the symbols declared in generated files, skipped by default")
+		unexported  = fs.Bool("include-unexported", false, "also report unexported functions, types, constants and variables without references")
+		mainPkgs    = fs.String("main-packages", "", "the policy for the exported symbols in package main, one of check (the default), skip or unexported-rule, to only report those without references")
+		enumPolicy  = fs.String("enum-policy", "", "the policy for the constants of an enum (a const group using iota), one of each (the default) or any-used, to leave them all out if any of them is used")
+		testFiles   = fs.Bool("include-test-files", false, "also analyze the exported symbols declared in _test.go files, e.g. test helpers")
+		maxSize     = fs.Int64("max-file-size", 0, "skip files bigger than this number of bytes (0 disables)")
+		maxSymbols  = fs.Int("max-symbols-per-file", 0, "skip files with more symbols than this (0 disables)")
+		concurrency = fs.Int("concurrency", 1, "number of files to fetch symbols and references for from gopls concurrently")
+		pipeline    = fs.Int("pipeline", 8, "number of reference requests for the symbols of a file sent to gopls at once, without waiting for the responses (1 disables)")
+		maxFindings = fs.Int("max-findings", -1, "fail if the number of findings exceeds this budget, and only then (negative disables)")
+		maxUnused   = fs.Float64("max-unused-percent", -1, "fail if the percentage of exported symbols that are unused or used in test only exceeds this (negative disables)")
+		config      = fs.String("config", lib.ConfigFilename, "the config file, relative to the workspace root")
+		ownersDir   = fs.String("owners-dir", "", "write one report per CODEOWNERS owner to this directory")
+		modulesDir  = fs.String("modules-dir", "", "write one report per Go module, named by the module path, to this directory")
+		outputDir   = fs.String("output-dir", "", "write one report per package, named by the package directory, and an index with the totals to this directory")
+		dot         = fs.String("dot", "", "write the reference graph of the unused symbols in DOT format to this file (requires -transitive)")
+		retries     = fs.Int("retries", 2, "retry failed gopls requests this many times")
+		backoff     = fs.Duration("retry-backoff", 250*time.Millisecond, "wait this long before the first retry, doubled for every attempt")
+		goplsWait   = fs.Duration("gopls-timeout", time.Minute, "restart gopls when a request gets no response for this long (0 disables)")
+		restarts    = fs.Int("gopls-restarts", 2, "restart gopls this many times when it exits or hangs before giving up")
+		watchdog    = fs.Duration("gopls-watchdog", 0, "ping gopls this often, logging when it does not respond within this long (0 disables)")
+		watchdogRe  = fs.Bool("gopls-watchdog-restart", false, "restart gopls when the watchdog finds it stalled, counted as one of -gopls-restarts")
+		goos        = fs.String("goos", "", "analyze with this GOOS, shorthand for a single -build-config")
+		goarch      = fs.String("goarch", "", "analyze with this GOARCH, shorthand for a single -build-config")
+		tags        = fs.String("tags", "", "analyze with these comma separated build tags, shorthand for a single -build-config")
+		goVersion   = fs.String("go-version", "", "analyze with the language semantics of this Go version, e.g. 1.18, instead of the go directive in go.mod")
+		readOnly    = fs.Bool("read-only", false, "analyze a workspace on a read-only file system, with copies of go.mod and go.sum and the gopls caches in a temporary directory")
+		backend     = fs.String("backend", lib.BackendGopls, "the analysis backend, gopls, or packages to type check the workspace with golang.org/x/tools/go/packages, without gopls")
+		gopls       = fs.String("gopls", "", "the gopls binary to run, a path or a name looked up in PATH (default $"+lib.GoplsEnv+", else gopls)")
+		goplsInst   = fs.Bool("gopls-install", false, "install a supported gopls into the cache directory with go install if gopls is missing or its version is not supported")
+		goplsCache  = fs.String("gopls-cache-dir", "", "keep the gopls file cache and the go build cache of gopls in this directory")
+		allBuilds   = fs.Bool("all-build-configs", false, "with multiple -build-config, only report findings appearing with every configuration analyzing the file")
+		timeout     = fs.Duration("timeout", 2*time.Minute, "stop the run after this long")
+		watch       = fs.Bool("watch", false, "keep running, re-analyzing the files affected whenever Go files change, and print the new findings of each round")
+		progress    = fs.Bool("progress", false, "report the files analyzed, the symbols checked, the findings so far and the current file on stderr, in place on a terminal, else as log lines")
+		logging     = addLogFlags(fs)
+		workspaces  stringList
+		failOn      stringList
+		failOwners  stringList
+		owners      stringList
+		lines       stringList
+		keep        repeatedList
+		exclude     stringList
+		ignoreRefs  stringList
+		downstream  stringList
+		kinds       stringList
+		fix         fixFlag
+		builds      buildConfigList
+	)
+	fs.Var(&fix, "fix", "move the declarations of the symbols used in test only (EU1001) to a _test.go file in the same package and remove the unused types with their methods, or, with -fix=interactive, prompt to remove, keep or keep always each unused symbol, or, with -fix=script, write the changes as rf commands to -fix-script instead")
+	fs.Var(&failOn, "fail-on", "fail the run (exit code 2) on any finding with these check codes, e.g. EU1002 or EU1001,EU1002, whatever their severity")
+	fs.Var(&failOwners, "fail-owner", "only fail the run on the findings owned by this CODEOWNERS owner, e.g. @org/payments or payments, or unowned, may be repeated")
+	fs.Var(&owners, "owner", "only analyze the files owned by this CODEOWNERS owner, e.g. @org/payments or payments, or unowned, may be repeated")
+	fs.Var(&exclude, "exclude", "do not analyze the directories matching these globs, relative to the workspace, e.g. third_party/**, may be repeated or comma separated")
+	fs.Var(&ignoreRefs, "ignore-refs", "references from the files matching these globs, relative to the workspace, e.g. examples/**, don't count as usage, the symbols only used there reported as EU1014, added to the weak-refs of the config, may be repeated or comma separated")
+	fs.Var(&downstream, "downstream", "only report the symbols not referenced by these consumers either, directories or module paths fetched with go get, e.g. ../app or github.com/acme/app@latest, may be repeated or comma separated")
+	fs.Var(&kinds, "kinds", "only check the symbols of these kinds, e.g. func,method,type,const,var or function,struct,field, or not of those prefixed with !, e.g. !const, overriding the kinds of the config")
+	fs.Var(&keep, "keep", "never report nor fix the symbols matching this regular expression, optionally scoped to package directories, e.g. pkg/api/...=^New, may be repeated")
+	fs.Var(&lines, "lines", "only report symbols whose declaration intersects this line range, e.g. file.go:10-80, may be repeated")
+	fs.Var(&builds, "build-config", `analyze with this build configuration, e.g. "goos=windows goarch=arm64 tags=integration", may be repeated`)
+	fs.Var(&workspaces, "wd", "a workspace root to analyze instead of the current directory, may be repeated or comma separated")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: punused [flags] [pattern...]\n       punused trend [flags]\n       punused merge [flags] report.json...\n       punused dashboard [flags] report.json...\n       punused doctor\n       punused init [flags]\n       punused serve-lsp [flags] [pattern...]\n       punused explain code\n       punused stats [flags] [pattern...]\n       punused export-index [flags] [pattern...]\n       punused export-inventory [flags] [pattern...]\n       punused issues [flags] report.json...\n       punused tui [flags] [pattern...]\n       punused suppress [flags] id...\n       punused analyze [flags] -o raw.json [pattern...]\n       punused report [flags] raw.json\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if *version {
+		fmt.Println(lib.ReadBuildInfo())
+		return 0
+	}
+	logging.setup()
+	if err := startProfiles(*cpuProfile, *memProfile, *traceOut); err != nil {
+		fatal(err)
+	}
+	defer stopProfiles()
+
+	patterns := filenamePatterns(fs.Args())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	if analyze {
+		if isFlagSet(fs, "format") && *format != "json" {
+			fatal(errors.New("punused analyze writes JSON, use punused report for the other formats"))
+		}
+		*format = "json"
+	}
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// The time spent deciding is not limited.
+	if fix.interactive && !isFlagSet(fs, "timeout") {
+		*timeout = 24 * time.Hour
+	}
+	// Runs until interrupted.
+	if *watch && !isFlagSet(fs, "timeout") {
+		*timeout = math.MaxInt64
+	}
+	ctx, cancel := runContext(*timeout)
 	defer cancel()
 
 	wd, _ := os.Getwd()
 
-	err := lib.Run(
-		ctx,
-		lib.RunConfig{
-			WorkspaceDir:    wd,
-			FilenamePattern: pattern,
-			Out:             os.Stdout,
+	configFilename := *config
+	if !filepath.IsAbs(configFilename) {
+		configFilename = filepath.Join(wd, configFilename)
+	}
+	conf, err := lib.LoadConfig(configFilename)
+	if err != nil {
+		fatal(err)
+	}
+
+	// The flags and arguments override the config.
+	if fs.NArg() == 0 && len(conf.Files) > 0 {
+		patterns = filenamePatterns(conf.Files)
+	}
+	if conf.Fix != nil && !isFlagSet(fs, "fix") {
+		fix.enabled = *conf.Fix
+	}
+	if len(failOn) == 0 {
+		failOn = conf.FailOn
+	}
+	switch *fixTestOnly {
+	case "":
+	case "move":
+		if fix.interactive || !*reportTest {
+			fatal(errors.New("-fix-test-only cannot be combined with -fix=interactive or -report-test-only=false"))
+		}
+		fix.enabled = true
+	default:
+		fatal(fmt.Errorf("-fix-test-only: unsupported mode %q, must be move", *fixTestOnly))
+	}
+	if *goVersion == "" {
+		*goVersion = conf.GoVersion
+	}
+	if *mainPkgs == "" {
+		*mainPkgs = conf.MainPackages
+	}
+	if *enumPolicy == "" {
+		*enumPolicy = conf.EnumPolicy
+	}
+	if *goos != "" || *goarch != "" || *tags != "" {
+		if len(builds) > 0 {
+			fatal(errors.New("-goos, -goarch and -tags cannot be combined with -build-config"))
+		}
+		bc, err := lib.ParseBuildConfig(buildConfigString(*goos, *goarch, *tags))
+		if err != nil {
+			fatal(err)
+		}
+		builds = append(builds, bc)
+	}
+
+	cfg := lib.RunConfig{
+		WorkspaceDir:     wd,
+		WorkspaceDirs:    workspaces,
+		BuildConfigs:     builds,
+		AllBuildConfigs:  *allBuilds,
+		GoVersion:        *goVersion,
+		ReadOnly:         *readOnly,
+		Backend:          *backend,
+		Gopls:            *gopls,
+		GoplsInstall:     *goplsInst,
+		GoplsCacheDir:    *goplsCache,
+		FilenamePatterns: patterns,
+		Out:              w,
+		Logger:           slog.Default(),
+		Format:           *format,
+		Verbose:          *verbose,
+		Stream:           *stream,
+		Packages:         *packages,
+		Blame:            *blame,
+		Transitive:       *transitive,
+		Reexports:        *reexports,
+		Top:              *top,
+		HistoryFile:      *history,
+		SummaryOut:       *summaryOut,
+		ManifestOut:      *manifest,
+		VerifyManifest:   *verifyMan,
+		Resume:           *resume,
+		OwnersDir:        *ownersDir,
+		ModulesDir:       *modulesDir,
+		OutputDir:        *outputDir,
+		Severity:         conf.Severity,
+		Hints:            conf.Hints,
+		Thresholds:       conf.Thresholds,
+		FailOn:           failOn,
+		EntryPoints:      conf.EntryPoints,
+		TestPackages:     conf.TestPackages,
+		DeadTargets:      conf.DeadTargets,
+		WeakRefs:         append(conf.WeakRefs, ignoreRefs...),
+		ExcludeDirs:      append(conf.Exclude, exclude...),
+		VendoredForks:    conf.VendoredForks,
+		SkipHeaders:      conf.SkipHeaders,
+		Keep:             append(conf.Keep, keep...),
+		Kinds:            kindsOr(kinds, conf.Kinds),
+		Receivers:        conf.Receivers,
+		IssueLink:        conf.IssueLink,
+		SourceLink:       conf.SourceLink,
+		CodePrefix:       conf.CodePrefix,
+
+		DynamicUsageNames: conf.DynamicUsage.Names,
+		DynamicUsageCalls: conf.DynamicUsage.Calls,
+		EmbeddedTemplates: conf.EmbeddedTemplates,
+		Downstream:        append(conf.Downstream, downstream...),
+		IgnoreFile:        filepath.Join(wd, lib.IgnoreFilename),
+
+		IgnoreGeneratedRefs:  *ignoreGen,
+		TaggedFields:         *tagged,
+		CaseOnly:             *caseOnly,
+		StdlibClones:         *stdlibClone,
+		MinRefs:              *minRefs,
+		SkipSerializedFields: *serialized,
+		SameFile:             *sameFile,
+		SamePackage:          *samePackage,
+		DuplicateThreshold:   *duplicates,
+		IncludeTestdata:      *testdata,
+		IncludeVendored:      *vendored,
+		FollowSymlinks:       *symlinks,
+		IncludeSubmodules:    *submodules,
+		AnnotateAll:          *annotateAll,
+		CollapseAccessors:    *collapseAcc,
+		IncludeGenerated:     *generated,
+		IncludeUnexported:    *unexported,
+		MainPackages:         *mainPkgs,
+		EnumPolicy:           *enumPolicy,
+		Fix:                  fix.enabled,
+		FixTestOnly:          *fixTestOnly != "",
+		FixVerify:            *fixVerify,
+		IgnoreTestOnly:       !*reportTest,
+		TestOnlySeverity:     lib.Severity(*testOnlySev),
+		FixReport:            *fixReport,
+		FixMessage:           *fixMessage,
+		Iterations:           *iterations,
+		Rename:               *rename,
+		SnippetLines:         *snippet,
+		ShowRefs:             *showRefs,
+		FailFast:             *failFast,
+		Base:                 *base,
+		FailIntroducedOnly:   *failNewOnly,
+		FailOnExcluded:       *failExcl,
+		Owners:               owners,
+		FailOwners:           failOwners,
+		Sample:               *sample,
+		AbsPaths:             *absPaths,
+		PathPrefix:           *pathPrefix,
+		Seed:                 *seed,
+		Retries:              *retries,
+		RetryBackoff:         *backoff,
+		GoplsTimeout:         *goplsWait,
+		GoplsRestarts:        *restarts,
+		GoplsWatchdog:        *watchdog,
+		GoplsWatchdogRestart: *watchdogRe,
+		IncludeTestFiles:     *testFiles,
+		MaxFileSize:          *maxSize,
+		MaxSymbolsPerFile:    *maxSymbols,
+		Concurrency:          *concurrency,
+		Pipeline:             *pipeline,
+	}
+
+	if *minConf != "" {
+		c, err := lib.ParseConfidence(*minConf)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.MinConfidence = c
+	}
+
+	if *overlayFn != "" {
+		overlay, err := lib.LoadOverlay(*overlayFn)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.Overlay = overlay
+	}
+
+	if *staged {
+		if *diff != "" || len(lines) > 0 || cfg.Fix || cfg.Rename || *patch != "" {
+			fatal(errors.New("-staged cannot be combined with -diff, -lines, -fix, -rename or -patch"))
+		}
+		files, changed, err := lib.GitStaged(wd)
+		if err != nil {
+			fatal(err)
+		}
+		if len(files) == 0 {
+			// Nothing to check.
+			return 0
+		}
+		cfg.FilenamePatterns = stagedPatterns(files, cfg.FilenamePatterns)
+		if cfg.Overlay == nil {
+			cfg.Overlay = make(map[string]string)
+		}
+		for filename, content := range files {
+			if _, found := cfg.Overlay[filename]; !found {
+				cfg.Overlay[filename] = content
+			}
+		}
+		cfg.ChangedLines = changed
+	}
+
+	if *diff != "" || len(lines) > 0 {
+		changed, err := changedLines(wd, *diff, lines)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.ChangedLines = changed
+	}
+
+	if *minAge != "" {
+		d, err := lib.ParseAge(*minAge)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.MinAge = d
+	}
+
+	if *fixMinAge != "" {
+		d, err := lib.ParseAge(*fixMinAge)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.FixMinAge = d
+	}
+
+	if *maxFindings >= 0 {
+		cfg.MaxFindings = maxFindings
+	}
+	if *maxUnused >= 0 {
+		cfg.MaxUnusedPercent = maxUnused
+	}
+
+	if *dot != "" {
+		f, err := os.Create(*dot)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		cfg.DotOut = f
+	}
+
+	if *progress {
+		cfg.Progress = os.Stderr
+	}
+
+	if !*noCache {
+		cfg.Cache = *cache
+		if cfg.Cache == "" {
+			if dir, err := os.UserCacheDir(); err == nil {
+				cfg.Cache = filepath.Join(dir, "punused")
+			}
+		}
+	}
+
+	if *patch != "" {
+		cfg.Fix = cfg.Fix || !cfg.Rename
+		if *patch == "-" {
+			cfg.Patch, cfg.Out = os.Stdout, os.Stderr
+		} else {
+			f, err := os.Create(*patch)
+			if err != nil {
+				fatal(err)
+			}
+			defer f.Close()
+			cfg.Patch = f
+		}
+	}
+
+	if fix.script {
+		if *patch != "" {
+			fatal(errors.New("-fix=script cannot be combined with -patch"))
+		}
+		if *fixScript == "-" {
+			cfg.Script, cfg.Out = os.Stdout, os.Stderr
+		} else {
+			f, err := os.Create(*fixScript)
+			if err != nil {
+				fatal(err)
+			}
+			defer f.Close()
+			cfg.Script = f
+		}
+	}
+
+	if fix.interactive {
+		if err := lib.FixInteractive(ctx, cfg, os.Stdin); err != nil {
+			slog.Error(err.Error())
+			return lib.ExitFailure
+		}
+		return 0
+	}
+
+	if *watch {
+		if err := lib.Watch(ctx, cfg); err != nil {
+			slog.Error(err.Error())
+			return lib.ExitFailure
+		}
+		return 0
+	}
+
+	if analyze {
+		// The gates apply to the reports.
+		cfg.FailOn, cfg.Thresholds, cfg.MaxFindings, cfg.MaxUnusedPercent = nil, nil, nil, nil
+	}
+
+	res, err := lib.Check(ctx, cfg)
+	if analyze {
+		// Only failing to analyze fails, not the findings with severity error.
+		var fe *lib.FailedError
+		if err != nil && !errors.As(err, &fe) {
+			slog.Error(err.Error())
+			return lib.ExitFailure
+		}
+		return lib.ExitClean
+	}
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	return lib.ExitCode(res, err)
+}
+
+// report writes the raw results of punused analyze in another format, checking them against the gates,
+// see lib.ReportFile, and returns the exit code, as for the analysis.
+func report(args []string) int {
+	fs := flag.NewFlagSet("punused report", flag.ContinueOnError)
+	var (
+		out         = fs.String("o", "", "write the report to this file instead of stdout")
+		format      = fs.String("format", "text", "output format, one of text, json, sarif, quickfix, md, html or checkstyle")
+		verbose     = fs.Bool("v", false, "include the hint, the symbol's signature and doc summary, and the summary, in the text output")
+		packages    = fs.Bool("packages", false, "print the summary and the exported, used in test only and unused symbols per package at the end of the text output")
+		maxFindings = fs.Int("max-findings", -1, "fail if the number of findings exceeds this budget, and only then (negative disables)")
+		maxUnused   = fs.Float64("max-unused-percent", -1, "fail if the percentage of exported symbols that are unused or used in test only exceeds this (negative disables)")
+		failNewOnly = fs.Bool("fail-introduced-only", false, "only fail on the findings introduced on the branch, for results analyzed with -base")
+		failExcl    = fs.Bool("fail-on-excluded", false, "fail if gopls reported files or directories excluded from its view")
+		config      = fs.String("config", lib.ConfigFilename, "the config file with the thresholds and fail-on codes")
+		logging     = addLogFlags(fs)
+		failOn      stringList
+		failOwners  stringList
+	)
+	fs.Var(&failOn, "fail-on", "fail (exit code 2) on any finding with these check codes, e.g. EU1002 or EU1001,EU1002, whatever their severity")
+	fs.Var(&failOwners, "fail-owner", "only fail on the findings owned by this CODEOWNERS owner, e.g. @org/payments or payments, or unowned, may be repeated")
+	filenames := parseInterspersed(fs, args)
+	logging.setup()
+
+	if len(filenames) != 1 {
+		fatal(errors.New("want one file with the raw results written by punused analyze"))
+	}
+	conf, err := lib.LoadConfig(*config)
+	if err != nil {
+		fatal(err)
+	}
+	if len(failOn) == 0 {
+		failOn = conf.FailOn
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	cfg := lib.RunConfig{
+		Out:                w,
+		Logger:             slog.Default(),
+		Format:             *format,
+		Verbose:            *verbose,
+		Packages:           *packages,
+		Thresholds:         conf.Thresholds,
+		FailOn:             failOn,
+		FailIntroducedOnly: *failNewOnly,
+		FailOnExcluded:     *failExcl,
+		FailOwners:         failOwners,
+		CodePrefix:         conf.CodePrefix,
+	}
+	if *maxFindings >= 0 {
+		cfg.MaxFindings = maxFindings
+	}
+	if *maxUnused >= 0 {
+		cfg.MaxUnusedPercent = maxUnused
+	}
+	res, err := lib.ReportFile(cfg, filenames[0])
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	return lib.ExitCode(res, err)
+}
+
+// trend prints the deltas between the runs recorded in the history file.
+func trend(args []string) {
+	fs := flag.NewFlagSet("punused trend", flag.ContinueOnError)
+	history := fs.String("history", ".punused-history.jsonl", "the history file, or its URL in a bucket, written by punused -history")
+	logging := addLogFlags(fs)
+	parseFlags(fs, args)
+	logging.setup()
+
+	ctx, cancel := runContext(time.Minute)
+	defer cancel()
+	if err := lib.Trend(ctx, os.Stdout, *history); err != nil {
+		fatal(err)
+	}
+}
+
+// merge combines JSON reports from multiple runs (e.g. CI shards) into one.
+func merge(args []string) {
+	fs := flag.NewFlagSet("punused merge", flag.ContinueOnError)
+	var (
+		out     = fs.String("o", "", "write the merged report to this file instead of stdout")
+		format  = fs.String("format", "", "output format, one of text, json, sarif, quickfix, md, html or checkstyle (default inferred from -o)")
+		logging = addLogFlags(fs)
+	)
+	filenames := parseInterspersed(fs, args)
+	logging.setup()
+
+	if len(filenames) == 0 {
+		fatal(errors.New("no reports to merge"))
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := lib.Merge(w, *format, *out, filenames...); err != nil {
+		fatal(err)
+	}
+}
+
+// dashboard writes an HTML page charting the findings of the JSON reports from multiple runs over time.
+func dashboard(args []string) {
+	fs := flag.NewFlagSet("punused dashboard", flag.ContinueOnError)
+	var (
+		out     = fs.String("o", "", "write the dashboard to this file instead of stdout")
+		logging = addLogFlags(fs)
+	)
+	filenames := parseInterspersed(fs, args)
+	logging.setup()
+
+	if len(filenames) == 0 {
+		fatal(errors.New("no reports to compare"))
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := lib.Dashboard(w, filenames...); err != nil {
+		fatal(err)
+	}
+}
+
+// fleet analyzes the repositories listed in a file into one report, see lib.Fleet, and returns the exit code,
+// lib.ExitFailure if any repository failed.
+func fleet(args []string) int {
+	fs := flag.NewFlagSet("punused fleet", flag.ContinueOnError)
+	var (
+		repos      = fs.String("repos", "repos.txt", "the repositories to analyze, one git URL or local directory per line, optionally followed by a name")
+		dir        = fs.String("dir", "", "the directory to clone the repositories into (default in the user cache directory)")
+		offline    = fs.Bool("offline", false, "analyze the repositories already cloned without updating them")
+		out        = fs.String("o", "", "write the combined report to this file instead of stdout")
+		format     = fs.String("format", "text", "output format, one of text, json or sarif")
+		transitive = fs.Bool("transitive", false, "also report symbols only used by unused code")
+		ignoreGen  = fs.Bool("ignore-generated-refs", false, "references from generated files do not count as usage (reported as EU1006)")
+		remote     = fs.String("gopls-remote", "auto", "the gopls daemon to share between the repositories, passed to gopls as -remote, or empty to start gopls for each")
+		timeout    = fs.Duration("timeout", 10*time.Minute, "the maximum time to analyze each repository")
+		logging    = addLogFlags(fs)
+	)
+	parseFlags(fs, args)
+	logging.setup()
+
+	list, err := lib.ReadFleetRepos(*repos)
+	if err != nil {
+		fatal(err)
+	}
+	if *dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			fatal(err)
+		}
+		*dir = filepath.Join(cache, "punused", "fleet")
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ctx, cancel := runContext(time.Duration(len(list)) * *timeout)
+	defer cancel()
+
+	res, err := lib.Fleet(ctx, lib.FleetConfig{
+		Repos:   list,
+		Dir:     *dir,
+		Offline: *offline,
+		Run: lib.RunConfig{
+			FilenamePatterns:    []string{"**/*.go"},
+			Logger:              slog.Default(),
+			Transitive:          *transitive,
+			IgnoreGeneratedRefs: *ignoreGen,
+			GoplsRemote:         *remote,
 		},
+		Format: *format,
+		Out:    w,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	if len(res.Failed) > 0 {
+		slog.Error(fmt.Sprintf("%d of %d repositories failed", len(res.Failed), len(list)))
+		return lib.ExitFailure
+	}
+	return 0
+}
+
+// stats prints the number of exported symbols by kind and package and how much they're used.
+func stats(args []string) {
+	fs := flag.NewFlagSet("punused stats", flag.ContinueOnError)
+	var (
+		format    = fs.String("format", "text", "output format, one of text or json")
+		ignoreGen = fs.Bool("ignore-generated-refs", false, "references from generated files do not count as usage")
+		methodsOf = fs.String("only-methods-of", "", "list the usage of every exported method of this type instead, e.g. Client or gopls.Client")
+		logging   = addLogFlags(fs)
+	)
+	parseFlags(fs, args)
+	logging.setup()
+
+	patterns := filenamePatterns(fs.Args())
+
+	ctx, cancel := runContext(2 * time.Minute)
+	defer cancel()
+
+	wd, _ := os.Getwd()
+	cfg := lib.RunConfig{
+		WorkspaceDir:        wd,
+		FilenamePatterns:    patterns,
+		Out:                 os.Stdout,
+		Logger:              slog.Default(),
+		Format:              *format,
+		IgnoreGeneratedRefs: *ignoreGen,
+	}
+	if *methodsOf != "" {
+		if err := lib.Methods(ctx, cfg, *methodsOf); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if err := lib.Stats(ctx, cfg); err != nil {
+		fatal(err)
+	}
+}
+
+// tui walks through the findings interactively, see lib.Triage.
+func tui(args []string) {
+	fs := flag.NewFlagSet("punused tui", flag.ContinueOnError)
+	var (
+		transitive = fs.Bool("transitive", false, "also report symbols only used by unused code")
+		ignoreGen  = fs.Bool("ignore-generated-refs", false, "references from generated files do not count as usage (reported as EU1006)")
+		logging    = addLogFlags(fs)
+	)
+	parseFlags(fs, args)
+	logging.setup()
+
+	patterns := filenamePatterns(fs.Args())
+
+	// The time spent deciding is not limited.
+	ctx, cancel := runContext(24 * time.Hour)
+	defer cancel()
+
+	// The symbols kept are added to this config.
+	wd, _ := os.Getwd()
+	conf, err := lib.LoadConfig(filepath.Join(wd, lib.ConfigFilename))
+	if err != nil {
+		fatal(err)
+	}
+	if fs.NArg() == 0 && len(conf.Files) > 0 {
+		patterns = filenamePatterns(conf.Files)
+	}
+
+	cfg := lib.RunConfig{
+		WorkspaceDir:        wd,
+		FilenamePatterns:    patterns,
+		Out:                 os.Stdout,
+		Logger:              slog.Default(),
+		Transitive:          *transitive,
+		IgnoreGeneratedRefs: *ignoreGen,
+		EntryPoints:         conf.EntryPoints,
+		TestPackages:        conf.TestPackages,
+		DeadTargets:         conf.DeadTargets,
+		WeakRefs:            conf.WeakRefs,
+		ExcludeDirs:         conf.Exclude,
+		SkipHeaders:         conf.SkipHeaders,
+		Keep:                conf.Keep,
+		Kinds:               conf.Kinds,
+		Receivers:           conf.Receivers,
+		DynamicUsageNames:   conf.DynamicUsage.Names,
+		DynamicUsageCalls:   conf.DynamicUsage.Calls,
+		EmbeddedTemplates:   conf.EmbeddedTemplates,
+		Downstream:          conf.Downstream,
+		MainPackages:        conf.MainPackages,
+		EnumPolicy:          conf.EnumPolicy,
+		IgnoreFile:          filepath.Join(wd, lib.IgnoreFilename),
+		CodePrefix:          conf.CodePrefix,
+	}
+	if err := lib.Triage(ctx, cfg, os.Stdin); err != nil {
+		fatal(err)
+	}
+}
+
+// serveLSP runs a language server on stdin and stdout publishing the findings in the open files as diagnostics,
+// see lib.ServeLSP.
+func serveLSP(args []string) {
+	fs := flag.NewFlagSet("punused serve-lsp", flag.ContinueOnError)
+	var (
+		ignoreGen = fs.Bool("ignore-generated-refs", false, "references from generated files do not count as usage (reported as EU1006)")
+		sameFile  = fs.Bool("same-file", false, "also report symbols only used in the file declaring them (EU1007)")
+		samePkg   = fs.Bool("same-package", false, "also report symbols only used in the package declaring them (EU1003)")
+		logging   = addLogFlags(fs)
+	)
+	parseFlags(fs, args)
+	logging.setup()
+
+	patterns := filenamePatterns(fs.Args())
+
+	// Runs until the editor exits.
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	wd, _ := os.Getwd()
+	conf, err := lib.LoadConfig(filepath.Join(wd, lib.ConfigFilename))
+	if err != nil {
+		fatal(err)
+	}
+	if fs.NArg() == 0 && len(conf.Files) > 0 {
+		patterns = filenamePatterns(conf.Files)
+	}
+
+	cfg := lib.RunConfig{
+		WorkspaceDir:        wd,
+		FilenamePatterns:    patterns,
+		Out:                 io.Discard,
+		Logger:              slog.Default(),
+		IgnoreGeneratedRefs: *ignoreGen,
+		SameFile:            *sameFile,
+		SamePackage:         *samePkg,
+		Severity:            conf.Severity,
+		EntryPoints:         conf.EntryPoints,
+		TestPackages:        conf.TestPackages,
+		DeadTargets:         conf.DeadTargets,
+		WeakRefs:            conf.WeakRefs,
+		ExcludeDirs:         conf.Exclude,
+		SkipHeaders:         conf.SkipHeaders,
+		Keep:                conf.Keep,
+		Kinds:               conf.Kinds,
+		Receivers:           conf.Receivers,
+		DynamicUsageNames:   conf.DynamicUsage.Names,
+		DynamicUsageCalls:   conf.DynamicUsage.Calls,
+		EmbeddedTemplates:   conf.EmbeddedTemplates,
+		Downstream:          conf.Downstream,
+		MainPackages:        conf.MainPackages,
+		EnumPolicy:          conf.EnumPolicy,
+		IgnoreFile:          filepath.Join(wd, lib.IgnoreFilename),
+		CodePrefix:          conf.CodePrefix,
+	}
+	if err := lib.ServeLSP(ctx, cfg, os.Stdin, os.Stdout); err != nil {
+		fatal(err)
+	}
+}
+
+// suppress adds findings to the ignore file by ID, see lib.Suppress.
+func suppress(args []string) {
+	fs := flag.NewFlagSet("punused suppress", flag.ContinueOnError)
+	var (
+		reason      = fs.String("reason", "", "why the findings are suppressed, written to the ignore file")
+		transitive  = fs.Bool("transitive", false, "for the IDs of findings reported with -transitive")
+		reexports   = fs.Bool("reexports", false, "for the IDs of findings reported with -reexports")
+		ignoreGen   = fs.Bool("ignore-generated-refs", false, "for the IDs of findings reported with -ignore-generated-refs")
+		sameFile    = fs.Bool("same-file", false, "for the IDs of findings reported with -same-file")
+		samePackage = fs.Bool("same-package", false, "for the IDs of findings reported with -same-package")
+		logging     = addLogFlags(fs)
 	)
+	ids := parseInterspersed(fs, args)
+	logging.setup()
+
+	if len(ids) == 0 {
+		fatal(errors.New("usage: punused suppress [-reason text] id..."))
+	}
+
+	ctx, cancel := runContext(2 * time.Minute)
+	defer cancel()
+
+	wd, _ := os.Getwd()
+	conf, err := lib.LoadConfig(filepath.Join(wd, lib.ConfigFilename))
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+	cfg := lib.RunConfig{
+		WorkspaceDir:        wd,
+		FilenamePatterns:    filenamePatterns(conf.Files),
+		Out:                 io.Discard,
+		Logger:              slog.Default(),
+		Transitive:          *transitive,
+		Reexports:           *reexports,
+		IgnoreGeneratedRefs: *ignoreGen,
+		SameFile:            *sameFile,
+		SamePackage:         *samePackage,
+		EntryPoints:         conf.EntryPoints,
+		TestPackages:        conf.TestPackages,
+		DeadTargets:         conf.DeadTargets,
+		WeakRefs:            conf.WeakRefs,
+		ExcludeDirs:         conf.Exclude,
+		SkipHeaders:         conf.SkipHeaders,
+		Keep:                conf.Keep,
+		Kinds:               conf.Kinds,
+		Receivers:           conf.Receivers,
+		DynamicUsageNames:   conf.DynamicUsage.Names,
+		DynamicUsageCalls:   conf.DynamicUsage.Calls,
+		EmbeddedTemplates:   conf.EmbeddedTemplates,
+		Downstream:          conf.Downstream,
+		MainPackages:        conf.MainPackages,
+		EnumPolicy:          conf.EnumPolicy,
+		IgnoreFile:          filepath.Join(wd, lib.IgnoreFilename),
+	}
+	if err := lib.Suppress(ctx, cfg, *reason, ids...); err != nil {
+		fatal(err)
+	}
+}
+
+// exportIndex writes the usage classification of every exported symbol for editor plugins.
+func exportIndex(args []string) {
+	fs := flag.NewFlagSet("punused export-index", flag.ContinueOnError)
+	var (
+		out       = fs.String("o", "", "write the index to this file instead of stdout")
+		ignoreGen = fs.Bool("ignore-generated-refs", false, "references from generated files do not count as usage")
+		logging   = addLogFlags(fs)
+	)
+	parseFlags(fs, args)
+	logging.setup()
+
+	patterns := filenamePatterns(fs.Args())
+
+	ctx, cancel := runContext(2 * time.Minute)
+	defer cancel()
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	wd, _ := os.Getwd()
+	cfg := lib.RunConfig{
+		WorkspaceDir:        wd,
+		FilenamePatterns:    patterns,
+		Out:                 w,
+		Logger:              slog.Default(),
+		IgnoreGeneratedRefs: *ignoreGen,
+	}
+	if err := lib.ExportIndex(ctx, cfg); err != nil {
+		fatal(err)
+	}
+}
+
+func exportInventory(args []string) {
+	fs := flag.NewFlagSet("punused export-inventory", flag.ContinueOnError)
+	var (
+		out       = fs.String("o", "", "write the inventory to this file instead of stdout")
+		release   = fs.String("release", "", "the release the inventory is taken for, e.g. v1.2.0, recorded in it")
+		ignoreGen = fs.Bool("ignore-generated-refs", false, "references from generated files do not count as usage")
+		logging   = addLogFlags(fs)
+	)
+	parseFlags(fs, args)
+	logging.setup()
+
+	patterns := filenamePatterns(fs.Args())
+
+	ctx, cancel := runContext(2 * time.Minute)
+	defer cancel()
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	wd, _ := os.Getwd()
+	cfg := lib.RunConfig{
+		WorkspaceDir:        wd,
+		FilenamePatterns:    patterns,
+		Out:                 w,
+		Logger:              slog.Default(),
+		IgnoreGeneratedRefs: *ignoreGen,
+	}
+	if err := lib.ExportInventory(ctx, cfg, *release); err != nil {
+		fatal(err)
+	}
+}
+
+// issues creates or updates a GitHub issue per package with findings in the JSON reports.
+func issues(args []string) {
+	fs := flag.NewFlagSet("punused issues", flag.ContinueOnError)
+	var (
+		repo    = fs.String("repo", os.Getenv("GITHUB_REPOSITORY"), "the GitHub repository, on the form owner/name")
+		label   = fs.String("label", "punused", "the label used to find and create the issues")
+		apiURL  = fs.String("api-url", "https://api.github.com", "the GitHub API URL")
+		dryRun  = fs.Bool("dry-run", false, "print the issues instead of creating or updating them")
+		logging = addLogFlags(fs)
+	)
+	filenames := parseInterspersed(fs, args)
+	logging.setup()
+
+	if len(filenames) == 0 {
+		fatal(errors.New("no reports"))
+	}
+
+	ctx, cancel := runContext(2 * time.Minute)
+	defer cancel()
+
+	cfg := lib.IssuesConfig{
+		Repo:   *repo,
+		Token:  os.Getenv("GITHUB_TOKEN"),
+		APIURL: *apiURL,
+		Label:  *label,
+		DryRun: *dryRun,
+		Out:    os.Stdout,
+	}
+	if err := lib.FileIssues(ctx, cfg, filenames...); err != nil {
+		fatal(err)
+	}
+}
+
+// parseInterspersed parses args allowing flags after the positional arguments,
+// e.g. "merge a.json b.json -o merged.sarif", and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		parseFlags(fs, args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// doctor verifies that the environment is set up correctly.
+func doctor(args []string) {
+	fs := flag.NewFlagSet("punused doctor", flag.ContinueOnError)
+	logging := addLogFlags(fs)
+	parseFlags(fs, args)
+	logging.setup()
+
+	wd, _ := os.Getwd()
+	if err := lib.Doctor(os.Stdout, wd); err != nil {
+		fatal(err)
+	}
+}
+
+// initConfig writes a starter config file for the workspace, e.g. punused init.
+func initConfig(args []string) {
+	fs := flag.NewFlagSet("punused init", flag.ContinueOnError)
+	var (
+		force   = fs.Bool("force", false, "overwrite the config file if it exists")
+		stdout  = fs.Bool("stdout", false, "print the config file instead of writing it")
+		logging = addLogFlags(fs)
+	)
+	parseFlags(fs, args)
+	logging.setup()
+
+	var out io.Writer
+	if *stdout {
+		out = os.Stdout
+	}
+	wd, _ := os.Getwd()
+	if err := lib.Init(os.Stderr, out, wd, *force); err != nil {
+		fatal(err)
+	}
+}
+
+// explain prints the documentation of a check, e.g. punused explain EU1001.
+func explain(args []string) {
+	fs := flag.NewFlagSet("punused explain", flag.ContinueOnError)
+	logging := addLogFlags(fs)
+	parseFlags(fs, args)
+	logging.setup()
+
+	if fs.NArg() != 1 {
+		fatal(errors.New("usage: punused explain code"))
+	}
+	if err := lib.Explain(os.Stdout, fs.Arg(0)); err != nil {
+		fatal(err)
+	}
+}
+
+// runContext returns a context that is cancelled after timeout or on SIGINT.
+func runContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// changedLines returns the lines added in the diff file, - for stdin, or, if no such file exists,
+// since the git revision in the repository in dir, if set, and the line ranges given.
+func changedLines(dir, diff string, ranges []string) (lib.ChangedLines, error) {
+	var (
+		changed = make(lib.ChangedLines)
+		err     error
+	)
+	switch diff {
+	case "":
+	case "-":
+		changed, err = lib.ParseDiff(os.Stdin)
+	default:
+		var f *os.File
+		if f, err = os.Open(diff); errors.Is(err, fs.ErrNotExist) {
+			changed, err = lib.GitDiff(dir, diff)
+		} else if err == nil {
+			changed, err = lib.ParseDiff(f)
+			f.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ranges {
+		if err := changed.ParseLineRange(s); err != nil {
+			return nil, err
+		}
+	}
+	return changed, nil
+}
+
+// fixFlag is the -fix flag, a boolean flag also accepting interactive, see lib.FixInteractive,
+// and script, see lib.RunConfig.Script.
+type fixFlag struct {
+	enabled, interactive, script bool
+}
+
+func (f *fixFlag) String() string {
+	switch {
+	case f.interactive:
+		return "interactive"
+	case f.script:
+		return "script"
+	}
+	return strconv.FormatBool(f.enabled)
+}
+
+func (f *fixFlag) Set(s string) error {
+	switch s {
+	case "interactive":
+		f.enabled, f.interactive, f.script = false, true, false
+		return nil
+	case "script":
+		f.enabled, f.interactive, f.script = true, false, true
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return errors.New("must be a boolean, interactive or script")
+	}
+	f.enabled, f.interactive, f.script = b, false, false
+	return nil
+}
+
+func (f *fixFlag) IsBoolFlag() bool { return true }
+
+// kindsOr returns the kinds given with -kinds, if any, else those of the config.
+func kindsOr(kinds, conf []string) []string {
+	if len(kinds) > 0 {
+		return kinds
+	}
+	return conf
+}
+
+// stringList is a flag that may be repeated or given a comma separated list.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// repeatedList is a flag that may be repeated, unlike stringList never split, e.g. for regular expressions.
+type repeatedList []string
+
+func (l *repeatedList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *repeatedList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// buildConfigString returns the build configuration, as parsed by lib.ParseBuildConfig, with the given values.
+func buildConfigString(goos, goarch, tags string) string {
+	var fields []string
+	for _, kv := range [][2]string{{"goos", goos}, {"goarch", goarch}, {"tags", tags}} {
+		if kv[1] != "" {
+			fields = append(fields, kv[0]+"="+kv[1])
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// buildConfigList is a flag that may be repeated with a build configuration, see lib.ParseBuildConfig.
+type buildConfigList []lib.BuildConfig
+
+func (l *buildConfigList) String() string {
+	var names []string
+	for _, bc := range *l {
+		names = append(names, bc.Name)
+	}
+	return strings.Join(names, "; ")
+}
+
+func (l *buildConfigList) Set(s string) error {
+	bc, err := lib.ParseBuildConfig(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, bc)
+	return nil
+}
+
+type logOptions struct {
+	format string
+	level  string
+}
+
+func addLogFlags(fs *flag.FlagSet) *logOptions {
+	o := &logOptions{}
+	fs.StringVar(&o.format, "log-format", "text", "log format, one of text or json; logs are always written to stderr")
+	fs.StringVar(&o.level, "log-level", "info", "log level, one of debug, info, warn or error")
+	return o
+}
+
+// setup installs the default logger, writing to stderr so stdout is kept for the findings.
+func (o *logOptions) setup() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.level)); err != nil {
+		fatal(err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch o.format {
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		fatal(fmt.Errorf("unsupported log format %q", o.format))
+	}
+	slog.SetDefault(slog.New(h))
+}
+
+// filenamePatterns returns the filename globs and Go package patterns given as arguments,
+// defaulting to every Go file in the workspace.
+func filenamePatterns(args []string) []string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "!") {
+			return args
+		}
+	}
+	return append([]string{"**/*.go"}, args...)
+}
+
+// stagedPatterns returns the filename patterns matching the staged files, sorted, with the exclusions,
+// prefixed with !, of patterns still applied.
+func stagedPatterns(files map[string]string, patterns []string) []string {
+	var staged []string
+	for filename := range files {
+		staged = append(staged, filename)
+	}
+	sort.Strings(staged)
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			staged = append(staged, p)
+		}
+	}
+	return staged
+}
+
+// isFlagSet reports whether the flag name was given on the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	var set bool
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// parseFlags parses args, exiting with lib.ExitFailure on invalid flags.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(lib.ExitFailure)
+	}
+}
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	stopProfiles()
+	os.Exit(lib.ExitFailure)
+}
+
+// stopProfiles stops the profiling started by startProfiles and writes the profiles,
+// also when exiting early with fatal. It does nothing when called again.
+var stopProfiles = func() {}
+
+// startProfiles starts writing a CPU profile to cpuProfile and an execution trace to traceOut, if set,
+// and sets up stopProfiles to stop them and write a heap profile to memProfile, if set.
+func startProfiles(cpuProfile, memProfile, traceOut string) error {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+		stops = nil
+	}
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if traceOut != "" {
+		f, err := os.Create(traceOut)
+		if err == nil {
+			if err = trace.Start(f); err != nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			stop()
+			return err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	if memProfile != "" {
+		stops = append(stops, func() {
+			if err := writeHeapProfile(memProfile); err != nil {
+				slog.Error("failed to write the heap profile", "error", err)
+			}
+		})
+	}
+	stopProfiles = stop
+	return nil
+}
+
+// writeHeapProfile writes a profile of the memory in use, after a garbage collection, to filename.
+func writeHeapProfile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	err = pprof.WriteHeapProfile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
 	}
+	return err
 }
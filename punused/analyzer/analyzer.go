@@ -0,0 +1,153 @@
+// Package analyzer provides punused as a go/analysis Analyzer, to run it with go vet -vettool,
+// see the punused-vet command, or register it in a linter runner such as golangci-lint.
+//
+// Each package is analyzed with gopls, which must be installed, in the workspace of its module,
+// so the references from the other packages of the workspace are counted. Starting gopls per package
+// is slow compared to the other analyzers; prefer the punused command for large workspaces.
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bep/punused/punused"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports the exported symbols of a package that are unused, or only used in tests, in its workspace.
+// The diagnostics have the rule ID, e.g. EU1002, as category.
+var Analyzer = &analysis.Analyzer{
+	Name:  "punused",
+	Doc:   "report exported symbols unused, or only used in tests, in the workspace",
+	URL:   "https://github.com/bep/punused",
+	Flags: flags(),
+	Run:   run,
+}
+
+var (
+	transitive        bool
+	sameFile          bool
+	samePackage       bool
+	includeUnexported bool
+
+	// reported holds the positions of the findings reported, see run.
+	reported sync.Map
+)
+
+func flags() flag.FlagSet {
+	fs := flag.NewFlagSet("punused", flag.ExitOnError)
+	fs.BoolVar(&transitive, "transitive", false, "also report the symbols only used by unused code (EU1005)")
+	fs.BoolVar(&sameFile, "same-file", false, "also report the symbols only used in their declaring file (EU1007)")
+	fs.BoolVar(&samePackage, "same-package", false, "also report the symbols only used in their declaring package (EU1003)")
+	fs.BoolVar(&includeUnexported, "include-unexported", false, "also report the unexported symbols without references")
+	return *fs
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	// The files by absolute filename; go vet passes them relative to the current directory.
+	files := make(map[string]*token.File)
+	var dir string
+	for _, f := range pass.Files {
+		tf := pass.Fset.File(f.Pos())
+		if tf == nil || strings.HasSuffix(tf.Name(), "_test.go") {
+			continue
+		}
+		filename, err := filepath.Abs(tf.Name())
+		if err != nil {
+			return nil, err
+		}
+		files[filename] = tf
+		dir = filepath.Dir(filename)
+	}
+	if dir == "" {
+		return nil, nil
+	}
+	root, err := workspaceRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := punused.Config{
+		Dir:               root,
+		Transitive:        transitive,
+		SameFile:          sameFile,
+		SamePackage:       samePackage,
+		IncludeUnexported: includeUnexported,
+	}
+	for filename := range files {
+		rel, err := filepath.Rel(root, filename)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Patterns = append(cfg.Patterns, filepath.ToSlash(rel))
+	}
+
+	findings, err := punused.Run(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range findings {
+		filename := filepath.Join(root, filepath.FromSlash(f.Symbol.Filename))
+		tf := files[filename]
+		if tf == nil {
+			continue
+		}
+		// A package is also analyzed with its tests when run standalone.
+		if _, seen := reported.LoadOrStore(fmt.Sprintf("%s:%d:%d", filename, f.Symbol.Line, f.Symbol.Column), true); seen {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      position(tf, f.Symbol.Line, f.Symbol.Column),
+			Category: string(f.Rule),
+			Message:  f.Symbol.Kind + " " + f.Symbol.Name + " " + f.Message + " (" + string(f.Rule) + ")",
+			URL:      "https://github.com/bep/punused#" + strings.ToLower(string(f.Rule)),
+		})
+	}
+	return nil, nil
+}
+
+// position returns the position in tf of line and column, starting at 1, clamped to the file.
+func position(tf *token.File, line, column int) token.Pos {
+	if line < 1 || line > tf.LineCount() {
+		return tf.Pos(0)
+	}
+	pos := tf.LineStart(line)
+	offset := tf.Offset(pos) + column - 1
+	if column < 1 || offset > tf.Size() {
+		return pos
+	}
+	return tf.Pos(offset)
+}
+
+// workspaceRoot returns the workspace dir belongs to: the directory of the go.work file above it, if any,
+// or else the one of its go.mod file.
+func workspaceRoot(dir string) (string, error) {
+	if gowork := os.Getenv("GOWORK"); gowork != "" && gowork != "off" {
+		return filepath.Dir(gowork), nil
+	}
+	var module string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.work")); err == nil && os.Getenv("GOWORK") != "off" {
+			return d, nil
+		}
+		if module == "" {
+			if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+				module = d
+			}
+		}
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+	if module == "" {
+		return "", errors.New("no go.mod found for " + dir)
+	}
+	return module, nil
+}
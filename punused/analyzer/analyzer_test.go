@@ -0,0 +1,47 @@
+package analyzer
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestWorkspaceRoot(t *testing.T) {
+	c := qt.New(t)
+	c.Setenv("GOWORK", "")
+
+	dir := t.TempDir()
+	mod := filepath.Join(dir, "mod")
+	pkg := filepath.Join(mod, "pkg", "sub")
+	c.Assert(os.MkdirAll(pkg, 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(mod, "go.mod"), []byte("module example.com/mod\n"), 0o644), qt.IsNil)
+
+	root, err := workspaceRoot(pkg)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.Equals, mod)
+
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.work"), []byte("go 1.21\n\nuse ./mod\n"), 0o644), qt.IsNil)
+	root, err = workspaceRoot(pkg)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.Equals, dir)
+
+	c.Setenv("GOWORK", "off")
+	root, err = workspaceRoot(pkg)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.Equals, mod)
+}
+
+func TestPosition(t *testing.T) {
+	c := qt.New(t)
+
+	src := "package p\n\nfunc Dead() {}\n"
+	tf := token.NewFileSet().AddFile("p.go", -1, len(src))
+	tf.SetLinesForContent([]byte(src))
+
+	c.Assert(tf.Position(position(tf, 3, 6)).String(), qt.Equals, "p.go:3:6")
+	c.Assert(tf.Position(position(tf, 3, 100)).String(), qt.Equals, "p.go:3:1")
+	c.Assert(tf.Position(position(tf, 10, 1)).String(), qt.Equals, "p.go:1:1")
+}
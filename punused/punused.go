@@ -0,0 +1,254 @@
+// Package punused finds the exported symbols in a Go workspace that are unused, or only used in tests,
+// for embedding in other tools. The punused command is built on the same analysis, using gopls,
+// which must be installed unless Config.Backend is packages.
+package punused
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/bep/punused/internal/lib"
+)
+
+// RuleID identifies a check, e.g. EU1002 for unused symbols, see punused explain.
+type RuleID string
+
+const (
+	RuleTestOnly    RuleID = "EU1001" // Used in test only.
+	RuleUnused      RuleID = "EU1002" // Unused.
+	RuleSamePackage RuleID = "EU1003" // Only used in its declaring package, with Config.SamePackage.
+	RuleMinRefs     RuleID = "EU1004" // Used, but with fewer references than Config.MinRefs.
+	RuleTransitive  RuleID = "EU1005" // Only used by unused code, with Config.Transitive.
+	RuleGenerated   RuleID = "EU1006" // Only used in generated code, with Config.IgnoreGeneratedRefs.
+	RuleSameFile    RuleID = "EU1007" // Only used in its declaring file, with Config.SameFile.
+	RuleDynamic     RuleID = "EU1008" // Unused, but dynamic usage is suspected.
+	RuleTagged      RuleID = "EU1009" // Unused in Go code, but tagged for serialization.
+	RuleDuplicate   RuleID = "EU1010" // Duplicates an exported function in another package.
+	RuleReexport    RuleID = "EU1011" // Only re-exported by unused or deprecated shims, with Config.Reexports.
+	RuleCaseOnly    RuleID = "EU1012" // Constant only used in switch cases or blank assignments, with Config.CaseOnly.
+	RuleStdlib      RuleID = "EU1013" // Duplicates a standard library function.
+	RuleWeak        RuleID = "EU1014" // Only used by weak references, e.g. from examples.
+)
+
+// Config configures Run.
+type Config struct {
+	// Dir is the workspace root, a directory with a go.mod or go.work file, relative to the current directory
+	// or absolute. Required.
+	Dir string
+
+	// Patterns are globs matching the filenames, relative to Dir, to analyze, where patterns prefixed
+	// with ! exclude the filenames matching them, e.g. "**/*.go" and "!**/*_gen.go", or Go package patterns,
+	// e.g. "./internal/...". Defaults to "**/*.go".
+	Patterns []string
+
+	// GOOS, GOARCH and Tags, if set, configure the build the workspace is analyzed with,
+	// e.g. windows, arm64 and integration.
+	GOOS   string
+	GOARCH string
+	Tags   []string
+
+	// Backend is the backend of the analysis, gopls, the default if not set, or packages to analyze the workspace
+	// without gopls, type checking it with golang.org/x/tools/go/packages, see punused -backend.
+	Backend string
+
+	// GoVersion, if set, is the Go language version, e.g. 1.18, to analyze the workspace with instead of
+	// the go directive in its go.mod file, see -go-version.
+	GoVersion string
+
+	// Transitive also reports the symbols only used by unused code (EU1005).
+	Transitive bool
+
+	// Reexports also reports the symbols only re-exported by unused or deprecated shims in other packages (EU1011).
+	Reexports bool
+
+	// CaseOnly also reports the constants only used as switch case expressions or in blank assignments (EU1012).
+	CaseOnly bool
+
+	// MinRefs, if > 1, also reports the exported symbols in use with fewer references than this (EU1004),
+	// e.g. 3 for those with one or two, candidates for inlining or unexporting.
+	MinRefs int
+
+	// IgnoreGeneratedRefs does not count the references from generated files, reporting the symbols
+	// only used in generated code (EU1006).
+	IgnoreGeneratedRefs bool
+
+	// SameFile and SamePackage also report the symbols only used in their declaring file (EU1007)
+	// or package (EU1003).
+	SameFile    bool
+	SamePackage bool
+
+	// IncludeUnexported also reports the unexported functions, types, constants and variables without references.
+	IncludeUnexported bool
+
+	// MainPackages is the policy for the exported symbols in package main: check (the default), skip,
+	// or unexported-rule to only report those without references, see punused -main-packages.
+	MainPackages string
+
+	// Keep are regular expressions matching the names of symbols never reported, e.g. "^Must",
+	// optionally scoped to package directories, e.g. "pkg/api/...=^New".
+	Keep []string
+
+	// Kinds, if set, limits the symbols checked to these kinds, e.g. "func", "method", "type", "const" or "var",
+	// or, prefixed with !, excludes them, e.g. "!const".
+	Kinds []string
+
+	// Logger, if set, gets the progress of the analysis. Nothing is logged by default.
+	Logger *slog.Logger
+
+	// OnFinding, if set, is called with each finding as soon as it's found, e.g. to publish diagnostics while
+	// Run is still running. It's called from the goroutine analyzing the files, which waits for it to return.
+	OnFinding func(Finding)
+}
+
+// Symbol is an exported symbol.
+type Symbol struct {
+	// Name is the name of the symbol, with the receiver type for methods, e.g. (*Server).Handle.
+	Name string
+
+	// Kind is the kind of symbol, e.g. function, method, field, variable, constant, struct or interface.
+	Kind string
+
+	// Filename is the file declaring the symbol, relative to Config.Dir, and Line and Column,
+	// starting at 1, the position of its name.
+	Filename string
+	Line     int
+	Column   int
+
+	// Signature is the type of the symbol, e.g. func(s string) error, if known.
+	Signature string
+}
+
+// Finding is a symbol reported by a check.
+type Finding struct {
+	Rule   RuleID
+	Symbol Symbol
+
+	// Message describes the finding, e.g. "is unused".
+	Message string
+
+	// Severity is one of error, warning or info, and Confidence one of high, medium or low.
+	Severity   string
+	Confidence string
+}
+
+// String returns f as printed by punused, e.g. p/p.go:3:6 function Dead is unused (EU1002).
+func (f Finding) String() string {
+	s := f.Symbol
+	return fmt.Sprintf("%s:%d:%d %s %s %s (%s)", s.Filename, s.Line, s.Column, s.Kind, s.Name, f.Message, f.Rule)
+}
+
+// Run analyzes the workspace in cfg.Dir and returns the findings, ordered by file, leaving out those
+// suppressed in its ignore file, see punused suppress. It never changes any files.
+func Run(ctx context.Context, cfg Config) ([]Finding, error) {
+	rc, err := cfg.runConfig()
+	if err != nil {
+		return nil, err
+	}
+	findings, err := lib.Findings(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Finding, len(findings))
+	for i, f := range findings {
+		result[i] = newFinding(f)
+	}
+	return result, nil
+}
+
+// Findings analyzes the workspace like Run, but yields the findings as they're found, in no particular order,
+// the analysis waiting for each to be consumed. Breaking out of the loop stops the analysis.
+// An error ends the sequence, yielded with a zero Finding:
+//
+//	for f, err := range punused.Findings(ctx, cfg) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(f)
+//	}
+func Findings(ctx context.Context, cfg Config) iter.Seq2[Finding, error] {
+	return func(yield func(Finding, error) bool) {
+		rc, err := cfg.runConfig()
+		if err != nil {
+			yield(Finding{}, err)
+			return
+		}
+		for f, err := range lib.StreamFindings(ctx, rc) {
+			if err != nil {
+				yield(Finding{}, err)
+				return
+			}
+			if !yield(newFinding(f), nil) {
+				return
+			}
+		}
+	}
+}
+
+// runConfig returns the configuration of the analysis of cfg.
+func (cfg Config) runConfig() (lib.RunConfig, error) {
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return lib.RunConfig{}, err
+	}
+	rc := lib.RunConfig{
+		WorkspaceDir:        dir,
+		FilenamePatterns:    cfg.Patterns,
+		Out:                 io.Discard,
+		Logger:              cfg.Logger,
+		Transitive:          cfg.Transitive,
+		Reexports:           cfg.Reexports,
+		CaseOnly:            cfg.CaseOnly,
+		MinRefs:             cfg.MinRefs,
+		IgnoreGeneratedRefs: cfg.IgnoreGeneratedRefs,
+		SameFile:            cfg.SameFile,
+		SamePackage:         cfg.SamePackage,
+		IncludeUnexported:   cfg.IncludeUnexported,
+		MainPackages:        cfg.MainPackages,
+		Keep:                cfg.Keep,
+		Kinds:               cfg.Kinds,
+		GoVersion:           cfg.GoVersion,
+		Backend:             cfg.Backend,
+		IgnoreFile:          filepath.Join(dir, lib.IgnoreFilename),
+	}
+	if len(rc.FilenamePatterns) == 0 {
+		rc.FilenamePatterns = []string{"**/*.go"}
+	}
+	if rc.Logger == nil {
+		rc.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if cfg.OnFinding != nil {
+		rc.OnFinding = func(f lib.Finding) { cfg.OnFinding(newFinding(f)) }
+	}
+	if cfg.GOOS != "" || cfg.GOARCH != "" || len(cfg.Tags) > 0 {
+		var name []string
+		for _, kv := range [][2]string{{"goos", cfg.GOOS}, {"goarch", cfg.GOARCH}, {"tags", strings.Join(cfg.Tags, ",")}} {
+			if kv[1] != "" {
+				name = append(name, kv[0]+"="+kv[1])
+			}
+		}
+		rc.BuildConfigs = []lib.BuildConfig{{Name: strings.Join(name, " "), GOOS: cfg.GOOS, GOARCH: cfg.GOARCH, Tags: cfg.Tags}}
+	}
+	return rc, nil
+}
+
+func newFinding(f lib.Finding) Finding {
+	return Finding{
+		Rule: RuleID(f.Code),
+		Symbol: Symbol{
+			Name:      f.Name,
+			Kind:      f.Kind,
+			Filename:  f.Filename,
+			Line:      f.Line,
+			Column:    f.Column,
+			Signature: f.Signature,
+		},
+		Message:    f.Message(),
+		Severity:   string(f.Severity),
+		Confidence: string(f.Confidence),
+	}
+}
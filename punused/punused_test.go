@@ -0,0 +1,69 @@
+package punused
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRun(t *testing.T) {
+	c := qt.New(t)
+
+	wd, _ := os.Getwd()
+	findings, err := Run(context.Background(), Config{
+		Dir:      filepath.Join(wd, ".."),
+		Patterns: []string{"**/testpackages/**.go"},
+	})
+	c.Assert(err, qt.IsNil)
+
+	byName := make(map[string]Finding)
+	for _, f := range findings {
+		byName[f.Symbol.Name] = f
+	}
+	c.Assert(byName["UnusedFunction"], qt.DeepEquals, Finding{
+		Rule: RuleUnused,
+		Symbol: Symbol{
+			Name:      "UnusedFunction",
+			Kind:      "function",
+			Filename:  "internal/lib/testpackages/firstpackage/code1.go",
+			Line:      19,
+			Column:    6,
+			Signature: "func()",
+		},
+		Message:    "is unused",
+		Severity:   "warning",
+		Confidence: "high",
+	})
+	c.Assert(byName["OnlyUsedInTestConst"].Rule, qt.Equals, RuleTestOnly)
+}
+
+func TestFindingsError(t *testing.T) {
+	c := qt.New(t)
+
+	var errs int
+	for f, err := range Findings(context.Background(), Config{Dir: t.TempDir()}) {
+		c.Assert(err, qt.ErrorMatches, ".*go.mod is missing.*")
+		c.Assert(f, qt.DeepEquals, Finding{})
+		errs++
+	}
+	c.Assert(errs, qt.Equals, 1)
+}
+
+func TestRunMinRefs(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.21\n"), 0o666), qt.IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(dir, "p"), 0o777), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "p", "p.go"), []byte("package p\n\nfunc Helper() {}\n"), 0o666), qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport \"example.com/m/p\"\n\nfunc main() { p.Helper() }\n"), 0o666), qt.IsNil)
+
+	findings, err := Run(context.Background(), Config{Dir: dir, Backend: "packages", MinRefs: 3})
+	c.Assert(err, qt.IsNil)
+	c.Assert(findings, qt.HasLen, 1)
+	c.Assert(findings[0].Rule, qt.Equals, RuleMinRefs)
+	c.Assert(findings[0].String(), qt.Equals, "p/p.go:3:6 function Helper has only 1 reference (EU1004)")
+}
@@ -0,0 +1,169 @@
+// Package punusedtest runs golden tests of the punused analysis, for regression cases of its classification:
+// a workspace, a directory with a go.mod file, usually in testdata, and the findings expected in it in a want.txt file
+// in the same directory, one per line as printed by punused, e.g.
+//
+//	p/p.go:3:6 function Dead is unused (EU1002)
+//
+// Blank lines and lines starting with # are ignored. Run the tests with PUNUSED_UPDATE=1 to write the findings
+// to the want.txt files instead, keeping the comments at the top. As with punused, gopls must be installed.
+package punusedtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bep/punused/punused"
+)
+
+// WantFilename is the name of the file with the expected findings in a workspace.
+const WantFilename = "want.txt"
+
+// UpdateEnv is the environment variable that, if set to 1, writes the findings to the want.txt files.
+const UpdateEnv = "PUNUSED_UPDATE"
+
+// Check analyzes the workspace dir with cfg, with Dir set to dir, and fails t if the findings,
+// sorted by position, differ from those in dir/want.txt.
+func Check(t testing.TB, dir string, cfg punused.Config) {
+	t.Helper()
+	cfg.Dir = dir
+	findings, err := punused.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("punused failed in %s: %s", dir, err)
+	}
+	got := make([]string, len(findings))
+	for i, f := range findings {
+		got[i] = f.String()
+	}
+	sortFindings(got)
+
+	filename := filepath.Join(dir, WantFilename)
+	if os.Getenv(UpdateEnv) == "1" {
+		// The comments describing the case are kept.
+		var content string
+		if b, err := os.ReadFile(filename); err == nil {
+			for _, line := range strings.Split(string(b), "\n") {
+				if !strings.HasPrefix(line, "#") {
+					break
+				}
+				content += line + "\n"
+			}
+		}
+		for _, f := range got {
+			content += f + "\n"
+		}
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseWant(string(b))
+	sortFindings(want)
+
+	missing, unexpected := diff(want, got)
+	for _, f := range missing {
+		t.Errorf("%s: missing finding: %s", dir, f)
+	}
+	for _, f := range unexpected {
+		t.Errorf("%s: unexpected finding: %s", dir, f)
+	}
+}
+
+// CheckAll runs Check on each workspace, i.e. directory with a go.mod file, directly in dir, e.g. testdata,
+// as a subtest named by the directory.
+func CheckAll(t *testing.T, dir string, cfg punused.Config) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for _, e := range entries {
+		workspace := filepath.Join(dir, e.Name())
+		if _, err := os.Stat(filepath.Join(workspace, "go.mod")); !e.IsDir() || err != nil {
+			continue
+		}
+		n++
+		t.Run(e.Name(), func(t *testing.T) {
+			Check(t, workspace, cfg)
+		})
+	}
+	if n == 0 {
+		t.Fatalf("no workspaces in %s", dir)
+	}
+}
+
+func parseWant(s string) []string {
+	var want []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			want = append(want, line)
+		}
+	}
+	return want
+}
+
+// sortFindings sorts the findings by filename, then line and column, as printed.
+func sortFindings(findings []string) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return lessPosition(findings[i], findings[j])
+	})
+}
+
+func lessPosition(a, b string) bool {
+	pa, pb := strings.SplitN(a, " ", 2)[0], strings.SplitN(b, " ", 2)[0]
+	fa, la, ca := splitPosition(pa)
+	fb, lb, cb := splitPosition(pb)
+	if fa != fb {
+		return fa < fb
+	}
+	if la != lb {
+		return la < lb
+	}
+	if ca != cb {
+		return ca < cb
+	}
+	return a < b
+}
+
+// splitPosition splits a position on the form filename:line:column.
+func splitPosition(pos string) (string, int, int) {
+	parts := strings.Split(pos, ":")
+	if len(parts) < 3 {
+		return pos, 0, 0
+	}
+	n := len(parts)
+	line, _ := strconv.Atoi(parts[n-2])
+	column, _ := strconv.Atoi(parts[n-1])
+	return strings.Join(parts[:n-2], ":"), line, column
+}
+
+// diff returns the findings in want but not in got, and those in got but not in want.
+func diff(want, got []string) (missing, unexpected []string) {
+	count := make(map[string]int)
+	for _, f := range got {
+		count[f]++
+	}
+	for _, f := range want {
+		if count[f] > 0 {
+			count[f]--
+		} else {
+			missing = append(missing, f)
+		}
+	}
+	for _, f := range got {
+		if count[f] > 0 {
+			count[f]--
+			unexpected = append(unexpected, f)
+		}
+	}
+	return missing, unexpected
+}
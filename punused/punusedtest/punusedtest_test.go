@@ -0,0 +1,27 @@
+package punusedtest
+
+import (
+	"testing"
+
+	"github.com/bep/punused/punused"
+)
+
+func TestCheckAll(t *testing.T) {
+	CheckAll(t, "testdata", punused.Config{})
+}
+
+func TestCheckAllPackagesBackend(t *testing.T) {
+	CheckAll(t, "testdata", punused.Config{Backend: "packages"})
+}
+
+func TestSortFindings(t *testing.T) {
+	findings := []string{
+		"p/p.go:10:2 field B is unused (EU1002)",
+		"p/a.go:3:6 function A is unused (EU1002)",
+		"p/p.go:9:6 function C is unused (EU1002)",
+	}
+	sortFindings(findings)
+	if findings[0][:6] != "p/a.go" || findings[1][:8] != "p/p.go:9" {
+		t.Fatalf("unexpected order: %q", findings)
+	}
+}
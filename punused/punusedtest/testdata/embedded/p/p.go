@@ -0,0 +1,13 @@
+package p
+
+// Base has its methods promoted to Derived.
+type Base struct{}
+
+func (Base) Hello() string { return "hello" }
+
+func (Base) Unused() {}
+
+type Derived struct {
+	Base
+	Name string
+}
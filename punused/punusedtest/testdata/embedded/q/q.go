@@ -0,0 +1,7 @@
+package q
+
+import "example.com/embedded/p"
+
+var D = p.Derived{Name: "d"}
+
+var _ = D.Hello()
@@ -0,0 +1,10 @@
+package main
+
+import "fmt"
+
+// Version is set with -ldflags -X at build time.
+var Version = "dev"
+
+func init() { fmt.Println("tool") }
+
+func main() { fmt.Println(Version) }
@@ -0,0 +1,17 @@
+package p
+
+// Set is only used instantiated.
+type Set[T comparable] map[T]struct{}
+
+func (s Set[T]) Add(v T) { s[v] = struct{}{} }
+
+func (s Set[T]) Has(v T) bool { _, ok := s[v]; return ok }
+
+// Map is used with inferred type arguments.
+func Map[T, U any](s []T, f func(T) U) []U {
+	var out []U
+	for _, v := range s {
+		out = append(out, f(v))
+	}
+	return out
+}
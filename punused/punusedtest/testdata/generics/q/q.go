@@ -0,0 +1,11 @@
+package q
+
+import "example.com/generics/p"
+
+func Use() []int {
+	s := p.Set[string]{}
+	s.Add("a")
+	return p.Map([]string{"a"}, func(v string) int { return len(v) })
+}
+
+var _ = Use
@@ -0,0 +1,21 @@
+package p
+
+import "fmt"
+
+// NotFoundError implements error, Error is called through the interface only.
+type NotFoundError struct{ Name string }
+
+func (e *NotFoundError) Error() string { return e.Name + " not found" }
+
+// Point implements fmt.Stringer, String is called by fmt only.
+type Point struct{ X, Y int }
+
+func (p Point) String() string { return fmt.Sprintf("(%d, %d)", p.X, p.Y) }
+
+// Norm is unused.
+func (p Point) Norm() int { return p.X*p.X + p.Y*p.Y }
+
+func Lookup(name string) error {
+	fmt.Println(Point{X: 1})
+	return &NotFoundError{Name: name}
+}
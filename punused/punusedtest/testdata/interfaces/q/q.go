@@ -0,0 +1,5 @@
+package q
+
+import "example.com/interfaces/p"
+
+var _ = p.Lookup("x")
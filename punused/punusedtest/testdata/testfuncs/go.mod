@@ -0,0 +1,3 @@
+module example.com/testfuncs
+
+go 1.21
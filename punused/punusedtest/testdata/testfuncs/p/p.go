@@ -0,0 +1,7 @@
+package p
+
+// Helper is only used in tests.
+func Helper() int { return 42 }
+
+// Dead is unused.
+func Dead() {}
@@ -0,0 +1,23 @@
+package p
+
+import "testing"
+
+func TestHelper(t *testing.T) {
+	if Helper() != 42 {
+		t.Fatal("wrong")
+	}
+}
+
+func BenchmarkHelper(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Helper()
+	}
+}
+
+func FuzzHelper(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {})
+}
+
+func ExampleHelper() {
+	Helper()
+}